@@ -0,0 +1,84 @@
+// Command bench runs the server's benchmarks and fails if any of them
+// allocates more than the stored baselines allow
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/piyushgupta53/webterm/internal/bench"
+	"github.com/sirupsen/logrus"
+)
+
+// benchPackage holds the benchmarks, as BenchmarkXxx functions in its tests
+const benchPackage = "github.com/piyushgupta53/webterm/internal/bench"
+
+func main() {
+	baselinePath := flag.String("baseline", "internal/bench/baseline.json", "file holding the baseline results")
+	update := flag.Bool("update", false, "record the results as the new baseline instead of comparing")
+	tolerance := flag.Float64("tolerance", 0.25, "fraction by which a result may exceed its baseline")
+	filter := flag.String("run", ".", "only run benchmarks matching this pattern, as passed to go test -bench")
+	flag.Parse()
+
+	baseline, err := bench.LoadBaseline(*baselinePath)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load baseline")
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", *filter, "-benchmem", "-json", benchPackage)
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	results, err := bench.ParseResults(&output)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to read benchmark results")
+	}
+	if runErr != nil {
+		os.Stdout.Write(output.Bytes())
+		logrus.WithError(runErr).Fatal("Benchmarks failed")
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		result := results[name]
+		change := "new"
+		if expected, exists := baseline[name]; exists && expected.NsPerOp > 0 {
+			change = fmt.Sprintf("%+.1f%%", 100*float64(result.NsPerOp-expected.NsPerOp)/float64(expected.NsPerOp))
+		}
+		fmt.Printf("%-28s %12d ns/op %8d allocs/op %10d B/op  %s\n",
+			name, result.NsPerOp, result.AllocsPerOp, result.BytesPerOp, change)
+	}
+
+	if *update {
+		for name, result := range results {
+			baseline[name] = result
+		}
+		if err := baseline.Save(*baselinePath); err != nil {
+			logrus.WithError(err).Fatal("Failed to save baseline")
+		}
+		fmt.Printf("Baseline written to %s\n", *baselinePath)
+		return
+	}
+
+	regressions := bench.Compare(baseline, results, *tolerance)
+	if len(regressions) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d regression(s) beyond %.0f%%:\n", len(regressions), *tolerance*100)
+	for _, regression := range regressions {
+		fmt.Printf("  %s\n", regression)
+	}
+	os.Exit(1)
+}
@@ -2,14 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/piyushgupta53/webterm/internal/accounting"
+	"github.com/piyushgupta53/webterm/internal/alerting"
 	"github.com/piyushgupta53/webterm/internal/api"
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/piyushgupta53/webterm/internal/auth"
 	"github.com/piyushgupta53/webterm/internal/config"
+	"github.com/piyushgupta53/webterm/internal/diskwatch"
+	"github.com/piyushgupta53/webterm/internal/limits"
+	"github.com/piyushgupta53/webterm/internal/maintenance"
+	"github.com/piyushgupta53/webterm/internal/secrets"
+	"github.com/piyushgupta53/webterm/internal/snippets"
 	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/tracing"
+	"github.com/piyushgupta53/webterm/internal/transcript"
+	"github.com/piyushgupta53/webterm/internal/types"
 	"github.com/piyushgupta53/webterm/internal/websocket"
 	"github.com/sirupsen/logrus"
 )
@@ -19,6 +32,12 @@ const (
 	Version = "1.0.0"
 )
 
+// diskCheckInterval is how often free space for session output is checked
+const diskCheckInterval = 10 * time.Second
+
+// alertEvaluationInterval is how often alert rules are evaluated
+const alertEvaluationInterval = 15 * time.Second
+
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
@@ -26,6 +45,10 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to load configuration")
 	}
 
+	// The tokens are kept in cfg; drop them from the environment so that
+	// nothing started later inherits them
+	os.Unsetenv("WEBTERM_AUTH_TOKENS")
+
 	// Setup logging
 	if err := cfg.SetupLogging(); err != nil {
 		logrus.WithError(err).Fatal("Failed to setup logging")
@@ -34,25 +57,241 @@ func main() {
 	logrus.WithFields(logrus.Fields{
 		"app":     AppName,
 		"version": Version,
-		"config":  cfg,
+		"config":  cfg.Redacted(),
 	}).Info("Starting application")
 
+	// Export traces of requests, WebSocket messages and sessions
+	var shutdownTracing func(context.Context) error
+	if cfg.OTLPEndpoint != "" {
+		shutdownTracing, err = tracing.Setup(context.Background(), cfg.OTLPEndpoint, cfg.TraceSampleRatio, Version)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to set up tracing")
+		}
+		logrus.WithFields(logrus.Fields{
+			"otlp_endpoint": cfg.OTLPEndpoint,
+			"sample_ratio":  cfg.TraceSampleRatio,
+		}).Info("Tracing enabled")
+	}
+
+	// Refuse pipes directories other local users could tamper with
+	pipesDir, err := terminal.PreparePipesDir(cfg.InstancePipesDir(), cfg.PipesDirAllowInsecure, cfg.PipesDirUnique)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to prepare pipes directory")
+	}
+	if cfg.PipesDirUnique {
+		defer os.RemoveAll(pipesDir)
+	}
+
+	// Keep other instances out of our pipes directory while we run
+	pipesLock, err := terminal.LockPipesDir(pipesDir)
+	if err != nil {
+		if errors.Is(err, terminal.ErrPipesDirLocked) {
+			logrus.WithError(err).Fatal("Another instance is running; give each instance its own WEBTERM_INSTANCE_ID")
+		}
+		logrus.WithError(err).Fatal("Failed to lock pipes directory")
+	}
+	defer pipesLock.Close()
+
 	// Create session manager
-	sessionManager := terminal.NewManager(cfg.PipesDir)
+	sessionManager := terminal.NewManager(pipesDir)
 	defer func() {
 		if err := sessionManager.Shutdown(); err != nil {
 			logrus.WithError(err).Error("Failed to shutdown session manager")
 		}
 	}()
 
+	// Restrict serial sessions to the configured devices
+	if len(cfg.SerialDevices) > 0 {
+		sessionManager.SetSerialDevices(cfg.SerialDevices)
+	}
+
+	// Restrict the shells and commands clients may ask for, and where they
+	// may start
+	sessionManager.SetLaunchPolicy(terminal.LaunchPolicy{
+		Shells:          cfg.ShellAllowlist,
+		Commands:        cfg.CommandAllowlist,
+		DisableCommands: cfg.DisableCommands,
+		WorkingDirRoots: cfg.WorkingDirRoots,
+	})
+
+	// Run sessions as an unprivileged account instead of the server's user
+	if err := sessionManager.SetRunAsPolicy(terminal.RunAsPolicy{
+		User:    cfg.RunAsUser,
+		Group:   cfg.RunAsGroup,
+		AsOwner: cfg.RunAsOwner,
+	}); err != nil {
+		logrus.WithError(err).Fatal("Invalid WEBTERM_RUN_AS_USER or WEBTERM_RUN_AS_GROUP")
+	}
+
+	// Restrict the environment variables clients may set
+	envPolicy := terminal.DefaultEnvPolicy()
+	envPolicy.Allowed = cfg.EnvAllowlist
+	if len(cfg.EnvBlocklist) > 0 {
+		envPolicy.Blocked = cfg.EnvBlocklist
+	}
+	envPolicy.MaxSize = cfg.EnvMaxBytes
+	envPolicy.DropBlocked = cfg.EnvDropBlocked
+	sessionManager.SetEnvPolicy(envPolicy)
+
+	// Make configured profiles available to session creation
+	if len(cfg.Profiles) > 0 {
+		sessionManager.SetProfiles(cfg.Profiles)
+		sessionManager.SetDefaultProfile(cfg.DefaultProfile)
+		sessionManager.SetHomeProfile(cfg.HomeProfile)
+	}
+
+	// Only allowlisted devices may be passed through to containers
+	devicePolicy := terminal.DevicePolicy{
+		AllowedDevices: cfg.ContainerDevices,
+		AllowGPUs:      cfg.ContainerAllowGPUs,
+	}
+	sessionManager.SetDevicePolicy(devicePolicy)
+
+	// Keep warm containers ready for container profiles
+	if cfg.ContainerPoolSize > 0 {
+		containerPool := terminal.NewContainerPool(cfg.Profiles, devicePolicy, cfg.ContainerPoolSize, cfg.ContainerPoolMaxIdle)
+		containerPool.Start()
+		defer containerPool.Stop()
+		sessionManager.SetContainerPool(containerPool)
+	}
+
+	// Track usage per user and tenant
+	accountant, err := accounting.NewAccountant(cfg.UsageFile, accounting.Quotas{
+		UserMonthlyHours:   cfg.UserMonthlyQuotaHours,
+		TenantMonthlyHours: cfg.TenantMonthlyQuotaHours,
+	})
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create usage accountant")
+	}
+	sessionManager.SetUsageRecorder(accountant)
+
+	// Issue short-lived credentials requested by profiles
+	var vault *secrets.VaultProvider
+	if cfg.VaultAddress != "" {
+		vault, err = secrets.NewVaultProvider(cfg.VaultAddress, cfg.VaultTokenFile, cfg.VaultNamespace)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create Vault secrets provider")
+		}
+		sessionManager.SetSecretsProvider(vault)
+	}
+
+	// Encrypt session output at rest, with a key that may itself be
+	// encrypted by Vault's transit engine
+	var sealer *transcript.Sealer
+	if cfg.TranscriptKeyFile != "" {
+		var unwrap func(ciphertext string) ([]byte, error)
+		if cfg.TranscriptVaultKey != "" {
+			unwrap = func(ciphertext string) ([]byte, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				return vault.Decrypt(ctx, cfg.TranscriptVaultKey, ciphertext)
+			}
+		}
+
+		sealer, err = transcript.LoadSealer(cfg.TranscriptKeyFile, unwrap)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to load transcript key")
+		}
+		sessionManager.SetSealer(sealer)
+		logrus.Info("Session output is encrypted at rest")
+	}
+
+	// Hold privileged sessions until an admin approves them
+	sessionManager.SetApprovalRequired(cfg.ApprovalRequired)
+	sessionManager.SetMaxOutputRate(cfg.MaxOutputRate)
+	sessionManager.SetOutputBuffering(cfg.OutputBufferSize, cfg.OutputFlushLatency)
+	sessionManager.SetRetentionPolicy(terminal.RetentionPolicy{
+		Terminated: cfg.TerminatedRetention,
+		Exited:     cfg.ExitedRetention,
+	})
+
+	// Cap sessions overall and per user and client IP
+	sessionLimits := limits.DefaultResourceLimits()
+	sessionLimits.MaxSessions = cfg.MaxSessions
+	sessionLimits.MaxSessionsPerUser = cfg.MaxSessionsPerUser
+	sessionLimits.MaxSessionsPerIP = cfg.MaxSessionsPerIP
+	sessionManager.SetSessionLimiter(limits.NewResourceMonitor(sessionLimits))
+
+	// Show GUI applications of sessions that ask for a display
+	if cfg.DisplayEnabled {
+		displayServer, err := terminal.FindDisplayServer()
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to enable session displays")
+		}
+		sessionManager.SetDisplayServer(displayServer)
+		logrus.WithField("server", displayServer).Info("Session displays enabled")
+	}
+
+	// Emulate the terminals of sessions for screen dumps and updates
+	sessionManager.SetTerminalState(cfg.TerminalState)
+
 	// Create WebSocket hub
 	wsHub := websocket.NewHub(sessionManager)
 
-	// Set up status callback to broadcast session status updates
-	sessionManager.SetStatusCallback(func(sessionID string, status string) {
-		wsHub.BroadcastSessionStatus(sessionID, status)
+	// Lock idle sessions until their user re-authenticates
+	wsHub.SetIdleLockTimeout(cfg.IdleLockTimeout)
+	wsHub.SetMOTD(cfg.MOTD)
+	wsHub.SetReconnectAdvice(websocket.ReconnectAdvice{RetryAfter: cfg.ReconnectAfter, URL: cfg.ReconnectURL})
+	wsHub.SetScrollback(cfg.ScrollbackKB << 10)
+	wsHub.SetSealer(sealer)
+
+	// Keep users' snippets for the command palette
+	snippetStore, err := snippets.NewStore(cfg.SnippetsFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create snippet store")
+	}
+	wsHub.SetSnippetStore(snippetStore)
+
+	// Record every write of terminal input for compliance, apart from the log
+	if cfg.InputAuditFile != "" {
+		inputAudit, err := audit.NewInputLog(cfg.InputAuditFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to open input audit file")
+		}
+		defer inputAudit.Close()
+		wsHub.SetInputAudit(inputAudit)
+	}
+
+	// Evaluate alert rules against metrics and the rates of events
+	var alerts *alerting.Evaluator
+	if cfg.AlertRulesFile != "" {
+		rules, err := alerting.LoadRules(cfg.AlertRulesFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid WEBTERM_ALERT_RULES_FILE")
+		}
+		alerts = alerting.NewEvaluator(rules)
+	}
+
+	// Set up status callback to broadcast session status updates, and count
+	// sessions failing towards alerts
+	sessionManager.SetStatusCallback(func(sessionID, status string, exitCode *int) {
+		wsHub.BroadcastSessionStatus(sessionID, status, exitCode)
+		if status == string(types.SessionStatusError) {
+			alerts.RecordEvent("session.error")
+		}
+	})
+
+	// Relay output to clients as sessions write it
+	sessionManager.SetOutputCallback(wsHub.HandleOutput)
+
+	// Disconnect clients of sessions being terminated before their files go
+	sessionManager.SetDetachCallback(wsHub.DetachSession)
+
+	// Relay the output of panes as they open and close
+	sessionManager.SetPaneCallback(wsHub.HandlePaneStatus)
+
+	// Show session warnings, such as an approaching time limit, as banners
+	sessionManager.SetWarningCallback(func(sessionID, message, level string) {
+		wsHub.SendBanner([]string{sessionID}, message, level)
 	})
 
+	// Enforce the roles of users on session operations
+	roles, err := api.NewRoles(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to configure roles")
+	}
+	wsHub.SetRoles(roles)
+
 	// Start WebSocket hub in goroutine
 	go wsHub.Run()
 
@@ -61,11 +300,61 @@ func main() {
 		wsHub.Stop()
 	}()
 
+	// Announce scheduled maintenance to every client and refuse new sessions
+	// shortly before it starts
+	maintenanceScheduler := maintenance.NewScheduler(func(text, level string) {
+		wsHub.SendBanner(nil, text, level)
+	})
+	admission := terminal.AdmissionCheckers{maintenanceScheduler}
+
+	// Refuse new sessions and reclaim space when the disk holding session
+	// output runs low
+	var diskWatchdog *diskwatch.Watchdog
+	if cfg.DiskMinFreeMB > 0 {
+		diskWatchdog = diskwatch.NewWatchdog(pipesDir, uint64(cfg.DiskMinFreeMB)<<20)
+		diskWatchdog.SetSealer(sealer)
+		diskWatchdog.Start(diskCheckInterval)
+		defer diskWatchdog.Stop()
+		admission = append(admission, diskWatchdog)
+	}
+	sessionManager.SetAdmissionChecker(admission)
+
+	if alerts != nil {
+		alerts.AddSource(alerting.SessionMetrics(sessionManager))
+		alerts.AddSource(alerting.RuntimeMetrics())
+		if diskWatchdog != nil {
+			alerts.AddSource(alerting.DiskMetrics(diskWatchdog))
+		}
+		alerts.Start(alertEvaluationInterval)
+		defer alerts.Stop()
+	}
+
 	// Create HTTP server
-	server := api.NewServer(cfg)
+	server, err := api.NewServer(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create HTTP server")
+	}
+
+	// Record security events
+	auditLogger, err := audit.NewLogger(cfg.AuditFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to open audit file")
+	}
+	defer auditLogger.Close()
+	auditLogger.SetObserver(func(event audit.Event) {
+		alerts.RecordEvent(event.Type)
+	})
+
+	// Throttle repeated authentication failures
+	server.Auth().SetLimiter(auth.NewLimiter(auth.LockoutPolicy{
+		MaxFailures:     cfg.AuthMaxFailures,
+		LockoutDuration: cfg.AuthLockoutDuration,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+	}, auditLogger))
 
 	// Setup routes with session manager and WebSocket hub
-	api.SetupRoutes(server, cfg, sessionManager, wsHub)
+	api.SetupRoutes(server, cfg, roles, sessionManager, wsHub, accountant, auditLogger, maintenanceScheduler, snippetStore, diskWatchdog)
 
 	// Start server in a goroutine
 	serverErrors := make(chan error, 1)
@@ -73,6 +362,16 @@ func main() {
 		serverErrors <- server.Start()
 	}()
 
+	// Step through log levels on SIGUSR2, to debug without restarting
+	logLevelSignals := make(chan os.Signal, 1)
+	signal.Notify(logLevelSignals, syscall.SIGUSR2)
+	go func() {
+		for range logLevelSignals {
+			level := config.CycleLogLevel()
+			logrus.WithField("log_level", level.String()).Warn("Log level changed")
+		}
+	}()
+
 	// Setup graceful shutdown
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
@@ -84,27 +383,37 @@ func main() {
 	case sig := <-shutdown:
 		logrus.WithField("signal", sig).Info("Shutdown signal received")
 
-		// Stop WebSocket hub first
-		wsHub.Stop()
+	case <-maintenanceScheduler.Done():
+		logrus.Info("Shutting down for scheduled maintenance")
+	}
 
-		// Shutdown session manager
-		if err := sessionManager.Shutdown(); err != nil {
-			logrus.WithError(err).Error("Failed to shutdown session manager")
-		}
+	// Stop WebSocket hub first
+	wsHub.Stop()
 
-		// Give outstanding requests a deadline for completion
-		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-		defer cancel()
+	// Shutdown session manager
+	if err := sessionManager.Shutdown(); err != nil {
+		logrus.WithError(err).Error("Failed to shutdown session manager")
+	}
 
-		// Attempt graceful shutdown
-		if err := server.Shutdown(ctx); err != nil {
-			logrus.WithError(err).Error("Failed to shutdown server gracefully")
+	// Give outstanding requests a deadline for completion
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
 
-			if err := server.Shutdown(context.Background()); err != nil {
-				logrus.WithError(err).Fatal("Failed to force shutdown server")
-			}
+	// Attempt graceful shutdown
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Error("Failed to shutdown server gracefully")
+
+		if err := server.Shutdown(context.Background()); err != nil {
+			logrus.WithError(err).Fatal("Failed to force shutdown server")
 		}
+	}
 
-		logrus.Info("Server shutdown complete")
+	// Export the spans still buffered
+	if shutdownTracing != nil {
+		if err := shutdownTracing(ctx); err != nil {
+			logrus.WithError(err).Error("Failed to flush traces")
+		}
 	}
+
+	logrus.Info("Server shutdown complete")
 }
@@ -0,0 +1,333 @@
+package accounting
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrQuotaExceeded is returned when a user or tenant has used up its monthly quota
+var ErrQuotaExceeded = errors.New("usage quota exceeded")
+
+// UsageRecord holds the resources consumed by a single session
+type UsageRecord struct {
+	SessionID  string    `json:"session_id"`
+	User       string    `json:"user"`
+	Tenant     string    `json:"tenant"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	BytesIn    int64     `json:"bytes_in"`
+	BytesOut   int64     `json:"bytes_out"`
+	CPUSeconds float64   `json:"cpu_seconds"`
+}
+
+// UsageSummary aggregates usage for one group over one period
+type UsageSummary struct {
+	Key          string    `json:"key"`
+	PeriodStart  time.Time `json:"period_start"`
+	Sessions     int       `json:"sessions"`
+	SessionHours float64   `json:"session_hours"`
+	BytesIn      int64     `json:"bytes_in"`
+	BytesOut     int64     `json:"bytes_out"`
+	CPUSeconds   float64   `json:"cpu_seconds"`
+}
+
+// Quotas defines monthly session-hour limits; zero disables a limit
+type Quotas struct {
+	UserMonthlyHours   float64
+	TenantMonthlyHours float64
+}
+
+// Accountant tracks resource usage per user and tenant
+type Accountant struct {
+	mutex     sync.RWMutex
+	live      map[string]*UsageRecord // Running sessions by ID
+	completed []*UsageRecord
+	quotas    Quotas
+	usageFile string // Optional append-only JSON lines file
+}
+
+// NewAccountant creates an accountant, loading previous records from usageFile if set
+func NewAccountant(usageFile string, quotas Quotas) (*Accountant, error) {
+	a := &Accountant{
+		live:      make(map[string]*UsageRecord),
+		quotas:    quotas,
+		usageFile: usageFile,
+	}
+
+	if usageFile != "" {
+		if err := a.load(); err != nil {
+			return nil, fmt.Errorf("failed to load usage records: %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+// SessionStarted begins tracking a session
+func (a *Accountant) SessionStarted(session *types.Session) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.live[session.ID] = &UsageRecord{
+		SessionID: session.ID,
+		User:      session.Owner,
+		Tenant:    session.Tenant,
+		StartedAt: time.Now(), // Sessions held for approval start once spawned
+	}
+}
+
+// SessionEnded finalizes a session's usage. Repeated calls for the same session are ignored.
+func (a *Accountant) SessionEnded(sessionID string, bytesIn, bytesOut int64, cpuSeconds float64) {
+	a.mutex.Lock()
+	record, exists := a.live[sessionID]
+	if !exists {
+		a.mutex.Unlock()
+		return
+	}
+
+	delete(a.live, sessionID)
+	record.EndedAt = time.Now()
+	record.BytesIn = bytesIn
+	record.BytesOut = bytesOut
+	record.CPUSeconds = cpuSeconds
+	a.completed = append(a.completed, record)
+	a.mutex.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"session_id":  record.SessionID,
+		"user":        record.User,
+		"tenant":      record.Tenant,
+		"duration":    record.EndedAt.Sub(record.StartedAt).String(),
+		"bytes_in":    record.BytesIn,
+		"bytes_out":   record.BytesOut,
+		"cpu_seconds": record.CPUSeconds,
+	}).Info("Session usage recorded")
+
+	if a.usageFile != "" {
+		if err := a.append(record); err != nil {
+			logrus.WithError(err).Error("Failed to persist usage record")
+		}
+	}
+}
+
+// CheckQuota returns ErrQuotaExceeded if the user or tenant has no session-hours left this month
+func (a *Accountant) CheckQuota(user, tenant string) error {
+	if a.quotas.UserMonthlyHours <= 0 && a.quotas.TenantMonthlyHours <= 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var userHours, tenantHours float64
+	for _, record := range a.snapshot(now) {
+		hours := overlap(record, monthStart, now).Hours()
+		if record.User == user {
+			userHours += hours
+		}
+		if record.Tenant == tenant {
+			tenantHours += hours
+		}
+	}
+
+	if a.quotas.UserMonthlyHours > 0 && userHours >= a.quotas.UserMonthlyHours {
+		return fmt.Errorf("%w: user %s used %.1f of %.1f session-hours this month", ErrQuotaExceeded, user, userHours, a.quotas.UserMonthlyHours)
+	}
+	if a.quotas.TenantMonthlyHours > 0 && tenantHours >= a.quotas.TenantMonthlyHours {
+		return fmt.Errorf("%w: tenant %s used %.1f of %.1f session-hours this month", ErrQuotaExceeded, tenant, tenantHours, a.quotas.TenantMonthlyHours)
+	}
+
+	return nil
+}
+
+// Report aggregates usage between from and to, grouped by "user" or "tenant"
+// and bucketed by "hour", "day" or "month". Usage of sessions spanning several
+// buckets is split proportionally to the time spent in each.
+func (a *Accountant) Report(from, to time.Time, groupBy, interval string) ([]UsageSummary, error) {
+	if groupBy != "user" && groupBy != "tenant" {
+		return nil, fmt.Errorf("invalid group_by: %s", groupBy)
+	}
+	if interval != "hour" && interval != "day" && interval != "month" {
+		return nil, fmt.Errorf("invalid interval: %s", interval)
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("invalid time range: %s to %s", from, to)
+	}
+
+	type bucketKey struct {
+		key   string
+		start time.Time
+	}
+	buckets := make(map[bucketKey]*UsageSummary)
+
+	for _, record := range a.snapshot(time.Now()) {
+		key := record.User
+		if groupBy == "tenant" {
+			key = record.Tenant
+		}
+
+		duration := record.EndedAt.Sub(record.StartedAt)
+		for start := truncate(maxTime(record.StartedAt, from), interval); start.Before(minTime(record.EndedAt, to)); start = advance(start, interval) {
+			end := advance(start, interval)
+			spent := overlap(record, maxTime(start, from), minTime(end, to))
+			if spent <= 0 {
+				continue
+			}
+
+			// Share of the session's counters attributed to this bucket
+			share := 1.0
+			if duration > 0 {
+				share = float64(spent) / float64(duration)
+			}
+
+			bk := bucketKey{key: key, start: start}
+			summary, exists := buckets[bk]
+			if !exists {
+				summary = &UsageSummary{Key: key, PeriodStart: start}
+				buckets[bk] = summary
+			}
+
+			summary.Sessions++
+			summary.SessionHours += spent.Hours()
+			summary.BytesIn += int64(float64(record.BytesIn) * share)
+			summary.BytesOut += int64(float64(record.BytesOut) * share)
+			summary.CPUSeconds += record.CPUSeconds * share
+		}
+	}
+
+	summaries := make([]UsageSummary, 0, len(buckets))
+	for _, summary := range buckets {
+		summaries = append(summaries, *summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].PeriodStart.Equal(summaries[j].PeriodStart) {
+			return summaries[i].PeriodStart.Before(summaries[j].PeriodStart)
+		}
+		return summaries[i].Key < summaries[j].Key
+	})
+
+	return summaries, nil
+}
+
+// snapshot returns copies of all records, with live sessions ending at now
+func (a *Accountant) snapshot(now time.Time) []UsageRecord {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	records := make([]UsageRecord, 0, len(a.completed)+len(a.live))
+	for _, record := range a.completed {
+		records = append(records, *record)
+	}
+	for _, record := range a.live {
+		liveRecord := *record
+		liveRecord.EndedAt = now
+		records = append(records, liveRecord)
+	}
+
+	return records
+}
+
+// load reads persisted usage records
+func (a *Accountant) load() error {
+	file, err := os.Open(a.usageFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			logrus.WithError(err).Warn("Skipping malformed usage record")
+			continue
+		}
+		a.completed = append(a.completed, &record)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"usage_file": a.usageFile,
+		"records":    len(a.completed),
+	}).Info("Usage records loaded")
+
+	return scanner.Err()
+}
+
+// append persists a completed record
+func (a *Accountant) append(record *UsageRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(a.usageFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// overlap returns how long a record's session ran within [from, to)
+func overlap(record UsageRecord, from, to time.Time) time.Duration {
+	start := maxTime(record.StartedAt, from)
+	end := minTime(record.EndedAt, to)
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// truncate returns the start of the interval containing t
+func truncate(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// advance returns the start of the interval following start
+func advance(start time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return start.Add(time.Hour)
+	case "day":
+		return start.AddDate(0, 0, 1)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,278 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// EventWindow is how far back event rates count events
+	EventWindow = time.Minute
+
+	// webhookTimeout bounds each attempt to deliver an alert
+	webhookTimeout = 10 * time.Second
+	// webhookAttempts is how often delivery is tried before giving up
+	webhookAttempts = 3
+)
+
+// Alert states
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Notification is what webhooks receive as an alert fires or resolves
+type Notification struct {
+	Rule      string    `json:"rule"`
+	State     string    `json:"state"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"` // When the condition started to hold
+	Time      time.Time `json:"time"`
+}
+
+// webhookClient delivers alert notifications
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// ruleState tracks whether a rule's condition holds
+type ruleState struct {
+	breachedSince time.Time // Zero while the condition does not hold
+	firing        bool
+}
+
+// Evaluator checks alert rules against metrics from its sources and the
+// rates of recorded events. Events of type t are counted over the last
+// EventWindow as the metric "events.t".
+type Evaluator struct {
+	rules   []*Rule
+	sources []Source
+	states  map[string]*ruleState
+
+	eventsMutex sync.Mutex
+	events      map[string][]time.Time
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewEvaluator creates an evaluator of rules
+func NewEvaluator(rules []*Rule) *Evaluator {
+	states := make(map[string]*ruleState, len(rules))
+	for _, rule := range rules {
+		states[rule.Name] = &ruleState{}
+	}
+
+	return &Evaluator{
+		rules:    rules,
+		states:   states,
+		events:   make(map[string][]time.Time),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// AddSource adds metrics to evaluate rules against. It must be called
+// before Start.
+func (e *Evaluator) AddSource(source Source) {
+	e.sources = append(e.sources, source)
+}
+
+// RecordEvent counts an event towards the rate of its type. A nil
+// evaluator ignores events.
+func (e *Evaluator) RecordEvent(eventType string) {
+	if e == nil {
+		return
+	}
+
+	e.eventsMutex.Lock()
+	defer e.eventsMutex.Unlock()
+	e.events[eventType] = append(e.events[eventType], time.Now())
+}
+
+// Start evaluates the rules every interval until Stop is called
+func (e *Evaluator) Start(interval time.Duration) {
+	logrus.WithField("rules", len(e.rules)).Info("Alert rules enabled")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				e.evaluate(time.Now())
+			case <-e.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops evaluating rules
+func (e *Evaluator) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopChan)
+	})
+}
+
+// evaluate checks every rule once
+func (e *Evaluator) evaluate(now time.Time) {
+	metrics := e.eventRates(now)
+	for _, source := range e.sources {
+		for name, value := range source() {
+			metrics[name] = value
+		}
+	}
+
+	for _, rule := range e.rules {
+		state := e.states[rule.Name]
+
+		// Event types that did not occur have a rate of zero
+		value, exists := metrics[rule.Metric]
+		if !exists && !isEventMetric(rule.Metric) {
+			continue
+		}
+
+		if !rule.breached(value) {
+			if state.firing {
+				e.notify(rule, StateResolved, value, state.breachedSince, now)
+			}
+			*state = ruleState{}
+			continue
+		}
+
+		if state.breachedSince.IsZero() {
+			state.breachedSince = now
+		}
+		if !state.firing && now.Sub(state.breachedSince) >= rule.holdFor {
+			state.firing = true
+			e.notify(rule, StateFiring, value, state.breachedSince, now)
+		}
+	}
+}
+
+// eventRates counts the events of each type within the window, forgetting
+// older ones
+func (e *Evaluator) eventRates(now time.Time) map[string]float64 {
+	e.eventsMutex.Lock()
+	defer e.eventsMutex.Unlock()
+
+	cutoff := now.Add(-EventWindow)
+	rates := make(map[string]float64, len(e.events))
+	for eventType, times := range e.events {
+		kept := 0
+		for kept < len(times) && times[kept].Before(cutoff) {
+			kept++
+		}
+		if kept == len(times) {
+			delete(e.events, eventType)
+			continue
+		}
+		e.events[eventType] = times[kept:]
+		rates["events."+eventType] = float64(len(times) - kept)
+	}
+	return rates
+}
+
+// isEventMetric reports whether a metric is the rate of an event type
+func isEventMetric(metric string) bool {
+	return strings.HasPrefix(metric, "events.")
+}
+
+// notify logs an alert changing state and posts it to the rule's webhook
+func (e *Evaluator) notify(rule *Rule, state string, value float64, since, now time.Time) {
+	notification := &Notification{
+		Rule:      rule.Name,
+		State:     state,
+		Metric:    rule.Metric,
+		Value:     value,
+		Threshold: rule.threshold(),
+		Since:     since,
+		Time:      now,
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"alert":     rule.Name,
+		"metric":    rule.Metric,
+		"value":     value,
+		"threshold": notification.Threshold,
+	})
+	if state == StateFiring {
+		logger.Warn("Alert firing")
+	} else {
+		logger.Info("Alert resolved")
+	}
+
+	if rule.WebhookURL != "" {
+		go deliverWebhook(rule.WebhookURL, notification)
+	}
+}
+
+// deliverWebhook posts a notification, retrying failed attempts with backoff
+func deliverWebhook(webhookURL string, notification *Notification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		logrus.WithError(err).WithField("alert", notification.Rule).Error("Failed to encode alert notification")
+		return
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"alert": notification.Rule,
+		"host":  hostOf(webhookURL),
+	})
+
+	backoff := time.Second
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		err = postWebhook(webhookURL, body)
+		if err == nil {
+			logger.Debug("Alert notification delivered")
+			return
+		}
+
+		logger.WithError(err).WithField("attempt", attempt).Warn("Alert notification failed")
+		if attempt < webhookAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	logger.Error("Giving up on alert notification")
+}
+
+// postWebhook makes one delivery attempt
+func postWebhook(webhookURL string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "webterm")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// hostOf returns the host of a URL for logging, leaving out paths and
+// query strings that may carry tokens
+func hostOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package alerting
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/diskwatch"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// Source returns current values of metrics by name
+type Source func() map[string]float64
+
+// SessionMetrics reports the sessions of a manager: how many are active and
+// in error, and how long the oldest session still starting has been at it
+func SessionMetrics(manager *terminal.Manager) Source {
+	return func() map[string]float64 {
+		var active, failed, startingSeconds float64
+		for _, session := range manager.ListSessions() {
+			if session.IsActive() {
+				active++
+			}
+			switch session.Status {
+			case types.SessionStatusError:
+				failed++
+			case types.SessionStatusStarting:
+				startingSeconds = max(startingSeconds, time.Since(session.CreatedAt).Seconds())
+			}
+		}
+
+		return map[string]float64{
+			"sessions_active":           active,
+			"sessions_error":            failed,
+			"sessions_starting_seconds": startingSeconds,
+		}
+	}
+}
+
+// RuntimeMetrics reports the memory the server has obtained from the system
+// and its goroutines
+func RuntimeMetrics() Source {
+	return func() map[string]float64 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+
+		return map[string]float64{
+			"memory_mb":  float64(stats.Sys) / (1 << 20),
+			"goroutines": float64(runtime.NumGoroutine()),
+		}
+	}
+}
+
+// DiskMetrics reports the free space the watchdog last saw for session output
+func DiskMetrics(watchdog *diskwatch.Watchdog) Source {
+	return func() map[string]float64 {
+		return map[string]float64{
+			"disk_free_mb": float64(watchdog.Status().FreeBytes) / (1 << 20),
+		}
+	}
+}
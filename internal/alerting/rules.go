@@ -0,0 +1,98 @@
+// Package alerting evaluates alert rules against server metrics and event
+// rates, logging alerts and posting them to webhooks as they fire and resolve
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Rule fires when a metric stays above or below a threshold for a while
+type Rule struct {
+	Name       string   `json:"name"`
+	Metric     string   `json:"metric"`
+	Above      *float64 `json:"above,omitempty"`
+	Below      *float64 `json:"below,omitempty"`
+	For        string   `json:"for,omitempty"`         // Go duration the condition must hold; fires at once if empty
+	WebhookURL string   `json:"webhook_url,omitempty"` // Receives a JSON POST as the alert fires and resolves
+
+	holdFor time.Duration
+}
+
+// LoadRules reads alert rules from a JSON file holding a list of them
+func LoadRules(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule == nil {
+			return nil, fmt.Errorf("rule %d is empty", i)
+		}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rule.Name, err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %q is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return rules, nil
+}
+
+// validate checks that a rule can be evaluated
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Metric == "" {
+		return fmt.Errorf("metric is required")
+	}
+	if (r.Above == nil) == (r.Below == nil) {
+		return fmt.Errorf("exactly one of above and below is required")
+	}
+
+	if r.For != "" {
+		holdFor, err := time.ParseDuration(r.For)
+		if err != nil || holdFor < 0 {
+			return fmt.Errorf("invalid for %q", r.For)
+		}
+		r.holdFor = holdFor
+	}
+
+	if r.WebhookURL != "" {
+		u, err := url.Parse(r.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook_url must be an absolute http or https URL")
+		}
+	}
+
+	return nil
+}
+
+// breached reports whether a value meets the rule's condition
+func (r *Rule) breached(value float64) bool {
+	if r.Above != nil {
+		return value > *r.Above
+	}
+	return value < *r.Below
+}
+
+// threshold returns the value the rule compares against
+func (r *Rule) threshold() float64 {
+	if r.Above != nil {
+		return *r.Above
+	}
+	return *r.Below
+}
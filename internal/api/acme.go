@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newCertManager creates an ACME certificate manager restricted to the configured domains
+func newCertManager(cfg *config.Config) *autocert.Manager {
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+		Cache:      autocert.DirCache(cfg.ACMECacheDir),
+		Email:      cfg.ACMEEmail,
+	}
+
+	if cfg.ACMEDirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"domains":   cfg.ACMEDomains,
+		"cache_dir": cfg.ACMECacheDir,
+	}).Info("ACME certificate management enabled")
+
+	return manager
+}
+
+// newChallengeServer creates the plain HTTP server answering HTTP-01 challenges.
+// Other requests are redirected to HTTPS.
+func newChallengeServer(cfg *config.Config, manager *autocert.Manager) *http.Server {
+	return &http.Server{
+		Addr:              cfg.ACMEHTTPAddress,
+		Handler:           manager.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// newAuthenticators creates the authenticators enabled in the configuration
+func newAuthenticators(cfg *config.Config) ([]auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+
+	if cfg.TLSClientCAFile != "" {
+		certAuth, err := auth.NewCertificateAuthenticator(cfg.TLSClientIdentity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBTERM_TLS_CLIENT_IDENTITY: %v", err)
+		}
+		authenticators = append(authenticators, certAuth)
+
+		logrus.WithFields(logrus.Fields{
+			"client_auth":    cfg.TLSClientAuth,
+			"identity_field": cfg.TLSClientIdentity,
+		}).Info("Client certificate authentication enabled")
+	}
+
+	if cfg.HtpasswdFile != "" {
+		htpasswdAuth, err := auth.NewHtpasswdAuthenticator(cfg.HtpasswdFile, "webterm")
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBTERM_HTPASSWD_FILE: %v", err)
+		}
+		authenticators = append(authenticators, htpasswdAuth)
+
+		logrus.WithField("htpasswd_file", cfg.HtpasswdFile).Info("Basic authentication enabled")
+	}
+
+	if len(cfg.AuthTokens) > 0 || cfg.AuthTokensFile != "" {
+		entries := cfg.AuthTokens
+		if cfg.AuthTokensFile != "" {
+			fileEntries, err := auth.LoadTokenFile(cfg.AuthTokensFile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid WEBTERM_AUTH_TOKENS_FILE: %v", err)
+			}
+			entries = append(append([]string(nil), entries...), fileEntries...)
+		}
+
+		tokenAuth, err := auth.NewTokenAuthenticator(entries, "webterm")
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth tokens: %v", err)
+		}
+		authenticators = append(authenticators, tokenAuth)
+
+		logrus.WithField("tokens", len(entries)).Info("Bearer token authentication enabled")
+	}
+
+	return authenticators, nil
+}
+
+// NewRoles creates the role assignments enabled in the configuration, or nil
+// when roles are not enforced. Users in WEBTERM_ADMINS are admins, and may
+// not be given another role, and users without a role are operators unless
+// WEBTERM_DEFAULT_ROLE says otherwise.
+// Sessions running as another account are always subject to roles, and
+// only their owners and admins type into them.
+func NewRoles(cfg *config.Config) (*auth.Roles, error) {
+	if !cfg.RolesEnabled() && !cfg.RunAsEnabled() {
+		return nil, nil
+	}
+
+	assigned, err := auth.ParseRoleAssignments(cfg.Roles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBTERM_ROLES: %v", err)
+	}
+	for _, admin := range cfg.Admins {
+		if role, exists := assigned[admin]; exists && role != auth.RoleAdmin {
+			return nil, fmt.Errorf("invalid WEBTERM_ROLES: %s is listed in WEBTERM_ADMINS but given role %s", admin, role)
+		}
+		assigned[admin] = auth.RoleAdmin
+	}
+
+	defaultRole := auth.RoleOperator
+	if cfg.DefaultRole != "" {
+		if defaultRole, err = auth.ParseRole(cfg.DefaultRole); err != nil {
+			return nil, fmt.Errorf("invalid WEBTERM_DEFAULT_ROLE: %v", err)
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"assigned":     len(assigned),
+		"default_role": defaultRole,
+		"owner_input":  cfg.RunAsEnabled(),
+	}).Info("Role-based access control enabled")
+
+	return auth.NewRoles(assigned, defaultRole, cfg.RunAsEnabled()), nil
+}
+
+// newAdminCheck returns whether users are admins. With roles enforced, the
+// admin role decides, which includes everyone in WEBTERM_ADMINS; without,
+// WEBTERM_ADMINS lists them.
+func newAdminCheck(cfg *config.Config, roles *auth.Roles) func(user string) bool {
+	if roles == nil {
+		return cfg.IsAdmin
+	}
+	return func(user string) bool {
+		return roles.Role(user) == auth.RoleAdmin
+	}
+}
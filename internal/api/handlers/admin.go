@@ -0,0 +1,671 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/accounting"
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/maintenance"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	ws "github.com/piyushgupta53/webterm/internal/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// UsageResponse represents the response for the usage report
+type UsageResponse struct {
+	From     time.Time                 `json:"from"`
+	To       time.Time                 `json:"to"`
+	GroupBy  string                    `json:"group_by"`
+	Interval string                    `json:"interval"`
+	Usage    []accounting.UsageSummary `json:"usage"`
+}
+
+// DashboardSession is a session as shown on the operator dashboard
+type DashboardSession struct {
+	types.SessionSnapshot
+	Clients   []ws.ClientInfo         `json:"clients"`
+	Locked    bool                    `json:"locked"`
+	LastInput *time.Time              `json:"last_input,omitempty"`
+	Resources *types.SessionResources `json:"resources,omitempty"`
+
+	// Paths of the session's pipes on the server, for debugging
+	InputPipe  string `json:"input_pipe,omitempty"`
+	OutputFile string `json:"output_file,omitempty"`
+	StderrFile string `json:"stderr_file,omitempty"`
+}
+
+// DashboardResponse represents the response for the operator dashboard
+type DashboardResponse struct {
+	Sessions     []DashboardSession `json:"sessions"`
+	SessionCount int                `json:"session_count"`
+	ClientCount  int                `json:"client_count"`
+	GeneratedAt  time.Time          `json:"generated_at"`
+}
+
+// maxBannerLength bounds the size of banner messages
+const maxBannerLength = 1024
+
+// BannerRequest represents an announcement to show above session terminals
+type BannerRequest struct {
+	Message  string   `json:"message"`
+	Level    string   `json:"level,omitempty"`    // "info" (default), "warning" or "critical"
+	Sessions []string `json:"sessions,omitempty"` // Session IDs; empty means every session
+}
+
+// BannerResponse reports how many clients a banner reached
+type BannerResponse struct {
+	Delivered int `json:"delivered"`
+}
+
+// MaintenanceRequest schedules a maintenance shutdown, either at a time or after a delay
+type MaintenanceRequest struct {
+	At          *time.Time `json:"at,omitempty"`
+	In          string     `json:"in,omitempty"`           // e.g. "30m"
+	BlockBefore string     `json:"block_before,omitempty"` // Refuse new sessions this long before, default 10m
+	Reason      string     `json:"reason,omitempty"`
+}
+
+// LogLevelRequest changes the server's log level
+type LogLevelRequest struct {
+	Level string `json:"level"` // "panic", "fatal", "error", "warn", "info", "debug" or "trace"
+}
+
+// LogLevelResponse reports the server's log level
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
+
+// defaultCaptureDuration is how long a capture records unless told otherwise
+const defaultCaptureDuration = 30 * time.Second
+
+// CaptureRequest starts recording a session's traffic
+type CaptureRequest struct {
+	Duration string `json:"duration,omitempty"` // e.g. "30s" (default), at most 5m
+	Payloads bool   `json:"payloads"`           // Also record message contents, including keystrokes
+}
+
+// CaptureResponse describes a started capture
+type CaptureResponse struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	EndsAt      time.Time `json:"ends_at"`
+	DownloadURL string    `json:"download_url"`
+}
+
+// AdminHandler handles operator-facing HTTP requests
+type AdminHandler struct {
+	accountant     *accounting.Accountant
+	sessionManager *terminal.Manager
+	hub            *ws.Hub
+	scheduler      *maintenance.Scheduler
+	isAdmin        func(user string) bool
+	auditor        *audit.Logger
+	inputAudit     *audit.InputLog
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(accountant *accounting.Accountant, sessionManager *terminal.Manager, hub *ws.Hub, scheduler *maintenance.Scheduler, isAdmin func(user string) bool, auditor *audit.Logger) *AdminHandler {
+	return &AdminHandler{
+		accountant:     accountant,
+		sessionManager: sessionManager,
+		hub:            hub,
+		scheduler:      scheduler,
+		isAdmin:        isAdmin,
+		auditor:        auditor,
+	}
+}
+
+// SetInputAudit sets the input audit log admins can verify; nil leaves
+// verification unavailable
+func (ah *AdminHandler) SetInputAudit(log *audit.InputLog) {
+	ah.inputAudit = log
+}
+
+// GetUsage handles GET /api/admin/usage
+func (ah *AdminHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Usage report request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	// Default to the current month
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now
+
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+
+	groupBy := query.Get("group_by")
+	if groupBy == "" {
+		groupBy = "user"
+	}
+
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+
+	usage, err := ah.accountant.Report(from, to, groupBy, interval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := UsageResponse{
+		From:     from,
+		To:       to,
+		GroupBy:  groupBy,
+		Interval: interval,
+		Usage:    usage,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode usage response")
+		return
+	}
+}
+
+// ListSessions handles GET /api/admin/sessions
+func (ah *AdminHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	activity := ah.hub.Activity()
+	sessions := ah.sessionManager.ListSessions()
+	filter, err := sessionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := DashboardResponse{
+		Sessions:    make([]DashboardSession, 0, len(sessions)),
+		GeneratedAt: time.Now(),
+	}
+
+	for _, session := range sessions {
+		if !filter.Matches(session) {
+			continue
+		}
+
+		entry := DashboardSession{
+			Clients:    []ws.ClientInfo{},
+			InputPipe:  session.InputPipe,
+			OutputFile: session.OutputFile,
+			StderrFile: session.StderrFile,
+		}
+
+		if sessionActivity, exists := activity[session.ID]; exists {
+			entry.Clients = sessionActivity.Clients
+			entry.Locked = sessionActivity.Locked
+			entry.LastInput = sessionActivity.LastInput
+		}
+		entry.SessionSnapshot = types.NewSessionSnapshot(session, len(entry.Clients))
+
+		if resources, err := ah.sessionManager.GetSessionResources(session.ID); err == nil {
+			entry.Resources = resources
+		}
+
+		response.ClientCount += len(entry.Clients)
+		response.Sessions = append(response.Sessions, entry)
+	}
+
+	sort.Slice(response.Sessions, func(i, j int) bool {
+		return response.Sessions[i].CreatedAt.Before(response.Sessions[j].CreatedAt)
+	})
+	response.SessionCount = len(response.Sessions)
+
+	ah.writeJSON(w, http.StatusOK, response)
+}
+
+// TerminateSession handles DELETE /api/admin/sessions/{id}
+func (ah *AdminHandler) TerminateSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Admin terminate session request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	session, err := ah.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	owner, metadata := session.Owner, session.Metadata
+
+	if err := ah.sessionManager.TerminateSession(r.Context(), sessionID); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to terminate session")
+		http.Error(w, "Failed to terminate session", http.StatusConflict)
+		return
+	}
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventSessionTerminated,
+		User:       auth.FromContext(r.Context()).User,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"session_id": sessionID,
+			"owner":      owner,
+			"metadata":   metadata,
+		},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// KickClient handles DELETE /api/admin/clients/{id}
+func (ah *AdminHandler) KickClient(w http.ResponseWriter, r *http.Request) {
+	clientID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"client_id":   clientID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Admin kick client request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	if !ah.hub.KickClient(clientID) {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventClientKicked,
+		User:       auth.FromContext(r.Context()).User,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"client_id": clientID,
+		},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SendBanner handles POST /api/admin/banner
+func (ah *AdminHandler) SendBanner(w http.ResponseWriter, r *http.Request) {
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Admin banner request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	var req BannerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Message == "" || len(req.Message) > maxBannerLength {
+		http.Error(w, "Banner message must be between 1 and 1024 bytes", http.StatusBadRequest)
+		return
+	}
+
+	if req.Level == "" {
+		req.Level = ws.BannerLevelInfo
+	}
+	if err := ws.ValidateBannerLevel(req.Level); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	delivered := ah.hub.SendBanner(req.Sessions, req.Message, req.Level)
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventBannerSent,
+		User:       auth.FromContext(r.Context()).User,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"message":   req.Message,
+			"level":     req.Level,
+			"sessions":  req.Sessions,
+			"delivered": delivered,
+		},
+	})
+
+	ah.writeJSON(w, http.StatusOK, BannerResponse{Delivered: delivered})
+}
+
+// ScheduleMaintenance handles POST /api/admin/maintenance
+func (ah *AdminHandler) ScheduleMaintenance(w http.ResponseWriter, r *http.Request) {
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Schedule maintenance request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var shutdownAt time.Time
+	switch {
+	case req.At != nil && req.In == "":
+		shutdownAt = *req.At
+	case req.At == nil && req.In != "":
+		delay, err := time.ParseDuration(req.In)
+		if err != nil {
+			http.Error(w, "Invalid in duration", http.StatusBadRequest)
+			return
+		}
+		shutdownAt = time.Now().Add(delay)
+	default:
+		http.Error(w, "Exactly one of at and in is required", http.StatusBadRequest)
+		return
+	}
+
+	blockBefore := maintenance.DefaultBlockBefore
+	if req.BlockBefore != "" {
+		parsed, err := time.ParseDuration(req.BlockBefore)
+		if err != nil {
+			http.Error(w, "Invalid block_before duration", http.StatusBadRequest)
+			return
+		}
+		blockBefore = parsed
+	}
+
+	user := auth.FromContext(r.Context()).User
+
+	if err := ah.scheduler.Schedule(shutdownAt, blockBefore, req.Reason, user); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventMaintenanceScheduled,
+		User:       user,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"shutdown_at":  shutdownAt,
+			"block_before": blockBefore.String(),
+			"reason":       req.Reason,
+		},
+	})
+
+	ah.writeJSON(w, http.StatusOK, ah.scheduler.State())
+}
+
+// CancelMaintenance handles DELETE /api/admin/maintenance
+func (ah *AdminHandler) CancelMaintenance(w http.ResponseWriter, r *http.Request) {
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Cancel maintenance request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	if err := ah.scheduler.Cancel(); err != nil {
+		http.Error(w, "No maintenance is scheduled", http.StatusNotFound)
+		return
+	}
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventMaintenanceCancelled,
+		User:       auth.FromContext(r.Context()).User,
+		RemoteAddr: r.RemoteAddr,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetLogLevel handles GET /api/admin/loglevel
+func (ah *AdminHandler) GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	ah.writeJSON(w, http.StatusOK, LogLevelResponse{Level: logrus.GetLevel().String()})
+}
+
+// SetLogLevel handles PUT /api/admin/loglevel
+func (ah *AdminHandler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Set log level request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	var req LogLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		http.Error(w, "Invalid log level", http.StatusBadRequest)
+		return
+	}
+
+	previous := logrus.GetLevel()
+	logrus.SetLevel(level)
+
+	logrus.WithFields(logrus.Fields{
+		"previous":  previous.String(),
+		"log_level": level.String(),
+	}).Warn("Log level changed")
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventLogLevelChanged,
+		User:       auth.FromContext(r.Context()).User,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"previous": previous.String(),
+			"level":    level.String(),
+		},
+	})
+
+	ah.writeJSON(w, http.StatusOK, LogLevelResponse{Level: level.String()})
+}
+
+// StartCapture handles POST /api/admin/sessions/{id}/capture
+func (ah *AdminHandler) StartCapture(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Start capture request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	var req CaptureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	duration := defaultCaptureDuration
+	if req.Duration != "" {
+		parsed, err := time.ParseDuration(req.Duration)
+		if err != nil || parsed <= 0 || parsed > ws.MaxCaptureDuration {
+			http.Error(w, "Duration must be positive and at most 5m", http.StatusBadRequest)
+			return
+		}
+		duration = parsed
+	}
+
+	session, err := ah.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	user := auth.FromContext(r.Context()).User
+
+	capture, err := ah.hub.StartCapture(sessionID, session.Owner, user, duration, req.Payloads)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventCaptureStarted,
+		User:       user,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"session_id": sessionID,
+			"owner":      session.Owner,
+			"capture_id": capture.ID,
+			"duration":   duration.String(),
+			"payloads":   req.Payloads,
+		},
+	})
+
+	ah.writeJSON(w, http.StatusCreated, CaptureResponse{
+		ID:          capture.ID,
+		SessionID:   sessionID,
+		EndsAt:      capture.EndsAt,
+		DownloadURL: "/api/admin/captures/" + capture.ID,
+	})
+}
+
+// GetCapture handles GET /api/admin/captures/{id}, downloading what a
+// capture has recorded so far
+func (ah *AdminHandler) GetCapture(w http.ResponseWriter, r *http.Request) {
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	capture, err := ah.hub.GetCapture(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Capture not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="capture-%s.json"`, capture.ID))
+	ah.writeJSON(w, http.StatusOK, capture)
+}
+
+// VerifyInputAudit handles GET /api/admin/input-audit/verify, checking that
+// the records of the input audit file are unaltered
+func (ah *AdminHandler) VerifyInputAudit(w http.ResponseWriter, r *http.Request) {
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	if ah.inputAudit == nil {
+		http.Error(w, "Input audit is not enabled", http.StatusNotFound)
+		return
+	}
+
+	result, err := ah.inputAudit.Verify()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to verify input audit file")
+		http.Error(w, "Failed to read input audit file", http.StatusInternalServerError)
+		return
+	}
+
+	if !result.Valid {
+		logrus.WithFields(logrus.Fields{
+			"line":   result.BrokenAt,
+			"reason": result.Reason,
+		}).Warn("Input audit file failed verification")
+	}
+
+	ah.writeJSON(w, http.StatusOK, result)
+}
+
+// requireAdmin rejects requests from users that are not admins
+func (ah *AdminHandler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !ah.isAdmin(auth.FromContext(r.Context()).User) {
+		http.Error(w, "Admin privileges required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// writeJSON encodes a JSON response
+func (ah *AdminHandler) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode admin response")
+	}
+}
+
+// RegisterRoutes registers all admin routes
+func (ah *AdminHandler) RegisterRoutes(router *mux.Router) {
+	adminRouter := router.PathPrefix("/api/admin").Subrouter()
+
+	adminRouter.HandleFunc("/usage", ah.GetUsage).Methods("GET")
+	adminRouter.HandleFunc("/sessions", ah.ListSessions).Methods("GET")
+	adminRouter.HandleFunc("/sessions/{id}", ah.TerminateSession).Methods("DELETE")
+	adminRouter.HandleFunc("/clients/{id}", ah.KickClient).Methods("DELETE")
+	adminRouter.HandleFunc("/banner", ah.SendBanner).Methods("POST")
+	adminRouter.HandleFunc("/maintenance", ah.ScheduleMaintenance).Methods("POST")
+	adminRouter.HandleFunc("/maintenance", ah.CancelMaintenance).Methods("DELETE")
+	adminRouter.HandleFunc("/sessions/{id}/capture", ah.StartCapture).Methods("POST")
+	adminRouter.HandleFunc("/captures/{id}", ah.GetCapture).Methods("GET")
+	adminRouter.HandleFunc("/loglevel", ah.GetLogLevel).Methods("GET")
+	adminRouter.HandleFunc("/loglevel", ah.SetLogLevel).Methods("PUT")
+	adminRouter.HandleFunc("/input-audit/verify", ah.VerifyInputAudit).Methods("GET")
+
+	logrus.Info("Admin routes registered")
+}
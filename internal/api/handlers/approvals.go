@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ApprovalHandler handles admin approval of sessions using privileged profiles
+type ApprovalHandler struct {
+	sessionManager *terminal.Manager
+	isAdmin        func(user string) bool
+	auditor        *audit.Logger
+}
+
+// NewApprovalHandler creates a new approval handler
+func NewApprovalHandler(sessionManager *terminal.Manager, isAdmin func(user string) bool, auditor *audit.Logger) *ApprovalHandler {
+	return &ApprovalHandler{
+		sessionManager: sessionManager,
+		isAdmin:        isAdmin,
+		auditor:        auditor,
+	}
+}
+
+// ListApprovals handles GET /api/approvals
+func (ah *ApprovalHandler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	approvals := ah.sessionManager.ListApprovals()
+
+	ah.writeJSON(w, http.StatusOK, types.ApprovalListResponse{
+		Approvals: approvals,
+		Count:     len(approvals),
+	})
+}
+
+// ApproveSession handles POST /api/approvals/{id}
+func (ah *ApprovalHandler) ApproveSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Approve session request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	approver := auth.FromContext(r.Context()).User
+
+	session, err := ah.sessionManager.ApproveSession(sessionID, approver)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to approve session")
+		switch {
+		case errors.Is(err, terminal.ErrApprovalNotFound):
+			http.Error(w, "Approval not found", http.StatusNotFound)
+		case errors.Is(err, terminal.ErrSelfApproval):
+			http.Error(w, "Sessions cannot be approved by their requester", http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to start approved session", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventSessionApproved,
+		User:       approver,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"session_id": session.ID,
+			"requester":  session.Owner,
+			"profile":    session.Profile,
+			"metadata":   session.Metadata,
+		},
+	})
+
+	// The session has only just started, so no client can be connected yet
+	ah.writeJSON(w, http.StatusOK, types.SessionResponse{Session: types.NewSessionSnapshot(session, 0)})
+}
+
+// DenySession handles DELETE /api/approvals/{id}
+func (ah *ApprovalHandler) DenySession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Deny session request")
+
+	if !ah.requireAdmin(w, r) {
+		return
+	}
+
+	approver := auth.FromContext(r.Context()).User
+
+	// Keep the request's details for the audit log
+	var metadata map[string]string
+	if session, err := ah.sessionManager.GetSession(sessionID); err == nil {
+		metadata = session.Metadata
+	}
+
+	if err := ah.sessionManager.DenySession(sessionID, approver); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to deny session")
+		if errors.Is(err, terminal.ErrApprovalNotFound) {
+			http.Error(w, "Approval not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to deny session", http.StatusInternalServerError)
+		return
+	}
+
+	ah.auditor.Log(audit.Event{
+		Type:       audit.EventSessionDenied,
+		User:       approver,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"session_id": sessionID,
+			"metadata":   metadata,
+		},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireAdmin rejects requests from users that are not admins
+func (ah *ApprovalHandler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !ah.isAdmin(auth.FromContext(r.Context()).User) {
+		http.Error(w, "Admin privileges required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// writeJSON encodes a JSON response
+func (ah *ApprovalHandler) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode approval response")
+	}
+}
+
+// RegisterRoutes registers all approval routes
+func (ah *ApprovalHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/approvals", ah.ListApprovals).Methods("GET")
+	apiRouter.HandleFunc("/approvals/{id}", ah.ApproveSession).Methods("POST")
+	apiRouter.HandleFunc("/approvals/{id}", ah.DenySession).Methods("DELETE")
+
+	logrus.Info("Approval routes registered")
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	ws "github.com/piyushgupta53/webterm/internal/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// BroadcastResponse represents the broadcast state of a session
+type BroadcastResponse struct {
+	SessionID string `json:"session_id"`
+	Enabled   bool   `json:"enabled"`
+	URL       string `json:"url,omitempty"`
+	Viewers   int    `json:"viewers"`
+}
+
+// BroadcastHandler handles publishing sessions at read-only broadcast links
+type BroadcastHandler struct {
+	sessionManager *terminal.Manager
+	hub            *ws.Hub
+	roles          *auth.Roles
+}
+
+// NewBroadcastHandler creates a new broadcast handler. With roles, only
+// users who may manage a session publish or revoke its broadcast.
+func NewBroadcastHandler(sessionManager *terminal.Manager, hub *ws.Hub, roles *auth.Roles) *BroadcastHandler {
+	return &BroadcastHandler{
+		sessionManager: sessionManager,
+		hub:            hub,
+		roles:          roles,
+	}
+}
+
+// EnableBroadcast handles POST /api/sessions/{id}/broadcast
+func (bh *BroadcastHandler) EnableBroadcast(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Enable broadcast request")
+
+	if !requireManage(w, r, bh.roles, bh.sessionManager, sessionID) {
+		return
+	}
+
+	token, err := bh.sessionManager.EnableBroadcast(sessionID)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to enable broadcast")
+		http.Error(w, "Failed to enable broadcast", http.StatusBadRequest)
+		return
+	}
+
+	bh.writeResponse(w, http.StatusOK, BroadcastResponse{
+		SessionID: sessionID,
+		Enabled:   true,
+		URL:       "/watch/" + token,
+		Viewers:   bh.hub.GetViewerCount(sessionID),
+	})
+}
+
+// GetBroadcast handles GET /api/sessions/{id}/broadcast
+func (bh *BroadcastHandler) GetBroadcast(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	session, err := bh.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	response := BroadcastResponse{
+		SessionID: sessionID,
+		Enabled:   session.Broadcasting,
+		Viewers:   bh.hub.GetViewerCount(sessionID),
+	}
+	if session.Broadcasting {
+		response.URL = "/watch/" + session.BroadcastToken
+	}
+
+	bh.writeResponse(w, http.StatusOK, response)
+}
+
+// DisableBroadcast handles DELETE /api/sessions/{id}/broadcast
+func (bh *BroadcastHandler) DisableBroadcast(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Disable broadcast request")
+
+	if !requireManage(w, r, bh.roles, bh.sessionManager, sessionID) {
+		return
+	}
+
+	if err := bh.sessionManager.DisableBroadcast(sessionID); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to disable broadcast")
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	// Disconnect anyone still watching through the revoked link
+	bh.hub.RevokeBroadcast(sessionID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeResponse writes a broadcast response as JSON
+func (bh *BroadcastHandler) writeResponse(w http.ResponseWriter, status int, response BroadcastResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode broadcast response")
+	}
+}
+
+// RegisterRoutes registers all broadcast routes
+func (bh *BroadcastHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/sessions/{id}/broadcast", bh.EnableBroadcast).Methods("POST")
+	apiRouter.HandleFunc("/sessions/{id}/broadcast", bh.GetBroadcast).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/broadcast", bh.DisableBroadcast).Methods("DELETE")
+
+	logrus.Info("Broadcast routes registered")
+}
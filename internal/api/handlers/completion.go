@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/sirupsen/logrus"
+)
+
+// CompletionHandler offers filesystem completions to frontends
+type CompletionHandler struct {
+	sessionManager *terminal.Manager
+}
+
+// NewCompletionHandler creates a new completion handler
+func NewCompletionHandler(sessionManager *terminal.Manager) *CompletionHandler {
+	return &CompletionHandler{
+		sessionManager: sessionManager,
+	}
+}
+
+// CompletePath handles GET /api/sessions/{id}/completions?path=...
+func (ch *CompletionHandler) CompletePath(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	session, err := ch.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	// Completions reveal the session's files, so only its owner may ask
+	if session.Owner != auth.FromContext(r.Context()).User {
+		http.Error(w, "Only the session owner can request completions", http.StatusForbidden)
+		return
+	}
+
+	completions, err := ch.sessionManager.CompletePath(sessionID, r.URL.Query().Get("path"))
+	if err != nil {
+		if errors.Is(err, terminal.ErrCompletionUnavailable) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to complete path")
+		http.Error(w, "Failed to complete path", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(completions); err != nil {
+		logrus.WithError(err).Error("Failed to encode completions")
+	}
+}
+
+// RegisterRoutes registers the completion routes
+func (ch *CompletionHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/sessions/{id}/completions", ch.CompletePath).Methods("GET")
+
+	logrus.Info("Completion routes registered")
+}
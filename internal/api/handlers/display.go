@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/sirupsen/logrus"
+)
+
+// DisplayHandler bridges WebSocket VNC clients, such as noVNC, to the X
+// displays of sessions
+type DisplayHandler struct {
+	sessionManager *terminal.Manager
+
+	// Upgrades display connections, which carry the VNC protocol in binary
+	// messages as noVNC expects
+	upgrader websocket.Upgrader
+}
+
+// NewDisplayHandler creates a new display handler accepting connections
+// from the pages origins allows
+func NewDisplayHandler(sessionManager *terminal.Manager, origins *OriginPolicy) *DisplayHandler {
+	return &DisplayHandler{
+		sessionManager: sessionManager,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  32 * 1024,
+			WriteBufferSize: 32 * 1024,
+			Subprotocols:    []string{"binary"},
+			CheckOrigin:     origins.Check,
+		},
+	}
+}
+
+// ConnectDisplay handles GET /api/sessions/{id}/display, relaying a WebSocket
+// to the VNC server of the session's display
+func (dh *DisplayHandler) ConnectDisplay(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	session, err := dh.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	// The display takes keyboard and mouse input, so only the owner may connect
+	if session.Owner != auth.FromContext(r.Context()).User {
+		http.Error(w, "Only the session owner can connect to its display", http.StatusForbidden)
+		return
+	}
+
+	vnc, err := dh.sessionManager.OpenDisplay(sessionID)
+	if err != nil {
+		if errors.Is(err, terminal.ErrNoDisplay) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to connect to session display")
+		http.Error(w, "Failed to connect to session display", http.StatusBadGateway)
+		return
+	}
+	defer vnc.Close()
+
+	conn, err := dh.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to upgrade display connection")
+		return
+	}
+	defer conn.Close()
+
+	logrus.WithFields(logrus.Fields{
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Display client connected")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relayDisplayOutput(conn, vnc)
+	}()
+	relayDisplayInput(conn, vnc)
+
+	// Unblock the other direction and wait for it
+	vnc.Close()
+	conn.Close()
+	<-done
+
+	logrus.WithField("session_id", sessionID).Info("Display client disconnected")
+}
+
+// relayDisplayInput copies WebSocket messages from the client to the VNC server
+func relayDisplayInput(conn *websocket.Conn, vnc net.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if _, err := vnc.Write(data); err != nil {
+			return
+		}
+	}
+}
+
+// relayDisplayOutput copies what the VNC server sends to the client as
+// binary messages
+func relayDisplayOutput(conn *websocket.Conn, vnc net.Conn) {
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := vnc.Read(buffer)
+		if n > 0 {
+			if err := conn.WriteMessage(websocket.BinaryMessage, buffer[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// RegisterRoutes registers the display routes
+func (dh *DisplayHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/sessions/{id}/display", dh.ConnectDisplay).Methods("GET")
+
+	logrus.Info("Display routes registered")
+}
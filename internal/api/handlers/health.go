@@ -6,6 +6,8 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/piyushgupta53/webterm/internal/diskwatch"
+	"github.com/piyushgupta53/webterm/internal/limits"
 	"github.com/sirupsen/logrus"
 )
 
@@ -32,11 +34,20 @@ type HealthCheck struct {
 type HealthMetrics struct {
 	ActiveSessions    int64   `json:"active_sessions"`
 	ActiveConnections int64   `json:"active_connections"`
+	BroadcastViewers  int64   `json:"broadcast_viewers"`
 	TotalSessions     int64   `json:"total_sessions"`
 	TotalConnections  int64   `json:"total_connections"`
 	TotalErrors       int64   `json:"total_errors"`
 	MemoryUsageMB     float64 `json:"memory_usage_mb"`
 	Goroutines        int     `json:"goroutines"`
+
+	// As the operating system sees the process; left out where the
+	// platform cannot report them
+	ResidentMemoryMB    float64 `json:"resident_memory_mb,omitempty"`
+	OpenFileDescriptors int     `json:"open_file_descriptors,omitempty"`
+
+	DiskFreeBytes uint64 `json:"disk_free_bytes,omitempty"` // Free space for session output
+	DiskDegraded  bool   `json:"disk_degraded"`
 }
 
 // SystemInfo represents system information
@@ -62,6 +73,12 @@ type EnhancedHealthHandler struct {
 	sessionManager interface {
 		GetSessionCount() int
 	}
+	viewerSource interface {
+		GetTotalViewerCount() int
+	}
+	diskSource interface {
+		Status() diskwatch.Status
+	}
 }
 
 // NewEnhancedHealthHandler creates a new enhanced health handler
@@ -94,6 +111,20 @@ func (h *EnhancedHealthHandler) SetSessionManager(manager interface {
 	h.sessionManager = manager
 }
 
+// SetViewerSource sets the source of broadcast viewer counts
+func (h *EnhancedHealthHandler) SetViewerSource(source interface {
+	GetTotalViewerCount() int
+}) {
+	h.viewerSource = source
+}
+
+// SetDiskSource sets the watchdog reporting free space for session output
+func (h *EnhancedHealthHandler) SetDiskSource(source interface {
+	Status() diskwatch.Status
+}) {
+	h.diskSource = source
+}
+
 // ServeHTTP implements the http.Handler interface for enhanced health checks
 func (h *EnhancedHealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -234,6 +265,21 @@ func (h *EnhancedHealthHandler) runHealthChecks() map[string]HealthCheck {
 		LastRun: now,
 	}
 
+	// Disk space check
+	if h.diskSource != nil {
+		disk := h.diskSource.Status()
+		check := HealthCheck{
+			Status:  "ok",
+			Message: "Disk space for session output normal",
+			LastRun: disk.CheckedAt,
+		}
+		if disk.Degraded {
+			check.Status = "error"
+			check.Message = "Disk space low, new sessions refused"
+		}
+		checks["disk"] = check
+	}
+
 	return checks
 }
 
@@ -247,6 +293,11 @@ func (h *EnhancedHealthHandler) getMetrics() HealthMetrics {
 		Goroutines:    runtime.NumGoroutine(),
 	}
 
+	if process, err := limits.ReadProcessUsage(); err == nil {
+		metrics.ResidentMemoryMB = float64(process.ResidentBytes) / 1024 / 1024
+		metrics.OpenFileDescriptors = process.OpenFileDescriptors
+	}
+
 	// Get metrics from metrics source if available
 	if h.metricsSource != nil {
 		if appMetrics := h.metricsSource.GetMetrics(); appMetrics != nil {
@@ -260,6 +311,18 @@ func (h *EnhancedHealthHandler) getMetrics() HealthMetrics {
 		metrics.ActiveSessions = int64(h.sessionManager.GetSessionCount())
 	}
 
+	// Get broadcast viewer count if available
+	if h.viewerSource != nil {
+		metrics.BroadcastViewers = int64(h.viewerSource.GetTotalViewerCount())
+	}
+
+	// Get free disk space if watched
+	if h.diskSource != nil {
+		disk := h.diskSource.Status()
+		metrics.DiskFreeBytes = disk.FreeBytes
+		metrics.DiskDegraded = disk.Degraded
+	}
+
 	return metrics
 }
 
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/maintenance"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/sirupsen/logrus"
+)
+
+// ServerInfoResponse describes the running server
+type ServerInfoResponse struct {
+	Version     string            `json:"version"`
+	Time        time.Time         `json:"time"`
+	Maintenance maintenance.State `json:"maintenance"`
+
+	// Platform features sessions depend on, detected at startup
+	Capabilities *terminal.Capabilities `json:"capabilities"`
+}
+
+// ServerInfoHandler reports server details to clients
+type ServerInfoHandler struct {
+	version      string
+	scheduler    *maintenance.Scheduler
+	capabilities *terminal.Capabilities
+}
+
+// NewServerInfoHandler creates a new server info handler
+func NewServerInfoHandler(version string, scheduler *maintenance.Scheduler, capabilities *terminal.Capabilities) *ServerInfoHandler {
+	return &ServerInfoHandler{
+		version:      version,
+		scheduler:    scheduler,
+		capabilities: capabilities,
+	}
+}
+
+// GetInfo handles GET /api/server/info
+func (sih *ServerInfoHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
+	response := ServerInfoResponse{
+		Version:      sih.version,
+		Time:         time.Now(),
+		Maintenance:  sih.scheduler.State(),
+		Capabilities: sih.capabilities,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode server info response")
+	}
+}
+
+// RegisterRoutes registers the server info routes
+func (sih *ServerInfoHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/api/server/info", sih.GetInfo).Methods("GET")
+
+	logrus.Info("Server info routes registered")
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	ws "github.com/piyushgupta53/webterm/internal/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// UnlockRequest represents a request to unlock an idle session
+type UnlockRequest struct {
+	Password string `json:"password,omitempty"`
+}
+
+// LockHandler handles locking sessions and unlocking them after re-authentication
+type LockHandler struct {
+	sessionManager *terminal.Manager
+	hub            *ws.Hub
+	auth           *auth.Middleware
+}
+
+// NewLockHandler creates a new lock handler
+func NewLockHandler(sessionManager *terminal.Manager, hub *ws.Hub, authMiddleware *auth.Middleware) *LockHandler {
+	return &LockHandler{
+		sessionManager: sessionManager,
+		hub:            hub,
+		auth:           authMiddleware,
+	}
+}
+
+// LockSession handles POST /api/sessions/{id}/lock
+func (lh *LockHandler) LockSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Lock session request")
+
+	if !lh.requireOwner(w, r, sessionID) {
+		return
+	}
+
+	// A session nobody could unlock stays locked for good
+	if !lh.auth.CanReauthenticate() {
+		http.Error(w, "Sessions cannot be locked without authentication", http.StatusConflict)
+		return
+	}
+
+	lh.hub.LockSession(sessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockSession handles POST /api/sessions/{id}/unlock
+func (lh *LockHandler) UnlockSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Unlock session request")
+
+	if !lh.requireOwner(w, r, sessionID) {
+		return
+	}
+
+	var req UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user := auth.FromContext(r.Context()).User
+
+	wait, err := lh.auth.Reauthenticate(r, user, req.Password)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Warn("Re-authentication failed")
+		if errors.Is(err, auth.ErrThrottled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "Too many failed authentication attempts", http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	lh.hub.UnlockSession(sessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireOwner rejects requests for sessions the caller does not own
+func (lh *LockHandler) requireOwner(w http.ResponseWriter, r *http.Request, sessionID string) bool {
+	session, err := lh.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return false
+	}
+
+	if session.Owner != auth.FromContext(r.Context()).User {
+		http.Error(w, "Only the session owner can lock or unlock it", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// RegisterRoutes registers the session lock routes
+func (lh *LockHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/sessions/{id}/lock", lh.LockSession).Methods("POST")
+	apiRouter.HandleFunc("/sessions/{id}/unlock", lh.UnlockSession).Methods("POST")
+
+	logrus.Info("Session lock routes registered")
+}
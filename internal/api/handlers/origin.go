@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OriginPolicy decides which pages may open WebSocket and WebTransport
+// connections. Browsers send cookies and basic auth credentials with
+// cross-site upgrades, so without a check any page a user visits could
+// drive their terminal sessions.
+type OriginPolicy struct {
+	allowed  map[string]bool
+	allowAny bool
+}
+
+// NewOriginPolicy allows the server's own origin and the listed ones, which
+// are lowercased scheme://host[:port] origins. allowAny turns the check off,
+// for development only.
+func NewOriginPolicy(allowed []string, allowAny bool) *OriginPolicy {
+	p := &OriginPolicy{
+		allowed:  make(map[string]bool, len(allowed)),
+		allowAny: allowAny,
+	}
+	for _, origin := range allowed {
+		p.allowed[origin] = true
+	}
+	return p
+}
+
+// Check reports whether the page a request comes from may connect. Requests
+// without an Origin header come from clients other than browsers and are
+// allowed, leaving them to authentication.
+func (p *OriginPolicy) Check(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.allowAny {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if p.allowed[strings.ToLower(origin)] {
+		return true
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"origin":      origin,
+		"host":        r.Host,
+		"remote_addr": r.RemoteAddr,
+	}).Warn("Rejected connection from disallowed origin")
+	return false
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// PaneListResponse represents the response for listing a session's panes
+type PaneListResponse struct {
+	Panes []*types.Pane `json:"panes"`
+	Count int           `json:"count"`
+}
+
+// PaneHandler handles the panes of sessions
+type PaneHandler struct {
+	sessionManager *terminal.Manager
+	roles          *auth.Roles
+}
+
+// NewPaneHandler creates a new pane handler. With roles, only users who may
+// manage a session open and close its panes.
+func NewPaneHandler(sessionManager *terminal.Manager, roles *auth.Roles) *PaneHandler {
+	return &PaneHandler{
+		sessionManager: sessionManager,
+		roles:          roles,
+	}
+}
+
+// ListPanes handles GET /api/sessions/{id}/panes
+func (ph *PaneHandler) ListPanes(w http.ResponseWriter, r *http.Request) {
+	panes, err := ph.sessionManager.ListPanes(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if panes == nil {
+		panes = []*types.Pane{}
+	}
+
+	ph.writeJSON(w, http.StatusOK, PaneListResponse{
+		Panes: panes,
+		Count: len(panes),
+	})
+}
+
+// CreatePane handles POST /api/sessions/{id}/panes
+func (ph *PaneHandler) CreatePane(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	var req types.PaneCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !requireManage(w, r, ph.roles, ph.sessionManager, sessionID) {
+		return
+	}
+
+	pane, err := ph.sessionManager.CreatePane(sessionID, &req)
+	if err != nil {
+		if errors.Is(err, terminal.ErrPanesUnavailable) || errors.Is(err, terminal.ErrTooManyPanes) ||
+			errors.Is(err, terminal.ErrSessionEnded) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, terminal.ErrShellNotAllowed) || errors.Is(err, terminal.ErrCommandNotAllowed) ||
+			errors.Is(err, terminal.ErrEnvNotAllowed) || errors.Is(err, terminal.ErrWorkingDirNotAllowed) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, terminal.ErrInvalidEnv) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to create pane")
+		http.Error(w, "Failed to create pane", http.StatusInternalServerError)
+		return
+	}
+
+	ph.writeJSON(w, http.StatusCreated, pane)
+}
+
+// ClosePane handles DELETE /api/sessions/{id}/panes/{pane}
+func (ph *PaneHandler) ClosePane(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if !requireManage(w, r, ph.roles, ph.sessionManager, vars["id"]) {
+		return
+	}
+
+	if err := ph.sessionManager.ClosePane(vars["id"], vars["pane"]); err != nil {
+		http.Error(w, "Pane not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes a response as JSON
+func (ph *PaneHandler) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode pane response")
+	}
+}
+
+// RegisterRoutes registers all pane routes
+func (ph *PaneHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/sessions/{id}/panes", ph.ListPanes).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/panes", ph.CreatePane).Methods("POST")
+	apiRouter.HandleFunc("/sessions/{id}/panes/{pane}", ph.ClosePane).Methods("DELETE")
+
+	logrus.Info("Pane routes registered")
+}
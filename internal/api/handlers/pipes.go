@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// PipeListResponse represents the response for listing a session's pipes
+type PipeListResponse struct {
+	Pipes []*types.SessionPipe `json:"pipes"`
+	Count int                  `json:"count"`
+}
+
+// PipeHandler handles pipes that forward output between sessions
+type PipeHandler struct {
+	sessionManager *terminal.Manager
+	roles          *auth.Roles
+}
+
+// NewPipeHandler creates a new pipe handler. With roles, only users who may
+// manage a session list and close its pipes.
+func NewPipeHandler(sessionManager *terminal.Manager, roles *auth.Roles) *PipeHandler {
+	return &PipeHandler{
+		sessionManager: sessionManager,
+		roles:          roles,
+	}
+}
+
+// ListPipes handles GET /api/sessions/{id}/pipes
+func (ph *PipeHandler) ListPipes(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	if !requireManage(w, r, ph.roles, ph.sessionManager, sessionID) {
+		return
+	}
+
+	pipes, err := ph.sessionManager.ListPipes(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	ph.writeJSON(w, http.StatusOK, PipeListResponse{
+		Pipes: pipes,
+		Count: len(pipes),
+	})
+}
+
+// CreatePipe handles POST /api/sessions/{id}/pipes
+func (ph *PipeHandler) CreatePipe(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	var req types.SessionPipeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	source, err := ph.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	// A pipe reads one session and types into the other, so the caller must
+	// own both
+	user := auth.FromContext(r.Context()).User
+	if source.Owner != user {
+		http.Error(w, "Only the session owner can pipe its output", http.StatusForbidden)
+		return
+	}
+	if target, err := ph.sessionManager.GetSession(req.Target); err == nil && target.Owner != user {
+		http.Error(w, "Only the target session's owner can pipe into it", http.StatusForbidden)
+		return
+	}
+
+	pipe, err := ph.sessionManager.CreatePipe(sessionID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, terminal.ErrInvalidPipe):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, terminal.ErrPipeLoop), errors.Is(err, terminal.ErrTooManyPipes),
+			errors.Is(err, terminal.ErrGoroutineBudget), errors.Is(err, terminal.ErrSessionEnded):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to create pipe")
+			http.Error(w, "Failed to create pipe", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	ph.writeJSON(w, http.StatusCreated, pipe)
+}
+
+// ClosePipe handles DELETE /api/sessions/{id}/pipes/{pipe}
+func (ph *PipeHandler) ClosePipe(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if !requireManage(w, r, ph.roles, ph.sessionManager, vars["id"]) {
+		return
+	}
+
+	if err := ph.sessionManager.ClosePipe(vars["id"], vars["pipe"]); err != nil {
+		http.Error(w, "Pipe not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes a response as JSON
+func (ph *PipeHandler) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode pipe response")
+	}
+}
+
+// RegisterRoutes registers all pipe routes
+func (ph *PipeHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/sessions/{id}/pipes", ph.ListPipes).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/pipes", ph.CreatePipe).Methods("POST")
+	apiRouter.HandleFunc("/sessions/{id}/pipes/{pipe}", ph.ClosePipe).Methods("DELETE")
+
+	logrus.Info("Pipe routes registered")
+}
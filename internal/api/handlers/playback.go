@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/piyushgupta53/webterm/internal/terminal/recording"
+	"github.com/sirupsen/logrus"
+)
+
+// maxPlaybackSpeed bounds how much faster than recorded playback may run
+const maxPlaybackSpeed = 64
+
+// PlayRecording handles GET /api/sessions/{id}/recording/play, streaming a
+// session's recording as server-sent events at the pace it was recorded.
+// ?seek=<seconds> starts partway through, sending the output before that
+// point at once, and ?speed=<factor> plays faster or slower.
+func (th *TranscriptHandler) PlayRecording(w http.ResponseWriter, r *http.Request) {
+	seek, err := parsePlaybackParam(r, "seek", 0)
+	if err != nil || seek < 0 {
+		http.Error(w, "Invalid seek parameter", http.StatusBadRequest)
+		return
+	}
+	speed, err := parsePlaybackParam(r, "speed", 1)
+	if err != nil || speed <= 0 || speed > maxPlaybackSpeed {
+		http.Error(w, fmt.Sprintf("Invalid speed parameter, must be above 0 and at most %d", maxPlaybackSpeed), http.StatusBadRequest)
+		return
+	}
+
+	session, ok := th.authorize(w, r, audit.EventRecordingPlayed)
+	if !ok {
+		return
+	}
+
+	if !session.Recording {
+		http.Error(w, "Session is not recorded", http.StatusNotFound)
+		return
+	}
+
+	file, err := th.sessionManager.OpenRecording(session.ID)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to open recording")
+		http.Error(w, "Failed to open recording", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	reader, err := recording.NewReader(file)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to read recording")
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+
+	// Playback outlasts the server's write timeout
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		logrus.WithError(err).Debug("Failed to clear write deadline for playback")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	player := &playback{w: w, controller: controller, seek: seek}
+	if err := player.send("header", reader.Header); err != nil {
+		return
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to read recording")
+			player.send("error", map[string]string{"error": "Failed to read recording"})
+			return
+		}
+
+		// Output before the seek position is sent at once, so the terminal
+		// shows what it showed then
+		if event.Time < seek {
+			player.skip(event)
+			continue
+		}
+		if err := player.catchUp(); err != nil {
+			return
+		}
+
+		if delay := time.Duration((event.Time-seek)/speed*float64(time.Second)) - time.Since(player.start); delay > 0 {
+			timer.Reset(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		player.duration = event.Time
+		if err := player.send("event", event); err != nil {
+			return
+		}
+	}
+
+	if err := player.catchUp(); err != nil {
+		return
+	}
+	player.send("end", map[string]float64{"duration": player.duration})
+}
+
+// playback streams the events of a recording as server-sent events
+type playback struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	seek       float64
+
+	// Output and the last resize before the seek position
+	skipped strings.Builder
+	resize  *recording.Event
+
+	caughtUp bool
+	start    time.Time // When playing from the seek position began
+	duration float64   // Time of the last event
+}
+
+// skip keeps what an event before the seek position changed on the terminal
+func (p *playback) skip(event *recording.Event) {
+	p.duration = event.Time
+	switch event.Code {
+	case recording.EventOutput:
+		p.skipped.WriteString(event.Data)
+	case recording.EventResize:
+		p.resize = event
+	}
+}
+
+// catchUp sends what was skipped before the seek position, once
+func (p *playback) catchUp() error {
+	if p.caughtUp {
+		return nil
+	}
+	p.caughtUp = true
+	p.start = time.Now()
+
+	if p.resize != nil {
+		if err := p.send("event", p.resize); err != nil {
+			return err
+		}
+	}
+	if p.skipped.Len() > 0 {
+		event := &recording.Event{Time: p.seek, Code: recording.EventOutput, Data: p.skipped.String()}
+		if err := p.send("event", event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send writes one server-sent event and flushes it to the client
+func (p *playback) send(name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(p.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return p.controller.Flush()
+}
+
+// parsePlaybackParam parses a number from the query, or returns fallback
+func parsePlaybackParam(r *http.Request, name string, fallback float64) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, fmt.Errorf("%s must be finite", name)
+	}
+	return number, nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReadinessResponse says whether the server should receive new traffic
+type ReadinessResponse struct {
+	Status string            `json:"status"` // "ready" or "degraded"
+	Checks map[string]string `json:"checks"` // "ok" or why the check failed
+}
+
+// ReadinessHandler serves /readyz for load balancers and orchestrators,
+// failing while any of its checks does
+type ReadinessHandler struct {
+	mutex  sync.Mutex
+	checks map[string]func() error
+}
+
+// NewReadinessHandler creates a readiness handler without checks
+func NewReadinessHandler() *ReadinessHandler {
+	return &ReadinessHandler{
+		checks: make(map[string]func() error),
+	}
+}
+
+// AddCheck adds a named check, which returns an error while not ready
+func (h *ReadinessHandler) AddCheck(name string, check func() error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checks[name] = check
+}
+
+// ServeHTTP reports readiness, with 503 Service Unavailable while degraded
+func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	checks := make(map[string]func() error, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mutex.Unlock()
+
+	response := ReadinessResponse{
+		Status: "ready",
+		Checks: make(map[string]string, len(checks)),
+	}
+	for name, check := range checks {
+		if err := check(); err != nil {
+			response.Status = "degraded"
+			response.Checks[name] = err.Error()
+			continue
+		}
+		response.Checks[name] = "ok"
+	}
+
+	status := http.StatusOK
+	if response.Status != "ready" {
+		status = http.StatusServiceUnavailable
+		logrus.WithField("checks", response.Checks).Debug("Readiness check failed")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
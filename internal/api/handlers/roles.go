@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/monitoring"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+)
+
+// requireManage rejects requests for sessions that do not exist or that the
+// caller's role does not let them manage
+func requireManage(w http.ResponseWriter, r *http.Request, roles *auth.Roles, sessionManager *terminal.Manager, sessionID string) bool {
+	session, err := sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return false
+	}
+
+	if !roles.CanManage(auth.FromContext(r.Context()).User, session.Owner) {
+		http.Error(w, "Your role does not allow managing this session", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// userClass buckets a user for metric labels: anonymous, their role when
+// roles are enforced, or authenticated
+func userClass(roles *auth.Roles, user string) string {
+	switch {
+	case user == "":
+		return monitoring.UserClassAnonymous
+	case roles != nil:
+		return string(roles.Role(user))
+	default:
+		return monitoring.UserClassAuthenticated
+	}
+}
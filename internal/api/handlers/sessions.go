@@ -2,26 +2,57 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/accounting"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/diskwatch"
+	apperrors "github.com/piyushgupta53/webterm/internal/errors"
+	"github.com/piyushgupta53/webterm/internal/maintenance"
+	"github.com/piyushgupta53/webterm/internal/monitoring"
 	"github.com/piyushgupta53/webterm/internal/terminal"
 	"github.com/piyushgupta53/webterm/internal/types"
+	ws "github.com/piyushgupta53/webterm/internal/websocket"
 	"github.com/sirupsen/logrus"
 )
 
 // SessionHandler handles session-related HTTP requests
 type SessionHandler struct {
 	sessionManager *terminal.Manager
+	hub            *ws.Hub
+	roles          *auth.Roles
+	metrics        *monitoring.ServerMetrics
 }
 
-// NewSessionHandler creates a new session handler
-func NewSessionHandler(sessionManager *terminal.Manager) *SessionHandler {
+// NewSessionHandler creates a new session handler. With roles, only users
+// whose role allows it create sessions and change or end them.
+func NewSessionHandler(sessionManager *terminal.Manager, hub *ws.Hub, roles *auth.Roles) *SessionHandler {
 	return &SessionHandler{
 		sessionManager: sessionManager,
+		hub:            hub,
+		roles:          roles,
 	}
 }
 
+// SetMetrics records session creation in the server's metrics
+func (sh *SessionHandler) SetMetrics(metrics *monitoring.ServerMetrics) {
+	sh.metrics = metrics
+}
+
+// snapshot copies a session for a response, with its current client count
+func (sh *SessionHandler) snapshot(session *types.Session) types.SessionSnapshot {
+	return types.NewSessionSnapshot(session, sh.hub.GetClientCount(session.ID))
+}
+
 // CreateSession handles POST /api/sessions
 func (sh *SessionHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
 	logrus.WithFields(logrus.Fields{
@@ -29,6 +60,7 @@ func (sh *SessionHandler) CreateSession(w http.ResponseWriter, r *http.Request)
 		"path":        r.URL.Path,
 		"remote_addr": r.RemoteAddr,
 	}).Info("Create session request")
+	received := time.Now()
 
 	// Parse request body
 	var req types.SessionCreateRequest
@@ -38,18 +70,38 @@ func (sh *SessionHandler) CreateSession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Sessions are owned by the requesting identity
+	identity := auth.FromContext(r.Context())
+	if !sh.roles.CanCreate(identity.User) {
+		http.Error(w, "Your role does not allow creating sessions", http.StatusForbidden)
+		return
+	}
+	req.Owner = identity.User
+	req.Tenant = identity.Tenant
+	req.ClientIP = clientIP(r)
+	req.ReceivedAt = received
+
 	// Create session
-	session, err := sh.sessionManager.CreateSession(&req)
+	start := time.Now()
+	session, err := sh.sessionManager.CreateSession(r.Context(), &req)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to create session")
-		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		writeCreateError(w, err)
 		return
 	}
 
+	sh.metrics.SessionCreated(session.ID, string(session.Backend), userClass(sh.roles, identity.User), time.Since(start))
+
+	// Sessions awaiting approval have been accepted but not yet started
+	status := http.StatusCreated
+	if session.Status == types.SessionStatusPending {
+		status = http.StatusAccepted
+	}
+
 	// Return session details
-	response := types.SessionResponse{Session: *session}
+	response := types.SessionResponse{Session: sh.snapshot(session)}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		logrus.WithError(err).Error("Failed to encode session response")
@@ -59,6 +111,116 @@ func (sh *SessionHandler) CreateSession(w http.ResponseWriter, r *http.Request)
 	logrus.WithField("session_id", session.ID).Info("Session created successfully")
 }
 
+// OpenHomeSession handles POST /api/sessions/home, returning the caller's
+// home session and creating it from the home profile on their first visit.
+// The body is optional and takes the same fields as POST /api/sessions,
+// which apply only when the session is created.
+func (sh *SessionHandler) OpenHomeSession(w http.ResponseWriter, r *http.Request) {
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Open home session request")
+	received := time.Now()
+
+	var req types.SessionCreateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	identity := auth.FromContext(r.Context())
+	if !sh.roles.CanCreate(identity.User) {
+		http.Error(w, "Your role does not allow creating sessions", http.StatusForbidden)
+		return
+	}
+	req.Owner = identity.User
+	req.Tenant = identity.Tenant
+	req.ClientIP = clientIP(r)
+	req.ReceivedAt = received
+
+	start := time.Now()
+	session, created, err := sh.sessionManager.HomeSession(r.Context(), &req)
+	if errors.Is(err, terminal.ErrHomeSessionsDisabled) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to create home session")
+		writeCreateError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		sh.metrics.SessionCreated(session.ID, string(session.Backend), userClass(sh.roles, identity.User), time.Since(start))
+		status = http.StatusCreated
+		if session.Status == types.SessionStatusPending {
+			status = http.StatusAccepted
+		}
+	}
+
+	response := types.SessionResponse{Session: sh.snapshot(session)}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode session response")
+	}
+}
+
+// writeCreateError responds to a request whose session could not be created
+func writeCreateError(w http.ResponseWriter, err error) {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		apperrors.WriteErrorResponse(w, appErr)
+		return
+	}
+	if errors.Is(err, accounting.ErrQuotaExceeded) {
+		http.Error(w, "Usage quota exceeded", http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, terminal.ErrInvalidPriority) || errors.Is(err, terminal.ErrInvalidMetadata) ||
+		errors.Is(err, terminal.ErrInvalidName) || errors.Is(err, terminal.ErrInvalidLabels) ||
+		errors.Is(err, terminal.ErrInvalidCallback) || errors.Is(err, terminal.ErrPTYRequired) ||
+		errors.Is(err, terminal.ErrInvalidWallTime) || errors.Is(err, terminal.ErrInvalidKerberosTicket) ||
+		errors.Is(err, terminal.ErrDisplayUnavailable) || errors.Is(err, terminal.ErrInvalidOutputBuffering) ||
+		errors.Is(err, terminal.ErrInvalidEnv) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, terminal.ErrShellNotAllowed) || errors.Is(err, terminal.ErrCommandNotAllowed) ||
+		errors.Is(err, terminal.ErrEnvNotAllowed) || errors.Is(err, terminal.ErrWorkingDirNotAllowed) ||
+		errors.Is(err, terminal.ErrNoAccount) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, terminal.ErrFeatureUnavailable) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if errors.Is(err, maintenance.ErrSessionsBlocked) {
+		http.Error(w, "Server is about to go down for maintenance", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, diskwatch.ErrLowDiskSpace) {
+		http.Error(w, "Server is low on disk space", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, "Failed to create session", http.StatusInternalServerError)
+}
+
+// clientIP returns the IP address a request came from, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // ListSessions handles GET /api/sessions
 func (sh *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 	logrus.WithFields(logrus.Fields{
@@ -67,18 +229,52 @@ func (sh *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 		"remote_addr": r.RemoteAddr,
 	}).Info("List sessions request")
 
-	// Get all sessions
+	filter, err := sessionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	order, err := terminal.ParseSessionSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, err := listPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Keep sessions selected by the filter, in order
 	sessions := sh.sessionManager.ListSessions()
+	matching := sessions[:0]
+	retained := 0
+	for _, session := range sessions {
+		if filter.Matches(session) {
+			matching = append(matching, session)
+			if session.RetainedUntil != nil {
+				retained++
+			}
+		}
+	}
+	order.Sort(matching)
 
-	// Convert to response format
-	sessionList := make([]types.Session, len(sessions))
-	for i, session := range sessions {
-		sessionList[i] = *session
+	// Convert the requested page to response format
+	page := matching[min(offset, len(matching)):min(offset+limit, len(matching))]
+	sessionList := make([]types.SessionSnapshot, 0, len(page))
+	for _, session := range page {
+		sessionList = append(sessionList, sh.snapshot(session))
 	}
 
 	response := types.SessionListResponse{
 		Sessions: sessionList,
 		Count:    len(sessionList),
+		Total:    len(matching),
+		Retained: retained,
+		Limit:    limit,
+		Offset:   offset,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -93,6 +289,69 @@ func (sh *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 	logrus.WithField("session_count", len(sessionList)).Debug("Sessions listed successfully")
 }
 
+// Page sizes of session lists
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// listPage parses the limit and offset query parameters of a list
+func listPage(r *http.Request) (limit, offset int, err error) {
+	query := r.URL.Query()
+
+	limit = defaultListLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// sessionFilter collects metadata.<key>=<value>, label=<key>[=<value>] and
+// status=<status> query parameters
+func sessionFilter(r *http.Request) (*terminal.SessionFilter, error) {
+	filter := &terminal.SessionFilter{Metadata: make(map[string]string)}
+	for param, values := range r.URL.Query() {
+		if key := strings.TrimPrefix(param, "metadata."); key != param && len(values) > 0 {
+			filter.Metadata[key] = values[0]
+		}
+	}
+
+	for _, value := range r.URL.Query()["label"] {
+		selector, err := terminal.ParseLabelSelector(value)
+		if err != nil {
+			return nil, err
+		}
+		filter.Labels = append(filter.Labels, selector)
+	}
+
+	for _, value := range r.URL.Query()["status"] {
+		for _, status := range strings.Split(value, ",") {
+			filter.Statuses = append(filter.Statuses, types.SessionStatus(status))
+		}
+	}
+
+	if value := r.URL.Query().Get("retained"); value != "" {
+		retained, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retained parameter: %s", value)
+		}
+		filter.Retained = &retained
+	}
+
+	return filter, nil
+}
+
 // GetSession handles GET /api/sessions/{id}
 func (sh *SessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -114,7 +373,7 @@ func (sh *SessionHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return session details
-	response := types.SessionResponse{Session: *session}
+	response := types.SessionResponse{Session: sh.snapshot(session)}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
@@ -139,8 +398,16 @@ func (sh *SessionHandler) TerminateSession(w http.ResponseWriter, r *http.Reques
 		"remote_addr": r.RemoteAddr,
 	}).Info("Terminate session request")
 
+	if !requireManage(w, r, sh.roles, sh.sessionManager, sessionID) {
+		return
+	}
+
 	// Terminate session
-	if err := sh.sessionManager.TerminateSession(sessionID); err != nil {
+	if err := sh.sessionManager.TerminateSession(r.Context(), sessionID); err != nil {
+		if errors.Is(err, terminal.ErrSessionEnded) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to terminate session")
 		http.Error(w, "Failed to terminate session", http.StatusInternalServerError)
 		return
@@ -152,14 +419,321 @@ func (sh *SessionHandler) TerminateSession(w http.ResponseWriter, r *http.Reques
 	logrus.WithField("session_id", sessionID).Info("Session terminated successfully")
 }
 
+// UpdateSession handles PATCH /api/sessions/{id}
+func (sh *SessionHandler) UpdateSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Update session request")
+
+	var req types.SessionUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Priority == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	if !requireManage(w, r, sh.roles, sh.sessionManager, sessionID) {
+		return
+	}
+
+	if err := sh.sessionManager.SetSessionPriority(sessionID, req.Priority); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to update session")
+		if errors.Is(err, terminal.ErrInvalidPriority) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update session: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	session, err := sh.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(types.SessionResponse{Session: sh.snapshot(session)}); err != nil {
+		logrus.WithError(err).Error("Failed to encode session response")
+	}
+}
+
+// PauseSession handles POST /api/sessions/{id}/pause
+func (sh *SessionHandler) PauseSession(w http.ResponseWriter, r *http.Request) {
+	sh.changeRunState(w, r, "pause", sh.sessionManager.PauseSession)
+}
+
+// ResumeSession handles POST /api/sessions/{id}/resume
+func (sh *SessionHandler) ResumeSession(w http.ResponseWriter, r *http.Request) {
+	sh.changeRunState(w, r, "resume", sh.sessionManager.ResumeSession)
+}
+
+// ResizeSession handles POST /api/sessions/{id}/resize, for clients that
+// set the terminal size without a WebSocket
+func (sh *SessionHandler) ResizeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Resize session request")
+
+	var req types.SessionResizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Rows <= 0 || req.Cols <= 0 || req.Rows > math.MaxUint16 || req.Cols > math.MaxUint16 {
+		http.Error(w, fmt.Sprintf("Invalid dimensions: rows and cols must be between 1 and %d", math.MaxUint16), http.StatusBadRequest)
+		return
+	}
+
+	if !requireManage(w, r, sh.roles, sh.sessionManager, sessionID) {
+		return
+	}
+
+	if err := sh.sessionManager.ResizeSession(sessionID, uint16(req.Rows), uint16(req.Cols)); err != nil {
+		if errors.Is(err, terminal.ErrResizeUnavailable) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to resize session")
+		http.Error(w, "Failed to resize session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SignalSession handles POST /api/sessions/{id}/signal
+func (sh *SessionHandler) SignalSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Signal session request")
+
+	var req types.SessionSignalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !requireManage(w, r, sh.roles, sh.sessionManager, sessionID) {
+		return
+	}
+
+	if err := sh.sessionManager.SignalSession(sessionID, req.Signal); err != nil {
+		if errors.Is(err, terminal.ErrInvalidSignal) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, terminal.ErrSignalUnavailable) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to signal session")
+		http.Error(w, "Failed to signal session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetScreen handles GET /api/sessions/{id}/screen
+func (sh *SessionHandler) GetScreen(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Debug("Get screen request")
+
+	if _, err := sh.sessionManager.GetSession(sessionID); err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	// Locked sessions are blanked, so their screens are not shown either
+	if sh.hub.IsLocked(sessionID) {
+		http.Error(w, "Session is locked", http.StatusLocked)
+		return
+	}
+
+	screen, err := sh.sessionManager.Screen(sessionID)
+	if err != nil {
+		if errors.Is(err, terminal.ErrNoScreen) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	cells, _ := strconv.ParseBool(r.URL.Query().Get("cells"))
+	snapshot := screen.Snapshot(cells)
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, line := range snapshot.Lines {
+			fmt.Fprintln(w, line)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		logrus.WithError(err).Error("Failed to encode screen response")
+	}
+}
+
+// GetScreenshot handles GET /api/sessions/{id}/screenshot, a preview of the
+// session's screen as a PNG thumbnail, or as text with format=text
+func (sh *SessionHandler) GetScreenshot(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	screen, err := sh.sessionManager.Screen(sessionID)
+	if err != nil {
+		if errors.Is(err, terminal.ErrNoScreen) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if sh.hub.IsLocked(sessionID) {
+		http.Error(w, "Session is locked", http.StatusLocked)
+		return
+	}
+
+	// Previews are refreshed often, so they must not be cached
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch query.Get("format") {
+	case "", "png":
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, line := range screen.Snapshot(false).Lines {
+			fmt.Fprintln(w, line)
+		}
+		return
+	default:
+		http.Error(w, "Invalid format: must be png or text", http.StatusBadRequest)
+		return
+	}
+
+	scale := 2
+	if value := query.Get("scale"); value != "" {
+		scale, err = strconv.Atoi(value)
+		if err != nil || scale < 1 || scale > terminal.MaxThumbnailScale {
+			http.Error(w, fmt.Sprintf("Invalid scale: must be between 1 and %d", terminal.MaxThumbnailScale), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, screen.Thumbnail(scale)); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to encode screenshot")
+	}
+}
+
+// GetTimeline handles GET /api/sessions/{id}/timeline, the session's input,
+// output and commands by minute and its clients attaching and detaching,
+// over the last minutes (60 by default)
+func (sh *SessionHandler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	if _, err := sh.sessionManager.GetSession(sessionID); err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	maxMinutes := int(ws.TimelineWindow / ws.TimelineBucket)
+	minutes := maxMinutes
+	if value := r.URL.Query().Get("minutes"); value != "" {
+		var err error
+		minutes, err = strconv.Atoi(value)
+		if err != nil || minutes < 1 || minutes > maxMinutes {
+			http.Error(w, fmt.Sprintf("Invalid minutes: must be between 1 and %d", maxMinutes), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sh.hub.Timeline(sessionID, time.Duration(minutes)*ws.TimelineBucket)); err != nil {
+		logrus.WithError(err).Error("Failed to encode timeline response")
+	}
+}
+
+// changeRunState pauses or resumes a session and returns its updated state
+func (sh *SessionHandler) changeRunState(w http.ResponseWriter, r *http.Request, action string, change func(string) error) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Session " + action + " request")
+
+	if !requireManage(w, r, sh.roles, sh.sessionManager, sessionID) {
+		return
+	}
+
+	if err := change(sessionID); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to " + action + " session")
+		http.Error(w, "Failed to "+action+" session: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	session, err := sh.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(types.SessionResponse{Session: sh.snapshot(session)}); err != nil {
+		logrus.WithError(err).Error("Failed to encode session response")
+	}
+}
+
 // RegisterRoutes registers all session-related routes
 func (sh *SessionHandler) RegisterRoutes(router *mux.Router) {
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
 	apiRouter.HandleFunc("/sessions", sh.CreateSession).Methods("POST")
 	apiRouter.HandleFunc("/sessions", sh.ListSessions).Methods("GET")
+	apiRouter.HandleFunc("/sessions/home", sh.OpenHomeSession).Methods("POST")
 	apiRouter.HandleFunc("/sessions/{id}", sh.GetSession).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}", sh.UpdateSession).Methods("PATCH")
 	apiRouter.HandleFunc("/sessions/{id}", sh.TerminateSession).Methods("DELETE")
+	apiRouter.HandleFunc("/sessions/{id}/pause", sh.PauseSession).Methods("POST")
+	apiRouter.HandleFunc("/sessions/{id}/resume", sh.ResumeSession).Methods("POST")
+	apiRouter.HandleFunc("/sessions/{id}/resize", sh.ResizeSession).Methods("POST")
+	apiRouter.HandleFunc("/sessions/{id}/signal", sh.SignalSession).Methods("POST")
+	apiRouter.HandleFunc("/sessions/{id}/screen", sh.GetScreen).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/screenshot", sh.GetScreenshot).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/timeline", sh.GetTimeline).Methods("GET")
 
 	logrus.Info("Session routes registered")
 }
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/snippets"
+	"github.com/sirupsen/logrus"
+)
+
+// SnippetRequest represents a request to create or update a snippet
+type SnippetRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Content     string `json:"content"`
+}
+
+// SnippetListResponse represents the response for listing snippets
+type SnippetListResponse struct {
+	Snippets []snippets.Snippet `json:"snippets"`
+	Count    int                `json:"count"`
+}
+
+// SnippetHandler handles each user's stored snippets
+type SnippetHandler struct {
+	store *snippets.Store
+}
+
+// NewSnippetHandler creates a new snippet handler
+func NewSnippetHandler(store *snippets.Store) *SnippetHandler {
+	return &SnippetHandler{
+		store: store,
+	}
+}
+
+// ListSnippets handles GET /api/snippets
+func (sh *SnippetHandler) ListSnippets(w http.ResponseWriter, r *http.Request) {
+	list := sh.store.List(auth.FromContext(r.Context()).User)
+
+	sh.writeJSON(w, http.StatusOK, SnippetListResponse{
+		Snippets: list,
+		Count:    len(list),
+	})
+}
+
+// CreateSnippet handles POST /api/snippets
+func (sh *SnippetHandler) CreateSnippet(w http.ResponseWriter, r *http.Request) {
+	var req SnippetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user := auth.FromContext(r.Context()).User
+
+	snippet, err := sh.store.Create(user, snippets.Snippet{
+		Name:        req.Name,
+		Description: req.Description,
+		Content:     req.Content,
+	})
+	if err != nil {
+		sh.writeError(w, user, err)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user":       user,
+		"snippet_id": snippet.ID,
+	}).Info("Snippet created")
+
+	sh.writeJSON(w, http.StatusCreated, snippet)
+}
+
+// GetSnippet handles GET /api/snippets/{id}
+func (sh *SnippetHandler) GetSnippet(w http.ResponseWriter, r *http.Request) {
+	user := auth.FromContext(r.Context()).User
+
+	snippet, err := sh.store.Get(user, mux.Vars(r)["id"])
+	if err != nil {
+		sh.writeError(w, user, err)
+		return
+	}
+
+	sh.writeJSON(w, http.StatusOK, snippet)
+}
+
+// UpdateSnippet handles PUT /api/snippets/{id}
+func (sh *SnippetHandler) UpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	var req SnippetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user := auth.FromContext(r.Context()).User
+
+	snippet, err := sh.store.Update(user, mux.Vars(r)["id"], snippets.Snippet{
+		Name:        req.Name,
+		Description: req.Description,
+		Content:     req.Content,
+	})
+	if err != nil {
+		sh.writeError(w, user, err)
+		return
+	}
+
+	sh.writeJSON(w, http.StatusOK, snippet)
+}
+
+// DeleteSnippet handles DELETE /api/snippets/{id}
+func (sh *SnippetHandler) DeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	user := auth.FromContext(r.Context()).User
+
+	if err := sh.store.Delete(user, mux.Vars(r)["id"]); err != nil {
+		sh.writeError(w, user, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeError maps snippet store errors to HTTP responses
+func (sh *SnippetHandler) writeError(w http.ResponseWriter, user string, err error) {
+	switch {
+	case errors.Is(err, snippets.ErrNotFound):
+		http.Error(w, "Snippet not found", http.StatusNotFound)
+	case errors.Is(err, snippets.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, snippets.ErrLimitReached):
+		http.Error(w, "Snippet limit reached", http.StatusConflict)
+	default:
+		logrus.WithError(err).WithField("user", user).Error("Failed to store snippet")
+		http.Error(w, "Failed to store snippet", http.StatusInternalServerError)
+	}
+}
+
+// writeJSON writes a response as JSON
+func (sh *SnippetHandler) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode snippet response")
+	}
+}
+
+// RegisterRoutes registers all snippet routes
+func (sh *SnippetHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/snippets", sh.ListSnippets).Methods("GET")
+	apiRouter.HandleFunc("/snippets", sh.CreateSnippet).Methods("POST")
+	apiRouter.HandleFunc("/snippets/{id}", sh.GetSnippet).Methods("GET")
+	apiRouter.HandleFunc("/snippets/{id}", sh.UpdateSnippet).Methods("PUT")
+	apiRouter.HandleFunc("/snippets/{id}", sh.DeleteSnippet).Methods("DELETE")
+
+	logrus.Info("Snippet routes registered")
+}
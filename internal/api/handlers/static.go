@@ -82,3 +82,32 @@ func (s *StaticHandler) ServeIndex(w http.ResponseWriter, r *http.Request) {
 
 	http.ServeFile(w, r, indexPath)
 }
+
+// ServeWatch serves the read-only broadcast viewer page
+func (s *StaticHandler) ServeWatch(w http.ResponseWriter, r *http.Request) {
+	watchPath := filepath.Join(s.staticDir, "watch.html")
+
+	if _, err := os.Stat(watchPath); os.IsNotExist(err) {
+		logrus.WithField("path", watchPath).Error("Watch page not found")
+		http.Error(w, "Watch page not found", http.StatusNotFound)
+		return
+	}
+
+	// Keep the broadcast token out of third-party referrers
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	http.ServeFile(w, r, watchPath)
+}
+
+// ServeAdmin serves the operator dashboard page
+func (s *StaticHandler) ServeAdmin(w http.ResponseWriter, r *http.Request) {
+	adminPath := filepath.Join(s.staticDir, "admin.html")
+
+	if _, err := os.Stat(adminPath); os.IsNotExist(err) {
+		logrus.WithField("path", adminPath).Error("Admin page not found")
+		http.Error(w, "Admin page not found", http.StatusNotFound)
+		return
+	}
+
+	http.ServeFile(w, r, adminPath)
+}
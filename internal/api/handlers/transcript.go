@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/terminal/recording"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// TranscriptHandler handles requests to download session output and to
+// download or play back recordings
+type TranscriptHandler struct {
+	sessionManager *terminal.Manager
+	isAdmin        func(user string) bool
+	auditor        *audit.Logger
+}
+
+// NewTranscriptHandler creates a new transcript handler
+func NewTranscriptHandler(sessionManager *terminal.Manager, isAdmin func(user string) bool, auditor *audit.Logger) *TranscriptHandler {
+	return &TranscriptHandler{
+		sessionManager: sessionManager,
+		isAdmin:        isAdmin,
+		auditor:        auditor,
+	}
+}
+
+// DownloadTranscript handles GET /api/sessions/{id}/transcript, sending the
+// output of a session's shell, decrypted if it is encrypted at rest
+func (th *TranscriptHandler) DownloadTranscript(w http.ResponseWriter, r *http.Request) {
+	session, ok := th.authorize(w, r, audit.EventTranscriptDownloaded)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.log"`, session.ID))
+
+	// Once output has been sent, a failure can only cut the download short
+	if err := th.sessionManager.WriteTranscript(w, session.ID); err != nil {
+		logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to send transcript")
+	}
+}
+
+// DownloadRecording handles GET /api/sessions/{id}/recording, sending the
+// asciicast recording of a session
+func (th *TranscriptHandler) DownloadRecording(w http.ResponseWriter, r *http.Request) {
+	session, ok := th.authorize(w, r, audit.EventRecordingDownloaded)
+	if !ok {
+		return
+	}
+
+	if !session.Recording {
+		http.Error(w, "Session is not recorded", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", recording.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.cast"`, session.ID))
+
+	if err := th.sessionManager.WriteRecording(w, session.ID); err != nil {
+		logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to send recording")
+	}
+}
+
+// authorize lets the session owner and admins download what a session
+// wrote, recording the download as an audit event
+func (th *TranscriptHandler) authorize(w http.ResponseWriter, r *http.Request, eventType string) (*types.Session, bool) {
+	sessionID := mux.Vars(r)["id"]
+
+	session, err := th.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	user := auth.FromContext(r.Context()).User
+	if session.Owner != user && !th.isAdmin(user) {
+		http.Error(w, "Only the session owner or an admin can download its output", http.StatusForbidden)
+		return nil, false
+	}
+
+	th.auditor.Log(audit.Event{
+		Type:       eventType,
+		User:       user,
+		RemoteAddr: r.RemoteAddr,
+		Details: map[string]interface{}{
+			"session_id": sessionID,
+			"owner":      session.Owner,
+		},
+	})
+	return session, true
+}
+
+// RegisterRoutes registers the transcript routes
+func (th *TranscriptHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/sessions/{id}/transcript", th.DownloadTranscript).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/recording", th.DownloadRecording).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/recording/play", th.PlayRecording).Methods("GET")
+
+	logrus.Info("Transcript routes registered")
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/snippets"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	ws "github.com/piyushgupta53/webterm/internal/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// UserDataResponse lists the data held about a user that was deleted, or
+// that would be in a dry run
+type UserDataResponse struct {
+	User         string   `json:"user"`
+	DryRun       bool     `json:"dry_run"`
+	Sessions     []string `json:"sessions"`
+	Transcripts  []string `json:"transcripts"` // Output files of the sessions and their panes
+	Captures     []string `json:"captures"`
+	Snippets     []string `json:"snippets"`
+	AuditEntries int      `json:"audit_entries"`
+}
+
+// UserDataHandler handles requests to erase the data held about a user
+type UserDataHandler struct {
+	sessionManager *terminal.Manager
+	hub            *ws.Hub
+	snippetStore   *snippets.Store
+	isAdmin        func(user string) bool
+	auditor        *audit.Logger
+}
+
+// NewUserDataHandler creates a new user data handler
+func NewUserDataHandler(sessionManager *terminal.Manager, hub *ws.Hub, snippetStore *snippets.Store, isAdmin func(user string) bool, auditor *audit.Logger) *UserDataHandler {
+	return &UserDataHandler{
+		sessionManager: sessionManager,
+		hub:            hub,
+		snippetStore:   snippetStore,
+		isAdmin:        isAdmin,
+		auditor:        auditor,
+	}
+}
+
+// PurgeUserData handles DELETE /api/users/{id}/data, ending and deleting
+// the user's sessions, their output and captures, the user's snippets and
+// the audit entries about the user. With ?dry_run=true it only lists them.
+func (uh *UserDataHandler) PurgeUserData(w http.ResponseWriter, r *http.Request) {
+	user := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"user":        user,
+		"remote_addr": r.RemoteAddr,
+	}).Info("Purge user data request")
+
+	admin := auth.FromContext(r.Context()).User
+	if !uh.isAdmin(admin) {
+		http.Error(w, "Admin privileges required", http.StatusForbidden)
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid dry_run parameter", http.StatusBadRequest)
+			return
+		}
+		dryRun = parsed
+	}
+
+	response := UserDataResponse{
+		User:        user,
+		DryRun:      dryRun,
+		Sessions:    []string{},
+		Transcripts: []string{},
+		Snippets:    []string{},
+	}
+
+	for _, session := range uh.sessionManager.OwnedSessions(user) {
+		response.Sessions = append(response.Sessions, session.ID)
+		response.Transcripts = append(response.Transcripts, uh.sessionManager.SessionTranscripts(session.ID)...)
+	}
+	sort.Strings(response.Sessions)
+	sort.Strings(response.Transcripts)
+
+	for _, snippet := range uh.snippetStore.List(user) {
+		response.Snippets = append(response.Snippets, snippet.ID)
+	}
+
+	if !dryRun {
+		for _, sessionID := range response.Sessions {
+			if err := uh.sessionManager.PurgeSession(sessionID); err != nil {
+				logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to purge session")
+				http.Error(w, "Failed to delete sessions", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := uh.snippetStore.DeleteAll(user); err != nil {
+			logrus.WithError(err).WithField("user", user).Error("Failed to delete snippets")
+			http.Error(w, "Failed to delete snippets", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Captures are purged after the sessions, so none can start in between
+	response.Captures = uh.hub.PurgeCaptures(user, dryRun)
+
+	purged, err := uh.auditor.Purge(user, dryRun)
+	if err != nil {
+		logrus.WithError(err).WithField("user", user).Error("Failed to purge audit entries")
+		http.Error(w, "Failed to delete audit entries", http.StatusInternalServerError)
+		return
+	}
+	response.AuditEntries = purged
+
+	// The purge itself is recorded, without the deleted data
+	if !dryRun {
+		uh.auditor.Log(audit.Event{
+			Type:       audit.EventUserDataPurged,
+			User:       admin,
+			RemoteAddr: r.RemoteAddr,
+			Details: map[string]interface{}{
+				"purged_user":   user,
+				"sessions":      len(response.Sessions),
+				"transcripts":   len(response.Transcripts),
+				"captures":      len(response.Captures),
+				"snippets":      len(response.Snippets),
+				"audit_entries": response.AuditEntries,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to encode user data response")
+	}
+}
+
+// RegisterRoutes registers the user data routes
+func (uh *UserDataHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/users/{id}/data", uh.PurgeUserData).Methods("DELETE")
+
+	logrus.Info("User data routes registered")
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pion/webrtc/v4"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/rtc"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	ws "github.com/piyushgupta53/webterm/internal/websocket"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WebRTCConfigResponse tells the browser how to build its peer connection
+type WebRTCConfigResponse struct {
+	ICEServers   []string `json:"ice_servers"`
+	ChannelLabel string   `json:"channel_label"`
+}
+
+// WebRTCHandler handles signaling for the WebRTC data channel transport
+type WebRTCHandler struct {
+	sessionManager *terminal.Manager
+	hub            *ws.Hub
+	answerer       *rtc.Answerer
+}
+
+// NewWebRTCHandler creates a new WebRTC signaling handler
+func NewWebRTCHandler(sessionManager *terminal.Manager, hub *ws.Hub, answerer *rtc.Answerer) *WebRTCHandler {
+	return &WebRTCHandler{
+		sessionManager: sessionManager,
+		hub:            hub,
+		answerer:       answerer,
+	}
+}
+
+// GetConfig handles GET /api/webrtc
+func (wh *WebRTCHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	iceServers := wh.answerer.ICEServers()
+	if iceServers == nil {
+		iceServers = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(WebRTCConfigResponse{
+		ICEServers:   iceServers,
+		ChannelLabel: rtc.DataChannelLabel,
+	}); err != nil {
+		logrus.WithError(err).Error("Failed to encode WebRTC config response")
+	}
+}
+
+// Offer handles POST /api/sessions/{id}/webrtc
+func (wh *WebRTCHandler) Offer(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	logrus.WithFields(logrus.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("WebRTC offer request")
+
+	session, err := wh.sessionManager.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if session.Status != types.SessionStatusRunning {
+		http.Error(w, "Session is not running", http.StatusConflict)
+		return
+	}
+
+	var offer webrtc.SessionDescription
+	if err := json.NewDecoder(r.Body).Decode(&offer); err != nil {
+		logrus.WithError(err).Error("Failed to decode WebRTC offer")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if offer.Type != webrtc.SDPTypeOffer {
+		http.Error(w, "Expected an SDP offer", http.StatusBadRequest)
+		return
+	}
+
+	userAgent := r.UserAgent()
+	user := auth.FromContext(r.Context()).User
+	traceLink := trace.SpanContextFromContext(r.Context())
+	answer, err := wh.answerer.Answer(offer, r.RemoteAddr, func(transport *rtc.DataChannelTransport) {
+		clientID := uuid.New().String()
+		client := ws.NewTransportClient(transport, wh.hub, sessionID, clientID, userAgent)
+		client.SetUser(user)
+		client.SetTraceLink(traceLink)
+
+		wh.hub.RegisterClient(client)
+		go client.Run()
+
+		logrus.WithFields(logrus.Fields{
+			"client_id":   clientID,
+			"session_id":  sessionID,
+			"remote_addr": transport.RemoteAddr(),
+		}).Info("WebRTC client connected successfully")
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to answer WebRTC offer")
+		http.Error(w, "Failed to negotiate WebRTC connection", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(answer); err != nil {
+		logrus.WithError(err).Error("Failed to encode WebRTC answer")
+	}
+}
+
+// RegisterRoutes registers all WebRTC signaling routes
+func (wh *WebRTCHandler) RegisterRoutes(router *mux.Router) {
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	apiRouter.HandleFunc("/webrtc", wh.GetConfig).Methods("GET")
+	apiRouter.HandleFunc("/sessions/{id}/webrtc", wh.Offer).Methods("POST")
+
+	logrus.Info("WebRTC routes registered")
+}
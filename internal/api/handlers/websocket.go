@@ -2,38 +2,51 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/piyushgupta53/webterm/internal/auth"
 	ws "github.com/piyushgupta53/webterm/internal/websocket"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// allow all in dev
-		// imlpement origin check in production
-		return true
-	},
-}
-
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
-	hub *ws.Hub
+	hub      *ws.Hub
+	upgrader websocket.Upgrader
 }
 
-// NewWebSocketHandler creates a new WebSocket handler
-func NewWebSocketHandler(hub *ws.Hub) *WebSocketHandler {
+// NewWebSocketHandler creates a new WebSocket handler accepting connections
+// from the pages origins allows
+func NewWebSocketHandler(hub *ws.Hub, origins *OriginPolicy) *WebSocketHandler {
 	return &WebSocketHandler{
 		hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     origins.Check,
+		},
 	}
 }
 
 func (wsh *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Broadcast viewers identify the session by its broadcast token
+	broadcastToken := r.URL.Query().Get("broadcast")
+
 	// Get session ID from query parameters
 	sessionID := r.URL.Query().Get("session")
+	if broadcastToken != "" {
+		resolved, err := wsh.hub.ResolveBroadcast(broadcastToken)
+		if err != nil {
+			logrus.WithField("remote_addr", r.RemoteAddr).Warn("Invalid broadcast token in WebSocket request")
+			http.Error(w, "Broadcast not found", http.StatusNotFound)
+			return
+		}
+		sessionID = resolved
+	}
+
 	if sessionID == "" {
 		logrus.WithField("remote_addr", r.RemoteAddr).Error("Missing session ID in WebSocket request")
 		http.Error(w, "Missing session parameter", http.StatusBadRequest)
@@ -47,7 +60,7 @@ func (wsh *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Requ
 	}).Info("WebSocket upgrade request")
 
 	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := wsh.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
 			"session_id":  sessionID,
@@ -60,7 +73,17 @@ func (wsh *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Requ
 	clientID := uuid.New().String()
 
 	// Create new client
-	client := ws.NewClient(conn, wsh.hub, sessionID, clientID, r.UserAgent())
+	var client *ws.Client
+	if broadcastToken != "" {
+		client = ws.NewBroadcastViewer(conn, wsh.hub, sessionID, clientID, r.UserAgent())
+	} else {
+		client = ws.NewClient(conn, wsh.hub, sessionID, clientID, r.UserAgent())
+		client.SetUser(auth.FromContext(r.Context()).User)
+		setMode(client, r)
+		setResume(client, r)
+	}
+	setOutputOptions(client, r)
+	setTraceLink(client, r)
 
 	// Register new client
 	wsh.hub.RegisterClient(client)
@@ -75,6 +98,47 @@ func (wsh *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Requ
 	}).Info("WebSocket client connected successfully")
 }
 
+// setResume has a reconnecting client resume from the token and output
+// offsets in its request, if any
+func setResume(client *ws.Client, r *http.Request) {
+	query := r.URL.Query()
+	token := query.Get("resume")
+	if token == "" {
+		return
+	}
+
+	offset, _ := strconv.ParseInt(query.Get("offset"), 10, 64)
+	stderrOffset, _ := strconv.ParseInt(query.Get("stderr_offset"), 10, 64)
+	client.SetResume(token, offset, stderrOffset)
+}
+
+// setMode has the client attach read-only if it asked with mode=readonly
+func setMode(client *ws.Client, r *http.Request) {
+	if r.URL.Query().Get("mode") == "readonly" {
+		client.SetReadOnly()
+	}
+}
+
+// setOutputOptions has inline images sent to the client as image messages
+// if it asked for them with images=true, and screen updates sent instead of
+// output with screen=diff or screen=cells
+func setOutputOptions(client *ws.Client, r *http.Request) {
+	query := r.URL.Query()
+	if enabled, _ := strconv.ParseBool(query.Get("images")); enabled {
+		client.EnableInlineImages()
+	}
+	switch updates := ws.ScreenUpdates(query.Get("screen")); updates {
+	case ws.ScreenUpdatesOutput, ws.ScreenUpdatesCells:
+		client.SetScreenUpdates(updates)
+	}
+}
+
+// setTraceLink links the spans of the client's messages to the trace of its
+// connection request
+func setTraceLink(client *ws.Client, r *http.Request) {
+	client.SetTraceLink(trace.SpanContextFromContext(r.Context()))
+}
+
 // ServeHTTP implements http.Handler
 func (wsh *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	wsh.HandleWebSocket(w, r)
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	ws "github.com/piyushgupta53/webterm/internal/websocket"
+	"github.com/piyushgupta53/webterm/internal/webtransport"
+	wt "github.com/quic-go/webtransport-go"
+	"github.com/sirupsen/logrus"
+)
+
+// acceptStreamTimeout bounds how long a new session waits for the browser's stream
+const acceptStreamTimeout = 10 * time.Second
+
+// WebTransportHandler handles WebTransport sessions over HTTP/3
+type WebTransportHandler struct {
+	hub    *ws.Hub
+	server *wt.Server
+}
+
+// NewWebTransportHandler creates a new WebTransport handler
+func NewWebTransportHandler(hub *ws.Hub, server *wt.Server) *WebTransportHandler {
+	return &WebTransportHandler{
+		hub:    hub,
+		server: server,
+	}
+}
+
+// HandleWebTransport upgrades an extended CONNECT request to a WebTransport
+// session and attaches the browser's first bidirectional stream to the session
+func (wth *WebTransportHandler) HandleWebTransport(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session")
+	if sessionID == "" {
+		logrus.WithField("remote_addr", r.RemoteAddr).Error("Missing session ID in WebTransport request")
+		http.Error(w, "Missing session parameter", http.StatusBadRequest)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+		"user_agent":  r.UserAgent(),
+	}).Info("WebTransport upgrade request")
+
+	session, err := wth.server.Upgrade(w, r)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"session_id":  sessionID,
+			"remote_addr": r.RemoteAddr,
+		}).Error("Failed to upgrade WebTransport session")
+		http.Error(w, "WebTransport upgrade failed", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(session.Context(), acceptStreamTimeout)
+	stream, err := session.AcceptStream(ctx)
+	cancel()
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("WebTransport client did not open a stream")
+		session.CloseWithError(0, "no stream opened")
+		return
+	}
+
+	// Generate unique client ID
+	clientID := uuid.New().String()
+
+	client := ws.NewTransportClient(webtransport.NewTransport(session, stream), wth.hub, sessionID, clientID, r.UserAgent())
+	client.SetUser(auth.FromContext(r.Context()).User)
+	setMode(client, r)
+	setResume(client, r)
+	setOutputOptions(client, r)
+	setTraceLink(client, r)
+
+	// Register new client
+	wth.hub.RegisterClient(client)
+
+	logrus.WithFields(logrus.Fields{
+		"client_id":   clientID,
+		"session_id":  sessionID,
+		"remote_addr": r.RemoteAddr,
+	}).Info("WebTransport client connected")
+
+	// Serve the client until the session ends
+	client.Run()
+}
+
+// ServeHTTP implements http.Handler
+func (wth *WebTransportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wth.HandleWebTransport(w, r)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/piyushgupta53/webterm/internal/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+)
+
+// listen opens the network listener described by lc, wrapping it in TLS if configured
+func (s *Server) listen(lc config.ListenerConfig) (net.Listener, error) {
+	if lc.Network == "unix" {
+		if err := removeStaleSocket(lc.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen(lc.Network, lc.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if !lc.TLS {
+		return listener, nil
+	}
+
+	tlsConfig, err := s.tlsConfig(lc)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return tls.NewListener(listener, tlsConfig), nil
+}
+
+// removeStaleSocket removes a socket left behind at path by an unclean
+// shutdown. Anything else at path is left alone, so a mistyped path cannot
+// delete a file.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect socket path: %w", err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return nil
+}
+
+// tlsConfig builds the TLS configuration for a listener, using ACME
+// certificates when the listener has no certificate files
+func (s *Server) tlsConfig(lc config.ListenerConfig) (*tls.Config, error) {
+	var tlsConfig *tls.Config
+
+	if lc.CertFile == "" && s.certManager != nil {
+		tlsConfig = &tls.Config{
+			GetCertificate: s.certManager.GetCertificate,
+			// acme.ALPNProto answers TLS-ALPN-01 challenges on this listener
+			NextProtos: []string{"h2", "http/1.1", acme.ALPNProto},
+			MinVersion: tls.VersionTLS12,
+		}
+	} else {
+		certificate, err := tls.LoadX509KeyPair(lc.CertFile, lc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			NextProtos:   []string{"h2", "http/1.1"},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	if s.config.TLSClientCAFile != "" {
+		clientCAs, err := loadCertPool(s.config.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client CA: %w", err)
+		}
+
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if s.config.TLSClientAuth == "optional" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// loadCertPool reads PEM certificates from path into a pool
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// serveListeners opens every configured listener and serves HTTP on it,
+// reporting serve errors on errs
+func (s *Server) serveListeners(errs chan<- error) error {
+	listeners := make([]net.Listener, 0, len(s.config.Listeners))
+
+	for _, lc := range s.config.Listeners {
+		listener, err := s.listen(lc)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", lc, err)
+		}
+		listeners = append(listeners, listener)
+
+		logrus.WithField("listener", lc.String()).Info("Listening")
+	}
+
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			errs <- s.httpServer.Serve(listener)
+		}(listener)
+	}
+
+	return nil
+}
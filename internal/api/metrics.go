@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/monitoring"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	ws "github.com/piyushgupta53/webterm/internal/websocket"
+)
+
+// Metrics returns the metrics the server exports for Prometheus
+func (s *Server) Metrics() *monitoring.ServerMetrics {
+	return s.metrics
+}
+
+// registerSessionGauges exports the sessions and their clients, read from
+// the manager and hub as metrics are scraped
+func registerSessionGauges(metrics *monitoring.ServerMetrics, sessionManager *terminal.Manager, wsHub *ws.Hub) {
+	metrics.Registry.NewGaugeFunc("webterm_sessions",
+		"Sessions, by backend and status.",
+		func() []monitoring.GaugeSample {
+			counts := make(map[[2]string]float64)
+			for _, session := range sessionManager.ListSessions() {
+				counts[[2]string{string(session.Backend), string(session.Status)}]++
+			}
+
+			samples := make([]monitoring.GaugeSample, 0, len(counts))
+			for labels, count := range counts {
+				samples = append(samples, monitoring.GaugeSample{Labels: labels[:], Value: count})
+			}
+			return samples
+		}, "backend", "status")
+
+	metrics.Registry.NewGaugeFunc("webterm_clients",
+		"Clients attached to sessions, by session backend and kind of client.",
+		func() []monitoring.GaugeSample {
+			counts := make(map[[2]string]float64)
+			for _, session := range sessionManager.ListSessions() {
+				backend := string(session.Backend)
+				viewers := wsHub.GetViewerCount(session.ID)
+				counts[[2]string{backend, "terminal"}] += float64(wsHub.GetClientCount(session.ID) - viewers)
+				counts[[2]string{backend, "broadcast_viewer"}] += float64(viewers)
+			}
+
+			samples := make([]monitoring.GaugeSample, 0, len(counts))
+			for labels, count := range counts {
+				samples = append(samples, monitoring.GaugeSample{Labels: labels[:], Value: count})
+			}
+			return samples
+		}, "backend", "kind")
+}
+
+// recordSessionStartup exports how long sessions took to show their first
+// output, and the stages of starting them
+func recordSessionStartup(metrics *monitoring.ServerMetrics, sessionManager *terminal.Manager) {
+	sessionManager.SetStartupCallback(func(timing *terminal.StartupTiming) {
+		metrics.SessionStartup(timing.SessionID, string(timing.Backend), map[string]time.Duration{
+			monitoring.StartupStagePipes:     timing.Pipes,
+			monitoring.StartupStagePTYStart:  timing.PTYStart,
+			monitoring.StartupStageFirstRead: timing.FirstRead,
+		}, timing.TimeToPrompt)
+	})
+}
+
+// metricsMiddleware records the route and latency of requests. Upgraded
+// WebSocket and WebTransport connections are left out, as their duration
+// is how long the terminal was open rather than latency.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" || r.Method == http.MethodConnect {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+
+		route := routeTemplate(r)
+		s.metrics.RequestCompleted(r.Method, route, wrapped.statusCode, routeSessionID(r, route), time.Since(start))
+	})
+}
+
+// routeTemplate returns the template of the route a request matched, which
+// keeps paths with IDs in them to one series or span name each
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
+// routeSessionID returns the session a request is for, if any. Only session
+// routes name sessions by ID; other IDs are of clients and captures.
+func routeSessionID(r *http.Request, route string) string {
+	if strings.Contains(route, "/sessions/{id}") {
+		return mux.Vars(r)["id"]
+	}
+	return ""
+}
@@ -2,27 +2,74 @@ package api
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/accounting"
 	"github.com/piyushgupta53/webterm/internal/api/handlers"
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/piyushgupta53/webterm/internal/auth"
 	"github.com/piyushgupta53/webterm/internal/config"
+	"github.com/piyushgupta53/webterm/internal/diskwatch"
+	"github.com/piyushgupta53/webterm/internal/maintenance"
+	"github.com/piyushgupta53/webterm/internal/rtc"
+	"github.com/piyushgupta53/webterm/internal/snippets"
 	"github.com/piyushgupta53/webterm/internal/terminal"
 	ws "github.com/piyushgupta53/webterm/internal/websocket"
 	"github.com/sirupsen/logrus"
 )
 
 // SetupRoutes configures all HTTP routes
-func SetupRoutes(server *Server, cfg *config.Config, sessionManager *terminal.Manager, wsHub *ws.Hub) {
+func SetupRoutes(server *Server, cfg *config.Config, roles *auth.Roles, sessionManager *terminal.Manager, wsHub *ws.Hub, accountant *accounting.Accountant, auditLogger *audit.Logger, scheduler *maintenance.Scheduler, snippetStore *snippets.Store, diskWatchdog *diskwatch.Watchdog) {
 	router := server.router
 
+	// Admin-only endpoints and role checks agree on who is an admin
+	isAdmin := newAdminCheck(cfg, roles)
+
 	// Create handlers
 	healthHandler := handlers.NewEnhancedHealthHandler("1.0.0")
 	staticHandler := handlers.NewStaticHandler(cfg.StaticDir)
-	sessionHandler := handlers.NewSessionHandler(sessionManager)
-	webSocketHandler := handlers.NewWebSocketHandler(wsHub)
+	sessionHandler := handlers.NewSessionHandler(sessionManager, wsHub, roles)
+	webSocketHandler := handlers.NewWebSocketHandler(wsHub, server.Origins())
+	adminHandler := handlers.NewAdminHandler(accountant, sessionManager, wsHub, scheduler, isAdmin, auditLogger)
+	serverInfoHandler := handlers.NewServerInfoHandler("1.0.0", scheduler, sessionManager.Capabilities())
+	broadcastHandler := handlers.NewBroadcastHandler(sessionManager, wsHub, roles)
+	lockHandler := handlers.NewLockHandler(sessionManager, wsHub, server.Auth())
+	snippetHandler := handlers.NewSnippetHandler(snippetStore)
+	userDataHandler := handlers.NewUserDataHandler(sessionManager, wsHub, snippetStore, isAdmin, auditLogger)
+	transcriptHandler := handlers.NewTranscriptHandler(sessionManager, isAdmin, auditLogger)
+	paneHandler := handlers.NewPaneHandler(sessionManager, roles)
+	pipeHandler := handlers.NewPipeHandler(sessionManager, roles)
+
+	// Export sessions and their creation latency to Prometheus
+	sessionHandler.SetMetrics(server.Metrics())
+	registerSessionGauges(server.Metrics(), sessionManager, wsHub)
+	recordSessionStartup(server.Metrics(), sessionManager)
+
+	// Report broadcast viewers in health metrics
+	healthHandler.SetViewerSource(wsHub)
+
+	// Report degraded state, such as low disk space, to load balancers
+	readyHandler := handlers.NewReadinessHandler()
+	if diskWatchdog != nil {
+		healthHandler.SetDiskSource(diskWatchdog)
+		readyHandler.AddCheck("disk", diskWatchdog.CheckReady)
+	}
+
+	// Health checks, static assets and broadcast viewers need no credentials
+	server.Auth().AllowPublic(func(r *http.Request) bool {
+		return r.URL.Path == "/health" || r.URL.Path == "/readyz" ||
+			strings.HasPrefix(r.URL.Path, "/static/") ||
+			strings.HasPrefix(r.URL.Path, "/watch/") ||
+			(r.URL.Path == "/api/ws" && r.URL.Query().Get("broadcast") != "")
+	})
 
 	// Health check point
 	router.Handle("/health", healthHandler).Methods("GET")
+	router.Handle("/readyz", readyHandler).Methods("GET")
+
+	// Prometheus metrics, behind authentication like the API
+	router.Handle("/metrics", server.Metrics()).Methods("GET")
 
 	// Static file routes
 	router.HandleFunc("/", staticHandler.ServeIndex).Methods("GET")
@@ -30,12 +77,75 @@ func SetupRoutes(server *Server, cfg *config.Config, sessionManager *terminal.Ma
 		http.StripPrefix("/static/", staticHandler),
 	).Methods("GET")
 
+	// Read-only broadcast viewer page
+	router.HandleFunc("/watch/{token}", staticHandler.ServeWatch).Methods("GET")
+
+	// Operator dashboard page; its API requires an admin
+	router.HandleFunc("/admin", staticHandler.ServeAdmin).Methods("GET")
+
+	// Register server info routes
+	serverInfoHandler.RegisterRoutes(router)
+
 	// Register session management routes
 	sessionHandler.RegisterRoutes(router)
 
+	// Register transcript and recording download routes
+	transcriptHandler.RegisterRoutes(router)
+
+	// Register pane routes
+	paneHandler.RegisterRoutes(router)
+
+	// Register session pipe routes
+	pipeHandler.RegisterRoutes(router)
+
+	// Register broadcast routes
+	broadcastHandler.RegisterRoutes(router)
+
+	// Register session lock routes
+	lockHandler.RegisterRoutes(router)
+
+	// Register snippet routes
+	snippetHandler.RegisterRoutes(router)
+
+	// Register path completion routes when frontends may browse session files
+	if cfg.CompletionEnabled {
+		completionHandler := handlers.NewCompletionHandler(sessionManager)
+		completionHandler.RegisterRoutes(router)
+	}
+
+	// Register display routes when sessions may have an X display
+	if cfg.DisplayEnabled {
+		displayHandler := handlers.NewDisplayHandler(sessionManager, server.Origins())
+		displayHandler.RegisterRoutes(router)
+	}
+
+	// Register admin routes
+	adminHandler.SetInputAudit(wsHub.InputAudit())
+	adminHandler.RegisterRoutes(router)
+
+	// Register user data purge routes
+	userDataHandler.RegisterRoutes(router)
+
+	// Register approval routes when privileged sessions need an admin's approval
+	if cfg.ApprovalRequired {
+		approvalHandler := handlers.NewApprovalHandler(sessionManager, isAdmin, auditLogger)
+		approvalHandler.RegisterRoutes(router)
+	}
+
+	// Register WebRTC signaling routes when the data channel transport is enabled
+	if cfg.WebRTCEnabled {
+		webRTCHandler := handlers.NewWebRTCHandler(sessionManager, wsHub, rtc.NewAnswerer(cfg.WebRTCICEServers))
+		webRTCHandler.RegisterRoutes(router)
+	}
+
 	// WebSocket route
 	router.Handle("/api/ws", webSocketHandler)
 
+	// WebTransport route, reachable only over HTTP/3
+	if server.WebTransport() != nil {
+		router.Handle("/api/webtransport", handlers.NewWebTransportHandler(wsHub, server.WebTransport()))
+	}
+
 	logrus.Info("Routes configured successfully")
 
 	// Log all registered routes for debugging
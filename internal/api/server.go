@@ -9,57 +9,185 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/piyushgupta53/webterm/internal/api/handlers"
+	"github.com/piyushgupta53/webterm/internal/auth"
 	"github.com/piyushgupta53/webterm/internal/config"
+	"github.com/piyushgupta53/webterm/internal/monitoring"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 // Server represents the HTTP server
 type Server struct {
-	httpServer *http.Server
-	config     *config.Config
-	router     *mux.Router
+	httpServer  *http.Server
+	http3Server *http3.Server // Optional QUIC listener on the same port
+	config      *config.Config
+	router      *mux.Router
+
+	// Wraps http3Server when WebTransport is enabled
+	webTransportServer *webtransport.Server
+
+	// ACME certificate manager and HTTP-01 challenge server, when ACME is enabled
+	certManager     *autocert.Manager
+	challengeServer *http.Server
+
+	// Authenticates requests and attaches the caller's identity
+	authMiddleware *auth.Middleware
+
+	// Metrics exported for Prometheus
+	metrics *monitoring.ServerMetrics
+
+	// Pages allowed to open WebSocket and WebTransport connections
+	origins *handlers.OriginPolicy
 }
 
 // NewServer creates a new HTTP server instance
-func NewServer(cfg *config.Config) *Server {
+func NewServer(cfg *config.Config) (*Server, error) {
+	authenticators, err := newAuthenticators(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	server := &Server{
-		config: cfg,
-		router: mux.NewRouter(),
+		config:         cfg,
+		router:         mux.NewRouter(),
+		authMiddleware: auth.NewMiddleware(authenticators...),
+		metrics:        monitoring.NewServerMetrics(),
+		origins:        handlers.NewOriginPolicy(cfg.AllowedOrigins, cfg.InsecureAllowAnyOrigin),
+	}
+	if cfg.InsecureAllowAnyOrigin {
+		logrus.Warn("Any origin allowed: pages on other sites can open connections to sessions with visitors' credentials")
 	}
 
 	// Setup middleware
 	server.router.Use(server.loggingMiddleware)
+	server.router.Use(server.metricsMiddleware)
+	server.router.Use(server.tracingMiddleware)
 	server.router.Use(server.corsMiddleware)
+	server.router.Use(server.authMiddleware.Handler)
+
+	// Obtain certificates automatically for TLS listeners without certificate files
+	if cfg.ACMEEnabled() {
+		server.certManager = newCertManager(cfg)
+		if cfg.ACMEHTTPAddress != "" {
+			server.challengeServer = newChallengeServer(cfg, server.certManager)
+		}
+	}
+
+	// Advertise HTTP/3 to TCP clients so browsers can switch to QUIC
+	if h3Listener := cfg.HTTP3Listener(); cfg.HTTP3Enabled && h3Listener != nil {
+		if cfg.WebTransportEnabled {
+			server.webTransportServer = &webtransport.Server{
+				H3: http3.Server{
+					Addr:    h3Listener.Address,
+					Handler: server.router,
+				},
+				CheckOrigin: server.origins.Check,
+			}
+			server.http3Server = &server.webTransportServer.H3
+		} else {
+			server.http3Server = &http3.Server{
+				Addr:    h3Listener.Address,
+				Handler: server.router,
+			}
+		}
+		server.router.Use(server.altSvcMiddleware)
+	}
 
-	// Create HTTP server
+	// Create HTTP server, served on every configured listener
 	server.httpServer = &http.Server{
-		Addr:         cfg.Address(),
 		Handler:      server.router,
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 	}
 
-	return server
+	return server, nil
 }
 
-// Start starts the HTTP server
+// Start starts the HTTP server on all listeners, and the HTTP/3 listener if
+// enabled. It returns when any listener fails.
 func (s *Server) Start() error {
 	logrus.WithFields(logrus.Fields{
-		"address":       s.config.Address(),
+		"listeners":     len(s.config.Listeners),
 		"static_dir":    s.config.StaticDir,
 		"read_timeout":  s.config.ReadTimeout,
 		"write_timeout": s.config.WriteTimeout,
+		"http3":         s.http3Server != nil,
 	}).Info("Starting HTTP server")
 
-	return s.httpServer.ListenAndServe()
+	errs := make(chan error, len(s.config.Listeners)+2)
+
+	if err := s.serveListeners(errs); err != nil {
+		return err
+	}
+
+	if s.challengeServer != nil {
+		logrus.WithField("address", s.challengeServer.Addr).Info("Serving ACME HTTP-01 challenges")
+		go func() {
+			errs <- s.challengeServer.ListenAndServe()
+		}()
+	}
+
+	if s.http3Server != nil {
+		tlsConfig, err := s.tlsConfig(*s.config.HTTP3Listener())
+		if err != nil {
+			return err
+		}
+		s.http3Server.TLSConfig = http3.ConfigureTLSConfig(tlsConfig)
+
+		go func() {
+			if s.webTransportServer != nil {
+				errs <- s.webTransportServer.ListenAndServe()
+			} else {
+				errs <- s.http3Server.ListenAndServe()
+			}
+		}()
+	}
+
+	return <-errs
 }
 
 // Shutdown gracefully shuts down the HTTP server
 func (s *Server) Shutdown(ctx context.Context) error {
 	logrus.Info("Shutting down HTTP server")
+
+	if s.webTransportServer != nil {
+		if err := s.webTransportServer.Close(); err != nil {
+			logrus.WithError(err).Error("Failed to close WebTransport server")
+		}
+	} else if s.http3Server != nil {
+		if err := s.http3Server.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Error("Failed to shutdown HTTP/3 server")
+		}
+	}
+
+	if s.challengeServer != nil {
+		if err := s.challengeServer.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Error("Failed to shutdown ACME challenge server")
+		}
+	}
+
 	return s.httpServer.Shutdown(ctx)
 }
 
+// WebTransport returns the WebTransport server, or nil when disabled
+func (s *Server) WebTransport() *webtransport.Server {
+	return s.webTransportServer
+}
+
+// Auth returns the authentication middleware, for registering public routes
+func (s *Server) Auth() *auth.Middleware {
+	return s.authMiddleware
+}
+
+// Origins returns the policy deciding which pages may open WebSocket and
+// WebTransport connections
+func (s *Server) Origins() *handlers.OriginPolicy {
+	return s.origins
+}
+
 // Router returns the mux router for route registration
 func (s *Server) Router() *mux.Router {
 	return s.router
@@ -80,7 +208,7 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 		duration := time.Since(start)
 		logrus.WithFields(logrus.Fields{
 			"method":      r.Method,
-			"uri":         r.RequestURI,
+			"uri":         auth.RedactedURI(r),
 			"status":      wrapped.statusCode,
 			"duration_ms": duration.Milliseconds(),
 			"remote_addr": r.RemoteAddr,
@@ -107,6 +235,19 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// altSvcMiddleware sets the Alt-Svc header on TCP responses to advertise HTTP/3
+func (s *Server) altSvcMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			if err := s.http3Server.SetQUICHeaders(w.Header()); err != nil {
+				logrus.WithError(err).Debug("Failed to set Alt-Svc header")
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // responseWriter wraps http.ResponseWriter to capture status codes
 type responseWriter struct {
 	http.ResponseWriter
@@ -126,3 +267,31 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	}
 	return nil, nil, fmt.Errorf("underlying ResponseWriter does not implement http.Hijacker")
 }
+
+// Unwrap returns the wrapped writer, for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Flush implements http.Flusher
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Connection implements http3.Hijacker for WebTransport upgrades
+func (rw *responseWriter) Connection() *http3.Conn {
+	if hijacker, ok := rw.ResponseWriter.(http3.Hijacker); ok {
+		return hijacker.Connection()
+	}
+	return nil
+}
+
+// HTTPStream implements http3.HTTPStreamer for WebTransport upgrades
+func (rw *responseWriter) HTTPStream() *http3.Stream {
+	if streamer, ok := rw.ResponseWriter.(http3.HTTPStreamer); ok {
+		return streamer.HTTPStream()
+	}
+	return nil
+}
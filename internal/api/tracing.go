@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/piyushgupta53/webterm/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingMiddleware starts a span for each request, continuing any trace
+// the caller propagated in its headers. Handlers find the span in the
+// request's context and parent their own spans to it.
+func (s *Server) tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		attrs := []attribute.KeyValue{
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+		}
+		if sessionID := routeSessionID(r, route); sessionID != "" {
+			attrs = append(attrs, tracing.AttrSessionID.String(sessionID))
+		}
+
+		ctx, span := tracing.Tracer().Start(tracing.Extract(r), r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...))
+		defer span.End()
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", wrapped.statusCode))
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wrapped.statusCode))
+		}
+	})
+}
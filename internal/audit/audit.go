@@ -0,0 +1,223 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Audit event types
+const (
+	// EventAuthFailure records rejected credentials
+	EventAuthFailure = "auth.failure"
+	// EventAuthLockout records a client or user being locked out after repeated failures
+	EventAuthLockout = "auth.lockout"
+	// EventSessionApproved records an admin approving a privileged session
+	EventSessionApproved = "session.approved"
+	// EventSessionDenied records an admin denying a privileged session
+	EventSessionDenied = "session.denied"
+	// EventSessionTerminated records an admin terminating another user's session
+	EventSessionTerminated = "session.terminated"
+	// EventClientKicked records an admin disconnecting a client from a session
+	EventClientKicked = "client.kicked"
+	// EventBannerSent records an admin broadcasting a banner to session clients
+	EventBannerSent = "banner.sent"
+	// EventMaintenanceScheduled records an admin scheduling a maintenance shutdown
+	EventMaintenanceScheduled = "maintenance.scheduled"
+	// EventMaintenanceCancelled records an admin cancelling a maintenance shutdown
+	EventMaintenanceCancelled = "maintenance.cancelled"
+	// EventLogLevelChanged records an admin changing the server's log level
+	EventLogLevelChanged = "log.level_changed"
+	// EventCaptureStarted records an admin starting a traffic capture of a session
+	EventCaptureStarted = "capture.started"
+	// EventUserDataPurged records an admin deleting the data held about a user
+	EventUserDataPurged = "user.data_purged"
+	// EventTranscriptDownloaded records a user downloading a session's output
+	EventTranscriptDownloaded = "transcript.downloaded"
+	// EventRecordingDownloaded records a user downloading a session's recording
+	EventRecordingDownloaded = "recording.downloaded"
+	// EventRecordingPlayed records a user playing back a session's recording
+	EventRecordingPlayed = "recording.played"
+)
+
+// Event is a single security-relevant occurrence
+type Event struct {
+	Time       time.Time              `json:"time"`
+	Type       string                 `json:"type"`
+	User       string                 `json:"user,omitempty"`
+	RemoteAddr string                 `json:"remote_addr,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// Logger records audit events to the application log and, optionally, an
+// append-only JSON lines file
+type Logger struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+
+	// Told of every event, such as to count them for alerts
+	observer func(Event)
+}
+
+// NewLogger creates an audit logger appending to path, or logging only to
+// the application log when path is empty
+func NewLogger(path string) (*Logger, error) {
+	l := &Logger{path: path}
+
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, err
+		}
+		l.file = file
+	}
+
+	return l, nil
+}
+
+// SetObserver sets a function told of every event as it is logged. It must
+// be called before events are logged.
+func (l *Logger) SetObserver(observer func(Event)) {
+	l.observer = observer
+}
+
+// Log records an event. A nil logger discards events.
+func (l *Logger) Log(event Event) {
+	if l == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"audit":       event.Type,
+		"user":        event.User,
+		"remote_addr": event.RemoteAddr,
+		"details":     event.Details,
+	}).Warn("Audit event")
+
+	if l.observer != nil {
+		l.observer(event)
+	}
+
+	if l.file == nil {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal audit event")
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		logrus.WithError(err).Error("Failed to write audit event")
+	}
+}
+
+// Close closes the audit file
+func (l *Logger) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.file.Close()
+}
+
+// Purge removes the events attributable to a user from the audit file:
+// those the user caused, and those about the user's sessions. It returns how
+// many there were; with dryRun, they are only counted. Events already in the
+// application log are not affected.
+func (l *Logger) Purge(user string, dryRun bool) (int, error) {
+	if l == nil || l.file == nil {
+		return 0, nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return 0, err
+	}
+
+	var kept bytes.Buffer
+	purged := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		var event Event
+		if json.Unmarshal(line, &event) == nil && attributable(event, user) {
+			purged++
+			continue
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if dryRun || purged == 0 {
+		return purged, nil
+	}
+
+	// Replace the file in one step, so a crash leaves either version
+	temp, err := os.CreateTemp(filepath.Dir(l.path), ".audit-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(kept.Bytes()); err != nil {
+		temp.Close()
+		return 0, err
+	}
+	if err := temp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(temp.Name(), l.path); err != nil {
+		return 0, err
+	}
+
+	// Keep appending to the new file
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, err
+	}
+	l.file.Close()
+	l.file = file
+
+	return purged, nil
+}
+
+// attributable reports whether an event was caused by a user or concerns one
+// of the user's sessions
+func attributable(event Event, user string) bool {
+	if event.User == user {
+		return true
+	}
+	for _, key := range []string{"owner", "requester"} {
+		if value, ok := event.Details[key].(string); ok && value == user {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// InputRecord is one write of terminal input to a session. Records are
+// chained: each carries the hash of the one before it, so editing, removing
+// or reordering records breaks the chain from that point on.
+type InputRecord struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id"`
+	Pane      string    `json:"pane,omitempty"`
+	ClientID  string    `json:"client_id,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Data      string    `json:"data"`
+	Prev      string    `json:"prev"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// InputVerification is the result of checking an input audit file's chain
+type InputVerification struct {
+	Records  int    `json:"records"`
+	Valid    bool   `json:"valid"`
+	BrokenAt uint64 `json:"broken_at,omitempty"` // Line of the first record failing the check
+	Reason   string `json:"reason,omitempty"`
+}
+
+// InputLog appends every input write to a hash-chained JSON lines file,
+// apart from the application log and audit events
+type InputLog struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	seq   uint64
+	last  string // Hash of the last record written
+}
+
+// NewInputLog opens an input audit file for appending, continuing the chain
+// of the records already in it
+func NewInputLog(path string) (*InputLog, error) {
+	l := &InputLog{path: path}
+
+	if existing, err := os.Open(path); err == nil {
+		err = scanInputRecords(existing, func(_ int, record InputRecord) error {
+			l.seq, l.last = record.Seq, record.Hash
+			return nil
+		})
+		existing.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input audit file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+
+	return l, nil
+}
+
+// Record appends a write of input to a session. A nil log records nothing.
+func (l *InputLog) Record(sessionID, pane, clientID, user, data string) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	record := InputRecord{
+		Seq:       l.seq + 1,
+		Time:      time.Now().UTC(),
+		SessionID: sessionID,
+		Pane:      pane,
+		ClientID:  clientID,
+		User:      user,
+		Data:      data,
+		Prev:      l.last,
+	}
+
+	hash, err := record.hash()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to hash input audit record")
+		return
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal input audit record")
+		return
+	}
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to write input audit record")
+		return
+	}
+	l.seq, l.last = record.Seq, record.Hash
+}
+
+// Verify checks the chain of every record in the file
+func (l *InputLog) Verify() (InputVerification, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return InputVerification{}, err
+	}
+	defer file.Close()
+
+	result := InputVerification{Valid: true}
+	var prev string
+	var seq uint64
+	err = scanInputRecords(file, func(line int, record InputRecord) error {
+		hash, err := record.hash()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case record.Prev != prev:
+			result.Reason = "record does not follow the one before it"
+		case record.Seq != seq+1:
+			result.Reason = fmt.Sprintf("expected sequence number %d, found %d", seq+1, record.Seq)
+		case record.Hash != hash:
+			result.Reason = "record does not match its hash"
+		default:
+			result.Records++
+			prev, seq = record.Hash, record.Seq
+			return nil
+		}
+
+		result.Valid = false
+		result.BrokenAt = uint64(line)
+		return errChainBroken
+	})
+	var malformed *malformedRecordError
+	if errors.As(err, &malformed) {
+		result.Valid = false
+		result.BrokenAt = uint64(malformed.line)
+		result.Reason = "record is not valid JSON"
+	} else if err != nil && err != errChainBroken {
+		return InputVerification{}, err
+	}
+
+	return result, nil
+}
+
+// Close closes the input audit file
+func (l *InputLog) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.file.Close()
+}
+
+// errChainBroken stops a scan at the first record failing verification
+var errChainBroken = errors.New("input audit chain broken")
+
+// malformedRecordError is returned for lines of an input audit file that
+// are not records
+type malformedRecordError struct {
+	line int
+	err  error
+}
+
+func (e *malformedRecordError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+// hash returns the hash chaining a record to the one before it, taken over
+// the record without its own hash
+func (r InputRecord) hash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// scanInputRecords calls fn with each record of an input audit file and its
+// line number, stopping at the first error
+func scanInputRecords(r io.Reader, fn func(line int, record InputRecord) error) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(data) > 0 {
+			var record InputRecord
+			if jsonErr := json.Unmarshal(data, &record); jsonErr != nil {
+				return &malformedRecordError{line: line, err: jsonErr}
+			}
+			if fnErr := fn(line, record); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNoCredentials is returned when a request carries no credentials for an authenticator
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when a request carries credentials that are not valid
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrThrottled is returned while a client or user is blocked after repeated failures
+	ErrThrottled = errors.New("too many failed authentication attempts")
+)
+
+// Authenticator identifies the caller of a request
+type Authenticator interface {
+	// Authenticate returns the caller's identity, ErrNoCredentials if the
+	// request carries no credentials it understands, or another error if
+	// the credentials are invalid
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Challenger is implemented by authenticators that can tell clients how to authenticate
+type Challenger interface {
+	// Challenge returns the value of the WWW-Authenticate header
+	Challenge() string
+}
+
+// PasswordVerifier is implemented by authenticators that can check a user's
+// password outside of a request's credentials
+type PasswordVerifier interface {
+	VerifyPassword(user, password string) error
+}
+
+// PeerVerifier is implemented by authenticators that identify the client of
+// a connection rather than of a request, such as by its TLS certificate
+type PeerVerifier interface {
+	VerifyPeer(r *http.Request, user string) error
+}
+
+// Middleware requires every request to be authenticated by one of its
+// authenticators, except requests matching a public rule. With no
+// authenticators, every request proceeds as anonymous.
+type Middleware struct {
+	authenticators []Authenticator
+	public         []func(r *http.Request) bool
+	limiter        *Limiter
+}
+
+// NewMiddleware creates an authentication middleware trying authenticators in order
+func NewMiddleware(authenticators ...Authenticator) *Middleware {
+	return &Middleware{
+		authenticators: authenticators,
+	}
+}
+
+// Enabled reports whether any authenticators are configured
+func (m *Middleware) Enabled() bool {
+	return len(m.authenticators) > 0
+}
+
+// SetLimiter throttles clients and users that repeatedly fail authentication
+func (m *Middleware) SetLimiter(limiter *Limiter) {
+	m.limiter = limiter
+}
+
+// AllowPublic lets requests matching match through without credentials
+func (m *Middleware) AllowPublic(match func(r *http.Request) bool) {
+	m.public = append(m.public, match)
+}
+
+// Handler wraps next with authentication
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		clientAddr := clientHost(r)
+		user, _, _ := r.BasicAuth()
+
+		if m.limiter != nil {
+			if wait := m.limiter.Blocked(clientAddr, user); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				http.Error(w, "Too many failed authentication attempts", http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		identity, err := m.authenticate(r)
+		if err == nil {
+			if m.limiter != nil {
+				m.limiter.RecordSuccess(clientAddr, user)
+			}
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+			return
+		}
+
+		if errors.Is(err, ErrNoCredentials) && m.isPublic(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"path":        r.URL.Path,
+			"remote_addr": r.RemoteAddr,
+		}).Warn("Authentication failed")
+
+		// Only wrong credentials count towards a lockout, not missing ones
+		if m.limiter != nil && !errors.Is(err, ErrNoCredentials) {
+			m.limiter.RecordFailure(clientAddr, user)
+		}
+
+		for _, authenticator := range m.authenticators {
+			if challenger, ok := authenticator.(Challenger); ok {
+				w.Header().Add("WWW-Authenticate", challenger.Challenge())
+			}
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// CanReauthenticate reports whether Reauthenticate can ever succeed, that is
+// whether an authenticator can check a fresh credential
+func (m *Middleware) CanReauthenticate() bool {
+	for _, authenticator := range m.authenticators {
+		switch authenticator.(type) {
+		case PasswordVerifier, PeerVerifier:
+			return true
+		}
+	}
+	return false
+}
+
+// Reauthenticate checks a fresh credential of an already authenticated user,
+// for example before unlocking an idle session. The password is checked
+// against the htpasswd file or, re-presenting a bearer token, against the
+// user's tokens. Without such authenticators, the client's TLS certificate
+// must belong to the user. Failures count towards the lockout policy, and
+// ErrThrottled is returned with the time to wait while blocked.
+func (m *Middleware) Reauthenticate(r *http.Request, user, password string) (time.Duration, error) {
+	if !m.CanReauthenticate() {
+		return 0, fmt.Errorf("%w: no authenticator can check a fresh credential", ErrNoCredentials)
+	}
+
+	clientAddr := clientHost(r)
+
+	if m.limiter != nil {
+		if wait := m.limiter.Blocked(clientAddr, user); wait > 0 {
+			return wait, ErrThrottled
+		}
+	}
+
+	if err := m.verifyFresh(r, user, password); err != nil {
+		if m.limiter != nil {
+			m.limiter.RecordFailure(clientAddr, user)
+		}
+		return 0, err
+	}
+
+	if m.limiter != nil {
+		m.limiter.RecordSuccess(clientAddr, user)
+	}
+	return 0, nil
+}
+
+// verifyFresh checks password against every password verifier, falling back
+// to the connection's peer only if there are none
+func (m *Middleware) verifyFresh(r *http.Request, user, password string) error {
+	var verifiers []PasswordVerifier
+	var peers []PeerVerifier
+	for _, authenticator := range m.authenticators {
+		if v, ok := authenticator.(PasswordVerifier); ok {
+			verifiers = append(verifiers, v)
+		}
+		if v, ok := authenticator.(PeerVerifier); ok {
+			peers = append(peers, v)
+		}
+	}
+
+	err := fmt.Errorf("%w: no credential given for %s", ErrInvalidCredentials, user)
+	if len(verifiers) > 0 {
+		if password == "" {
+			return err
+		}
+		for _, verifier := range verifiers {
+			if err = verifier.VerifyPassword(user, password); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+
+	for _, peer := range peers {
+		if err = peer.VerifyPeer(r, user); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// authenticate returns the identity from the first authenticator that
+// recognizes the request's credentials
+func (m *Middleware) authenticate(r *http.Request) (*Identity, error) {
+	for _, authenticator := range m.authenticators {
+		identity, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return identity, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// clientHost returns the client's IP address without the port
+func clientHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isPublic reports whether the request matches a public rule
+func (m *Middleware) isPublic(r *http.Request) bool {
+	for _, match := range m.public {
+		if match(r) {
+			return true
+		}
+	}
+	return false
+}
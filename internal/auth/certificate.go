@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// Certificate identity fields a client certificate's user can be taken from
+const (
+	CertFieldCommonName = "cn"
+	CertFieldEmail      = "email"
+	CertFieldDNS        = "dns"
+	CertFieldURI        = "uri"
+	CertFieldSubject    = "subject"
+)
+
+// CertificateAuthenticator identifies callers by their verified TLS client certificate
+type CertificateAuthenticator struct {
+	userField string
+}
+
+// NewCertificateAuthenticator creates an authenticator taking the user name
+// from the given certificate field
+func NewCertificateAuthenticator(userField string) (*CertificateAuthenticator, error) {
+	switch userField {
+	case CertFieldCommonName, CertFieldEmail, CertFieldDNS, CertFieldURI, CertFieldSubject:
+	default:
+		return nil, fmt.Errorf("unsupported certificate identity field: %s", userField)
+	}
+
+	return &CertificateAuthenticator{
+		userField: userField,
+	}, nil
+}
+
+// Authenticate maps the client certificate to an identity. The tenant is the
+// first organization in the certificate subject.
+func (a *CertificateAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	// Only chains verified against the client CA count as credentials
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+
+	user := certificateUser(cert, a.userField)
+	if user == "" {
+		return nil, fmt.Errorf("%w: client certificate has no %s", ErrInvalidCredentials, a.userField)
+	}
+
+	tenant := DefaultTenant
+	if len(cert.Subject.Organization) > 0 {
+		tenant = cert.Subject.Organization[0]
+	}
+
+	return &Identity{
+		User:   user,
+		Tenant: tenant,
+	}, nil
+}
+
+// VerifyPeer checks that the request's verified client certificate belongs
+// to user
+func (a *CertificateAuthenticator) VerifyPeer(r *http.Request, user string) error {
+	identity, err := a.Authenticate(r)
+	if err != nil {
+		return err
+	}
+	if identity.User != user {
+		return fmt.Errorf("%w: client certificate belongs to %s, not %s", ErrInvalidCredentials, identity.User, user)
+	}
+	return nil
+}
+
+// certificateUser returns the value of field in cert, or "" if absent
+func certificateUser(cert *x509.Certificate, field string) string {
+	switch field {
+	case CertFieldCommonName:
+		return cert.Subject.CommonName
+	case CertFieldEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	case CertFieldDNS:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case CertFieldURI:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case CertFieldSubject:
+		return cert.Subject.String()
+	}
+	return ""
+}
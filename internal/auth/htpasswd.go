@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// verifiedTTL is how long a successful password check is remembered, so
+// bcrypt does not run on every request of a browser using basic auth
+const verifiedTTL = 5 * time.Minute
+
+// dummyHash is compared against for unknown users so that response times
+// do not reveal which user names exist
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("webterm"), bcrypt.DefaultCost)
+
+// HtpasswdAuthenticator checks HTTP basic auth credentials against an
+// htpasswd file with bcrypt hashes. The file is reloaded when it changes.
+type HtpasswdAuthenticator struct {
+	path  string
+	realm string
+
+	mutex    sync.Mutex
+	hashes   map[string][]byte
+	modTime  time.Time
+	verified map[[sha256.Size]byte]time.Time // Recently verified credentials
+}
+
+// NewHtpasswdAuthenticator loads an htpasswd file
+func NewHtpasswdAuthenticator(path, realm string) (*HtpasswdAuthenticator, error) {
+	a := &HtpasswdAuthenticator{
+		path:     path,
+		realm:    realm,
+		verified: make(map[[sha256.Size]byte]time.Time),
+	}
+
+	if err := a.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Authenticate verifies the request's basic auth credentials
+func (a *HtpasswdAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	if err := a.reloadIfChanged(); err != nil {
+		logrus.WithError(err).WithField("path", a.path).Error("Failed to reload htpasswd file")
+	}
+
+	key := sha256.Sum256([]byte(user + "\x00" + password))
+
+	a.mutex.Lock()
+	hash, exists := a.hashes[user]
+	expiresAt, cached := a.verified[key]
+	a.mutex.Unlock()
+
+	if exists && cached && time.Now().Before(expiresAt) {
+		return &Identity{User: user, Tenant: DefaultTenant}, nil
+	}
+
+	if err := a.checkPassword(user, password, hash, exists); err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	now := time.Now()
+	for cachedKey, expiry := range a.verified {
+		if now.After(expiry) {
+			delete(a.verified, cachedKey)
+		}
+	}
+	a.verified[key] = now.Add(verifiedTTL)
+	a.mutex.Unlock()
+
+	return &Identity{User: user, Tenant: DefaultTenant}, nil
+}
+
+// VerifyPassword checks a user's password, bypassing the cache of recently
+// verified credentials
+func (a *HtpasswdAuthenticator) VerifyPassword(user, password string) error {
+	if err := a.reloadIfChanged(); err != nil {
+		logrus.WithError(err).WithField("path", a.path).Error("Failed to reload htpasswd file")
+	}
+
+	a.mutex.Lock()
+	hash, exists := a.hashes[user]
+	a.mutex.Unlock()
+
+	return a.checkPassword(user, password, hash, exists)
+}
+
+// checkPassword compares a password against a user's bcrypt hash
+func (a *HtpasswdAuthenticator) checkPassword(user, password string, hash []byte, exists bool) error {
+	if !exists {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return fmt.Errorf("%w: unknown user %s", ErrInvalidCredentials, user)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return fmt.Errorf("%w: wrong password for %s", ErrInvalidCredentials, user)
+	}
+
+	return nil
+}
+
+// Challenge asks browsers to prompt for basic auth credentials
+func (a *HtpasswdAuthenticator) Challenge() string {
+	return fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, a.realm)
+}
+
+// reloadIfChanged re-reads the htpasswd file when its modification time changes
+func (a *HtpasswdAuthenticator) reloadIfChanged() error {
+	stat, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.hashes != nil && stat.ModTime().Equal(a.modTime) {
+		return nil
+	}
+
+	hashes, err := loadHtpasswd(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.hashes = hashes
+	a.modTime = stat.ModTime()
+	a.verified = make(map[[sha256.Size]byte]time.Time)
+
+	logrus.WithFields(logrus.Fields{
+		"path":  a.path,
+		"users": len(hashes),
+	}).Info("Htpasswd file loaded")
+
+	return nil
+}
+
+// loadHtpasswd parses user:hash lines, keeping only bcrypt hashes
+func loadHtpasswd(path string) (map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hashes := make(map[string][]byte)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		user, hash, found := strings.Cut(line, ":")
+		if !found || user == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(hash, "$2") {
+			logrus.WithField("user", user).Warn("Skipping htpasswd entry without a bcrypt hash")
+			continue
+		}
+
+		hashes[user] = []byte(hash)
+	}
+
+	return hashes, scanner.Err()
+}
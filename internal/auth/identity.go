@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+)
+
+const (
+	// AnonymousUser is the user name for unauthenticated requests
+	AnonymousUser = "anonymous"
+	// DefaultTenant is the tenant for identities that do not belong to one
+	DefaultTenant = "default"
+)
+
+// Identity describes who is making a request
+type Identity struct {
+	User   string `json:"user"`
+	Tenant string `json:"tenant"`
+}
+
+// contextKey is the type for auth values stored in a request context
+type contextKey struct{}
+
+// Anonymous returns the identity used for unauthenticated requests
+func Anonymous() *Identity {
+	return &Identity{
+		User:   AnonymousUser,
+		Tenant: DefaultTenant,
+	}
+}
+
+// WithIdentity returns a copy of ctx carrying the identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity carried by ctx, or the anonymous identity
+func FromContext(ctx context.Context) *Identity {
+	if identity, ok := ctx.Value(contextKey{}).(*Identity); ok && identity != nil {
+		return identity
+	}
+	return Anonymous()
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/sirupsen/logrus"
+)
+
+// pruneInterval is how often records of quiet clients are forgotten
+const pruneInterval = time.Minute
+
+// LockoutPolicy controls how repeated authentication failures are throttled
+type LockoutPolicy struct {
+	MaxFailures     int           // Failures before a lockout; zero disables throttling
+	LockoutDuration time.Duration // How long a lockout lasts
+	BaseDelay       time.Duration // Delay after the first failure, doubled for each further failure
+	MaxDelay        time.Duration // Upper bound on the backoff delay
+}
+
+// failureRecord tracks failures for one client address or user name
+type failureRecord struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// Limiter tracks failed authentication per client address and per user,
+// applying exponential backoff and temporary lockouts
+type Limiter struct {
+	policy  LockoutPolicy
+	auditor *audit.Logger
+
+	mutex     sync.Mutex
+	records   map[string]*failureRecord
+	lastPrune time.Time
+}
+
+// NewLimiter creates a limiter enforcing policy, recording events with auditor
+func NewLimiter(policy LockoutPolicy, auditor *audit.Logger) *Limiter {
+	return &Limiter{
+		policy:  policy,
+		auditor: auditor,
+		records: make(map[string]*failureRecord),
+	}
+}
+
+// Blocked returns how long the client or user must wait before trying again
+func (l *Limiter) Blocked(remoteAddr, user string) time.Duration {
+	if l.policy.MaxFailures <= 0 {
+		return 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range limiterKeys(remoteAddr, user) {
+		if record, exists := l.records[key]; exists && now.Before(record.blockedUntil) {
+			wait = max(wait, record.blockedUntil.Sub(now))
+		}
+	}
+
+	return wait
+}
+
+// RecordFailure counts a failed attempt for the client and user
+func (l *Limiter) RecordFailure(remoteAddr, user string) {
+	if l.policy.MaxFailures <= 0 {
+		return
+	}
+
+	l.auditor.Log(audit.Event{
+		Type:       audit.EventAuthFailure,
+		User:       user,
+		RemoteAddr: remoteAddr,
+	})
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	for _, key := range limiterKeys(remoteAddr, user) {
+		record, exists := l.records[key]
+		if !exists {
+			record = &failureRecord{}
+			l.records[key] = record
+		}
+
+		record.failures++
+		record.lastFailure = now
+
+		if record.failures >= l.policy.MaxFailures {
+			record.blockedUntil = now.Add(l.policy.LockoutDuration)
+			record.failures = 0
+
+			logrus.WithFields(logrus.Fields{
+				"key":      key,
+				"duration": l.policy.LockoutDuration.String(),
+			}).Warn("Authentication locked out")
+
+			l.auditor.Log(audit.Event{
+				Type:       audit.EventAuthLockout,
+				User:       user,
+				RemoteAddr: remoteAddr,
+				Details: map[string]interface{}{
+					"key":      key,
+					"duration": l.policy.LockoutDuration.String(),
+				},
+			})
+			continue
+		}
+
+		record.blockedUntil = now.Add(l.backoff(record.failures))
+	}
+}
+
+// RecordSuccess clears the failure history of the client and user
+func (l *Limiter) RecordSuccess(remoteAddr, user string) {
+	if l.policy.MaxFailures <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, key := range limiterKeys(remoteAddr, user) {
+		delete(l.records, key)
+	}
+}
+
+// backoff returns the delay after the given number of consecutive failures
+func (l *Limiter) backoff(failures int) time.Duration {
+	delay := l.policy.BaseDelay
+	for i := 1; i < failures && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, l.policy.MaxDelay)
+}
+
+// prune forgets records that are neither blocked nor recently failed
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for key, record := range l.records {
+		if now.After(record.blockedUntil) && now.Sub(record.lastFailure) > l.policy.LockoutDuration {
+			delete(l.records, key)
+		}
+	}
+}
+
+// limiterKeys returns the keys failures are tracked under
+func limiterKeys(remoteAddr, user string) []string {
+	keys := []string{"ip:" + remoteAddr}
+	if user != "" {
+		keys = append(keys, "user:"+user)
+	}
+	return keys
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is what a user may do with sessions
+type Role string
+
+const (
+	// RoleViewer attaches to sessions read-only
+	RoleViewer Role = "viewer"
+	// RoleOperator also types into sessions, and creates and manages
+	// sessions of their own
+	RoleOperator Role = "operator"
+	// RoleAdmin also manages, such as terminates, sessions of any user
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole parses the name of a role
+func ParseRole(name string) (Role, error) {
+	switch role := Role(name); role {
+	case RoleViewer, RoleOperator, RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role %q, must be viewer, operator or admin", name)
+	}
+}
+
+// ParseRoleAssignments parses user:role entries into the role of each user
+func ParseRoleAssignments(entries []string) (map[string]Role, error) {
+	assigned := make(map[string]Role, len(entries))
+	for _, entry := range entries {
+		user, name, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || user == "" {
+			return nil, fmt.Errorf("invalid role entry %q: expected user:role", entry)
+		}
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid role entry for %q: %v", user, err)
+		}
+		assigned[user] = role
+	}
+	return assigned, nil
+}
+
+// Roles assigns roles to users, and users without one the default role. A
+// nil Roles enforces nothing: every user may do everything.
+type Roles struct {
+	assigned    map[string]Role
+	defaultRole Role
+	ownerInput  bool // Only owners and admins type into sessions
+}
+
+// NewRoles creates the role assignments of the server. With ownerInput,
+// operators type only into their own sessions, as when sessions run as
+// another system account than the server's.
+func NewRoles(assigned map[string]Role, defaultRole Role, ownerInput bool) *Roles {
+	return &Roles{assigned: assigned, defaultRole: defaultRole, ownerInput: ownerInput}
+}
+
+// Role returns the role of a user
+func (r *Roles) Role(user string) Role {
+	if r == nil {
+		return RoleAdmin
+	}
+	if role, exists := r.assigned[user]; exists {
+		return role
+	}
+	return r.defaultRole
+}
+
+// CanInput reports whether a user may type into, or resize, a session owned
+// by owner
+func (r *Roles) CanInput(user, owner string) bool {
+	switch r.Role(user) {
+	case RoleAdmin:
+		return true
+	case RoleOperator:
+		return !r.ownerInput || user == owner
+	default:
+		return false
+	}
+}
+
+// CanCreate reports whether a user may create sessions
+func (r *Roles) CanCreate(user string) bool {
+	return r.Role(user) != RoleViewer
+}
+
+// CanManage reports whether a user may change or end a session owned by
+// owner, such as by terminating, pausing or resizing it
+func (r *Roles) CanManage(user, owner string) bool {
+	switch r.Role(user) {
+	case RoleAdmin:
+		return true
+	case RoleOperator:
+		return user == owner
+	default:
+		return false
+	}
+}
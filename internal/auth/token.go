@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// AccessTokenParam is the query parameter carrying a bearer token where
+// browsers cannot set headers, such as page loads and WebSocket upgrades
+const AccessTokenParam = "access_token"
+
+// TokenAuthenticator identifies callers by a bearer token, each token
+// belonging to one user
+type TokenAuthenticator struct {
+	users map[[sha256.Size]byte]string // User by hashed token
+	realm string
+}
+
+// NewTokenAuthenticator creates an authenticator from user:token entries
+func NewTokenAuthenticator(entries []string, realm string) (*TokenAuthenticator, error) {
+	a := &TokenAuthenticator{
+		users: make(map[[sha256.Size]byte]string, len(entries)),
+		realm: realm,
+	}
+
+	for _, entry := range entries {
+		user, token, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || user == "" || token == "" {
+			return nil, fmt.Errorf("invalid token entry for %q: expected user:token", user)
+		}
+
+		key := sha256.Sum256([]byte(token))
+		if _, exists := a.users[key]; exists {
+			return nil, fmt.Errorf("token of %s is also given to another user", user)
+		}
+		a.users[key] = user
+	}
+
+	return a, nil
+}
+
+// Authenticate looks up the user of the request's bearer token. Tokens are
+// compared by their hashes, so lookups take no longer for near misses.
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	user, exists := a.users[sha256.Sum256([]byte(token))]
+	if !exists {
+		return nil, fmt.Errorf("%w: unknown bearer token", ErrInvalidCredentials)
+	}
+
+	return &Identity{User: user, Tenant: DefaultTenant}, nil
+}
+
+// VerifyPassword checks that token is one of the user's bearer tokens, so
+// that re-presenting a token counts as a fresh credential
+func (a *TokenAuthenticator) VerifyPassword(user, token string) error {
+	owner, exists := a.users[sha256.Sum256([]byte(token))]
+	if !exists || owner != user {
+		return fmt.Errorf("%w: wrong bearer token for %s", ErrInvalidCredentials, user)
+	}
+	return nil
+}
+
+// Challenge tells API clients to send a bearer token
+func (a *TokenAuthenticator) Challenge() string {
+	return fmt.Sprintf(`Bearer realm=%q`, a.realm)
+}
+
+// bearerToken returns the token from the Authorization header or, for page
+// loads, WebSocket upgrades and WebTransport sessions, the access token query
+// parameter
+func bearerToken(r *http.Request) string {
+	if scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " "); found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodConnect {
+		return r.URL.Query().Get(AccessTokenParam)
+	}
+
+	return ""
+}
+
+// LoadTokenFile reads user:token entries, one per line, skipping blank
+// lines and # comments
+func LoadTokenFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+
+	return entries, scanner.Err()
+}
+
+// RedactedURI returns the request URI with any access token masked, for logging
+func RedactedURI(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has(AccessTokenParam) {
+		return r.RequestURI
+	}
+
+	query.Set(AccessTokenParam, "REDACTED")
+	redacted := *r.URL
+	redacted.RawQuery = query.Encode()
+	return redacted.RequestURI()
+}
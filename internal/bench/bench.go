@@ -0,0 +1,197 @@
+// Package bench measures the hot paths of the server and compares the results
+// against stored baselines, so that performance regressions are caught. The
+// benchmarks themselves are BenchmarkXxx functions in this package's tests;
+// this file reads their results from `go test -bench -json`.
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Result is the outcome of running a benchmark
+type Result struct {
+	NsPerOp     int64 `json:"ns_per_op"`
+	AllocsPerOp int64 `json:"allocs_per_op"`
+	BytesPerOp  int64 `json:"bytes_per_op"`
+}
+
+// Baseline holds the expected results, keyed by benchmark name
+type Baseline map[string]Result
+
+// Regression is a metric that got worse than its baseline allows
+type Regression struct {
+	Name     string
+	Metric   string
+	Baseline int64
+	Current  int64
+}
+
+// String describes the regression for humans
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s went from %d to %d", r.Name, r.Metric, r.Baseline, r.Current)
+}
+
+// testEvent is the part of a `go test -json` event the results are read from
+type testEvent struct {
+	Action string
+	Test   string
+	Output string
+}
+
+// ParseResults reads the output of `go test -bench -benchmem -json` and
+// returns the results keyed by benchmark name, such as "json/decode_input"
+// for BenchmarkJSON/decode_input
+func ParseResults(r io.Reader) (map[string]Result, error) {
+	// A benchmark's result line may be split across several events
+	outputs := make(map[string]*strings.Builder)
+	var order []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed to parse test event: %w", err)
+		}
+		if event.Action != "output" || !strings.HasPrefix(event.Test, "Benchmark") {
+			continue
+		}
+
+		output, exists := outputs[event.Test]
+		if !exists {
+			output = &strings.Builder{}
+			outputs[event.Test] = output
+			order = append(order, event.Test)
+		}
+		output.WriteString(event.Output)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read test events: %w", err)
+	}
+
+	results := make(map[string]Result)
+	for _, test := range order {
+		for _, line := range strings.Split(outputs[test].String(), "\n") {
+			if result, ok := parseResultLine(line); ok {
+				results[resultName(test)] = result
+			}
+		}
+	}
+	return results, nil
+}
+
+// parseResultLine parses a line such as
+// "BenchmarkJSON/decode_input-8  2000  723 ns/op  352 B/op  1 allocs/op"
+func parseResultLine(line string) (Result, bool) {
+	var result Result
+	found := false
+
+	fields := strings.Fields(line)
+	for i := 1; i < len(fields); i++ {
+		value, err := strconv.ParseFloat(fields[i-1], 64)
+		if err != nil {
+			continue
+		}
+
+		switch fields[i] {
+		case "ns/op":
+			result.NsPerOp = int64(math.Round(value))
+			found = true
+		case "B/op":
+			result.BytesPerOp = int64(value)
+		case "allocs/op":
+			result.AllocsPerOp = int64(value)
+		}
+	}
+	return result, found
+}
+
+// resultName turns the name of a benchmark into its name in the baseline,
+// so BenchmarkJSON/decode_input becomes json/decode_input
+func resultName(test string) string {
+	name := strings.TrimPrefix(test, "Benchmark")
+	group, rest, _ := strings.Cut(name, "/")
+	if rest == "" {
+		return strings.ToLower(group)
+	}
+	return strings.ToLower(group) + "/" + rest
+}
+
+// LoadBaseline reads baselines from a JSON file. A missing file yields an
+// empty baseline, against which nothing can regress.
+func LoadBaseline(path string) (Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Baseline{}, nil
+		}
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline: %w", err)
+	}
+	return baseline, nil
+}
+
+// Save writes the baseline to a JSON file
+func (b Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	return nil
+}
+
+// Compare returns the allocation metrics of results that exceed their
+// baseline by more than tolerance, a fraction such as 0.2 for 20%. Timings
+// depend on the machine, so ns/op is only reported, never compared.
+// Benchmarks without a baseline are not compared.
+func Compare(baseline Baseline, results map[string]Result, tolerance float64) []Regression {
+	var regressions []Regression
+
+	for name, current := range results {
+		expected, exists := baseline[name]
+		if !exists {
+			continue
+		}
+
+		metrics := []struct {
+			name              string
+			expected, current int64
+		}{
+			{"allocs/op", expected.AllocsPerOp, current.AllocsPerOp},
+			{"B/op", expected.BytesPerOp, current.BytesPerOp},
+		}
+		for _, metric := range metrics {
+			if float64(metric.current) > float64(metric.expected)*(1+tolerance) {
+				regressions = append(regressions, Regression{
+					Name:     name,
+					Metric:   metric.name,
+					Baseline: metric.expected,
+					Current:  metric.current,
+				})
+			}
+		}
+	}
+
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].Name != regressions[j].Name {
+			return regressions[i].Name < regressions[j].Name
+		}
+		return regressions[i].Metric < regressions[j].Metric
+	})
+	return regressions
+}
@@ -0,0 +1,225 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/piyushgupta53/webterm/internal/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// broadcastClients is how many clients watch the session in the broadcast
+// benchmark
+const broadcastClients = 16
+
+// outputChunk resembles a read of busy terminal output, escape sequences
+// included
+var outputChunk = strings.Repeat("\x1b[32mok\x1b[0m  build/step \"done\"\r\n", 128)
+
+// env is a running hub and session the benchmarks exercise end to end,
+// started by the first benchmark that needs it
+type env struct {
+	manager *terminal.Manager
+	hub     *websocket.Hub
+	session *types.Session
+	input   *benchTransport
+	clients []*benchTransport
+}
+
+var (
+	sharedEnv    *env
+	sharedEnvErr error
+	sharedOnce   sync.Once
+	pipesDir     string
+)
+
+func TestMain(m *testing.M) {
+	// The hot paths log every message at info level, which would dominate
+	// the measurements
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	code := m.Run()
+
+	if sharedEnv != nil {
+		sharedEnv.Close()
+	}
+	if pipesDir != "" {
+		os.RemoveAll(pipesDir)
+	}
+	os.Exit(code)
+}
+
+// getEnv returns the shared environment, starting it on first use
+func getEnv(b *testing.B) *env {
+	sharedOnce.Do(func() {
+		pipesDir, sharedEnvErr = os.MkdirTemp("", "webterm-bench-")
+		if sharedEnvErr != nil {
+			return
+		}
+		sharedEnv, sharedEnvErr = newEnv(pipesDir)
+	})
+	if sharedEnvErr != nil {
+		b.Fatalf("failed to start benchmark environment: %v", sharedEnvErr)
+	}
+	return sharedEnv
+}
+
+// newEnv starts a hub and a session that discards its input, keeping its
+// pipes in pipesDir
+func newEnv(pipesDir string) (*env, error) {
+	manager := terminal.NewManager(pipesDir)
+	hub := websocket.NewHub(manager)
+	manager.SetStatusCallback(hub.BroadcastSessionStatus)
+	manager.SetOutputCallback(hub.HandleOutput)
+	go hub.Run()
+
+	session, err := manager.CreateSession(context.Background(), &types.SessionCreateRequest{
+		Command: []string{"sh", "-c", "cat > /dev/null"},
+	})
+	if err != nil {
+		hub.Stop()
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	e := &env{manager: manager, hub: hub, session: session}
+	e.input = e.connect()
+	for i := 0; i < broadcastClients; i++ {
+		e.clients = append(e.clients, e.connect())
+	}
+
+	// Clients are registered asynchronously by the hub
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.GetClientCount(session.ID) < broadcastClients+1 {
+		if time.Now().After(deadline) {
+			e.Close()
+			return nil, fmt.Errorf("clients did not register with the hub")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return e, nil
+}
+
+// connect attaches a client to the session over an in-memory transport
+func (e *env) connect() *benchTransport {
+	transport := newBenchTransport()
+	client := websocket.NewTransportClient(transport, e.hub, e.session.ID, fmt.Sprintf("bench-%p", transport), "bench")
+	e.hub.RegisterClient(client)
+	go client.Run()
+	return transport
+}
+
+// Close disconnects the clients and stops the session and hub
+func (e *env) Close() {
+	e.input.Close()
+	for _, client := range e.clients {
+		client.Close()
+	}
+	e.hub.Stop()
+	e.manager.Shutdown()
+}
+
+// BenchmarkJSON measures encoding and decoding of the messages that pass
+// through the hot paths
+func BenchmarkJSON(b *testing.B) {
+	b.Run("encode_output", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := types.NewOutputMessage("bench", outputChunk).ToJSON(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// A keystroke sent by a client
+	b.Run("decode_input", func(b *testing.B) {
+		data := []byte(`{"type":"input","session_id":"bench","data":"l"}`)
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := types.FromJSON(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkInput measures a keystroke travelling from a client through the
+// hub into the session's input pipe
+func BenchmarkInput(b *testing.B) {
+	b.Run("websocket_to_pipe", func(b *testing.B) {
+		e := getEnv(b)
+		data := []byte(`{"type":"input","data":"l"}`)
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			e.input.incoming <- data
+		}
+	})
+}
+
+// BenchmarkBroadcast measures sending one message to every client of a
+// session
+func BenchmarkBroadcast(b *testing.B) {
+	b.Run(fmt.Sprintf("%d_clients", broadcastClients), func(b *testing.B) {
+		e := getEnv(b)
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			e.hub.BroadcastSessionStatus(e.session.ID, string(types.SessionStatusRunning), nil)
+		}
+	})
+}
+
+// benchTransport is an in-memory transport that discards what is sent to it
+type benchTransport struct {
+	incoming chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func newBenchTransport() *benchTransport {
+	return &benchTransport{
+		incoming: make(chan []byte),
+		closed:   make(chan struct{}),
+	}
+}
+
+// ReadMessage returns the next message fed to the transport
+func (t *benchTransport) ReadMessage() ([]byte, error) {
+	select {
+	case data := <-t.incoming:
+		return data, nil
+	case <-t.closed:
+		return nil, io.EOF
+	}
+}
+
+// WriteMessage discards the message
+func (t *benchTransport) WriteMessage(data []byte) error {
+	return nil
+}
+
+// Ping always succeeds
+func (t *benchTransport) Ping() error {
+	return nil
+}
+
+// Close ends the transport, making ReadMessage fail
+func (t *benchTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+// RemoteAddr returns a placeholder address
+func (t *benchTransport) RemoteAddr() string {
+	return "bench"
+}
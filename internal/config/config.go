@@ -1,14 +1,28 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/piyushgupta53/webterm/internal/logging"
+	"github.com/piyushgupta53/webterm/internal/types"
 	"github.com/sirupsen/logrus"
 )
 
+// instanceIDPattern restricts instance IDs to names usable as a directory
+var instanceIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// domainPattern matches domain names allowed by egress policies
+var domainPattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
 // Config holds all configuration for the application
 type Config struct {
 	// Server configuration
@@ -17,6 +31,84 @@ type Config struct {
 	ReadTimeout  time.Duration `json:"read_timeout"`
 	WriteTimeout time.Duration `json:"write_timeout"`
 
+	// Listen addresses; defaults to Host:Port
+	Listeners []ListenerConfig `json:"listeners"`
+
+	// TLS configuration
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+
+	// Client certificate (mTLS) authentication, enabled by setting a client CA
+	TLSClientCAFile   string `json:"tls_client_ca_file,omitempty"`
+	TLSClientAuth     string `json:"tls_client_auth,omitempty"`     // "require" or "optional"
+	TLSClientIdentity string `json:"tls_client_identity,omitempty"` // Certificate field used as the user name
+
+	// HTTP basic authentication against an htpasswd file with bcrypt hashes
+	HtpasswdFile string `json:"htpasswd_file,omitempty"`
+
+	// Bearer token authentication, from user:token entries and a file of them
+	AuthTokens     []string `json:"-"`
+	AuthTokensFile string   `json:"auth_tokens_file,omitempty"`
+
+	// Brute-force protection for authentication
+	AuthMaxFailures     int           `json:"auth_max_failures"`
+	AuthLockoutDuration time.Duration `json:"auth_lockout_duration"`
+
+	// Origins of pages allowed to open WebSocket and WebTransport
+	// connections besides the server's own, and the development escape
+	// hatch allowing any
+	AllowedOrigins         []string `json:"allowed_origins,omitempty"`
+	InsecureAllowAnyOrigin bool     `json:"insecure_allow_any_origin"`
+
+	// Append-only audit event file
+	AuditFile string `json:"audit_file,omitempty"`
+
+	// Hash-chained file recording every write of terminal input
+	InputAuditFile string `json:"input_audit_file,omitempty"`
+
+	// Alert rules evaluated against metrics and event rates
+	AlertRulesFile string `json:"alert_rules_file,omitempty"`
+
+	// OpenTelemetry traces, exported over OTLP/HTTP to this URL (such as
+	// http://collector:4318) and sampled at this ratio; an empty endpoint
+	// disables tracing
+	OTLPEndpoint     string  `json:"otlp_endpoint,omitempty"`
+	TraceSampleRatio float64 `json:"trace_sample_ratio"`
+
+	// Users allowed to perform admin actions such as approving sessions
+	Admins []string `json:"admins,omitempty"`
+
+	// Roles of users, as user:role entries, and the role of users without
+	// one; setting either enforces roles on session operations
+	Roles       []string `json:"roles,omitempty"`
+	DefaultRole string   `json:"default_role,omitempty"`
+
+	// Hold sessions with privileged profiles until an admin approves them
+	ApprovalRequired bool `json:"approval_required"`
+
+	// HashiCorp Vault issuing short-lived credentials requested by profiles
+	VaultAddress   string `json:"vault_address,omitempty"`
+	VaultTokenFile string `json:"vault_token_file,omitempty"`
+	VaultNamespace string `json:"vault_namespace,omitempty"`
+
+	// Key encrypting session output files at rest, optionally wrapped by a
+	// Vault transit key
+	TranscriptKeyFile  string `json:"transcript_key_file,omitempty"`
+	TranscriptVaultKey string `json:"transcript_vault_key,omitempty"`
+
+	// ACME (Let's Encrypt) automatic certificates, enabled by listing domains
+	ACMEDomains      []string `json:"acme_domains,omitempty"`
+	ACMECacheDir     string   `json:"acme_cache_dir"`
+	ACMEEmail        string   `json:"acme_email,omitempty"`
+	ACMEHTTPAddress  string   `json:"acme_http_address,omitempty"` // HTTP-01 challenge listener; empty disables it
+	ACMEDirectoryURL string   `json:"acme_directory_url,omitempty"`
+
+	// HTTP/3 (QUIC) listener, served on the same port over UDP
+	HTTP3Enabled bool `json:"http3_enabled"`
+
+	// WebTransport endpoint on the HTTP/3 listener
+	WebTransportEnabled bool `json:"webtransport_enabled"`
+
 	// Static files configuration
 	StaticDir string `json:"static_dir"`
 
@@ -24,8 +116,119 @@ type Config struct {
 	SessionTimeout time.Duration `json:"session_timeout"`
 	PipesDir       string        `json:"pipes_dir"`
 
+	// How long sessions stay listed after being terminated, and after their
+	// shell exits by itself
+	TerminatedRetention time.Duration `json:"terminated_retention"`
+	ExitedRetention     time.Duration `json:"exited_retention"`
+
+	// Sessions that may exist at once on the server, and per user and per
+	// client IP; 0 leaves users and IPs unlimited
+	MaxSessions        int `json:"max_sessions"`
+	MaxSessionsPerUser int `json:"max_sessions_per_user"`
+	MaxSessionsPerIP   int `json:"max_sessions_per_ip"`
+
+	// Name of this server among others sharing the pipes directory; its
+	// pipes are kept in a subdirectory of that name
+	InstanceID string `json:"instance_id,omitempty"`
+
+	// Use a pipes directory others can write to, and give each run its own
+	// subdirectory of it
+	PipesDirAllowInsecure bool `json:"pipes_dir_allow_insecure"`
+	PipesDirUnique        bool `json:"pipes_dir_unique"`
+
+	// Bytes of output per second each session may write to disk; 0 is unlimited
+	MaxOutputRate int64 `json:"max_output_rate"`
+
+	// Bytes of output sessions read at once, and how long they wait for more
+	// before writing it; sessions may ask for their own
+	OutputBufferSize   int           `json:"output_buffer_size"`
+	OutputFlushLatency time.Duration `json:"output_flush_latency"`
+
+	// Free space to keep on the pipes directory's volume; 0 disables the watchdog
+	DiskMinFreeMB int64 `json:"disk_min_free_mb"`
+
+	// Lock sessions after this long without input until the user re-authenticates
+	IdleLockTimeout time.Duration `json:"idle_lock_timeout,omitempty"`
+
+	// Earlier output replayed to clients as they attach; 0 disables replay
+	ScrollbackKB int64 `json:"scrollback_kb"`
+
+	// Message of the day shown to clients when they attach to a session
+	MOTDFile string `json:"motd_file,omitempty"`
+	MOTD     string `json:"-"`
+
+	// When clients disconnected by a shutdown should reconnect, and another
+	// node they may reconnect to meanwhile
+	ReconnectAfter time.Duration `json:"reconnect_after"`
+	ReconnectURL   string        `json:"reconnect_url,omitempty"`
+
+	// Users' stored snippets; empty keeps them in memory only
+	SnippetsFile string `json:"snippets_file,omitempty"`
+
+	// Serve filesystem completions for the working directory of pty sessions
+	CompletionEnabled bool `json:"completion_enabled"`
+
+	// Give sessions that ask for one an X display, shown over VNC
+	DisplayEnabled bool `json:"display_enabled"`
+
+	// Keep the state of the terminals of PTY sessions by emulating them
+	TerminalState bool `json:"terminal_state"`
+
+	// Serial backend configuration
+	SerialDevices []string `json:"serial_devices,omitempty"`
+
+	// Shells and command programs clients may ask for, as path patterns;
+	// empty allows any. DisableCommands refuses every command.
+	ShellAllowlist   []string `json:"shell_allowlist,omitempty"`
+	CommandAllowlist []string `json:"command_allowlist,omitempty"`
+	DisableCommands  bool     `json:"disable_commands"`
+
+	// Directories clients may start sessions and panes in, with everything
+	// beneath them, as path patterns; empty allows any
+	WorkingDirRoots []string `json:"working_dir_roots,omitempty"`
+
+	// System account sessions run as, by name or ID, or the account named
+	// like each session's owner; empty runs them as the server's user
+	RunAsUser  string `json:"run_as_user,omitempty"`
+	RunAsGroup string `json:"run_as_group,omitempty"`
+	RunAsOwner bool   `json:"run_as_owner"`
+
+	// Environment variables clients may set, as name patterns, and how many
+	// bytes of them; an empty blocklist blocks the variables blocked by
+	// default. EnvDropBlocked drops forbidden variables instead of refusing.
+	EnvAllowlist   []string `json:"env_allowlist,omitempty"`
+	EnvBlocklist   []string `json:"env_blocklist,omitempty"`
+	EnvMaxBytes    int      `json:"env_max_bytes"`
+	EnvDropBlocked bool     `json:"env_drop_blocked"`
+
+	// Session profiles configuration
+	ProfilesFile   string                    `json:"profiles_file,omitempty"`
+	DefaultProfile string                    `json:"default_profile,omitempty"`
+	HomeProfile    string                    `json:"home_profile,omitempty"`
+	Profiles       map[string]*types.Profile `json:"-"`
+
+	// Usage accounting configuration
+	UsageFile               string  `json:"usage_file,omitempty"`
+	UserMonthlyQuotaHours   float64 `json:"user_monthly_quota_hours"`
+	TenantMonthlyQuotaHours float64 `json:"tenant_monthly_quota_hours"`
+
+	// Container device passthrough configuration
+	ContainerDevices   []string `json:"container_devices,omitempty"`
+	ContainerAllowGPUs bool     `json:"container_allow_gpus"`
+
+	// Container warm pool configuration
+	ContainerPoolSize    int           `json:"container_pool_size"`
+	ContainerPoolMaxIdle time.Duration `json:"container_pool_max_idle"`
+
+	// WebRTC transport configuration
+	WebRTCEnabled    bool     `json:"webrtc_enabled"`
+	WebRTCICEServers []string `json:"webrtc_ice_servers,omitempty"`
+
 	// Logging configuration
 	LogLevel string `json:"log_level"`
+
+	// Log the contents of terminal input and output, which are otherwise redacted
+	LogAuditMode bool `json:"log_audit_mode"`
 }
 
 // Load creates a new configuration with defaults and environment variable overrides
@@ -40,6 +243,32 @@ func Load() (*Config, error) {
 		SessionTimeout: 30 * time.Minute,
 		PipesDir:       "/tmp/webterm-pipes",
 		LogLevel:       "info",
+
+		TerminatedRetention: 30 * time.Second,
+		ExitedRetention:     5 * time.Minute,
+
+		MaxSessions: 100,
+
+		EnvMaxBytes: 32 * 1024,
+
+		ScrollbackKB: 64,
+
+		ReconnectAfter: 5 * time.Second,
+
+		OutputBufferSize: 8192,
+
+		TLSClientAuth:     "require",
+		TLSClientIdentity: "cn",
+
+		AuthMaxFailures:     5,
+		AuthLockoutDuration: 15 * time.Minute,
+
+		ACMECacheDir:    "/var/lib/webterm/acme",
+		ACMEHTTPAddress: ":80",
+
+		ContainerPoolMaxIdle: 30 * time.Minute,
+
+		TraceSampleRatio: 1,
 	}
 
 	// Override with environment variables if present
@@ -63,16 +292,759 @@ func Load() (*Config, error) {
 		cfg.LogLevel = logLevel
 	}
 
+	if auditMode := os.Getenv("WEBTERM_LOG_AUDIT_MODE"); auditMode != "" {
+		if b, err := strconv.ParseBool(auditMode); err == nil {
+			cfg.LogAuditMode = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_LOG_AUDIT_MODE: %v", err)
+		}
+	}
+
 	if pipesDir := os.Getenv("WEBTERM_PIPES_DIR"); pipesDir != "" {
 		cfg.PipesDir = pipesDir
 	}
 
+	if instanceID := os.Getenv("WEBTERM_INSTANCE_ID"); instanceID != "" {
+		if !instanceIDPattern.MatchString(instanceID) {
+			return nil, fmt.Errorf("invalid WEBTERM_INSTANCE_ID: %s", instanceID)
+		}
+		cfg.InstanceID = instanceID
+	}
+
+	if allowInsecure := os.Getenv("WEBTERM_PIPES_DIR_ALLOW_INSECURE"); allowInsecure != "" {
+		if b, err := strconv.ParseBool(allowInsecure); err == nil {
+			cfg.PipesDirAllowInsecure = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_PIPES_DIR_ALLOW_INSECURE: %v", err)
+		}
+	}
+
+	if unique := os.Getenv("WEBTERM_PIPES_DIR_UNIQUE"); unique != "" {
+		if b, err := strconv.ParseBool(unique); err == nil {
+			cfg.PipesDirUnique = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_PIPES_DIR_UNIQUE: %v", err)
+		}
+	}
+
+	if maxOutputRate := os.Getenv("WEBTERM_MAX_OUTPUT_RATE"); maxOutputRate != "" {
+		if n, err := strconv.ParseInt(maxOutputRate, 10, 64); err == nil && n >= 0 {
+			cfg.MaxOutputRate = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_MAX_OUTPUT_RATE: %s", maxOutputRate)
+		}
+	}
+
+	if retention := os.Getenv("WEBTERM_TERMINATED_RETENTION"); retention != "" {
+		if d, err := time.ParseDuration(retention); err == nil && d >= 0 {
+			cfg.TerminatedRetention = d
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_TERMINATED_RETENTION: %s", retention)
+		}
+	}
+
+	if retention := os.Getenv("WEBTERM_EXITED_RETENTION"); retention != "" {
+		if d, err := time.ParseDuration(retention); err == nil && d >= 0 {
+			cfg.ExitedRetention = d
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_EXITED_RETENTION: %s", retention)
+		}
+	}
+
+	if maxSessions := os.Getenv("WEBTERM_MAX_SESSIONS"); maxSessions != "" {
+		if n, err := strconv.Atoi(maxSessions); err == nil && n > 0 {
+			cfg.MaxSessions = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_MAX_SESSIONS: must be a positive integer")
+		}
+	}
+
+	if perUser := os.Getenv("WEBTERM_MAX_SESSIONS_PER_USER"); perUser != "" {
+		if n, err := strconv.Atoi(perUser); err == nil && n >= 0 {
+			cfg.MaxSessionsPerUser = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_MAX_SESSIONS_PER_USER: must be a non-negative integer")
+		}
+	}
+
+	if perIP := os.Getenv("WEBTERM_MAX_SESSIONS_PER_IP"); perIP != "" {
+		if n, err := strconv.Atoi(perIP); err == nil && n >= 0 {
+			cfg.MaxSessionsPerIP = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_MAX_SESSIONS_PER_IP: must be a non-negative integer")
+		}
+	}
+
+	if bufferSize := os.Getenv("WEBTERM_OUTPUT_BUFFER_SIZE"); bufferSize != "" {
+		if n, err := strconv.Atoi(bufferSize); err == nil && n >= 512 && n <= 1<<20 {
+			cfg.OutputBufferSize = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_OUTPUT_BUFFER_SIZE: must be between 512 and 1048576 bytes")
+		}
+	}
+
+	if flushLatency := os.Getenv("WEBTERM_OUTPUT_FLUSH_LATENCY"); flushLatency != "" {
+		if d, err := time.ParseDuration(flushLatency); err == nil && d >= 0 && d <= time.Second {
+			cfg.OutputFlushLatency = d
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_OUTPUT_FLUSH_LATENCY: must be a duration between 0 and 1s")
+		}
+	}
+
+	if minFree := os.Getenv("WEBTERM_DISK_MIN_FREE_MB"); minFree != "" {
+		if n, err := strconv.ParseInt(minFree, 10, 64); err == nil && n >= 0 {
+			cfg.DiskMinFreeMB = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_DISK_MIN_FREE_MB: %s", minFree)
+		}
+	}
+
+	if idleLock := os.Getenv("WEBTERM_IDLE_LOCK_TIMEOUT"); idleLock != "" {
+		if d, err := time.ParseDuration(idleLock); err == nil && d >= 0 {
+			cfg.IdleLockTimeout = d
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_IDLE_LOCK_TIMEOUT: %s", idleLock)
+		}
+	}
+
+	if scrollback := os.Getenv("WEBTERM_SCROLLBACK_KB"); scrollback != "" {
+		if n, err := strconv.ParseInt(scrollback, 10, 64); err == nil && n >= 0 {
+			cfg.ScrollbackKB = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_SCROLLBACK_KB: %s", scrollback)
+		}
+	}
+
+	if motdFile := os.Getenv("WEBTERM_MOTD_FILE"); motdFile != "" {
+		cfg.MOTDFile = motdFile
+	}
+
+	if reconnectAfter := os.Getenv("WEBTERM_RECONNECT_AFTER"); reconnectAfter != "" {
+		if d, err := time.ParseDuration(reconnectAfter); err == nil && d >= 0 {
+			cfg.ReconnectAfter = d
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_RECONNECT_AFTER: %s", reconnectAfter)
+		}
+	}
+
+	if reconnectURL := os.Getenv("WEBTERM_RECONNECT_URL"); reconnectURL != "" {
+		u, err := url.Parse(reconnectURL)
+		if err != nil || u.Host == "" ||
+			(u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ws" && u.Scheme != "wss") {
+			return nil, fmt.Errorf("invalid WEBTERM_RECONNECT_URL: %s", reconnectURL)
+		}
+		cfg.ReconnectURL = reconnectURL
+	}
+
+	if certFile := os.Getenv("WEBTERM_TLS_CERT_FILE"); certFile != "" {
+		cfg.TLSCertFile = certFile
+	}
+
+	if keyFile := os.Getenv("WEBTERM_TLS_KEY_FILE"); keyFile != "" {
+		cfg.TLSKeyFile = keyFile
+	}
+
+	if clientCAFile := os.Getenv("WEBTERM_TLS_CLIENT_CA_FILE"); clientCAFile != "" {
+		cfg.TLSClientCAFile = clientCAFile
+	}
+
+	if clientAuth := os.Getenv("WEBTERM_TLS_CLIENT_AUTH"); clientAuth != "" {
+		if clientAuth != "require" && clientAuth != "optional" {
+			return nil, fmt.Errorf("invalid WEBTERM_TLS_CLIENT_AUTH: %s", clientAuth)
+		}
+		cfg.TLSClientAuth = clientAuth
+	}
+
+	if clientIdentity := os.Getenv("WEBTERM_TLS_CLIENT_IDENTITY"); clientIdentity != "" {
+		cfg.TLSClientIdentity = clientIdentity
+	}
+
+	if htpasswdFile := os.Getenv("WEBTERM_HTPASSWD_FILE"); htpasswdFile != "" {
+		cfg.HtpasswdFile = htpasswdFile
+	}
+
+	if tokens := os.Getenv("WEBTERM_AUTH_TOKENS"); tokens != "" {
+		cfg.AuthTokens = splitList(tokens)
+	}
+
+	if tokensFile := os.Getenv("WEBTERM_AUTH_TOKENS_FILE"); tokensFile != "" {
+		cfg.AuthTokensFile = tokensFile
+	}
+
+	if maxFailures := os.Getenv("WEBTERM_AUTH_MAX_FAILURES"); maxFailures != "" {
+		if n, err := strconv.Atoi(maxFailures); err == nil && n >= 0 {
+			cfg.AuthMaxFailures = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_AUTH_MAX_FAILURES: %s", maxFailures)
+		}
+	}
+
+	if lockout := os.Getenv("WEBTERM_AUTH_LOCKOUT_DURATION"); lockout != "" {
+		if d, err := time.ParseDuration(lockout); err == nil {
+			cfg.AuthLockoutDuration = d
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_AUTH_LOCKOUT_DURATION: %v", err)
+		}
+	}
+
+	if origins := os.Getenv("WEBTERM_ALLOWED_ORIGINS"); origins != "" {
+		for _, origin := range splitList(origins) {
+			normalized, err := normalizeOrigin(origin)
+			if err != nil {
+				return nil, fmt.Errorf("invalid WEBTERM_ALLOWED_ORIGINS: %v", err)
+			}
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, normalized)
+		}
+	}
+
+	if anyOrigin := os.Getenv("WEBTERM_INSECURE_ALLOW_ANY_ORIGIN"); anyOrigin != "" {
+		if b, err := strconv.ParseBool(anyOrigin); err == nil {
+			cfg.InsecureAllowAnyOrigin = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_INSECURE_ALLOW_ANY_ORIGIN: %v", err)
+		}
+	}
+
+	if auditFile := os.Getenv("WEBTERM_AUDIT_FILE"); auditFile != "" {
+		cfg.AuditFile = auditFile
+	}
+
+	if inputAuditFile := os.Getenv("WEBTERM_INPUT_AUDIT_FILE"); inputAuditFile != "" {
+		cfg.InputAuditFile = inputAuditFile
+	}
+
+	if alertRulesFile := os.Getenv("WEBTERM_ALERT_RULES_FILE"); alertRulesFile != "" {
+		cfg.AlertRulesFile = alertRulesFile
+	}
+
+	if otlpEndpoint := os.Getenv("WEBTERM_OTLP_ENDPOINT"); otlpEndpoint != "" {
+		cfg.OTLPEndpoint = otlpEndpoint
+	}
+
+	if ratio := os.Getenv("WEBTERM_TRACE_SAMPLE_RATIO"); ratio != "" {
+		if r, err := strconv.ParseFloat(ratio, 64); err == nil && r >= 0 && r <= 1 {
+			cfg.TraceSampleRatio = r
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_TRACE_SAMPLE_RATIO: %s", ratio)
+		}
+	}
+
+	if admins := os.Getenv("WEBTERM_ADMINS"); admins != "" {
+		cfg.Admins = splitList(admins)
+	}
+
+	if roles := os.Getenv("WEBTERM_ROLES"); roles != "" {
+		cfg.Roles = splitList(roles)
+	}
+
+	if defaultRole := os.Getenv("WEBTERM_DEFAULT_ROLE"); defaultRole != "" {
+		cfg.DefaultRole = defaultRole
+	}
+
+	if approvalRequired := os.Getenv("WEBTERM_APPROVAL_REQUIRED"); approvalRequired != "" {
+		if b, err := strconv.ParseBool(approvalRequired); err == nil {
+			cfg.ApprovalRequired = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_APPROVAL_REQUIRED: %v", err)
+		}
+	}
+
+	if vaultAddress := os.Getenv("WEBTERM_VAULT_ADDR"); vaultAddress != "" {
+		cfg.VaultAddress = vaultAddress
+	}
+
+	if vaultTokenFile := os.Getenv("WEBTERM_VAULT_TOKEN_FILE"); vaultTokenFile != "" {
+		cfg.VaultTokenFile = vaultTokenFile
+	}
+
+	if vaultNamespace := os.Getenv("WEBTERM_VAULT_NAMESPACE"); vaultNamespace != "" {
+		cfg.VaultNamespace = vaultNamespace
+	}
+
+	if keyFile := os.Getenv("WEBTERM_TRANSCRIPT_KEY_FILE"); keyFile != "" {
+		cfg.TranscriptKeyFile = keyFile
+	}
+
+	if vaultKey := os.Getenv("WEBTERM_TRANSCRIPT_VAULT_KEY"); vaultKey != "" {
+		cfg.TranscriptVaultKey = vaultKey
+	}
+
+	if domains := os.Getenv("WEBTERM_ACME_DOMAINS"); domains != "" {
+		cfg.ACMEDomains = splitList(domains)
+	}
+
+	if cacheDir := os.Getenv("WEBTERM_ACME_CACHE_DIR"); cacheDir != "" {
+		cfg.ACMECacheDir = cacheDir
+	}
+
+	if email := os.Getenv("WEBTERM_ACME_EMAIL"); email != "" {
+		cfg.ACMEEmail = email
+	}
+
+	if httpAddress, ok := os.LookupEnv("WEBTERM_ACME_HTTP_ADDR"); ok {
+		cfg.ACMEHTTPAddress = httpAddress
+	}
+
+	if directoryURL := os.Getenv("WEBTERM_ACME_DIRECTORY_URL"); directoryURL != "" {
+		cfg.ACMEDirectoryURL = directoryURL
+	}
+
+	if http3Enabled := os.Getenv("WEBTERM_HTTP3_ENABLED"); http3Enabled != "" {
+		if b, err := strconv.ParseBool(http3Enabled); err == nil {
+			cfg.HTTP3Enabled = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_HTTP3_ENABLED: %v", err)
+		}
+	}
+
+	if webTransportEnabled := os.Getenv("WEBTERM_WEBTRANSPORT_ENABLED"); webTransportEnabled != "" {
+		if b, err := strconv.ParseBool(webTransportEnabled); err == nil {
+			cfg.WebTransportEnabled = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_WEBTRANSPORT_ENABLED: %v", err)
+		}
+	}
+
+	if serialDevices := os.Getenv("WEBTERM_SERIAL_DEVICES"); serialDevices != "" {
+		cfg.SerialDevices = splitList(serialDevices)
+	}
+
+	if shells := os.Getenv("WEBTERM_SHELL_ALLOWLIST"); shells != "" {
+		cfg.ShellAllowlist = splitList(shells)
+	}
+
+	if commands := os.Getenv("WEBTERM_COMMAND_ALLOWLIST"); commands != "" {
+		cfg.CommandAllowlist = splitList(commands)
+	}
+
+	if roots := os.Getenv("WEBTERM_WORKING_DIR_ROOTS"); roots != "" {
+		cfg.WorkingDirRoots = splitList(roots)
+	}
+
+	if runAsUser := os.Getenv("WEBTERM_RUN_AS_USER"); runAsUser != "" {
+		cfg.RunAsUser = runAsUser
+	}
+
+	if runAsGroup := os.Getenv("WEBTERM_RUN_AS_GROUP"); runAsGroup != "" {
+		cfg.RunAsGroup = runAsGroup
+	}
+
+	if runAsOwner := os.Getenv("WEBTERM_RUN_AS_OWNER"); runAsOwner != "" {
+		if b, err := strconv.ParseBool(runAsOwner); err == nil {
+			cfg.RunAsOwner = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_RUN_AS_OWNER: %v", err)
+		}
+	}
+
+	if disableCommands := os.Getenv("WEBTERM_DISABLE_COMMANDS"); disableCommands != "" {
+		if b, err := strconv.ParseBool(disableCommands); err == nil {
+			cfg.DisableCommands = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_DISABLE_COMMANDS: %v", err)
+		}
+	}
+
+	if envAllowlist := os.Getenv("WEBTERM_ENV_ALLOWLIST"); envAllowlist != "" {
+		cfg.EnvAllowlist = splitList(envAllowlist)
+	}
+
+	if envBlocklist := os.Getenv("WEBTERM_ENV_BLOCKLIST"); envBlocklist != "" {
+		cfg.EnvBlocklist = splitList(envBlocklist)
+	}
+
+	if envMaxBytes := os.Getenv("WEBTERM_ENV_MAX_BYTES"); envMaxBytes != "" {
+		if n, err := strconv.Atoi(envMaxBytes); err == nil && n >= 0 {
+			cfg.EnvMaxBytes = n
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_ENV_MAX_BYTES: must be a non-negative integer")
+		}
+	}
+
+	if dropBlocked := os.Getenv("WEBTERM_ENV_DROP_BLOCKED"); dropBlocked != "" {
+		if b, err := strconv.ParseBool(dropBlocked); err == nil {
+			cfg.EnvDropBlocked = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_ENV_DROP_BLOCKED: %v", err)
+		}
+	}
+
+	if profilesFile := os.Getenv("WEBTERM_PROFILES_FILE"); profilesFile != "" {
+		cfg.ProfilesFile = profilesFile
+	}
+
+	if defaultProfile := os.Getenv("WEBTERM_DEFAULT_PROFILE"); defaultProfile != "" {
+		cfg.DefaultProfile = defaultProfile
+	}
+
+	if homeProfile := os.Getenv("WEBTERM_HOME_PROFILE"); homeProfile != "" {
+		cfg.HomeProfile = homeProfile
+	}
+
+	if snippetsFile := os.Getenv("WEBTERM_SNIPPETS_FILE"); snippetsFile != "" {
+		cfg.SnippetsFile = snippetsFile
+	}
+
+	if completionEnabled := os.Getenv("WEBTERM_COMPLETION_ENABLED"); completionEnabled != "" {
+		if b, err := strconv.ParseBool(completionEnabled); err == nil {
+			cfg.CompletionEnabled = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_COMPLETION_ENABLED: %v", err)
+		}
+	}
+
+	if displayEnabled := os.Getenv("WEBTERM_DISPLAY_ENABLED"); displayEnabled != "" {
+		if b, err := strconv.ParseBool(displayEnabled); err == nil {
+			cfg.DisplayEnabled = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_DISPLAY_ENABLED: %v", err)
+		}
+	}
+
+	if terminalState := os.Getenv("WEBTERM_TERMINAL_STATE"); terminalState != "" {
+		if b, err := strconv.ParseBool(terminalState); err == nil {
+			cfg.TerminalState = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_TERMINAL_STATE: %v", err)
+		}
+	}
+
+	if usageFile := os.Getenv("WEBTERM_USAGE_FILE"); usageFile != "" {
+		cfg.UsageFile = usageFile
+	}
+
+	if quota := os.Getenv("WEBTERM_USER_MONTHLY_QUOTA_HOURS"); quota != "" {
+		if hours, err := strconv.ParseFloat(quota, 64); err == nil && hours >= 0 {
+			cfg.UserMonthlyQuotaHours = hours
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_USER_MONTHLY_QUOTA_HOURS: %s", quota)
+		}
+	}
+
+	if quota := os.Getenv("WEBTERM_TENANT_MONTHLY_QUOTA_HOURS"); quota != "" {
+		if hours, err := strconv.ParseFloat(quota, 64); err == nil && hours >= 0 {
+			cfg.TenantMonthlyQuotaHours = hours
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_TENANT_MONTHLY_QUOTA_HOURS: %s", quota)
+		}
+	}
+
+	if devices := os.Getenv("WEBTERM_CONTAINER_DEVICES"); devices != "" {
+		cfg.ContainerDevices = splitList(devices)
+	}
+
+	if allowGPUs := os.Getenv("WEBTERM_CONTAINER_ALLOW_GPUS"); allowGPUs != "" {
+		if b, err := strconv.ParseBool(allowGPUs); err == nil {
+			cfg.ContainerAllowGPUs = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_CONTAINER_ALLOW_GPUS: %v", err)
+		}
+	}
+
+	if poolSize := os.Getenv("WEBTERM_CONTAINER_POOL_SIZE"); poolSize != "" {
+		if size, err := strconv.Atoi(poolSize); err == nil && size >= 0 {
+			cfg.ContainerPoolSize = size
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_CONTAINER_POOL_SIZE: %s", poolSize)
+		}
+	}
+
+	if maxIdle := os.Getenv("WEBTERM_CONTAINER_POOL_MAX_IDLE"); maxIdle != "" {
+		if d, err := time.ParseDuration(maxIdle); err == nil {
+			cfg.ContainerPoolMaxIdle = d
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_CONTAINER_POOL_MAX_IDLE: %v", err)
+		}
+	}
+
+	if webrtcEnabled := os.Getenv("WEBTERM_WEBRTC_ENABLED"); webrtcEnabled != "" {
+		if b, err := strconv.ParseBool(webrtcEnabled); err == nil {
+			cfg.WebRTCEnabled = b
+		} else {
+			return nil, fmt.Errorf("invalid WEBTERM_WEBRTC_ENABLED: %v", err)
+		}
+	}
+
+	if iceServers := os.Getenv("WEBTERM_WEBRTC_ICE_SERVERS"); iceServers != "" {
+		cfg.WebRTCICEServers = splitList(iceServers)
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("invalid TLS configuration: WEBTERM_TLS_CERT_FILE and WEBTERM_TLS_KEY_FILE must be set together")
+	}
+
+	listen := os.Getenv("WEBTERM_LISTEN")
+	if listen == "" {
+		listen = cfg.Address()
+	}
+	listeners, err := parseListeners(listen, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.ACMEEnabled())
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBTERM_LISTEN: %v", err)
+	}
+	cfg.Listeners = listeners
+
+	// QUIC always runs over TLS
+	if cfg.HTTP3Enabled && cfg.HTTP3Listener() == nil {
+		return nil, fmt.Errorf("invalid WEBTERM_HTTP3_ENABLED: HTTP/3 requires a TLS listener")
+	}
+
+	if cfg.WebTransportEnabled && !cfg.HTTP3Enabled {
+		return nil, fmt.Errorf("invalid WEBTERM_WEBTRANSPORT_ENABLED: WebTransport requires WEBTERM_HTTP3_ENABLED")
+	}
+
+	// Two-person approval needs someone other than the requester to approve
+	if cfg.ApprovalRequired && len(cfg.Admins) == 0 {
+		return nil, fmt.Errorf("invalid WEBTERM_APPROVAL_REQUIRED: approvals require WEBTERM_ADMINS")
+	}
+
+	// Without authentication, anyone could pick the account a session runs as
+	if cfg.RunAsOwner && !cfg.AuthEnabled() {
+		return nil, fmt.Errorf("invalid WEBTERM_RUN_AS_OWNER: running sessions as their owners requires authentication")
+	}
+
+	if cfg.ProfilesFile != "" {
+		profiles, err := loadProfiles(cfg.ProfilesFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBTERM_PROFILES_FILE: %v", err)
+		}
+		cfg.Profiles = profiles
+	}
+
+	if cfg.MOTDFile != "" {
+		motd, err := os.ReadFile(cfg.MOTDFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBTERM_MOTD_FILE: %v", err)
+		}
+		cfg.MOTD = strings.TrimSpace(string(motd))
+	}
+
+	// Unlocking needs a credential to check
+	if cfg.IdleLockTimeout > 0 && !cfg.AuthEnabled() {
+		return nil, fmt.Errorf("invalid WEBTERM_IDLE_LOCK_TIMEOUT: locking idle sessions requires authentication")
+	}
+
+	if cfg.VaultAddress != "" && cfg.VaultTokenFile == "" {
+		return nil, fmt.Errorf("invalid WEBTERM_VAULT_ADDR: WEBTERM_VAULT_TOKEN_FILE is required")
+	}
+
+	if cfg.TranscriptVaultKey != "" && (cfg.TranscriptKeyFile == "" || cfg.VaultAddress == "") {
+		return nil, fmt.Errorf("invalid WEBTERM_TRANSCRIPT_VAULT_KEY: WEBTERM_TRANSCRIPT_KEY_FILE and WEBTERM_VAULT_ADDR are required")
+	}
+
+	for name, profile := range cfg.Profiles {
+		if len(profile.Secrets) > 0 && cfg.VaultAddress == "" {
+			return nil, fmt.Errorf("invalid WEBTERM_PROFILES_FILE: profile %q requests secrets but WEBTERM_VAULT_ADDR is not set", name)
+		}
+	}
+
+	if cfg.DefaultProfile != "" {
+		if _, exists := cfg.Profiles[cfg.DefaultProfile]; !exists {
+			return nil, fmt.Errorf("invalid WEBTERM_DEFAULT_PROFILE: profile %q not found", cfg.DefaultProfile)
+		}
+	}
+
+	if cfg.HomeProfile != "" {
+		if _, exists := cfg.Profiles[cfg.HomeProfile]; !exists {
+			return nil, fmt.Errorf("invalid WEBTERM_HOME_PROFILE: profile %q not found", cfg.HomeProfile)
+		}
+	}
+
 	return cfg, nil
 }
 
+// loadProfiles reads session profiles from a JSON file keyed by profile name
+func loadProfiles(path string) (map[string]*types.Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles map[string]*types.Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, err
+	}
+
+	for name, profile := range profiles {
+		if profile == nil {
+			return nil, fmt.Errorf("profile %q is empty", name)
+		}
+		profile.Name = name
+
+		if err := validateEgress(profile); err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		if err := validateKerberos(profile); err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+	}
+
+	return profiles, nil
+}
+
+// validateEgress checks that a profile's egress policy can be enforced for
+// its backend
+func validateEgress(profile *types.Profile) error {
+	egress := profile.Egress
+	if egress == nil {
+		return nil
+	}
+
+	switch profile.Backend {
+	case types.SessionBackendContainer:
+		// Allowlists are enforced by a firewall in the container's own network
+		if len(egress.Allow) > 0 && profile.Container != nil {
+			if network := profile.Container.Network; network == "none" || network == "host" {
+				return fmt.Errorf("egress allowlist cannot be enforced on the %s network", network)
+			}
+		}
+	case types.SessionBackendSandbox:
+		if len(egress.Allow) > 0 {
+			return fmt.Errorf("sandbox profiles only support denying all egress")
+		}
+	default:
+		return fmt.Errorf("egress policies require the container or sandbox backend")
+	}
+
+	for _, destination := range egress.Allow {
+		if _, network, err := net.ParseCIDR(destination); err == nil {
+			if network.IP.To4() == nil {
+				return fmt.Errorf("egress destination %q is not IPv4", destination)
+			}
+			continue
+		}
+		if ip := net.ParseIP(destination); ip != nil {
+			if ip.To4() == nil {
+				return fmt.Errorf("egress destination %q is not IPv4", destination)
+			}
+			continue
+		}
+		if len(destination) > 253 || !domainPattern.MatchString(destination) {
+			return fmt.Errorf("invalid egress destination %q", destination)
+		}
+	}
+
+	return nil
+}
+
+// validateKerberos checks that a profile's Kerberos options can give
+// sessions a ticket
+func validateKerberos(profile *types.Profile) error {
+	kerberos := profile.Kerberos
+	if kerberos == nil {
+		return nil
+	}
+
+	if profile.Backend == types.SessionBackendSerial {
+		return fmt.Errorf("kerberos tickets are not available for serial profiles")
+	}
+	if kerberos.Keytab == "" && !kerberos.AllowDelegation {
+		return fmt.Errorf("kerberos needs a keytab or allow_delegation")
+	}
+	if kerberos.Keytab != "" {
+		if kerberos.Principal == "" {
+			return fmt.Errorf("kerberos keytab needs a principal")
+		}
+		if _, err := os.Stat(kerberos.Keytab); err != nil {
+			return fmt.Errorf("kerberos keytab: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// normalizeOrigin checks that an origin is a scheme and host, such as
+// https://term.example.com:8443, and lowercases it as browsers send it
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%q is not an http or https origin", origin)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("%q has more than a scheme and host", origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // Address returns the full server address
 func (c *Config) Address() string {
-	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// InstancePipesDir returns the pipes directory of this instance
+func (c *Config) InstancePipesDir() string {
+	if c.InstanceID == "" {
+		return c.PipesDir
+	}
+	return filepath.Join(c.PipesDir, c.InstanceID)
+}
+
+// ACMEEnabled reports whether certificates are obtained automatically via ACME
+func (c *Config) ACMEEnabled() bool {
+	return len(c.ACMEDomains) > 0
+}
+
+// HTTP3Listener returns the TCP listener whose address and certificate the
+// HTTP/3 listener shares, which is the first TLS listener over TCP
+func (c *Config) HTTP3Listener() *ListenerConfig {
+	for i := range c.Listeners {
+		if c.Listeners[i].Network == "tcp" && c.Listeners[i].TLS {
+			return &c.Listeners[i]
+		}
+	}
+	return nil
+}
+
+// RolesEnabled reports whether roles are enforced on session operations
+func (c *Config) RolesEnabled() bool {
+	return len(c.Roles) > 0 || c.DefaultRole != ""
+}
+
+// RunAsEnabled reports whether sessions run as another system account than
+// the server's
+func (c *Config) RunAsEnabled() bool {
+	return c.RunAsUser != "" || c.RunAsOwner
+}
+
+// AuthEnabled reports whether any authentication method is configured
+func (c *Config) AuthEnabled() bool {
+	return c.TLSClientCAFile != "" || c.HtpasswdFile != "" || len(c.AuthTokens) > 0 || c.AuthTokensFile != ""
+}
+
+// Redacted returns a copy of the configuration that is safe to log, with
+// the bearer tokens masked
+func (c *Config) Redacted() Config {
+	redacted := *c
+	if len(c.AuthTokens) > 0 {
+		redacted.AuthTokens = make([]string, len(c.AuthTokens))
+		for i := range redacted.AuthTokens {
+			redacted.AuthTokens[i] = "REDACTED"
+		}
+	}
+	return redacted
+}
+
+// IsAdmin reports whether user is listed in WEBTERM_ADMINS
+func (c *Config) IsAdmin(user string) bool {
+	for _, admin := range c.Admins {
+		if admin == user {
+			return true
+		}
+	}
+	return false
 }
 
 // SetupLogging configures the global logger based on configuration
@@ -87,5 +1059,38 @@ func (c *Config) SetupLogging() error {
 		TimestampFormat: time.RFC3339,
 	})
 
+	// Keystrokes can include passwords, so terminal contents stay out of
+	// the log unless audit mode is explicitly enabled
+	logging.SetAuditMode(c.LogAuditMode)
+	logrus.AddHook(logging.SensitiveHook{})
+	if c.LogAuditMode {
+		logrus.Warn("Log audit mode enabled: terminal input and output will be logged")
+	}
+
 	return nil
 }
+
+// logLevelCycle is the order CycleLogLevel steps through, from least to most
+// verbose
+var logLevelCycle = []logrus.Level{
+	logrus.ErrorLevel,
+	logrus.WarnLevel,
+	logrus.InfoLevel,
+	logrus.DebugLevel,
+	logrus.TraceLevel,
+}
+
+// CycleLogLevel switches logging to the next more verbose level, wrapping
+// around from trace to error, and returns the new level
+func CycleLogLevel() logrus.Level {
+	next := logLevelCycle[0]
+	current := logrus.GetLevel()
+	for i, level := range logLevelCycle {
+		if level == current && i+1 < len(logLevelCycle) {
+			next = logLevelCycle[i+1]
+		}
+	}
+
+	logrus.SetLevel(next)
+	return next
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ListenerConfig describes one address the server accepts connections on
+type ListenerConfig struct {
+	Network  string `json:"network"` // "tcp" or "unix"
+	Address  string `json:"address"`
+	TLS      bool   `json:"tls"`
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+}
+
+// String returns the listener in WEBTERM_LISTEN syntax, without TLS file paths
+func (l ListenerConfig) String() string {
+	if l.Network == "unix" {
+		return "unix:" + l.Address
+	}
+	if l.TLS {
+		return "https://" + l.Address
+	}
+	return "http://" + l.Address
+}
+
+// parseListeners parses a comma-separated list of listen addresses:
+//
+//	host:port                     TLS when a global certificate or ACME is configured
+//	http://host:port              always plain HTTP
+//	https://host:port             TLS with the global or ACME certificate
+//	https://host:port?cert=a&key=b  TLS with its own certificate
+//	unix:/path/to/socket          plain HTTP on a Unix socket
+//
+// TLS listeners without certificate files get their certificates from ACME.
+func parseListeners(value, certFile, keyFile string, acme bool) ([]ListenerConfig, error) {
+	var listeners []ListenerConfig
+
+	for _, entry := range splitList(value) {
+		listener, err := parseListener(entry, certFile, keyFile, acme)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", entry, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no listen addresses")
+	}
+
+	return listeners, nil
+}
+
+func parseListener(entry, certFile, keyFile string, acme bool) (ListenerConfig, error) {
+	if path, ok := strings.CutPrefix(entry, "unix:"); ok {
+		if path == "" {
+			return ListenerConfig{}, fmt.Errorf("missing socket path")
+		}
+		return ListenerConfig{Network: "unix", Address: path}, nil
+	}
+
+	if !strings.Contains(entry, "://") {
+		if _, _, err := net.SplitHostPort(entry); err != nil {
+			return ListenerConfig{}, err
+		}
+		return ListenerConfig{
+			Network:  "tcp",
+			Address:  entry,
+			TLS:      (certFile != "" && keyFile != "") || acme,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		}, nil
+	}
+
+	u, err := url.Parse(entry)
+	if err != nil {
+		return ListenerConfig{}, err
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return ListenerConfig{}, err
+	}
+
+	listener := ListenerConfig{Network: "tcp", Address: u.Host}
+
+	switch u.Scheme {
+	case "http":
+	case "https":
+		listener.TLS = true
+		listener.CertFile = certFile
+		listener.KeyFile = keyFile
+
+		query := u.Query()
+		if query.Has("cert") || query.Has("key") {
+			listener.CertFile = query.Get("cert")
+			listener.KeyFile = query.Get("key")
+		}
+		if (listener.CertFile == "" || listener.KeyFile == "") && !acme {
+			return ListenerConfig{}, fmt.Errorf("https listener needs a certificate and key")
+		}
+	default:
+		return ListenerConfig{}, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	return listener, nil
+}
@@ -0,0 +1,15 @@
+//go:build !openbsd
+
+package diskwatch
+
+import "syscall"
+
+// diskSpace returns the bytes available to the server and the size of the
+// volume holding path
+func diskSpace(path string) (free, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Blocks) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,13 @@
+package diskwatch
+
+import "syscall"
+
+// diskSpace returns the bytes available to the server and the size of the
+// volume holding path. OpenBSD prefixes the fields of statfs with f_.
+func diskSpace(path string) (free, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return uint64(stat.F_bavail) * uint64(stat.F_bsize), uint64(stat.F_blocks) * uint64(stat.F_bsize), nil
+}
@@ -0,0 +1,245 @@
+// Package diskwatch keeps the volume holding session output files from
+// filling up
+package diskwatch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/transcript"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrLowDiskSpace is returned for new sessions while free space is below the threshold
+var ErrLowDiskSpace = errors.New("disk space for session output is low")
+
+// truncatedMarker is written to output files emptied to free space, so
+// attached clients see why earlier output is gone
+const truncatedMarker = "\r\n[webterm: earlier output removed, disk space low]\r\n"
+
+// Status describes the free space of the watched volume
+type Status struct {
+	Path           string    `json:"path"`
+	FreeBytes      uint64    `json:"free_bytes"`
+	TotalBytes     uint64    `json:"total_bytes"`
+	MinFreeBytes   uint64    `json:"min_free_bytes"`
+	Degraded       bool      `json:"degraded"`
+	TruncatedFiles int       `json:"truncated_files"` // Output files emptied since startup
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+// Watchdog checks the free space of the pipes directory's volume. Below the
+// threshold it refuses new sessions and empties the output files written
+// least recently until enough space is free again.
+type Watchdog struct {
+	dir     string
+	minFree uint64
+
+	mutex  sync.Mutex
+	status Status
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+
+	// Encrypts the marker left in emptied files; nil writes it in plaintext
+	sealer *transcript.Sealer
+}
+
+// NewWatchdog creates a watchdog keeping at least minFree bytes free on the
+// volume holding dir
+func NewWatchdog(dir string, minFree uint64) *Watchdog {
+	return &Watchdog{
+		dir:      dir,
+		minFree:  minFree,
+		status:   Status{Path: dir, MinFreeBytes: minFree},
+		stopChan: make(chan struct{}),
+	}
+}
+
+// SetSealer sets how session output files are encrypted at rest. It must be
+// called before Start.
+func (w *Watchdog) SetSealer(sealer *transcript.Sealer) {
+	w.sealer = sealer
+}
+
+// Start checks free space now and then every interval until Stop is called
+func (w *Watchdog) Start(interval time.Duration) {
+	w.check()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.check()
+			case <-w.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the periodic checks
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopChan)
+	})
+}
+
+// Status returns the result of the latest check
+func (w *Watchdog) Status() Status {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.status
+}
+
+// CheckNewSession refuses new sessions while disk space is low
+func (w *Watchdog) CheckNewSession() error {
+	if w.Status().Degraded {
+		return ErrLowDiskSpace
+	}
+	return nil
+}
+
+// CheckReady reports whether the server is degraded by low disk space
+func (w *Watchdog) CheckReady() error {
+	status := w.Status()
+	if status.Degraded {
+		return fmt.Errorf("%w: %d bytes free, %d required", ErrLowDiskSpace, status.FreeBytes, status.MinFreeBytes)
+	}
+	return nil
+}
+
+// check measures free space, reclaiming some if it is below the threshold
+func (w *Watchdog) check() {
+	free, total, err := diskSpace(w.dir)
+	if err != nil {
+		logrus.WithError(err).WithField("path", w.dir).Warn("Failed to check free disk space")
+		return
+	}
+
+	truncated := 0
+	if free < w.minFree {
+		truncated, free = w.reclaim(free)
+	}
+
+	w.mutex.Lock()
+	wasDegraded := w.status.Degraded
+	w.status.FreeBytes = free
+	w.status.TotalBytes = total
+	w.status.Degraded = free < w.minFree
+	w.status.TruncatedFiles += truncated
+	w.status.CheckedAt = time.Now()
+	degraded := w.status.Degraded
+	w.mutex.Unlock()
+
+	fields := logrus.Fields{
+		"path":           w.dir,
+		"free_bytes":     free,
+		"min_free_bytes": w.minFree,
+	}
+	if degraded && !wasDegraded {
+		logrus.WithFields(fields).Error("Disk space low, refusing new sessions")
+	} else if !degraded && wasDegraded {
+		logrus.WithFields(fields).Info("Disk space recovered, accepting new sessions")
+	}
+}
+
+// reclaim empties output files, least recently written first, until enough
+// space is free. It returns how many files were emptied and the free space.
+func (w *Watchdog) reclaim(free uint64) (int, uint64) {
+	marker := w.sealer.Seal(0, []byte(truncatedMarker))
+	files, err := outputFiles(w.dir, int64(len(marker)))
+	if err != nil {
+		logrus.WithError(err).WithField("path", w.dir).Warn("Failed to list output files")
+		return 0, free
+	}
+
+	truncated := 0
+	for _, file := range files {
+		if free >= w.minFree {
+			break
+		}
+
+		if err := truncateOutput(file.path, marker); err != nil {
+			logrus.WithError(err).WithField("file", file.path).Warn("Failed to truncate output file")
+			continue
+		}
+		truncated++
+
+		logrus.WithFields(logrus.Fields{
+			"file":  file.path,
+			"bytes": file.size,
+		}).Warn("Truncated session output to free disk space")
+
+		if free, _, err = diskSpace(w.dir); err != nil {
+			break
+		}
+	}
+
+	return truncated, free
+}
+
+// outputFile is a session output file that could be truncated
+type outputFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// outputFiles lists output and stderr files in dir larger than minSize,
+// least recently written first
+func outputFiles(dir string, minSize int64) ([]outputFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []outputFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".output") || strings.HasSuffix(name, ".stderr")) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.Size() <= minSize {
+			continue
+		}
+
+		files = append(files, outputFile{
+			path:    filepath.Join(dir, name),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	return files, nil
+}
+
+// truncateOutput empties an output file, leaving a marker. Its session keeps
+// appending to it, and readers start over from the beginning.
+func truncateOutput(path string, marker []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.Write(marker)
+	return err
+}
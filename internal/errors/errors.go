@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -17,6 +18,8 @@ const (
 	ErrSessionCreateFailed    ErrorCode = "SESSION_CREATE_FAILED"
 	ErrSessionTerminateFailed ErrorCode = "SESSION_TERMINATE_FAILED"
 	ErrSessionInvalidState    ErrorCode = "SESSION_INVALID_STATE"
+	ErrSessionLimit           ErrorCode = "SESSION_LIMIT"
+	ErrSessionQuota           ErrorCode = "SESSION_QUOTA_EXCEEDED"
 
 	// WebSocket errors
 	ErrWebSocketUpgradeFailed    ErrorCode = "WEBSOCKET_UPGRADE_FAILED"
@@ -115,6 +118,21 @@ func NewSessionTerminateFailedError(sessionID string, cause error) *AppError {
 		WithCause(cause)
 }
 
+func NewSessionLimitError(limit int) *AppError {
+	return NewAppError(ErrSessionLimit, "Server session limit reached", http.StatusServiceUnavailable).
+		WithDetails(fmt.Sprintf("The server runs at most %d sessions at once", limit)).
+		WithContext("limit", limit).
+		WithRetryable(true)
+}
+
+func NewSessionQuotaError(scope string, limit int) *AppError {
+	return NewAppError(ErrSessionQuota, "Session quota exceeded", http.StatusTooManyRequests).
+		WithDetails(fmt.Sprintf("At most %d sessions are allowed per %s; end one to start another", limit, scope)).
+		WithContext("scope", scope).
+		WithContext("limit", limit).
+		WithRetryable(true)
+}
+
 func NewWebSocketUpgradeFailedError(cause error) *AppError {
 	return NewAppError(ErrWebSocketUpgradeFailed, "Failed to upgrade WebSocket connection", http.StatusBadRequest).
 		WithCause(cause)
@@ -249,11 +267,9 @@ func WriteErrorResponse(w http.ResponseWriter, err error) {
 	}
 }
 
-// Helper function to write JSON (avoiding import cycles)
-func writeJSON(_ http.ResponseWriter, _ interface{}) error {
-	// This would normally use json.NewEncoder(w).Encode(data)
-	// but we're avoiding imports for this example
-	return nil
+// writeJSON writes data as the JSON body of a response
+func writeJSON(w http.ResponseWriter, data interface{}) error {
+	return json.NewEncoder(w).Encode(data)
 }
 
 // Recovery middleware for panic handling
@@ -7,6 +7,7 @@ import (
 	"syscall"
 	"time"
 
+	apperrors "github.com/piyushgupta53/webterm/internal/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,6 +18,8 @@ type ResourceLimits struct {
 	MaxFileDescriptors int `json:"max_file_descriptors"`
 	MaxMemoryMB        int `json:"max_memory_mb"`
 	MaxGoroutines      int `json:"max_goroutines"`
+	MaxSessionsPerUser int `json:"max_sessions_per_user"` // 0 means unlimited
+	MaxSessionsPerIP   int `json:"max_sessions_per_ip"`   // 0 means unlimited
 }
 
 // DefaultResourceLimits returns sensible default limits
@@ -34,7 +37,9 @@ func DefaultResourceLimits() *ResourceLimits {
 type ResourceMonitor struct {
 	limits             *ResourceLimits
 	mutex              sync.RWMutex
-	currentSessions    int
+	sessions           map[string]sessionHolder // Counted sessions by session ID
+	userSessions       map[string]int           // Counted sessions by owner
+	ipSessions         map[string]int           // Counted sessions by client IP
 	currentConnections int
 	warningThreshold   float64 // Percentage at which to warn
 
@@ -42,6 +47,12 @@ type ResourceMonitor struct {
 	metricsCallback func(goroutines int64, memoryMB float64)
 }
 
+// sessionHolder is who a counted session is held against
+type sessionHolder struct {
+	user string
+	ip   string
+}
+
 // NewResourceMonitor creates a new resource monitor
 func NewResourceMonitor(limits *ResourceLimits) *ResourceMonitor {
 	if limits == nil {
@@ -50,6 +61,9 @@ func NewResourceMonitor(limits *ResourceLimits) *ResourceMonitor {
 
 	return &ResourceMonitor{
 		limits:           limits,
+		sessions:         make(map[string]sessionHolder),
+		userSessions:     make(map[string]int),
+		ipSessions:       make(map[string]int),
 		warningThreshold: 0.8, // Warn at 80%
 	}
 }
@@ -61,19 +75,30 @@ func (rm *ResourceMonitor) SetMetricsCallback(callback func(int64, float64)) {
 	rm.metricsCallback = callback
 }
 
-// CheckSessionLimit checks if a new session can be created
-func (rm *ResourceMonitor) CheckSessionLimit() error {
+// CheckSessionLimit checks if a new session can be created for a user
+// from a client IP. Past the server-wide limit it returns a 503 error; past
+// the user's or the IP's own limit, a 429 error.
+func (rm *ResourceMonitor) CheckSessionLimit(user, ip string) error {
 	rm.mutex.RLock()
 	defer rm.mutex.RUnlock()
 
-	if rm.currentSessions >= rm.limits.MaxSessions {
-		return fmt.Errorf("session limit exceeded: %d/%d", rm.currentSessions, rm.limits.MaxSessions)
+	currentSessions := len(rm.sessions)
+	if currentSessions >= rm.limits.MaxSessions {
+		return apperrors.NewSessionLimitError(rm.limits.MaxSessions)
+	}
+
+	if rm.limits.MaxSessionsPerUser > 0 && user != "" && rm.userSessions[user] >= rm.limits.MaxSessionsPerUser {
+		return apperrors.NewSessionQuotaError("user", rm.limits.MaxSessionsPerUser)
+	}
+
+	if rm.limits.MaxSessionsPerIP > 0 && ip != "" && rm.ipSessions[ip] >= rm.limits.MaxSessionsPerIP {
+		return apperrors.NewSessionQuotaError("ip", rm.limits.MaxSessionsPerIP)
 	}
 
 	// Warning threshold
-	if float64(rm.currentSessions) > float64(rm.limits.MaxSessions)*rm.warningThreshold {
+	if float64(currentSessions) > float64(rm.limits.MaxSessions)*rm.warningThreshold {
 		logrus.WithFields(logrus.Fields{
-			"current_sessions": rm.currentSessions,
+			"current_sessions": currentSessions,
 			"max_sessions":     rm.limits.MaxSessions,
 		}).Warn("Approaching session limit")
 	}
@@ -101,20 +126,41 @@ func (rm *ResourceMonitor) CheckConnectionLimit() error {
 	return nil
 }
 
-// AddSession increments the session counter
-func (rm *ResourceMonitor) AddSession() {
+// AddSession counts a session against the limits of its user and client IP
+func (rm *ResourceMonitor) AddSession(sessionID, user, ip string) {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
-	rm.currentSessions++
+
+	if _, exists := rm.sessions[sessionID]; exists {
+		return
+	}
+	rm.sessions[sessionID] = sessionHolder{user: user, ip: ip}
+	rm.userSessions[user]++
+	rm.ipSessions[ip]++
 }
 
-// RemoveSession decrements the session counter
-func (rm *ResourceMonitor) RemoveSession() {
+// RemoveSession stops counting a session. Removing a session that is not
+// counted does nothing.
+func (rm *ResourceMonitor) RemoveSession(sessionID string) {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
-	if rm.currentSessions > 0 {
-		rm.currentSessions--
+
+	holder, exists := rm.sessions[sessionID]
+	if !exists {
+		return
+	}
+	delete(rm.sessions, sessionID)
+	release(rm.userSessions, holder.user)
+	release(rm.ipSessions, holder.ip)
+}
+
+// release decrements a per-holder count, forgetting holders left with none
+func release(counts map[string]int, holder string) {
+	if counts[holder] <= 1 {
+		delete(counts, holder)
+		return
 	}
+	counts[holder]--
 }
 
 // AddConnection increments the connection counter
@@ -206,11 +252,15 @@ func (rm *ResourceMonitor) checkFileDescriptors() error {
 	return nil
 }
 
-// getCurrentFileDescriptors gets the current file descriptor count
+// getCurrentFileDescriptors gets the current file descriptor count, or 0
+// where the platform cannot report it
 func (rm *ResourceMonitor) getCurrentFileDescriptors() int {
-	// This is a simplified implementation
-	// In production, you might want to read from /proc/self/fd or use lsof
-	return 0
+	fds, err := openFileDescriptors()
+	if err != nil {
+		logrus.WithError(err).Debug("Could not count file descriptors")
+		return 0
+	}
+	return fds
 }
 
 // StartMonitoring starts periodic resource monitoring
@@ -236,18 +286,29 @@ func (rm *ResourceMonitor) GetCurrentUsage() map[string]interface{} {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	return map[string]interface{}{
-		"sessions":    rm.currentSessions,
+	usage := map[string]interface{}{
+		"sessions":    len(rm.sessions),
 		"connections": rm.currentConnections,
 		"memory_mb":   float64(m.Alloc) / 1024 / 1024,
 		"goroutines":  runtime.NumGoroutine(),
 		"limits": map[string]interface{}{
-			"max_sessions":    rm.limits.MaxSessions,
-			"max_connections": rm.limits.MaxConnections,
-			"max_memory_mb":   rm.limits.MaxMemoryMB,
-			"max_goroutines":  rm.limits.MaxGoroutines,
+			"max_sessions":          rm.limits.MaxSessions,
+			"max_sessions_per_user": rm.limits.MaxSessionsPerUser,
+			"max_sessions_per_ip":   rm.limits.MaxSessionsPerIP,
+			"max_connections":       rm.limits.MaxConnections,
+			"max_memory_mb":         rm.limits.MaxMemoryMB,
+			"max_goroutines":        rm.limits.MaxGoroutines,
+			"max_file_descriptors":  rm.limits.MaxFileDescriptors,
 		},
 	}
+
+	// Left out where the platform cannot report them
+	if process, err := ReadProcessUsage(); err == nil {
+		usage["file_descriptors"] = process.OpenFileDescriptors
+		usage["resident_memory_mb"] = float64(process.ResidentBytes) / 1024 / 1024
+	}
+
+	return usage
 }
 
 // UpdateLimits updates the resource limits
@@ -0,0 +1,24 @@
+package limits
+
+// ProcessUsage is the operating system's view of the server process, which
+// unlike Go's runtime statistics includes memory outside the Go heap
+type ProcessUsage struct {
+	OpenFileDescriptors int    `json:"open_file_descriptors"`
+	ResidentBytes       uint64 `json:"resident_bytes"`
+}
+
+// ReadProcessUsage reads the open file descriptors and resident memory of
+// the server process, from /proc on Linux and libproc on macOS
+func ReadProcessUsage() (ProcessUsage, error) {
+	fds, err := openFileDescriptors()
+	if err != nil {
+		return ProcessUsage{}, err
+	}
+
+	resident, err := residentMemoryBytes()
+	if err != nil {
+		return ProcessUsage{}, err
+	}
+
+	return ProcessUsage{OpenFileDescriptors: fds, ResidentBytes: resident}, nil
+}
@@ -0,0 +1,49 @@
+//go:build darwin && cgo
+
+package limits
+
+/*
+#include <libproc.h>
+#include <sys/proc_info.h>
+#include <unistd.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// openFileDescriptors lists the server's file descriptors with proc_pidinfo
+func openFileDescriptors() (int, error) {
+	pid := C.int(C.getpid())
+	entrySize := int(unsafe.Sizeof(C.struct_proc_fdinfo{}))
+
+	// Asked without a buffer, proc_pidinfo returns the size the list needs
+	size := C.proc_pidinfo(pid, C.PROC_PIDLISTFDS, 0, nil, 0)
+	if size <= 0 {
+		return 0, fmt.Errorf("proc_pidinfo failed to size the file descriptor list")
+	}
+
+	// Leave room for descriptors opened in between
+	entries := make([]C.struct_proc_fdinfo, int(size)/entrySize+16)
+	size = C.proc_pidinfo(pid, C.PROC_PIDLISTFDS, 0, unsafe.Pointer(&entries[0]), C.int(len(entries)*entrySize))
+	if size <= 0 {
+		return 0, fmt.Errorf("proc_pidinfo failed to list file descriptors")
+	}
+
+	return int(size) / entrySize, nil
+}
+
+// residentMemoryBytes reads the resident size from the task info
+// proc_pidinfo reports
+func residentMemoryBytes() (uint64, error) {
+	var info C.struct_proc_taskinfo
+	infoSize := C.int(unsafe.Sizeof(info))
+
+	if C.proc_pidinfo(C.int(C.getpid()), C.PROC_PIDTASKINFO, 0, unsafe.Pointer(&info), infoSize) != infoSize {
+		return 0, fmt.Errorf("proc_pidinfo failed to read task info")
+	}
+
+	return uint64(info.pti_resident_size), nil
+}
@@ -0,0 +1,40 @@
+//go:build linux
+
+package limits
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// openFileDescriptors counts the entries of /proc/self/fd
+func openFileDescriptors() (int, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	// Reading the directory opened one more
+	return len(entries) - 1, nil
+}
+
+// residentMemoryBytes reads the resident set size from /proc/self/statm,
+// which counts it in pages
+func residentMemoryBytes() (uint64, error) {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected /proc/self/statm format")
+	}
+
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected /proc/self/statm format: %w", err)
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !(darwin && cgo)
+
+package limits
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// openFileDescriptors is not implemented on this platform
+func openFileDescriptors() (int, error) {
+	return 0, fmt.Errorf("process usage is not supported on %s", runtime.GOOS)
+}
+
+// residentMemoryBytes is not implemented on this platform
+func residentMemoryBytes() (uint64, error) {
+	return 0, fmt.Errorf("process usage is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,68 @@
+// Package logging keeps terminal contents out of the application log
+package logging
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FieldData is the log field holding terminal input or output. Keystrokes
+// can include passwords, so its value is redacted unless audit mode is on.
+const FieldData = "data"
+
+// sensitiveFields are redacted from every log entry outside audit mode
+var sensitiveFields = []string{FieldData}
+
+// auditMode is set when terminal contents may be logged
+var auditMode int32
+
+// SetAuditMode allows or forbids terminal contents in the log
+func SetAuditMode(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&auditMode, value)
+}
+
+// AuditMode reports whether terminal contents are logged
+func AuditMode() bool {
+	return atomic.LoadInt32(&auditMode) == 1
+}
+
+// SensitiveHook redacts sensitive fields from log entries before they are
+// written, whatever level or logger they come from
+type SensitiveHook struct{}
+
+// Levels applies the hook at every level
+func (SensitiveHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire replaces sensitive values with their length outside audit mode
+func (SensitiveHook) Fire(entry *logrus.Entry) error {
+	if AuditMode() {
+		return nil
+	}
+
+	for _, field := range sensitiveFields {
+		if value, exists := entry.Data[field]; exists {
+			entry.Data[field] = redact(value)
+		}
+	}
+	return nil
+}
+
+// redact describes a value without revealing it
+func redact(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("[redacted %d bytes]", len(v))
+	case []byte:
+		return fmt.Sprintf("[redacted %d bytes]", len(v))
+	default:
+		return "[redacted]"
+	}
+}
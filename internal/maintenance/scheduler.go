@@ -0,0 +1,223 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultBlockBefore is how long before a scheduled shutdown new sessions are refused
+const DefaultBlockBefore = 10 * time.Minute
+
+// ErrSessionsBlocked is returned for new sessions close to a scheduled shutdown
+var ErrSessionsBlocked = errors.New("new sessions are blocked for scheduled maintenance")
+
+// ErrNotScheduled is returned when cancelling maintenance that is not scheduled
+var ErrNotScheduled = errors.New("no maintenance is scheduled")
+
+// warningTimes are how long before the shutdown clients are warned
+var warningTimes = []time.Duration{
+	time.Hour,
+	30 * time.Minute,
+	15 * time.Minute,
+	10 * time.Minute,
+	5 * time.Minute,
+	time.Minute,
+}
+
+// Notifier delivers an announcement to every client, with a banner level
+type Notifier func(text, level string)
+
+// State describes the scheduled maintenance, if any
+type State struct {
+	Scheduled       bool       `json:"scheduled"`
+	ShutdownAt      *time.Time `json:"shutdown_at,omitempty"`
+	BlockSessionsAt *time.Time `json:"block_sessions_at,omitempty"`
+	SessionsBlocked bool       `json:"sessions_blocked"`
+	Reason          string     `json:"reason,omitempty"`
+	ScheduledBy     string     `json:"scheduled_by,omitempty"`
+}
+
+// Scheduler counts down to a scheduled shutdown, warning clients on the way
+type Scheduler struct {
+	mutex       sync.Mutex
+	notify      Notifier
+	shutdownAt  time.Time
+	blockBefore time.Duration
+	reason      string
+	scheduledBy string
+	cancel      chan struct{}
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewScheduler creates a scheduler that announces maintenance through notify
+func NewScheduler(notify Notifier) *Scheduler {
+	return &Scheduler{
+		notify: notify,
+		done:   make(chan struct{}),
+	}
+}
+
+// Schedule sets the shutdown time, replacing any earlier schedule. New
+// sessions are refused from blockBefore ahead of the shutdown.
+func (s *Scheduler) Schedule(shutdownAt time.Time, blockBefore time.Duration, reason, user string) error {
+	if !shutdownAt.After(time.Now()) {
+		return fmt.Errorf("shutdown time must be in the future")
+	}
+	if blockBefore < 0 {
+		return fmt.Errorf("block window must not be negative")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cancel != nil {
+		close(s.cancel)
+	}
+
+	s.shutdownAt = shutdownAt
+	s.blockBefore = blockBefore
+	s.reason = reason
+	s.scheduledBy = user
+	s.cancel = make(chan struct{})
+
+	go s.countdown(shutdownAt, reason, s.cancel)
+
+	logrus.WithFields(logrus.Fields{
+		"shutdown_at":  shutdownAt,
+		"block_before": blockBefore.String(),
+		"reason":       reason,
+		"scheduled_by": user,
+	}).Info("Maintenance scheduled")
+
+	s.notify(announcement("Server maintenance scheduled for "+shutdownAt.UTC().Format("15:04 MST"), reason), "warning")
+	return nil
+}
+
+// Cancel drops the scheduled maintenance
+func (s *Scheduler) Cancel() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cancel == nil {
+		return ErrNotScheduled
+	}
+
+	close(s.cancel)
+	s.cancel = nil
+	s.shutdownAt = time.Time{}
+	s.reason = ""
+	s.scheduledBy = ""
+
+	logrus.Info("Maintenance cancelled")
+
+	s.notify("Scheduled server maintenance has been cancelled", "info")
+	return nil
+}
+
+// State returns the current maintenance state
+func (s *Scheduler) State() State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cancel == nil {
+		return State{}
+	}
+
+	shutdownAt := s.shutdownAt
+	blockAt := shutdownAt.Add(-s.blockBefore)
+
+	return State{
+		Scheduled:       true,
+		ShutdownAt:      &shutdownAt,
+		BlockSessionsAt: &blockAt,
+		SessionsBlocked: !time.Now().Before(blockAt),
+		Reason:          s.reason,
+		ScheduledBy:     s.scheduledBy,
+	}
+}
+
+// CheckNewSession refuses new sessions close to a scheduled shutdown
+func (s *Scheduler) CheckNewSession() error {
+	if s.State().SessionsBlocked {
+		return ErrSessionsBlocked
+	}
+	return nil
+}
+
+// Done is closed when the scheduled shutdown time is reached
+func (s *Scheduler) Done() <-chan struct{} {
+	return s.done
+}
+
+// countdown warns clients at each warning time and signals Done at the deadline
+func (s *Scheduler) countdown(shutdownAt time.Time, reason string, cancel chan struct{}) {
+	for _, before := range warningTimes {
+		warnAt := shutdownAt.Add(-before)
+		if !warnAt.After(time.Now()) {
+			continue
+		}
+
+		if !wait(time.Until(warnAt), cancel) {
+			return
+		}
+
+		level := "warning"
+		if before <= 5*time.Minute {
+			level = "critical"
+		}
+		s.notify(announcement(fmt.Sprintf("Server maintenance in %s, open sessions will be closed", formatDuration(before)), reason), level)
+	}
+
+	if !wait(time.Until(shutdownAt), cancel) {
+		return
+	}
+
+	logrus.Info("Maintenance time reached")
+
+	s.notify(announcement("Server is shutting down for maintenance", reason), "critical")
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+// wait sleeps for d, returning false if cancelled first
+func wait(d time.Duration, cancel chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// announcement appends the reason for maintenance to a message
+func announcement(message, reason string) string {
+	if reason == "" {
+		return message
+	}
+	return message + ": " + reason
+}
+
+// formatDuration renders a warning time as minutes or hours
+func formatDuration(d time.Duration) string {
+	if d >= time.Hour {
+		hours := int(d.Hours())
+		if hours == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", hours)
+	}
+
+	minutes := int(d.Minutes())
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
@@ -0,0 +1,297 @@
+package monitoring
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentTypeOpenMetrics is the exposition format that carries exemplars
+const ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// ContentTypePrometheus is the classic Prometheus text format
+const ContentTypePrometheus = "text/plain; version=0.0.4; charset=utf-8"
+
+// Labels are the values of a metric's labels, in the order they were declared
+type Labels []string
+
+// Exemplar links a sample to where it came from, such as a session ID
+type Exemplar struct {
+	Labels map[string]string
+	Value  float64
+	Time   time.Time
+}
+
+// collector writes the samples of one metric family
+type collector interface {
+	write(w io.Writer, openMetrics bool)
+}
+
+// Registry holds metrics and writes them in the Prometheus text formats
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds a metric family to the registry
+func (r *Registry) register(c collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes every metric, in OpenMetrics format with exemplars if
+// openMetrics is set and in the classic format otherwise
+func (r *Registry) Write(w io.Writer, openMetrics bool) {
+	r.mutex.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mutex.Unlock()
+
+	for _, c := range collectors {
+		c.write(w, openMetrics)
+	}
+	if openMetrics {
+		fmt.Fprintln(w, "# EOF")
+	}
+}
+
+// family is the name, help and label names shared by a metric's series
+type family struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// header writes the HELP and TYPE lines of a family
+func (f *family) header(w io.Writer, metricType string, openMetrics bool) {
+	name := f.name
+	if metricType == "counter" && openMetrics {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n", name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+// labelString formats label names and values as {a="x",b="y"}, with extra
+// pairs appended, or nothing if there are none
+func labelString(names []string, values Labels, extra ...string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabel escapes a label value for the text formats
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatFloat formats a sample value
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// seriesKey joins label values into a map key
+func seriesKey(values Labels) string {
+	return strings.Join(values, "\xff")
+}
+
+// sortedKeys returns the keys of a series map in order, so output is stable
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a count that only goes up, per combination of label values
+type Counter struct {
+	family
+	mutex  sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels Labels
+	value  float64
+}
+
+// NewCounter registers a counter; its name should end in _total
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: family{name, help, labels}, series: make(map[string]*counterSeries)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series with the label values
+func (c *Counter) Inc(values ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := seriesKey(values)
+	series := c.series[key]
+	if series == nil {
+		series = &counterSeries{labels: append(Labels(nil), values...)}
+		c.series[key] = series
+	}
+	series.value++
+}
+
+func (c *Counter) write(w io.Writer, openMetrics bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.header(w, "counter", openMetrics)
+	for _, key := range sortedKeys(c.series) {
+		series := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelString(c.labels, series.labels), formatFloat(series.value))
+	}
+}
+
+// Histogram counts observations into buckets, per combination of label
+// values, keeping the latest exemplar of each bucket
+type Histogram struct {
+	family
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels    Labels
+	counts    []uint64 // Per bucket, not cumulative; the last is +Inf
+	exemplars []*Exemplar
+	sum       float64
+	count     uint64
+}
+
+// NewHistogram registers a histogram with the upper bounds of its buckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{family: family{name, help, labels}, buckets: buckets, series: make(map[string]*histogramSeries)}
+	r.register(h)
+	return h
+}
+
+// Observe records a value in the series with the label values. A non-nil
+// exemplar is kept for the value's bucket.
+func (h *Histogram) Observe(value float64, exemplar map[string]string, values ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := seriesKey(values)
+	series := h.series[key]
+	if series == nil {
+		series = &histogramSeries{
+			labels:    append(Labels(nil), values...),
+			counts:    make([]uint64, len(h.buckets)+1),
+			exemplars: make([]*Exemplar, len(h.buckets)+1),
+		}
+		h.series[key] = series
+	}
+
+	bucket := sort.SearchFloat64s(h.buckets, value)
+	series.counts[bucket]++
+	series.sum += value
+	series.count++
+	if exemplar != nil {
+		series.exemplars[bucket] = &Exemplar{Labels: exemplar, Value: value, Time: time.Now()}
+	}
+}
+
+func (h *Histogram) write(w io.Writer, openMetrics bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.header(w, "histogram", openMetrics)
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+
+		var cumulative uint64
+		for i, count := range series.counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+
+			line := fmt.Sprintf("%s_bucket%s %d", h.name, labelString(h.labels, series.labels, "le", formatFloat(le)), cumulative)
+			if exemplar := series.exemplars[i]; openMetrics && exemplar != nil {
+				line += " # " + exemplarLabels(exemplar.Labels) + " " + formatFloat(exemplar.Value) +
+					" " + strconv.FormatFloat(float64(exemplar.Time.UnixMilli())/1000, 'f', 3, 64)
+			}
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelString(h.labels, series.labels), formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelString(h.labels, series.labels), series.count)
+	}
+}
+
+// exemplarLabels formats the labels of an exemplar in order
+func exemplarLabels(labels map[string]string) string {
+	names := sortedKeys(labels)
+	values := make(Labels, len(names))
+	for i, name := range names {
+		values[i] = labels[name]
+	}
+	if s := labelString(names, values); s != "" {
+		return s
+	}
+	return "{}"
+}
+
+// GaugeSample is the value of one series of a gauge
+type GaugeSample struct {
+	Labels Labels
+	Value  float64
+}
+
+// GaugeFunc is a gauge whose series are read as metrics are written
+type GaugeFunc struct {
+	family
+	collect func() []GaugeSample
+}
+
+// NewGaugeFunc registers a gauge read by collect
+func (r *Registry) NewGaugeFunc(name, help string, collect func() []GaugeSample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{family: family{name, help, labels}, collect: collect}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer, openMetrics bool) {
+	samples := g.collect()
+	sort.Slice(samples, func(i, j int) bool {
+		return seriesKey(samples[i].Labels) < seriesKey(samples[j].Labels)
+	})
+
+	g.header(w, "gauge", openMetrics)
+	for _, sample := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labelString(g.labels, sample.Labels), formatFloat(sample.Value))
+	}
+}
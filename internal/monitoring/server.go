@@ -0,0 +1,125 @@
+package monitoring
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Buckets of request and session creation latency, in seconds
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// User classes bucket the users behind requests, so that labels stay few
+const (
+	UserClassAnonymous     = "anonymous"
+	UserClassAuthenticated = "authenticated"
+)
+
+// ServerMetrics are the metrics the server exports for Prometheus. Labels
+// are bounded for dashboarding: sessions are labelled by backend and status,
+// users by class, and requests by route template rather than path. Slow
+// samples carry the session ID as an exemplar instead of a label.
+type ServerMetrics struct {
+	Registry *Registry
+
+	sessionsCreated       *Counter
+	sessionCreateDuration *Histogram
+	sessionStartupStage   *Histogram
+	timeToPrompt          *Histogram
+	requests              *Counter
+	requestDuration       *Histogram
+}
+
+// NewServerMetrics registers the server's metrics in a new registry
+func NewServerMetrics() *ServerMetrics {
+	registry := NewRegistry()
+
+	return &ServerMetrics{
+		Registry: registry,
+		sessionsCreated: registry.NewCounter("webterm_sessions_created_total",
+			"Sessions created, by backend and class of the creating user.",
+			"backend", "user_class"),
+		sessionCreateDuration: registry.NewHistogram("webterm_session_create_duration_seconds",
+			"Time taken to create a session, by backend.",
+			latencyBuckets, "backend"),
+		sessionStartupStage: registry.NewHistogram("webterm_session_startup_stage_seconds",
+			"Time taken by the stages of starting a session, by backend and stage.",
+			latencyBuckets, "backend", "stage"),
+		timeToPrompt: registry.NewHistogram("webterm_session_time_to_prompt_seconds",
+			"Time from a session being requested to its first output reaching clients, by backend.",
+			latencyBuckets, "backend"),
+		requests: registry.NewCounter("webterm_http_requests_total",
+			"HTTP requests completed, by method, route and status code.",
+			"method", "route", "code"),
+		requestDuration: registry.NewHistogram("webterm_http_request_duration_seconds",
+			"Time taken to serve HTTP requests, by method and route.",
+			latencyBuckets, "method", "route"),
+	}
+}
+
+// SessionCreated records a session being created. A nil ServerMetrics
+// records nothing.
+func (m *ServerMetrics) SessionCreated(sessionID, backend, userClass string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.sessionsCreated.Inc(backend, userClass)
+	m.sessionCreateDuration.Observe(duration.Seconds(), sessionExemplar(sessionID), backend)
+}
+
+// Stages of starting a session, as labelled in its startup metrics
+const (
+	StartupStagePipes     = "pipes"
+	StartupStagePTYStart  = "pty_start"
+	StartupStageFirstRead = "first_read"
+)
+
+// SessionStartup records how long a session took to show its first
+// output, and how long each stage of starting it took, by stage. A nil
+// ServerMetrics records nothing.
+func (m *ServerMetrics) SessionStartup(sessionID, backend string, stages map[string]time.Duration, timeToPrompt time.Duration) {
+	if m == nil {
+		return
+	}
+
+	exemplar := sessionExemplar(sessionID)
+	for stage, duration := range stages {
+		m.sessionStartupStage.Observe(duration.Seconds(), exemplar, backend, stage)
+	}
+	m.timeToPrompt.Observe(timeToPrompt.Seconds(), exemplar, backend)
+}
+
+// RequestCompleted records an HTTP request being served. The session ID,
+// if the route has one, becomes the exemplar of its latency.
+func (m *ServerMetrics) RequestCompleted(method, route string, code int, sessionID string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.requests.Inc(method, route, strconv.Itoa(code))
+	m.requestDuration.Observe(duration.Seconds(), sessionExemplar(sessionID), method, route)
+}
+
+// sessionExemplar returns the exemplar labels for a session, or nil without one
+func sessionExemplar(sessionID string) map[string]string {
+	if sessionID == "" {
+		return nil
+	}
+	return map[string]string{"session_id": sessionID}
+}
+
+// ServeHTTP writes the metrics, in OpenMetrics format with exemplars when
+// the scraper accepts it
+func (m *ServerMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", ContentTypeOpenMetrics)
+	} else {
+		w.Header().Set("Content-Type", ContentTypePrometheus)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	m.Registry.Write(w, openMetrics)
+}
@@ -257,18 +257,22 @@ func (cp *ConnectionPool) Stop() {
 	close(cp.stopChan)
 }
 
-// OutputBuffer optimizes terminal output buffering
+// OutputBuffer batches terminal output, passing it on once maxSize bytes
+// are buffered or flushTime after the first of them. Flushes happen in
+// order, one at a time.
 type OutputBuffer struct {
 	buffer    []byte
 	mutex     sync.Mutex
 	maxSize   int
 	flushTime time.Duration
 	timer     *time.Timer
-	callback  func([]byte)
+	callback  func([]byte) error
+	err       error // From a flush by the timer, returned by the next call
 }
 
-// NewOutputBuffer creates a new output buffer
-func NewOutputBuffer(maxSize int, flushTime time.Duration, callback func([]byte)) *OutputBuffer {
+// NewOutputBuffer creates a new output buffer. The data passed to callback
+// is reused once it returns, so the callback must copy anything it keeps.
+func NewOutputBuffer(maxSize int, flushTime time.Duration, callback func([]byte) error) *OutputBuffer {
 	return &OutputBuffer{
 		buffer:    make([]byte, 0, maxSize),
 		maxSize:   maxSize,
@@ -277,63 +281,70 @@ func NewOutputBuffer(maxSize int, flushTime time.Duration, callback func([]byte)
 	}
 }
 
-// Write adds data to the buffer
-func (ob *OutputBuffer) Write(data []byte) {
+// Write adds data to the buffer. It returns the error of the callback if
+// this or an earlier flush failed.
+func (ob *OutputBuffer) Write(data []byte) error {
 	ob.mutex.Lock()
 	defer ob.mutex.Unlock()
 
-	// Reset timer
-	if ob.timer != nil {
-		ob.timer.Stop()
-	}
-
 	// Add data to buffer
 	ob.buffer = append(ob.buffer, data...)
 
 	// Flush if buffer is full
 	if len(ob.buffer) >= ob.maxSize {
 		ob.flushLocked()
-		return
+		return ob.takeError()
 	}
 
-	// Set timer for automatic flush
-	ob.timer = time.AfterFunc(ob.flushTime, func() {
-		ob.mutex.Lock()
-		defer ob.mutex.Unlock()
-		ob.flushLocked()
-	})
+	// Flush what has arrived once the flush time has passed
+	if ob.timer == nil {
+		ob.timer = time.AfterFunc(ob.flushTime, func() {
+			ob.mutex.Lock()
+			defer ob.mutex.Unlock()
+			ob.timer = nil
+			ob.flushLocked()
+		})
+	}
+	return ob.takeError()
 }
 
-// Flush forces a buffer flush
-func (ob *OutputBuffer) Flush() {
+// Flush forces a buffer flush, returning the error of the callback if this
+// or an earlier flush failed
+func (ob *OutputBuffer) Flush() error {
 	ob.mutex.Lock()
 	defer ob.mutex.Unlock()
 	ob.flushLocked()
+	return ob.takeError()
 }
 
 // flushLocked flushes the buffer (assumes mutex is held)
 func (ob *OutputBuffer) flushLocked() {
-	if len(ob.buffer) == 0 {
-		return
-	}
-
 	if ob.timer != nil {
 		ob.timer.Stop()
 		ob.timer = nil
 	}
 
-	// Send data via callback
+	if len(ob.buffer) == 0 {
+		return
+	}
+
 	if ob.callback != nil {
-		// Make a copy to avoid data races
-		data := make([]byte, len(ob.buffer))
-		copy(data, ob.buffer)
-		go ob.callback(data)
+		if err := ob.callback(ob.buffer); err != nil && ob.err == nil {
+			ob.err = err
+		}
 	}
 
 	// Reset buffer
 	ob.buffer = ob.buffer[:0]
 }
 
+// takeError returns and clears the error of a failed flush (assumes mutex is held)
+func (ob *OutputBuffer) takeError() error {
+	err := ob.err
+	ob.err = nil
+	return err
+}
+
 // PerformanceMonitor tracks and optimizes performance
 type PerformanceMonitor struct {
 	mutex               sync.RWMutex
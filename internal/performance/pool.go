@@ -0,0 +1,40 @@
+package performance
+
+import "sync"
+
+// BufferPool recycles fixed-size byte slices so that hot read loops do not
+// allocate a new buffer for every read
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewBufferPool creates a pool of buffers of the given size
+func NewBufferPool(size int) *BufferPool {
+	bp := &BufferPool{size: size}
+	bp.pool.New = func() interface{} {
+		buffer := make([]byte, size)
+		return &buffer
+	}
+	return bp
+}
+
+// Get returns a buffer of length n, taken from the pool when n fits in the
+// pool's buffers and freshly allocated otherwise
+func (bp *BufferPool) Get(n int) []byte {
+	if n > bp.size {
+		return make([]byte, n)
+	}
+	buffer := bp.pool.Get().(*[]byte)
+	return (*buffer)[:n]
+}
+
+// Put returns a buffer obtained from Get to the pool. The caller must not
+// use the buffer afterwards; buffers not sized for the pool are dropped.
+func (bp *BufferPool) Put(buffer []byte) {
+	if cap(buffer) != bp.size {
+		return
+	}
+	buffer = buffer[:bp.size]
+	bp.pool.Put(&buffer)
+}
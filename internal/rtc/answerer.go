@@ -0,0 +1,114 @@
+package rtc
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// DataChannelLabel is the label browsers must give the terminal data channel
+const DataChannelLabel = "terminal"
+
+// gatherTimeout bounds how long an answer waits for ICE candidate gathering
+const gatherTimeout = 10 * time.Second
+
+// openTimeout is how long the browser has, once answered, to open the
+// terminal data channel before its peer connection is closed
+const openTimeout = 30 * time.Second
+
+// Answerer accepts WebRTC offers from browsers and opens terminal data channels
+type Answerer struct {
+	iceServers []string
+}
+
+// NewAnswerer creates an answerer using the given STUN/TURN server URLs
+func NewAnswerer(iceServers []string) *Answerer {
+	return &Answerer{
+		iceServers: iceServers,
+	}
+}
+
+// ICEServers returns the configured STUN/TURN server URLs
+func (a *Answerer) ICEServers() []string {
+	return a.iceServers
+}
+
+// Answer negotiates a peer connection for the offer and returns the SDP answer.
+// onOpen is called with a transport once the browser's terminal data channel opens.
+func (a *Answerer) Answer(offer webrtc.SessionDescription, remoteAddr string, onOpen func(*DataChannelTransport)) (*webrtc.SessionDescription, error) {
+	config := webrtc.Configuration{}
+	if len(a.iceServers) > 0 {
+		config.ICEServers = []webrtc.ICEServer{{URLs: a.iceServers}}
+	}
+
+	peer, err := webrtc.NewPeerConnection(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create peer connection: %w", err)
+	}
+
+	var opened atomic.Bool
+	peer.OnDataChannel(func(channel *webrtc.DataChannel) {
+		if channel.Label() != DataChannelLabel {
+			logrus.WithField("label", channel.Label()).Warn("Ignoring unexpected WebRTC data channel")
+			return
+		}
+
+		channel.OnOpen(func() {
+			opened.Store(true)
+			onOpen(newDataChannelTransport(peer, channel, remoteAddr))
+		})
+	})
+
+	peer.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		logrus.WithFields(logrus.Fields{
+			"remote_addr": remoteAddr,
+			"state":       state.String(),
+		}).Debug("WebRTC connection state changed")
+
+		// Disconnected peers are not given the chance to recover, so that
+		// browsers that went away do not hold on to connections
+		switch state {
+		case webrtc.PeerConnectionStateDisconnected, webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed:
+			peer.Close()
+		}
+	})
+
+	if err := peer.SetRemoteDescription(offer); err != nil {
+		peer.Close()
+		return nil, fmt.Errorf("invalid offer: %w", err)
+	}
+
+	answer, err := peer.CreateAnswer(nil)
+	if err != nil {
+		peer.Close()
+		return nil, fmt.Errorf("failed to create answer: %w", err)
+	}
+
+	// Candidates are returned in the answer rather than trickled
+	gatherComplete := webrtc.GatheringCompletePromise(peer)
+	if err := peer.SetLocalDescription(answer); err != nil {
+		peer.Close()
+		return nil, fmt.Errorf("failed to set local description: %w", err)
+	}
+
+	select {
+	case <-gatherComplete:
+	case <-time.After(gatherTimeout):
+		peer.Close()
+		return nil, fmt.Errorf("timed out gathering ICE candidates")
+	}
+
+	// Browsers that never open the data channel leave nothing else to close
+	// the peer connection
+	time.AfterFunc(openTimeout, func() {
+		if !opened.Load() {
+			logrus.WithField("remote_addr", remoteAddr).Warn("Closing WebRTC connection whose data channel never opened")
+			peer.Close()
+		}
+	})
+
+	return peer.LocalDescription(), nil
+}
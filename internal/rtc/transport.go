@@ -0,0 +1,87 @@
+package rtc
+
+import (
+	"io"
+	"sync"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// DataChannelTransport carries terminal messages over a WebRTC data channel
+type DataChannelTransport struct {
+	peer       *webrtc.PeerConnection
+	channel    *webrtc.DataChannel
+	remoteAddr string
+
+	incoming  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newDataChannelTransport wires the data channel callbacks into a transport
+func newDataChannelTransport(peer *webrtc.PeerConnection, channel *webrtc.DataChannel, remoteAddr string) *DataChannelTransport {
+	t := &DataChannelTransport{
+		peer:       peer,
+		channel:    channel,
+		remoteAddr: remoteAddr,
+		incoming:   make(chan []byte, 64),
+		closed:     make(chan struct{}),
+	}
+
+	channel.OnMessage(func(msg webrtc.DataChannelMessage) {
+		select {
+		case t.incoming <- msg.Data:
+		case <-t.closed:
+		}
+	})
+	channel.OnClose(func() {
+		t.Close()
+	})
+
+	return t
+}
+
+// ReadMessage blocks until the browser sends a message or the channel closes
+func (t *DataChannelTransport) ReadMessage() ([]byte, error) {
+	select {
+	case data := <-t.incoming:
+		return data, nil
+	case <-t.closed:
+		return nil, io.EOF
+	}
+}
+
+// WriteMessage sends a text message on the data channel
+func (t *DataChannelTransport) WriteMessage(data []byte) error {
+	select {
+	case <-t.closed:
+		return io.ErrClosedPipe
+	default:
+	}
+	return t.channel.SendText(string(data))
+}
+
+// Ping is a no-op; SCTP heartbeats and ICE consent checks detect dead peers
+func (t *DataChannelTransport) Ping() error {
+	select {
+	case <-t.closed:
+		return io.ErrClosedPipe
+	default:
+		return nil
+	}
+}
+
+// Close tears down the data channel and its peer connection
+func (t *DataChannelTransport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		err = t.peer.Close()
+	})
+	return err
+}
+
+// RemoteAddr returns the address the peer signaled from
+func (t *DataChannelTransport) RemoteAddr() string {
+	return t.remoteAddr
+}
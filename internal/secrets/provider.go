@@ -0,0 +1,21 @@
+package secrets
+
+import (
+	"context"
+	"time"
+)
+
+// Lease is a set of credentials issued by a provider. Leases with an ID
+// are revoked when the session they were issued for ends.
+type Lease struct {
+	ID   string
+	Path string
+	Data map[string]string
+	TTL  time.Duration
+}
+
+// Provider issues short-lived credentials and revokes them when no longer needed
+type Provider interface {
+	Issue(ctx context.Context, path string) (*Lease, error)
+	Revoke(ctx context.Context, lease *Lease) error
+}
@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultProvider issues credentials from HashiCorp Vault secrets engines
+type VaultProvider struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// vaultResponse is the envelope Vault returns for secret reads
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	Errors        []string               `json:"errors"`
+}
+
+// NewVaultProvider creates a Vault provider authenticating with the token
+// stored in tokenFile. The token is kept out of the environment so that it
+// is not inherited by session shells.
+func NewVaultProvider(address, tokenFile, namespace string) (*VaultProvider, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault token: %w", err)
+	}
+
+	return &VaultProvider{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     strings.TrimSpace(string(token)),
+		namespace: namespace,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Issue reads a secret, which for dynamic secrets engines such as database
+// or aws creates new credentials under a lease
+func (v *VaultProvider) Issue(ctx context.Context, path string) (*Lease, error) {
+	var response vaultResponse
+	if err := v.do(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	data := response.Data
+
+	// KV version 2 nests the secret under data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+
+	lease := &Lease{
+		ID:   response.LeaseID,
+		Path: path,
+		Data: make(map[string]string, len(data)),
+		TTL:  time.Duration(response.LeaseDuration) * time.Second,
+	}
+
+	for key, value := range data {
+		switch typed := value.(type) {
+		case string:
+			lease.Data[key] = typed
+		default:
+			encoded, err := json.Marshal(typed)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode %s field %s: %w", path, key, err)
+			}
+			lease.Data[key] = string(encoded)
+		}
+	}
+
+	return lease, nil
+}
+
+// Revoke revokes a lease immediately. Static secrets without a lease are ignored.
+func (v *VaultProvider) Revoke(ctx context.Context, lease *Lease) error {
+	if lease.ID == "" {
+		return nil
+	}
+
+	body := map[string]string{"lease_id": lease.ID}
+	if err := v.do(ctx, http.MethodPut, "/v1/sys/leases/revoke", body, nil); err != nil {
+		return fmt.Errorf("failed to revoke lease %s: %w", lease.ID, err)
+	}
+
+	return nil
+}
+
+// do performs an authenticated Vault API request
+func (v *VaultProvider) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, v.address+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var response vaultResponse
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&response)
+		if len(response.Errors) > 0 {
+			return fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(response.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Decrypt unwraps ciphertext, such as a data key, with a key of the transit
+// secrets engine mounted at transit/
+func (v *VaultProvider) Decrypt(ctx context.Context, keyName, ciphertext string) ([]byte, error) {
+	var response vaultResponse
+	body := map[string]string{"ciphertext": strings.TrimSpace(ciphertext)}
+	if err := v.do(ctx, http.MethodPost, "/v1/transit/decrypt/"+keyName, body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decrypt with %s: %w", keyName, err)
+	}
+
+	plaintext, ok := response.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault returned no plaintext for %s", keyName)
+	}
+	return base64.StdEncoding.DecodeString(plaintext)
+}
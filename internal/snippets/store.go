@@ -0,0 +1,273 @@
+package snippets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limits on what each user may store
+const (
+	MaxSnippetsPerUser = 200
+	MaxNameLength      = 100
+	MaxContentLength   = 4096
+)
+
+var (
+	// ErrNotFound is returned for snippets that do not exist for the user
+	ErrNotFound = errors.New("snippet not found")
+	// ErrInvalid is returned for snippets that fail validation
+	ErrInvalid = errors.New("invalid snippet")
+	// ErrLimitReached is returned when a user already has the maximum number of snippets
+	ErrLimitReached = errors.New("snippet limit reached")
+)
+
+// Snippet is a stored piece of terminal input, such as a frequent command
+type Snippet struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Content     string    `json:"content"` // Typed into the session as is; end with a newline to run it
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Store keeps each user's snippets, optionally persisted to a JSON file
+type Store struct {
+	mutex    sync.RWMutex
+	snippets map[string]map[string]*Snippet // User to snippet ID to snippet
+	file     string
+}
+
+// NewStore creates a store, loading previously saved snippets from file if set
+func NewStore(file string) (*Store, error) {
+	s := &Store{
+		snippets: make(map[string]map[string]*Snippet),
+		file:     file,
+	}
+
+	if file != "" {
+		if err := s.load(); err != nil {
+			return nil, fmt.Errorf("failed to load snippets: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// List returns a user's snippets sorted by name
+func (s *Store) List(user string) []Snippet {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	list := make([]Snippet, 0, len(s.snippets[user]))
+	for _, snippet := range s.snippets[user] {
+		list = append(list, *snippet)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+
+	return list
+}
+
+// Get returns one of a user's snippets
+func (s *Store) Get(user, id string) (*Snippet, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	snippet, exists := s.snippets[user][id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	copied := *snippet
+	return &copied, nil
+}
+
+// Create stores a new snippet for a user
+func (s *Store) Create(user string, snippet Snippet) (*Snippet, error) {
+	if err := validate(&snippet); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.snippets[user]) >= MaxSnippetsPerUser {
+		return nil, ErrLimitReached
+	}
+
+	now := time.Now()
+	snippet.ID = uuid.New().String()
+	snippet.CreatedAt = now
+	snippet.UpdatedAt = now
+
+	if s.snippets[user] == nil {
+		s.snippets[user] = make(map[string]*Snippet)
+	}
+	s.snippets[user][snippet.ID] = &snippet
+
+	if err := s.save(); err != nil {
+		delete(s.snippets[user], snippet.ID)
+		return nil, err
+	}
+
+	copied := snippet
+	return &copied, nil
+}
+
+// Update replaces the name, description and content of a user's snippet
+func (s *Store) Update(user, id string, update Snippet) (*Snippet, error) {
+	if err := validate(&update); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snippet, exists := s.snippets[user][id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	previous := *snippet
+	snippet.Name = update.Name
+	snippet.Description = update.Description
+	snippet.Content = update.Content
+	snippet.UpdatedAt = time.Now()
+
+	if err := s.save(); err != nil {
+		*snippet = previous
+		return nil, err
+	}
+
+	copied := *snippet
+	return &copied, nil
+}
+
+// Delete removes a user's snippet
+func (s *Store) Delete(user, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snippet, exists := s.snippets[user][id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	delete(s.snippets[user], id)
+	if err := s.save(); err != nil {
+		s.snippets[user][id] = snippet
+		return err
+	}
+
+	return nil
+}
+
+// DeleteAll removes every snippet of a user
+func (s *Store) DeleteAll(user string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snippets, exists := s.snippets[user]
+	if !exists {
+		return nil
+	}
+
+	delete(s.snippets, user)
+	if err := s.save(); err != nil {
+		s.snippets[user] = snippets
+		return err
+	}
+
+	return nil
+}
+
+// validate trims and checks a snippet supplied by a user
+func validate(snippet *Snippet) error {
+	snippet.Name = strings.TrimSpace(snippet.Name)
+	snippet.Description = strings.TrimSpace(snippet.Description)
+
+	if snippet.Name == "" || len(snippet.Name) > MaxNameLength {
+		return fmt.Errorf("%w: name must be 1 to %d bytes", ErrInvalid, MaxNameLength)
+	}
+	if snippet.Content == "" || len(snippet.Content) > MaxContentLength {
+		return fmt.Errorf("%w: content must be 1 to %d bytes", ErrInvalid, MaxContentLength)
+	}
+
+	return nil
+}
+
+// load reads saved snippets from the store file, if it exists
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	saved := make(map[string][]*Snippet)
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	for user, list := range saved {
+		s.snippets[user] = make(map[string]*Snippet, len(list))
+		for _, snippet := range list {
+			s.snippets[user][snippet.ID] = snippet
+		}
+	}
+
+	return nil
+}
+
+// save writes all snippets to the store file (assumes mutex is held)
+func (s *Store) save() error {
+	if s.file == "" {
+		return nil
+	}
+
+	saved := make(map[string][]*Snippet, len(s.snippets))
+	for user, byID := range s.snippets {
+		for _, snippet := range byID {
+			saved[user] = append(saved[user], snippet)
+		}
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Replace the file atomically so a crash never leaves it half written
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), ".snippets-*")
+	if err != nil {
+		return fmt.Errorf("failed to save snippets: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save snippets: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save snippets: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.file); err != nil {
+		return fmt.Errorf("failed to save snippets: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+var (
+	// ErrShellNotAllowed is returned for shells outside the shell allowlist
+	ErrShellNotAllowed = errors.New("shell not allowed")
+	// ErrCommandNotAllowed is returned for commands outside the command
+	// allowlist, or for any command when commands are disabled
+	ErrCommandNotAllowed = errors.New("command not allowed")
+)
+
+// LaunchPolicy restricts the shells and commands clients may ask sessions
+// and panes to run, and where. Shells, commands and working directories
+// set by a profile are chosen by the administrator and are not checked.
+type LaunchPolicy struct {
+	Shells          []string // Patterns of shells clients may ask for; empty allows any
+	Commands        []string // Patterns of programs clients may run as commands; empty allows any
+	DisableCommands bool     // Refuse every command clients ask for
+	WorkingDirRoots []string // Patterns of directories clients may start in, with everything beneath them; empty allows any
+}
+
+// SetLaunchPolicy sets the shells and commands clients may ask for
+func (m *Manager) SetLaunchPolicy(policy LaunchPolicy) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.launchPolicy = policy
+}
+
+// checkLaunch checks the shell and command of a request against the launch
+// policy, skipping those its profile set. A shell or command the profile set
+// cannot be swapped for another, for example in a pane (assumes mutex is
+// held).
+func (m *Manager) checkLaunch(shell string, command []string, profile *types.Profile) error {
+	if profile != nil && profile.Shell != "" && shell != profile.Shell {
+		return fmt.Errorf("%w: profile %s runs %s", ErrShellNotAllowed, profile.Name, profile.Shell)
+	}
+	if profile != nil && len(profile.Command) > 0 && !slices.Equal(command, profile.Command) {
+		return fmt.Errorf("%w: profile %s runs %s", ErrCommandNotAllowed, profile.Name, profile.Command[0])
+	}
+
+	if shell != "" && (profile == nil || profile.Shell == "") {
+		if len(m.launchPolicy.Shells) > 0 && !matchesAny(shell, m.launchPolicy.Shells) {
+			return fmt.Errorf("%w: %s", ErrShellNotAllowed, shell)
+		}
+	}
+
+	if len(command) > 0 && (profile == nil || len(profile.Command) == 0) {
+		if m.launchPolicy.DisableCommands {
+			return fmt.Errorf("%w: commands are disabled on this server", ErrCommandNotAllowed)
+		}
+		if len(m.launchPolicy.Commands) > 0 && !matchesAny(command[0], m.launchPolicy.Commands) {
+			return fmt.Errorf("%w: %s", ErrCommandNotAllowed, command[0])
+		}
+	}
+
+	return nil
+}
+
+// matchesAny reports whether a program, as named by the client, matches
+// one of the patterns. Names are matched as given, so an allowed
+// /bin/bash does not allow bash looked up on the PATH.
+func matchesAny(program string, patterns []string) bool {
+	program = filepath.Clean(program)
+	for _, pattern := range patterns {
+		if matched, err := filepath.Match(pattern, program); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrApprovalNotFound is returned when no session is awaiting approval under an ID
+	ErrApprovalNotFound = errors.New("approval not found")
+	// ErrSelfApproval is returned when a user tries to approve their own session
+	ErrSelfApproval = errors.New("sessions cannot be approved by their requester")
+)
+
+// pendingRequest is a session create request held until an admin approves it
+type pendingRequest struct {
+	req     *types.SessionCreateRequest
+	profile *types.Profile
+}
+
+// ListApprovals returns the sessions awaiting approval, oldest first
+func (m *Manager) ListApprovals() []types.Approval {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	approvals := make([]types.Approval, 0, len(m.pendingRequests))
+	for sessionID := range m.pendingRequests {
+		session := m.sessions[sessionID]
+		approvals = append(approvals, types.Approval{
+			ID:          session.ID,
+			Requester:   session.Owner,
+			Tenant:      session.Tenant,
+			Profile:     session.Profile,
+			Metadata:    session.Metadata,
+			RequestedAt: session.CreatedAt,
+		})
+	}
+
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].RequestedAt.Before(approvals[j].RequestedAt)
+	})
+
+	return approvals
+}
+
+// ApproveSession spawns a pending session on behalf of approver, who must
+// not be the user that requested it
+func (m *Manager) ApproveSession(sessionID, approver string) (*types.Session, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	pending, exists := m.pendingRequests[sessionID]
+	if !exists {
+		return nil, ErrApprovalNotFound
+	}
+
+	session := m.sessions[sessionID]
+	if session.Owner == approver {
+		return nil, ErrSelfApproval
+	}
+
+	delete(m.pendingRequests, sessionID)
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"profile":    session.Profile,
+		"owner":      session.Owner,
+		"approver":   approver,
+	}).Info("Session approved")
+
+	session.Status = types.SessionStatusStarting
+	session.UpdateLastActive()
+
+	// Time sessions from their approval, not from their request
+	if err := m.launchSession(context.Background(), session, pending.req, pending.profile, newStartupClock(time.Now())); err != nil {
+		session.Status = types.SessionStatusError
+		session.ErrorMessage = err.Error()
+		if m.statusCallback != nil {
+			m.statusCallback(sessionID, string(types.SessionStatusError), nil)
+		}
+		return nil, fmt.Errorf("failed to start approved session: %w", err)
+	}
+
+	if m.statusCallback != nil {
+		m.statusCallback(sessionID, string(types.SessionStatusStarting), nil)
+	}
+
+	return session, nil
+}
+
+// DenySession discards a pending session without spawning it
+func (m *Manager) DenySession(sessionID, approver string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.pendingRequests[sessionID]; !exists {
+		return ErrApprovalNotFound
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"approver":   approver,
+	}).Info("Session denied")
+
+	m.sessions[sessionID].ErrorMessage = "denied by " + approver
+	return m.cleanupSession(sessionID)
+}
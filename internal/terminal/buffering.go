@@ -0,0 +1,106 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/performance"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Output buffer sizes and flush latencies sessions may use
+const (
+	DefaultOutputBufferSize = 8192
+	MinOutputBufferSize     = 512
+	MaxOutputBufferSize     = 1 << 20
+	MaxOutputFlushLatency   = time.Second
+)
+
+// ErrInvalidOutputBuffering is returned for output buffering sessions may not use
+var ErrInvalidOutputBuffering = errors.New("invalid output_buffering")
+
+// SetOutputBuffering sets how much output sessions read at once and how
+// long they wait for more before writing it, unless a session asks for
+// otherwise. A latency of 0 writes output as soon as it is read.
+func (m *Manager) SetOutputBuffering(bufferSize int, flushLatency time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.outputBufferSize = bufferSize
+	m.outputFlushLatency = flushLatency
+}
+
+// resolveOutputBuffering fills in the output buffering a session asked for
+// from the server's defaults (assumes mutex is held)
+func (m *Manager) resolveOutputBuffering(requested *types.OutputBuffering) (*types.OutputBuffering, error) {
+	resolved := &types.OutputBuffering{
+		BufferSize:   m.outputBufferSize,
+		FlushLatency: m.outputFlushLatency.String(),
+	}
+	if resolved.BufferSize == 0 {
+		resolved.BufferSize = DefaultOutputBufferSize
+	}
+	if requested == nil {
+		return resolved, nil
+	}
+
+	if requested.BufferSize != 0 {
+		if requested.BufferSize < MinOutputBufferSize || requested.BufferSize > MaxOutputBufferSize {
+			return nil, fmt.Errorf("%w: buffer_size must be between %d and %d bytes",
+				ErrInvalidOutputBuffering, MinOutputBufferSize, MaxOutputBufferSize)
+		}
+		resolved.BufferSize = requested.BufferSize
+	}
+
+	if requested.FlushLatency != "" {
+		latency, err := time.ParseDuration(requested.FlushLatency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOutputBuffering, err)
+		}
+		if latency < 0 || latency > MaxOutputFlushLatency {
+			return nil, fmt.Errorf("%w: flush_latency must be between 0 and %s",
+				ErrInvalidOutputBuffering, MaxOutputFlushLatency)
+		}
+		resolved.FlushLatency = latency.String()
+	}
+
+	return resolved, nil
+}
+
+// outputBuffering returns the buffer size and flush latency a session was
+// created with, resolved and validated then
+func outputBuffering(session *types.Session) (int, time.Duration) {
+	if session.OutputBuffering == nil {
+		return DefaultOutputBufferSize, 0
+	}
+	latency, _ := time.ParseDuration(session.OutputBuffering.FlushLatency)
+	return session.OutputBuffering.BufferSize, latency
+}
+
+// SetOutputBuffering sets how much output is read at once and how long to
+// wait for more before writing it. It must be called before Start.
+func (sr *SessionRunner) SetOutputBuffering(bufferSize int, flushLatency time.Duration) {
+	sr.bufferSize = bufferSize
+	sr.flushLatency = flushLatency
+}
+
+// batchOutput returns the function output read from a session is passed
+// to, and one writing what it holds back. Without a flush latency output is
+// written as it is read; with one, reads are batched, so bursts are written
+// and relayed in fewer, larger chunks.
+func (sr *SessionRunner) batchOutput(file *outputWriter) (write func(data []byte) error, flush func()) {
+	write = func(data []byte) error {
+		return sr.writeOutput(file, data)
+	}
+	if sr.flushLatency <= 0 {
+		return write, func() {}
+	}
+
+	batch := performance.NewOutputBuffer(sr.bufferSize, sr.flushLatency, write)
+	return batch.Write, func() {
+		if err := batch.Flush(); err != nil {
+			logrus.WithError(err).WithField("session_id", sr.session.ID).Warn("Failed to write batched output")
+		}
+	}
+}
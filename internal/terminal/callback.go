@@ -0,0 +1,170 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// callbackTimeout bounds each attempt to deliver a completion callback
+	callbackTimeout = 10 * time.Second
+	// callbackAttempts is how often delivery is tried before giving up
+	callbackAttempts = 3
+	// callbackShutdownWait bounds how long shutdown waits for callbacks in flight
+	callbackShutdownWait = 15 * time.Second
+)
+
+// ErrInvalidCallback is returned for callback URLs that cannot be used
+var ErrInvalidCallback = errors.New("invalid callback URL")
+
+// callbackClient delivers completion callbacks
+var callbackClient = &http.Client{Timeout: callbackTimeout}
+
+// validateCallbackURL checks that a callback URL is an absolute HTTP(S) URL
+func validateCallbackURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: must be an absolute http or https URL", ErrInvalidCallback)
+	}
+
+	return nil
+}
+
+// trackCallback remembers where to report a session's completion
+func (m *Manager) trackCallback(sessionID, callbackURL string) {
+	if callbackURL == "" {
+		return
+	}
+
+	m.callbacksMutex.Lock()
+	defer m.callbacksMutex.Unlock()
+	m.callbacks[sessionID] = callbackURL
+}
+
+// notifyCallback reports a stopped session to its callback URL in the
+// background. Only the first call for a session sends anything.
+func (m *Manager) notifyCallback(session *types.Session, runner *SessionRunner, status types.SessionStatus) {
+	m.callbacksMutex.Lock()
+	callbackURL, exists := m.callbacks[session.ID]
+	delete(m.callbacks, session.ID)
+	m.callbacksMutex.Unlock()
+
+	if !exists {
+		return
+	}
+
+	endedAt := time.Now()
+	completion := types.SessionCompletion{
+		SessionID:       session.ID,
+		Status:          status,
+		Error:           session.ErrorMessage,
+		Reason:          session.TerminationReason,
+		Owner:           session.Owner,
+		Metadata:        session.Metadata,
+		CreatedAt:       session.CreatedAt,
+		EndedAt:         endedAt,
+		DurationSeconds: endedAt.Sub(session.CreatedAt).Seconds(),
+		OutputBytes:     runner.GetBytesRead(),
+	}
+	if session.Process != nil && session.Process.ProcessState != nil && session.Process.ProcessState.Exited() {
+		exitCode := session.Process.ProcessState.ExitCode()
+		completion.ExitCode = &exitCode
+	}
+
+	m.callbacksPending.Add(1)
+	go func() {
+		defer m.callbacksPending.Done()
+		deliverCallback(callbackURL, &completion)
+	}()
+}
+
+// deliverCallback posts a completion, retrying failed attempts with backoff
+func deliverCallback(callbackURL string, completion *types.SessionCompletion) {
+	body, err := json.Marshal(completion)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", completion.SessionID).Error("Failed to encode session callback")
+		return
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"session_id": completion.SessionID,
+		"host":       hostOf(callbackURL),
+	})
+
+	backoff := time.Second
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		err = postCallback(callbackURL, body)
+		if err == nil {
+			logger.Info("Session callback delivered")
+			return
+		}
+
+		logger.WithError(err).WithField("attempt", attempt).Warn("Session callback failed")
+		if attempt < callbackAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	logger.Error("Giving up on session callback")
+}
+
+// postCallback makes one delivery attempt
+func postCallback(callbackURL string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "webterm")
+
+	resp, err := callbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// waitForCallbacks waits for callbacks in flight, up to timeout
+func (m *Manager) waitForCallbacks(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		m.callbacksPending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logrus.Warn("Timed out waiting for session callbacks")
+	}
+}
+
+// hostOf returns the host of a URL for logging, leaving out paths and
+// query strings that may carry tokens
+func hostOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/creack/pty"
+	"github.com/google/uuid"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrFeatureUnavailable is returned for sessions needing a platform feature
+// the server found missing at startup
+var ErrFeatureUnavailable = errors.New("not supported on this server")
+
+// Capabilities are the platform features sessions depend on, probed once at
+// startup so that sessions needing a missing one are refused up front
+// rather than failing as they start
+type Capabilities struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+
+	FIFO       bool `json:"fifo"`       // Named pipes for session input; needed by every session
+	PTY        bool `json:"pty"`        // Pseudo-terminals; needed by sessions that allocate one
+	Cgroups    bool `json:"cgroups"`    // Control groups, used by container runtimes
+	Namespaces bool `json:"namespaces"` // User namespaces; needed by gVisor sandboxes
+	ConPTY     bool `json:"conpty"`     // Windows pseudo consoles; sessions do not run on Windows yet
+}
+
+// DetectCapabilities probes the platform, creating a named pipe in the
+// pipes directory to check that it supports them
+func DetectCapabilities(pipesDir string) *Capabilities {
+	caps := &Capabilities{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+	}
+
+	caps.FIFO = probeFIFO(pipesDir)
+	caps.PTY = probePTY()
+	caps.Cgroups = probeCgroups()
+	caps.Namespaces = probeNamespaces()
+
+	logger := logrus.WithFields(logrus.Fields{
+		"os":         caps.OS,
+		"arch":       caps.Arch,
+		"fifo":       caps.FIFO,
+		"pty":        caps.PTY,
+		"cgroups":    caps.Cgroups,
+		"namespaces": caps.Namespaces,
+	})
+	if !caps.FIFO || !caps.PTY {
+		logger.Warn("Platform is missing features sessions need; affected sessions will be refused")
+	} else {
+		logger.Info("Platform capabilities detected")
+	}
+
+	return caps
+}
+
+// probeFIFO checks that named pipes can be created in the pipes directory
+func probeFIFO(pipesDir string) bool {
+	if err := os.MkdirAll(pipesDir, 0700); err != nil {
+		return false
+	}
+
+	probe := filepath.Join(pipesDir, ".probe-"+uuid.New().String())
+	if err := makeFIFO(probe); err != nil {
+		logrus.WithError(err).Debug("Named pipes are unavailable")
+		return false
+	}
+	os.Remove(probe)
+	return true
+}
+
+// probePTY checks that a pseudo-terminal can be opened
+func probePTY() bool {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		logrus.WithError(err).Debug("Pseudo-terminals are unavailable")
+		return false
+	}
+	tty.Close()
+	ptmx.Close()
+	return true
+}
+
+// checkCapabilities refuses sessions that need a feature the platform lacks
+func (m *Manager) checkCapabilities(req *types.SessionCreateRequest, profile *types.Profile, backend types.SessionBackend) error {
+	caps := m.capabilities
+	if caps == nil {
+		return nil
+	}
+
+	if !caps.FIFO {
+		return fmt.Errorf("sessions are %w: named pipes are unavailable", ErrFeatureUnavailable)
+	}
+
+	if backend != types.SessionBackendSerial && (req.AllocatePTY == nil || *req.AllocatePTY) && !caps.PTY {
+		return fmt.Errorf("pty sessions are %w: pseudo-terminals are unavailable", ErrFeatureUnavailable)
+	}
+
+	if backend == types.SessionBackendSandbox && profile != nil && profile.Sandbox != nil &&
+		profile.Sandbox.Runtime == types.SandboxRuntimeGVisor && !caps.Namespaces {
+		return fmt.Errorf("gvisor sandboxes are %w: user namespaces are unavailable", ErrFeatureUnavailable)
+	}
+
+	return nil
+}
+
+// Capabilities returns the platform features detected at startup
+func (m *Manager) Capabilities() *Capabilities {
+	return m.capabilities
+}
@@ -0,0 +1,28 @@
+//go:build linux
+
+package terminal
+
+import "os"
+
+// probeCgroups checks for a mounted cgroup hierarchy, v2 or v1
+func probeCgroups() bool {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		return true
+	}
+	_, err := os.Stat("/proc/self/cgroup")
+	return err == nil
+}
+
+// probeNamespaces checks that the kernel has user namespaces and lets
+// unprivileged users create them
+func probeNamespaces() bool {
+	if _, err := os.Stat("/proc/self/ns/user"); err != nil {
+		return false
+	}
+
+	// Distributions may turn unprivileged user namespaces off
+	if data, err := os.ReadFile("/proc/sys/kernel/unprivileged_userns_clone"); err == nil && len(data) > 0 && data[0] == '0' {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package terminal
+
+// probeCgroups reports cgroups as missing, as only Linux has them
+func probeCgroups() bool {
+	return false
+}
+
+// probeNamespaces reports user namespaces as missing, as only Linux has them
+func probeNamespaces() bool {
+	return false
+}
@@ -33,6 +33,11 @@ func (cm *CleanupManager) CleanupSession(session *types.Session) error {
 		}
 	}
 
+	// Close the stderr pipe of commands run without a PTY
+	if session.Stderr != nil {
+		session.Stderr.Close()
+	}
+
 	// Terminate process if running
 	if session.Process != nil {
 		if err := cm.terminateProcess(session.Process); err != nil {
@@ -40,10 +45,22 @@ func (cm *CleanupManager) CleanupSession(session *types.Session) error {
 		}
 	}
 
+	// Destroy the session's container
+	if session.Container != "" && session.ContainerRuntime != "" {
+		if err := removeContainer(session.ContainerRuntime, session.Container); err != nil {
+			logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to remove container")
+		}
+	}
+
 	// Clean up named pipes
 	if err := cm.pipeManager.CleanupSessionPipes(session.ID, session.InputPipe, session.OutputFile); err != nil {
 		logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to cleanup pipes")
 	}
+	if session.StderrFile != "" {
+		if err := os.Remove(session.StderrFile); err != nil && !os.IsNotExist(err) {
+			logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to remove stderr file")
+		}
+	}
 
 	logrus.WithField("session_id", session.ID).Info("Session cleanup completed")
 	return nil
@@ -142,10 +159,15 @@ func (cm *CleanupManager) CleanupOrphanedResources() error {
 		return err
 	}
 
-	// Remove all files in pipes directory
+	// Remove all files in pipes directory, including secrets directories, but
+	// not the directories of other instances that are still running
 	for _, entry := range entries {
 		filePath := pipesDir + "/" + entry.Name()
-		if err := os.Remove(filePath); err != nil {
+		if entry.IsDir() && pipesDirInUse(filePath) {
+			logrus.WithField("dir", filePath).Debug("Skipping pipes directory of a running instance")
+			continue
+		}
+		if err := os.RemoveAll(filePath); err != nil {
 			logrus.WithError(err).WithField("file", filePath).Error("Failed to remove orphaned file")
 		} else {
 			logrus.WithField("file", filePath).Info("Removed orphaned file")
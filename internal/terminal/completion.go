@@ -0,0 +1,102 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// maxCompletions caps the entries returned for one partial path
+const maxCompletions = 100
+
+// ErrCompletionUnavailable is returned for sessions whose files are not on the host
+var ErrCompletionUnavailable = errors.New("path completion is only available for running pty sessions")
+
+// CompletePath lists the files and directories matching a partial path, with
+// relative paths resolved against the working directory of the session's
+// foreground process
+func (m *Manager) CompletePath(sessionID, partial string) (*types.PathCompletions, error) {
+	m.mutex.RLock()
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		m.mutex.RUnlock()
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	// Container and sandbox sessions see a different filesystem than the server
+	if session.Backend != types.SessionBackendPTY || session.Container != "" || !session.IsActive() ||
+		session.PTY == nil || session.Process == nil || session.Process.Process == nil {
+		m.mutex.RUnlock()
+		return nil, ErrCompletionUnavailable
+	}
+
+	ptyFile, pid := session.PTY, session.Process.Process.Pid
+	m.mutex.RUnlock()
+
+	cwd, err := sessionWorkingDir(ptyFile, pid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find session working directory: %w", err)
+	}
+
+	return completePath(cwd, partial), nil
+}
+
+// completePath matches the last element of partial against the entries of
+// the directory it names. Hidden entries are only offered for prefixes
+// starting with a dot.
+func completePath(cwd, partial string) *types.PathCompletions {
+	result := &types.PathCompletions{
+		Cwd:         cwd,
+		Completions: []types.PathCompletion{},
+	}
+
+	dirPart, prefix := "", partial
+	if i := strings.LastIndex(partial, "/"); i >= 0 {
+		dirPart, prefix = partial[:i+1], partial[i+1:]
+	}
+
+	dir := dirPart
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(cwd, dir)
+	}
+
+	// Unreadable or missing directories simply have no completions
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return result
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+
+		if len(result.Completions) == maxCompletions {
+			result.Truncated = true
+			break
+		}
+
+		completion := types.PathCompletion{Value: dirPart + name, Type: "file"}
+		if isDir(dir, entry) {
+			completion.Value += "/"
+			completion.Type = "directory"
+		}
+		result.Completions = append(result.Completions, completion)
+	}
+
+	return result
+}
+
+// isDir reports whether a directory entry is a directory or a link to one
+func isDir(dir string, entry os.DirEntry) bool {
+	if entry.Type()&os.ModeSymlink != 0 {
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		return err == nil && info.IsDir()
+	}
+	return entry.IsDir()
+}
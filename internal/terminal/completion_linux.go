@@ -0,0 +1,21 @@
+//go:build linux
+
+package terminal
+
+import (
+	"fmt"
+	"os"
+)
+
+// sessionWorkingDir returns the working directory of the terminal's
+// foreground process group, such as a program the shell is running, falling
+// back to the shell's own
+func sessionWorkingDir(ptyFile *os.File, shellPid int) (string, error) {
+	if pgrp, err := foregroundProcessGroup(ptyFile); err == nil && pgrp > 0 && pgrp != shellPid {
+		if cwd, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pgrp)); err == nil {
+			return cwd, nil
+		}
+	}
+
+	return os.Readlink(fmt.Sprintf("/proc/%d/cwd", shellPid))
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// sessionWorkingDir is not implemented on this platform
+func sessionWorkingDir(_ *os.File, _ int) (string, error) {
+	return "", fmt.Errorf("session working directories are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,326 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultContainerRuntime is the container CLI used when a profile does not name one
+const DefaultContainerRuntime = "docker"
+
+// hostnamePattern matches host names and domains given to containers
+var hostnamePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// containerName returns the name of the container backing a session
+func containerName(sessionID string) string {
+	return "webterm-" + sessionID
+}
+
+// buildContainerCommand builds the command that runs a session inside a new container
+func buildContainerCommand(opts *types.ContainerOptions, policy DevicePolicy, egress *egressFilter, sessionID string, req *types.SessionCreateRequest, creds *sessionCredentials) (string, []string, error) {
+	if opts == nil || opts.Image == "" {
+		return "", nil, fmt.Errorf("container backend requires an image")
+	}
+
+	runtimePath, err := resolveContainerRuntime(opts.Runtime)
+	if err != nil {
+		return "", nil, err
+	}
+
+	runArgs, err := containerRunArgs(opts, policy, egress)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := []string{
+		runtimePath, "run", "--rm", "-i", "-t",
+		"--name", containerName(sessionID),
+		"--label", "webterm.session=" + sessionID,
+	}
+	args = append(args, runArgs...)
+	args = append(args, containerSessionArgs(req)...)
+	args = append(args, creds.containerArgs(true)...)
+	args = append(args, opts.Image)
+	args = append(args, containerShellCommand(req)...)
+
+	return runtimePath, args, nil
+}
+
+// startFilteredContainer starts a session's container idle, like a warm
+// container, so that its egress firewall is in place before the session's
+// shell is executed in it
+func startFilteredContainer(opts *types.ContainerOptions, policy DevicePolicy, egress *egressFilter, sessionID string, creds *sessionCredentials) (string, error) {
+	if opts == nil || opts.Image == "" {
+		return "", fmt.Errorf("container backend requires an image")
+	}
+
+	runtimePath, err := resolveContainerRuntime(opts.Runtime)
+	if err != nil {
+		return "", err
+	}
+
+	runArgs, err := containerRunArgs(opts, policy, egress)
+	if err != nil {
+		return "", err
+	}
+
+	name := containerName(sessionID)
+	args := []string{"run", "-d", "--rm", "--name", name, "--label", "webterm.session=" + sessionID}
+	args = append(args, runArgs...)
+	args = append(args, creds.containerArgs(true)...)
+	args = append(args, "--entrypoint", "sleep", opts.Image, "infinity")
+
+	if _, err := runContainerCommand(2*time.Minute, runtimePath, args...); err != nil {
+		return "", err
+	}
+
+	if err := egress.apply(runtimePath, name); err != nil {
+		removeContainer(runtimePath, name)
+		return "", err
+	}
+	return runtimePath, nil
+}
+
+// DevicePolicy is the admin allowlist for container device passthrough
+type DevicePolicy struct {
+	AllowedDevices []string // Glob patterns of host devices profiles may request
+	AllowGPUs      bool     // Whether profiles may request GPUs
+}
+
+// containerRunArgs returns the network, mount and device flags shared by all containers of a profile
+func containerRunArgs(opts *types.ContainerOptions, policy DevicePolicy, egress *egressFilter) ([]string, error) {
+	network := opts.Network
+	switch {
+	case egress.denyAll():
+		network = "none"
+	case network == "" && egress != nil:
+		network = "bridge"
+	case network == "":
+		network = "none"
+	}
+
+	args := []string{"--network", network}
+	args = append(args, egress.runArgs()...)
+	for _, mount := range opts.Mounts {
+		if err := validateMount(mount); err != nil {
+			return nil, err
+		}
+		args = append(args, "-v", mount)
+	}
+
+	devices, err := resolveDevices(opts.Devices, policy.AllowedDevices)
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		args = append(args, "--device", device)
+	}
+
+	if opts.GPUs != "" {
+		if !policy.AllowGPUs {
+			return nil, fmt.Errorf("GPU passthrough is not allowed")
+		}
+		args = append(args, "--gpus", opts.GPUs)
+	}
+
+	resolverArgs, err := containerResolverArgs(opts)
+	if err != nil {
+		return nil, err
+	}
+	// Without a network there is nothing to resolve, and the CLI refuses them
+	if network != "none" {
+		args = append(args, resolverArgs...)
+	}
+
+	return args, nil
+}
+
+// containerResolverArgs returns the flags overriding name resolution inside
+// a container: /etc/hosts entries, name servers and search domains
+func containerResolverArgs(opts *types.ContainerOptions) ([]string, error) {
+	// Sort for a stable command line
+	names := make([]string, 0, len(opts.Hosts))
+	for name := range opts.Hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var args []string
+	for _, name := range names {
+		if !hostnamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid host name: %s", name)
+		}
+		address := opts.Hosts[name]
+		if net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("invalid address for host %s: %s", name, address)
+		}
+		args = append(args, "--add-host", name+":"+address)
+	}
+
+	for _, server := range opts.DNS {
+		if net.ParseIP(server) == nil {
+			return nil, fmt.Errorf("invalid name server: %s", server)
+		}
+		args = append(args, "--dns", server)
+	}
+
+	for _, domain := range opts.DNSSearch {
+		if !hostnamePattern.MatchString(domain) {
+			return nil, fmt.Errorf("invalid search domain: %s", domain)
+		}
+		args = append(args, "--dns-search", domain)
+	}
+
+	return args, nil
+}
+
+// resolveDevices expands device requests and checks every device against the allowlist.
+// Requests are host paths or globs, optionally followed by :container[:permissions].
+func resolveDevices(requests, allowed []string) ([]string, error) {
+	var devices []string
+
+	for _, request := range requests {
+		hostPath, mapping, _ := strings.Cut(request, ":")
+
+		matches, err := filepath.Glob(hostPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device pattern: %s", request)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("device not found: %s", hostPath)
+		}
+		if mapping != "" && len(matches) > 1 {
+			return nil, fmt.Errorf("device mapping requires a single device: %s", request)
+		}
+
+		for _, match := range matches {
+			if !isDeviceAllowed(match, allowed) {
+				return nil, fmt.Errorf("device not allowed: %s", match)
+			}
+
+			stat, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat device: %w", err)
+			}
+			if stat.Mode()&os.ModeDevice == 0 {
+				return nil, fmt.Errorf("not a device: %s", match)
+			}
+
+			if mapping != "" {
+				devices = append(devices, match+":"+mapping)
+			} else {
+				devices = append(devices, match)
+			}
+		}
+	}
+
+	return devices, nil
+}
+
+// buildContainerExecCommand builds the command that runs a session inside an already running container
+func buildContainerExecCommand(runtimePath, name string, req *types.SessionCreateRequest, creds *sessionCredentials) []string {
+	args := []string{runtimePath, "exec", "-i", "-t"}
+	args = append(args, containerSessionArgs(req)...)
+	args = append(args, creds.containerArgs(false)...)
+	args = append(args, name)
+	return append(args, containerShellCommand(req)...)
+}
+
+// containerSessionArgs returns the working directory and environment flags for a session
+func containerSessionArgs(req *types.SessionCreateRequest) []string {
+	var args []string
+	if req.WorkingDir != "" {
+		args = append(args, "-w", req.WorkingDir)
+	}
+
+	// Sort for a stable command line
+	keys := make([]string, 0, len(req.Env))
+	for key := range req.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", key, req.Env[key]))
+	}
+
+	return args
+}
+
+// containerShellCommand returns the requested command, or an interactive shell from the image
+func containerShellCommand(req *types.SessionCreateRequest) []string {
+	switch {
+	case len(req.Command) > 0:
+		return req.Command
+	case req.Shell != "":
+		return append([]string{req.Shell}, getInteractiveArgs(req.Shell)...)
+	default:
+		return []string{"/bin/sh", "-i"}
+	}
+}
+
+// validateMount checks a host:container[:ro] bind mount specification
+func validateMount(mount string) error {
+	parts := strings.Split(mount, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("invalid mount: %s", mount)
+	}
+
+	if !filepath.IsAbs(parts[0]) || !filepath.IsAbs(parts[1]) {
+		return fmt.Errorf("mount paths must be absolute: %s", mount)
+	}
+
+	if len(parts) == 3 && parts[2] != "ro" && parts[2] != "rw" {
+		return fmt.Errorf("invalid mount mode: %s", mount)
+	}
+
+	return nil
+}
+
+// resolveContainerRuntime locates the container CLI binary
+func resolveContainerRuntime(configured string) (string, error) {
+	name := configured
+	if name == "" {
+		name = DefaultContainerRuntime
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("container runtime not found: %s", name)
+	}
+	return path, nil
+}
+
+// removeContainer force-removes a session's container
+func removeContainer(runtimePath, name string) error {
+	logrus.WithField("container", name).Info("Removing session container")
+
+	_, err := runContainerCommand(30*time.Second, runtimePath, "rm", "-f", name)
+	return err
+}
+
+// runContainerCommand runs a container CLI command with a timeout and returns its output
+func runContainerCommand(timeout time.Duration, runtimePath string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, runtimePath, args...).CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("timed out running %s %s", filepath.Base(runtimePath), args[0])
+		}
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
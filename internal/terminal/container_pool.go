@@ -0,0 +1,315 @@
+package terminal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// warmContainer is a pre-started container waiting to be claimed by a session
+type warmContainer struct {
+	name      string
+	createdAt time.Time
+}
+
+// ContainerPool keeps pre-started containers ready for container-backed profiles
+// so that session creation does not wait for image pulls and container startup
+type ContainerPool struct {
+	profiles map[string]*types.Profile
+	policy   DevicePolicy
+	size     int
+	maxIdle  time.Duration
+
+	mutex    sync.Mutex
+	warm     map[string][]*warmContainer // Warm containers by profile name
+	pulled   map[string]bool             // Profiles whose image has been pulled
+	filling  map[string]bool             // Profiles currently being refilled
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewContainerPool creates a warm pool for every container profile
+func NewContainerPool(profiles map[string]*types.Profile, policy DevicePolicy, size int, maxIdle time.Duration) *ContainerPool {
+	pooled := make(map[string]*types.Profile)
+	for name, profile := range profiles {
+		if profile.Backend == types.SessionBackendContainer && profile.Container != nil {
+			pooled[name] = profile
+		}
+	}
+
+	return &ContainerPool{
+		profiles: pooled,
+		policy:   policy,
+		size:     size,
+		maxIdle:  maxIdle,
+		warm:     make(map[string][]*warmContainer),
+		pulled:   make(map[string]bool),
+		filling:  make(map[string]bool),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start fills the pool and begins periodic reaping
+func (cp *ContainerPool) Start() {
+	logrus.WithFields(logrus.Fields{
+		"profiles": len(cp.profiles),
+		"size":     cp.size,
+		"max_idle": cp.maxIdle,
+	}).Info("Starting container warm pool")
+
+	for name := range cp.profiles {
+		go cp.fill(name)
+	}
+
+	go cp.reapRoutine()
+}
+
+// Acquire claims a warm container for a profile, returning false if none is ready
+func (cp *ContainerPool) Acquire(profileName string) (string, bool) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	containers := cp.warm[profileName]
+	if len(containers) == 0 {
+		return "", false
+	}
+
+	claimed := containers[0]
+	cp.warm[profileName] = containers[1:]
+
+	logrus.WithFields(logrus.Fields{
+		"profile":   profileName,
+		"container": claimed.name,
+		"remaining": len(cp.warm[profileName]),
+	}).Info("Claimed warm container")
+
+	// Replace the claimed container in the background
+	go cp.fill(profileName)
+
+	return claimed.name, true
+}
+
+// fill starts containers until the profile's pool is full
+func (cp *ContainerPool) fill(profileName string) {
+	cp.mutex.Lock()
+	if cp.filling[profileName] {
+		cp.mutex.Unlock()
+		return
+	}
+	cp.filling[profileName] = true
+	cp.mutex.Unlock()
+
+	defer func() {
+		cp.mutex.Lock()
+		cp.filling[profileName] = false
+		cp.mutex.Unlock()
+	}()
+
+	profile := cp.profiles[profileName]
+	runtimePath, err := resolveContainerRuntime(profile.Container.Runtime)
+	if err != nil {
+		logrus.WithError(err).WithField("profile", profileName).Error("Cannot fill container pool")
+		return
+	}
+
+	if err := cp.pullImage(profileName, runtimePath, profile.Container.Image); err != nil {
+		logrus.WithError(err).WithField("profile", profileName).Error("Failed to pull container image")
+		return
+	}
+
+	for {
+		select {
+		case <-cp.stopChan:
+			return
+		default:
+		}
+
+		cp.mutex.Lock()
+		missing := cp.size - len(cp.warm[profileName])
+		cp.mutex.Unlock()
+
+		if missing <= 0 {
+			return
+		}
+
+		name, err := cp.startWarmContainer(profileName, runtimePath, profile.Container)
+		if err != nil {
+			logrus.WithError(err).WithField("profile", profileName).Error("Failed to start warm container")
+			return
+		}
+
+		cp.mutex.Lock()
+		select {
+		case <-cp.stopChan:
+			// The pool stopped while the container was starting
+			cp.mutex.Unlock()
+			cp.removeAll(profileName, []string{name})
+			return
+		default:
+		}
+		cp.warm[profileName] = append(cp.warm[profileName], &warmContainer{name: name, createdAt: time.Now()})
+		cp.mutex.Unlock()
+	}
+}
+
+// pullImage pulls a profile's image once so that warm containers start quickly
+func (cp *ContainerPool) pullImage(profileName, runtimePath, image string) error {
+	cp.mutex.Lock()
+	pulled := cp.pulled[profileName]
+	cp.mutex.Unlock()
+
+	if pulled {
+		return nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile": profileName,
+		"image":   image,
+	}).Info("Pulling container image")
+
+	if _, err := runContainerCommand(10*time.Minute, runtimePath, "pull", image); err != nil {
+		return err
+	}
+
+	cp.mutex.Lock()
+	cp.pulled[profileName] = true
+	cp.mutex.Unlock()
+
+	return nil
+}
+
+// startWarmContainer starts an idle container that sessions can exec into
+func (cp *ContainerPool) startWarmContainer(profileName, runtimePath string, opts *types.ContainerOptions) (string, error) {
+	name := "webterm-pool-" + uuid.New().String()
+
+	// Allowed domains are resolved again for every warm container
+	egress, err := resolveEgress(cp.profiles[profileName].Egress)
+	if err != nil {
+		return "", err
+	}
+
+	runArgs, err := containerRunArgs(opts, cp.policy, egress)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"run", "-d", "--rm", "--name", name, "--label", "webterm.pool=" + profileName}
+	args = append(args, runArgs...)
+	args = append(args, "--entrypoint", "sleep", opts.Image, "infinity")
+
+	if _, err := runContainerCommand(2*time.Minute, runtimePath, args...); err != nil {
+		return "", err
+	}
+
+	if err := egress.apply(runtimePath, name); err != nil {
+		removeContainer(runtimePath, name)
+		return "", err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile":   profileName,
+		"container": name,
+	}).Debug("Started warm container")
+
+	return name, nil
+}
+
+// reapRoutine periodically replaces containers that have been idle too long
+func (cp *ContainerPool) reapRoutine() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cp.reap()
+		case <-cp.stopChan:
+			return
+		}
+	}
+}
+
+// reap removes stale warm containers and refills the pool
+func (cp *ContainerPool) reap() {
+	if cp.maxIdle <= 0 {
+		return
+	}
+
+	now := time.Now()
+	stale := make(map[string][]string)
+
+	cp.mutex.Lock()
+	for profileName, containers := range cp.warm {
+		fresh := containers[:0]
+		for _, container := range containers {
+			if now.Sub(container.createdAt) > cp.maxIdle {
+				stale[profileName] = append(stale[profileName], container.name)
+			} else {
+				fresh = append(fresh, container)
+			}
+		}
+		cp.warm[profileName] = fresh
+	}
+	cp.mutex.Unlock()
+
+	for profileName, names := range stale {
+		cp.removeAll(profileName, names)
+
+		logrus.WithFields(logrus.Fields{
+			"profile": profileName,
+			"reaped":  len(names),
+		}).Info("Reaped idle warm containers")
+
+		go cp.fill(profileName)
+	}
+}
+
+// removeAll removes the named warm containers of a profile
+func (cp *ContainerPool) removeAll(profileName string, names []string) {
+	runtimePath, err := resolveContainerRuntime(cp.profiles[profileName].Container.Runtime)
+	if err != nil {
+		return
+	}
+
+	for _, name := range names {
+		if err := removeContainer(runtimePath, name); err != nil {
+			logrus.WithError(err).WithField("container", name).Warn("Failed to remove warm container")
+		}
+	}
+}
+
+// GetStats returns the number of warm containers per profile
+func (cp *ContainerPool) GetStats() map[string]int {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	stats := make(map[string]int, len(cp.profiles))
+	for name := range cp.profiles {
+		stats[name] = len(cp.warm[name])
+	}
+	return stats
+}
+
+// Stop stops reaping and removes all warm containers
+func (cp *ContainerPool) Stop() {
+	cp.stopOnce.Do(func() {
+		logrus.Info("Stopping container warm pool")
+		close(cp.stopChan)
+
+		cp.mutex.Lock()
+		warm := cp.warm
+		cp.warm = make(map[string][]*warmContainer)
+		cp.mutex.Unlock()
+
+		for profileName, containers := range warm {
+			names := make([]string, 0, len(containers))
+			for _, container := range containers {
+				names = append(names, container.name)
+			}
+			cp.removeAll(profileName, names)
+		}
+	})
+}
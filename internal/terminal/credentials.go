@@ -0,0 +1,281 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/secrets"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SecretsDirEnv names the variable pointing sessions at their secret files
+	SecretsDirEnv = "WEBTERM_SECRETS_DIR"
+	// containerSecretsDir is where the secrets directory is mounted in containers
+	containerSecretsDir = "/run/secrets/webterm"
+	// secretsTimeout bounds each request to the secrets provider
+	secretsTimeout = 10 * time.Second
+)
+
+// sessionCredentials holds the secrets issued for one session
+type sessionCredentials struct {
+	env     map[string]string // Variables injected into the session
+	dir     string            // Host directory holding secret files, if any
+	ccache  string            // Kerberos credential cache in dir, if any
+	account *account          // Account the session runs as, owning dir; nil for the server's user
+	leases  []*secrets.Lease
+}
+
+// issueCredentials fetches the secrets a profile asks for, writes any secret
+// files and sets up Kerberos tickets. Leases issued before a failure are
+// revoked.
+func (m *Manager) issueCredentials(session *types.Session, req *types.SessionCreateRequest, profile *types.Profile) (*sessionCredentials, error) {
+	forwarded, err := decodeKerberosTicket(req.KerberosTicket, profile)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil || (len(profile.Secrets) == 0 && profile.Kerberos == nil) {
+		return nil, nil
+	}
+	if len(profile.Secrets) > 0 && m.secretsProvider == nil {
+		return nil, fmt.Errorf("profile %s requires secrets but no secrets provider is configured", profile.Name)
+	}
+
+	acct, err := m.sessionAccount(session)
+	if err != nil {
+		return nil, err
+	}
+	creds := &sessionCredentials{env: make(map[string]string), account: acct}
+
+	for _, spec := range profile.Secrets {
+		path := strings.NewReplacer(
+			"{user}", url.PathEscape(session.Owner),
+			"{tenant}", url.PathEscape(session.Tenant),
+		).Replace(spec.Path)
+
+		ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+		lease, err := m.secretsProvider.Issue(ctx, path)
+		cancel()
+		if err != nil {
+			m.releaseCredentials(session.ID, creds)
+			return nil, fmt.Errorf("failed to issue credentials: %w", err)
+		}
+		creds.leases = append(creds.leases, lease)
+
+		for name, field := range spec.Env {
+			value, exists := lease.Data[field]
+			if !exists {
+				m.releaseCredentials(session.ID, creds)
+				return nil, fmt.Errorf("secret %s has no field %s", path, field)
+			}
+			creds.env[name] = value
+		}
+
+		for name, field := range spec.Files {
+			value, exists := lease.Data[field]
+			if !exists {
+				m.releaseCredentials(session.ID, creds)
+				return nil, fmt.Errorf("secret %s has no field %s", path, field)
+			}
+			if err := creds.writeFile(m.pipeManager.GetPipesDir(), session.ID, name, value); err != nil {
+				m.releaseCredentials(session.ID, creds)
+				return nil, err
+			}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"session_id": session.ID,
+			"path":       path,
+			"lease_id":   lease.ID,
+			"ttl":        lease.TTL.String(),
+		}).Info("Session credentials issued")
+	}
+
+	if err := creds.issueKerberos(m.pipeManager.GetPipesDir(), session, profile.Kerberos, forwarded); err != nil {
+		m.releaseCredentials(session.ID, creds)
+		return nil, err
+	}
+
+	// Hand the directory over last, so the server fills it without needing
+	// to override its permissions
+	if creds.dir != "" {
+		if err := creds.chown(creds.dir); err != nil {
+			m.releaseCredentials(session.ID, creds)
+			return nil, err
+		}
+	}
+
+	return creds, nil
+}
+
+// writeFile stores a secret in the session's private secrets directory
+func (c *sessionCredentials) writeFile(baseDir, sessionID, name, value string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid secret file name: %q", name)
+	}
+
+	if err := c.ensureDir(baseDir, sessionID); err != nil {
+		return err
+	}
+
+	path := filepath.Join(c.dir, name)
+	if err := os.WriteFile(path, []byte(value), 0600); err != nil {
+		return fmt.Errorf("failed to write secret file: %w", err)
+	}
+
+	return c.chown(path)
+}
+
+// ensureDir creates the session's private secrets directory, once. Sessions
+// running as another account cannot enter the server's pipes directory, so
+// theirs is created in the system's temporary directory instead.
+func (c *sessionCredentials) ensureDir(baseDir, sessionID string) error {
+	if c.dir != "" {
+		return nil
+	}
+
+	if c.account != nil && c.account.credential != nil {
+		dir, err := os.MkdirTemp("", "webterm-"+sessionID+".secrets-")
+		if err != nil {
+			return fmt.Errorf("failed to create secrets directory: %w", err)
+		}
+		c.dir = dir
+		return nil
+	}
+
+	dir := filepath.Join(baseDir, sessionID+".secrets")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	c.dir = dir
+	return nil
+}
+
+// chown gives a secret file or directory to the account the session runs
+// as, so that it can read it and, with the mode kept private, no one else
+func (c *sessionCredentials) chown(path string) error {
+	if c.account == nil || c.account.credential == nil {
+		return nil
+	}
+
+	credential := c.account.credential
+	if err := os.Chown(path, int(credential.Uid), int(credential.Gid)); err != nil {
+		return fmt.Errorf("failed to give secret file to account %s: %w", c.account.name, err)
+	}
+	return nil
+}
+
+// processEnv returns env with the secrets added, for backends running on the host
+func (c *sessionCredentials) processEnv(env map[string]string) map[string]string {
+	if c == nil {
+		return env
+	}
+
+	merged := make(map[string]string, len(env)+len(c.env)+2)
+	for key, value := range env {
+		merged[key] = value
+	}
+	for key, value := range c.env {
+		merged[key] = value
+	}
+	if c.dir != "" {
+		merged[SecretsDirEnv] = c.dir
+	}
+	if c.ccache != "" {
+		merged[kerberosCacheEnv] = "FILE:" + c.ccache
+	}
+
+	return merged
+}
+
+// containerArgs returns the container CLI flags exposing the secrets. Values
+// are passed through the CLI's environment rather than its command line so
+// that they do not show up in the host's process list.
+func (c *sessionCredentials) containerArgs(mount bool) []string {
+	if c == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(c.env))
+	for name := range c.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var args []string
+	for _, name := range names {
+		args = append(args, "-e", name)
+	}
+	if mount && c.dir != "" {
+		args = append(args,
+			"-v", c.dir+":"+containerSecretsDir+":ro",
+			"-e", SecretsDirEnv+"="+containerSecretsDir,
+		)
+	}
+	if c.ccache != "" {
+		args = append(args, "-e", kerberosCacheEnv+"=FILE:"+containerSecretsDir+"/"+kerberosCacheName)
+	}
+
+	return args
+}
+
+// trackCredentials remembers a session's credentials for revocation
+func (m *Manager) trackCredentials(sessionID string, creds *sessionCredentials) {
+	if creds == nil {
+		return
+	}
+
+	m.credentialsMutex.Lock()
+	defer m.credentialsMutex.Unlock()
+	m.credentials[sessionID] = creds
+}
+
+// revokeCredentials revokes a session's leases and removes its secret files.
+// It is safe to call more than once.
+func (m *Manager) revokeCredentials(sessionID string) {
+	m.credentialsMutex.Lock()
+	creds, exists := m.credentials[sessionID]
+	delete(m.credentials, sessionID)
+	m.credentialsMutex.Unlock()
+
+	if exists {
+		m.releaseCredentials(sessionID, creds)
+	}
+}
+
+// releaseCredentials revokes leases and deletes secret files
+func (m *Manager) releaseCredentials(sessionID string, creds *sessionCredentials) {
+	if creds.dir != "" {
+		if err := os.RemoveAll(creds.dir); err != nil {
+			logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to remove secrets directory")
+		}
+	}
+
+	for _, lease := range creds.leases {
+		ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+		err := m.secretsProvider.Revoke(ctx, lease)
+		cancel()
+
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"session_id": sessionID,
+				"lease_id":   lease.ID,
+			}).Error("Failed to revoke session credentials")
+			continue
+		}
+
+		if lease.ID != "" {
+			logrus.WithFields(logrus.Fields{
+				"session_id": sessionID,
+				"lease_id":   lease.ID,
+			}).Info("Session credentials revoked")
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package terminal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// firstDisplay is the lowest X display number given to sessions, above
+	// those a desktop on the host would use
+	firstDisplay = 100
+	// maxDisplays bounds the display numbers tried when starting a display
+	maxDisplays = 100
+	// displayStartTimeout bounds how long an X server may take to start
+	displayStartTimeout = 10 * time.Second
+	// displayGeometry is the screen size of session displays
+	displayGeometry = "1280x800"
+)
+
+var (
+	// ErrDisplayUnavailable is returned for displays requested where they
+	// cannot be provided
+	ErrDisplayUnavailable = errors.New("graphical displays are only available for pty sessions on servers with WEBTERM_DISPLAY_ENABLED")
+	// ErrNoDisplay is returned for the display of a session without one
+	ErrNoDisplay = errors.New("session has no display")
+)
+
+// displayServer is the X server of a session, which also serves the screen
+// over VNC on a Unix socket. A nil displayServer adds nothing to a session.
+type displayServer struct {
+	number    int
+	socket    string // VNC socket
+	authority string // Xauthority file holding the session's cookie
+	process   *exec.Cmd
+	exited    chan struct{}
+}
+
+// FindDisplayServer returns the path of the Xvnc binary that serves session
+// displays
+func FindDisplayServer() (string, error) {
+	path, err := exec.LookPath("Xvnc")
+	if err != nil {
+		return "", fmt.Errorf("graphical displays require Xvnc (TigerVNC): %w", err)
+	}
+	return path, nil
+}
+
+// validateDisplay checks that a session's display can be provided
+func (m *Manager) validateDisplay(req *types.SessionCreateRequest, backend types.SessionBackend) error {
+	if !req.Display {
+		return nil
+	}
+	if m.displayServer == "" || backend != types.SessionBackendPTY {
+		return ErrDisplayUnavailable
+	}
+	return nil
+}
+
+// startDisplay starts an X server for a session that asked for one, on the
+// first free display number (assumes mutex is held)
+func (m *Manager) startDisplay(session *types.Session, req *types.SessionCreateRequest) error {
+	if !req.Display {
+		return nil
+	}
+
+	dir := m.pipeManager.GetPipesDir()
+	display := &displayServer{
+		socket:    filepath.Join(dir, session.ID+".vnc"),
+		authority: filepath.Join(dir, session.ID+".xauth"),
+	}
+
+	// Only holders of the cookie may connect to the X server
+	if err := writeXauthority(display.authority); err != nil {
+		return err
+	}
+
+	for number := firstDisplay; number < firstDisplay+maxDisplays; number++ {
+		if m.displayInUse(number) {
+			continue
+		}
+
+		display.number = number
+		err := display.start(m.displayServer)
+		if err == nil {
+			m.displays[session.ID] = display
+			session.Display = fmt.Sprintf(":%d", number)
+
+			logrus.WithFields(logrus.Fields{
+				"session_id": session.ID,
+				"display":    session.Display,
+			}).Info("Session display started")
+			return nil
+		}
+
+		logrus.WithError(err).WithField("display", number).Debug("Failed to start display, trying the next one")
+	}
+
+	os.Remove(display.authority)
+	return fmt.Errorf("failed to start display: no free display number")
+}
+
+// displayInUse reports whether an X server of this or another program holds a
+// display number (assumes mutex is held)
+func (m *Manager) displayInUse(number int) bool {
+	for _, display := range m.displays {
+		if display.number == number {
+			return true
+		}
+	}
+
+	for _, path := range []string{
+		fmt.Sprintf("/tmp/.X%d-lock", number),
+		fmt.Sprintf("/tmp/.X11-unix/X%d", number),
+	} {
+		if _, err := os.Lstat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// start runs the X server and waits for its VNC socket to appear
+func (d *displayServer) start(serverPath string) error {
+	os.Remove(d.socket)
+
+	d.process = exec.Command(serverPath, fmt.Sprintf(":%d", d.number),
+		"-auth", d.authority,
+		"-nolisten", "tcp",
+		"-rfbunixpath", d.socket,
+		"-rfbunixmode", "0600",
+		"-SecurityTypes", "None",
+		"-geometry", displayGeometry,
+		"-depth", "24",
+		"-AlwaysShared",
+	)
+	if err := d.process.Start(); err != nil {
+		return fmt.Errorf("failed to start display server: %w", err)
+	}
+
+	d.exited = make(chan struct{})
+	go func() {
+		d.process.Wait()
+		close(d.exited)
+	}()
+
+	deadline := time.NewTimer(displayStartTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if _, err := os.Stat(d.socket); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.exited:
+			return fmt.Errorf("display server exited: %v", d.process.ProcessState)
+		case <-deadline.C:
+			d.process.Process.Kill()
+			<-d.exited
+			return fmt.Errorf("display server did not start within %s", displayStartTimeout)
+		}
+	}
+}
+
+// stop ends the X server and removes its files. The server is asked to exit
+// first, so that it releases its display number.
+func (d *displayServer) stop() {
+	if d == nil {
+		return
+	}
+
+	if d.process != nil && d.process.Process != nil {
+		d.process.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-d.exited:
+		case <-time.After(5 * time.Second):
+			d.process.Process.Kill()
+			<-d.exited
+		}
+	}
+	os.Remove(d.socket)
+	os.Remove(d.authority)
+}
+
+// env returns env with the variables pointing X clients at the display
+func (d *displayServer) env(env map[string]string) map[string]string {
+	if d == nil {
+		return env
+	}
+
+	merged := make(map[string]string, len(env)+2)
+	for key, value := range env {
+		merged[key] = value
+	}
+	merged["DISPLAY"] = fmt.Sprintf(":%d", d.number)
+	merged["XAUTHORITY"] = d.authority
+	return merged
+}
+
+// stopDisplay ends a session's X server (assumes mutex is held)
+func (m *Manager) stopDisplay(sessionID string) {
+	if display, exists := m.displays[sessionID]; exists {
+		display.stop()
+		delete(m.displays, sessionID)
+		logrus.WithField("session_id", sessionID).Info("Session display stopped")
+	}
+}
+
+// OpenDisplay connects to the VNC server showing a session's display
+func (m *Manager) OpenDisplay(sessionID string) (net.Conn, error) {
+	m.mutex.RLock()
+	_, exists := m.sessions[sessionID]
+	display := m.displays[sessionID]
+	m.mutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if display == nil {
+		return nil, ErrNoDisplay
+	}
+
+	return net.DialTimeout("unix", display.socket, 5*time.Second)
+}
+
+// writeXauthority writes an Xauthority file with a new MIT-MAGIC-COOKIE-1
+// that matches any display on the host
+func writeXauthority(path string) error {
+	cookie := make([]byte, 16)
+	if _, err := rand.Read(cookie); err != nil {
+		return fmt.Errorf("failed to generate display cookie: %w", err)
+	}
+
+	// Each entry is a family followed by length-prefixed address, display
+	// number, authorization name and data. FamilyWild has no address.
+	var entry bytes.Buffer
+	binary.Write(&entry, binary.BigEndian, uint16(0xffff))
+	for _, field := range [][]byte{nil, nil, []byte("MIT-MAGIC-COOKIE-1"), cookie} {
+		binary.Write(&entry, binary.BigEndian, uint16(len(field)))
+		entry.Write(field)
+	}
+
+	if err := os.WriteFile(path, entry.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write display authority: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// egressLookupTimeout bounds resolving the domains of an egress policy
+const egressLookupTimeout = 10 * time.Second
+
+// egressFilter enforces a profile's egress policy on a container: without
+// allowed destinations the container gets no network, otherwise a firewall
+// in its network namespace rejects everything else. A nil egressFilter
+// leaves the container's network alone.
+type egressFilter struct {
+	allowed []*net.IPNet
+	hosts   []string // host:address entries pinning allowed domains
+}
+
+// resolveEgress resolves the domains allowed by a policy to the addresses
+// they have now, returning nil without a policy. Lookups can take seconds, so
+// it must not be called with the manager's mutex held.
+func resolveEgress(policy *types.EgressPolicy) (*egressFilter, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	filter := &egressFilter{}
+	for _, destination := range policy.Allow {
+		if _, network, err := net.ParseCIDR(destination); err == nil {
+			filter.allowed = append(filter.allowed, network)
+			continue
+		}
+		if ip := net.ParseIP(destination).To4(); ip != nil {
+			filter.allowed = append(filter.allowed, &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)})
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), egressLookupTimeout)
+		addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", destination)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve egress destination %s: %w", destination, err)
+		}
+		for _, ip := range addrs {
+			ip = ip.To4()
+			filter.allowed = append(filter.allowed, &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)})
+			filter.hosts = append(filter.hosts, destination+":"+ip.String())
+		}
+	}
+
+	return filter, nil
+}
+
+// denyAll reports whether nothing may be reached at all
+func (f *egressFilter) denyAll() bool {
+	return f != nil && len(f.allowed) == 0
+}
+
+// runArgs returns the container flags the firewall relies on. Allowed domains
+// are pinned in /etc/hosts, since DNS is blocked; IPv6 is disabled and the
+// container may not change its own firewall.
+func (f *egressFilter) runArgs() []string {
+	if f == nil || f.denyAll() {
+		return nil
+	}
+
+	args := []string{"--cap-drop", "NET_ADMIN", "--sysctl", "net.ipv6.conf.all.disable_ipv6=1"}
+	for _, host := range f.hosts {
+		args = append(args, "--add-host", host)
+	}
+	return args
+}
+
+// apply installs the firewall in a running container's network namespace,
+// before anything but its idle entrypoint runs in it. Like resolveEgress, it
+// runs without the manager's mutex held.
+func (f *egressFilter) apply(runtimePath, container string) error {
+	if f == nil || f.denyAll() {
+		return nil
+	}
+
+	pid, err := runContainerCommand(30*time.Second, runtimePath, "inspect", "--format", "{{.State.Pid}}", container)
+	if err != nil {
+		return fmt.Errorf("failed to find container process: %w", err)
+	}
+	if _, err := strconv.Atoi(pid); err != nil || pid == "0" {
+		return fmt.Errorf("container %s is not running", container)
+	}
+
+	nsenter, err := exec.LookPath("nsenter")
+	if err != nil {
+		return fmt.Errorf("egress policies require nsenter: %w", err)
+	}
+	iptables, err := exec.LookPath("iptables")
+	if err != nil {
+		return fmt.Errorf("egress policies require iptables: %w", err)
+	}
+
+	rules := [][]string{
+		{"-A", "OUTPUT", "-o", "lo", "-j", "ACCEPT"},
+		{"-A", "OUTPUT", "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"},
+	}
+	for _, network := range f.allowed {
+		rules = append(rules, []string{"-A", "OUTPUT", "-d", network.String(), "-j", "ACCEPT"})
+	}
+	rules = append(rules, []string{"-A", "OUTPUT", "-j", "REJECT"})
+
+	for _, rule := range rules {
+		args := append([]string{"-t", pid, "-n", iptables, "-w"}, rule...)
+		if _, err := runContainerCommand(10*time.Second, nsenter, args...); err != nil {
+			return fmt.Errorf("failed to apply egress policy: %w", err)
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"container": container,
+		"allowed":   len(f.allowed),
+	}).Info("Egress policy applied")
+	return nil
+}
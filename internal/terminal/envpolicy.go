@@ -0,0 +1,122 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxEnvSize is how many bytes of environment a client may set,
+// counted as NAME=value pairs
+const DefaultMaxEnvSize = 32 * 1024
+
+// DefaultBlockedEnv are patterns of variables that change which code a
+// process loads or runs, which clients may not set unless configured
+// otherwise
+var DefaultBlockedEnv = []string{
+	"LD_*", "DYLD_*", "GCONV_PATH", "PATH", "IFS",
+	"BASH_ENV", "ENV", "BASH_FUNC_*", "SHELLOPTS", "BASHOPTS", "PS4", "PROMPT_COMMAND",
+	"PYTHONSTARTUP", "PYTHONPATH", "PERL5OPT", "PERL5LIB", "RUBYOPT", "NODE_OPTIONS",
+}
+
+var (
+	// ErrEnvNotAllowed is returned for variables the environment policy forbids
+	ErrEnvNotAllowed = errors.New("environment variable not allowed")
+	// ErrInvalidEnv is returned for malformed or oversized environments
+	ErrInvalidEnv = errors.New("invalid env")
+)
+
+// EnvPolicy restricts the environment variables clients may set for
+// sessions and panes. Variables set by a profile are chosen by the
+// administrator and are not checked.
+type EnvPolicy struct {
+	Allowed     []string // Patterns of the only variables clients may set; empty allows any not blocked
+	Blocked     []string // Patterns of variables clients may not set
+	MaxSize     int      // Bytes of environment clients may set; 0 is unlimited
+	DropBlocked bool     // Drop forbidden variables instead of refusing the request
+}
+
+// DefaultEnvPolicy returns the environment policy used unless configured
+// otherwise
+func DefaultEnvPolicy() EnvPolicy {
+	return EnvPolicy{
+		Blocked: DefaultBlockedEnv,
+		MaxSize: DefaultMaxEnvSize,
+	}
+}
+
+// SetEnvPolicy sets the environment variables clients may set
+func (m *Manager) SetEnvPolicy(policy EnvPolicy) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.envPolicy = policy
+}
+
+// sanitizeEnv checks the environment a client asked for against the
+// environment policy, returning it with forbidden variables dropped if the
+// policy says so (assumes mutex is held)
+func (m *Manager) sanitizeEnv(owner string, env map[string]string) (map[string]string, error) {
+	if len(env) == 0 {
+		return env, nil
+	}
+
+	size := 0
+	var forbidden []string
+	for name, value := range env {
+		if name == "" || strings.ContainsAny(name, "=\x00") || strings.ContainsRune(value, 0) {
+			return nil, fmt.Errorf("%w: %q is not a valid variable", ErrInvalidEnv, name)
+		}
+		size += len(name) + len(value) + 2
+		if !m.envPolicy.allows(name) {
+			forbidden = append(forbidden, name)
+		}
+	}
+
+	if m.envPolicy.MaxSize > 0 && size > m.envPolicy.MaxSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrInvalidEnv, size, m.envPolicy.MaxSize)
+	}
+
+	if len(forbidden) == 0 {
+		return env, nil
+	}
+	sort.Strings(forbidden)
+
+	if !m.envPolicy.DropBlocked {
+		return nil, fmt.Errorf("%w: %s", ErrEnvNotAllowed, strings.Join(forbidden, ", "))
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"owner":     owner,
+		"variables": forbidden,
+	}).Warn("Dropped environment variables the policy forbids")
+
+	sanitized := make(map[string]string, len(env)-len(forbidden))
+	for name, value := range env {
+		if m.envPolicy.allows(name) {
+			sanitized[name] = value
+		}
+	}
+	return sanitized, nil
+}
+
+// allows reports whether clients may set a variable
+func (p EnvPolicy) allows(name string) bool {
+	if len(p.Allowed) > 0 && !matchesEnv(name, p.Allowed) {
+		return false
+	}
+	return !matchesEnv(name, p.Blocked)
+}
+
+// matchesEnv reports whether a variable name matches one of the patterns
+func matchesEnv(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// HomeSessionName is the display name of home sessions
+const HomeSessionName = "home"
+
+// ErrHomeSessionsDisabled is returned when no home profile is configured
+var ErrHomeSessionsDisabled = errors.New("home sessions are not enabled")
+
+// SetHomeProfile sets the profile users' home sessions are created from.
+// An empty name disables home sessions.
+func (m *Manager) SetHomeProfile(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.homeProfile = name
+}
+
+// HomeSession returns the home session of the request's owner, creating it
+// from the home profile when the owner has none that is still alive, and
+// reports whether it was created. Users return to the same shell on every
+// visit instead of starting a new one each time.
+func (m *Manager) HomeSession(ctx context.Context, req *types.SessionCreateRequest) (*types.Session, bool, error) {
+	// Two tabs opened at once must not create two home sessions
+	m.homeMutex.Lock()
+	defer m.homeMutex.Unlock()
+
+	m.mutex.RLock()
+	profile := m.homeProfile
+	existing := m.findHomeSession(req.Owner, req.Tenant)
+	m.mutex.RUnlock()
+
+	if profile == "" {
+		return nil, false, ErrHomeSessionsDisabled
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	home := *req
+	home.Profile = profile
+	home.Home = true
+	if home.Name == "" {
+		home.Name = HomeSessionName
+	}
+
+	session, err := m.CreateSession(ctx, &home)
+	if err != nil {
+		return nil, false, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"owner":      req.Owner,
+		"profile":    profile,
+	}).Info("Home session created")
+	return session, true, nil
+}
+
+// findHomeSession returns the home session of a user that has not ended
+// (assumes mutex is held)
+func (m *Manager) findHomeSession(owner, tenant string) *types.Session {
+	for _, session := range m.sessions {
+		if !session.Home || session.Owner != owner || session.Tenant != tenant {
+			continue
+		}
+		switch session.Status {
+		case types.SessionStatusPending, types.SessionStatusStarting,
+			types.SessionStatusRunning, types.SessionStatusPaused:
+			return session
+		}
+	}
+	return nil
+}
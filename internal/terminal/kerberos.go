@@ -0,0 +1,100 @@
+package terminal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// kerberosCacheEnv names the variable pointing Kerberos tools at the cache
+	kerberosCacheEnv = "KRB5CCNAME"
+	// kerberosCacheName is the credential cache in the session's secrets directory
+	kerberosCacheName = "krb5cc"
+	// maxKerberosTicket bounds the size of a forwarded credential cache
+	maxKerberosTicket = 64 * 1024
+)
+
+// ErrInvalidKerberosTicket is returned for forwarded tickets that are not
+// accepted
+var ErrInvalidKerberosTicket = errors.New("invalid kerberos_ticket")
+
+// decodeKerberosTicket checks that a forwarded credential cache is allowed by
+// the profile and looks like an MIT ccache file, and decodes it
+func decodeKerberosTicket(ticket string, profile *types.Profile) ([]byte, error) {
+	if ticket == "" {
+		return nil, nil
+	}
+	if profile == nil || profile.Kerberos == nil || !profile.Kerberos.AllowDelegation {
+		return nil, fmt.Errorf("%w: the profile does not accept forwarded tickets", ErrInvalidKerberosTicket)
+	}
+
+	cache, err := base64.StdEncoding.DecodeString(ticket)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not base64", ErrInvalidKerberosTicket)
+	}
+	if len(cache) > maxKerberosTicket {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidKerberosTicket, maxKerberosTicket)
+	}
+
+	// Credential cache files start with 0x05 and a format version of 1 to 4
+	if len(cache) < 2 || cache[0] != 5 || cache[1] < 1 || cache[1] > 4 {
+		return nil, fmt.Errorf("%w: not a credential cache file", ErrInvalidKerberosTicket)
+	}
+
+	return cache, nil
+}
+
+// issueKerberos gives a session its Kerberos credential cache: the ticket
+// forwarded by the client if there is one, otherwise a ticket obtained with
+// the profile's keytab
+func (c *sessionCredentials) issueKerberos(baseDir string, session *types.Session, options *types.KerberosOptions, forwarded []byte) error {
+	if forwarded != nil {
+		if err := c.writeFile(baseDir, session.ID, kerberosCacheName, string(forwarded)); err != nil {
+			return err
+		}
+		c.ccache = filepath.Join(c.dir, kerberosCacheName)
+
+		logrus.WithField("session_id", session.ID).Info("Forwarded Kerberos ticket stored")
+		return nil
+	}
+
+	if options == nil || options.Keytab == "" {
+		return nil
+	}
+
+	if err := c.ensureDir(baseDir, session.ID); err != nil {
+		return err
+	}
+
+	principal := strings.NewReplacer(
+		"{user}", session.Owner,
+		"{tenant}", session.Tenant,
+	).Replace(options.Principal)
+	ccache := filepath.Join(c.dir, kerberosCacheName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "kinit", "-k", "-t", options.Keytab, "-c", "FILE:"+ccache, principal).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to obtain Kerberos ticket for %s: %v: %s", principal, err, strings.TrimSpace(string(output)))
+	}
+	if err := c.chown(ccache); err != nil {
+		return err
+	}
+	c.ccache = ccache
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"principal":  principal,
+	}).Info("Kerberos ticket obtained")
+	return nil
+}
@@ -0,0 +1,114 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// Limits on session names and labels
+const (
+	maxNameLength       = 64
+	maxLabels           = 16
+	maxLabelValueLength = 63
+)
+
+var (
+	// ErrInvalidName is returned for session names that do not fit the limits
+	ErrInvalidName = errors.New("invalid name")
+	// ErrInvalidLabels is returned for labels that do not fit the limits
+	ErrInvalidLabels = errors.New("invalid labels")
+)
+
+// labelValuePattern matches label values, which cannot hold an = so that
+// selectors such as team=infra are unambiguous
+var labelValuePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]*$`)
+
+// validateName checks the display name of a session
+func validateName(name string) error {
+	if len(name) > maxNameLength {
+		return fmt.Errorf("%w: longer than %d bytes", ErrInvalidName, maxNameLength)
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return fmt.Errorf("%w: contains unprintable characters", ErrInvalidName)
+	}
+	return nil
+}
+
+// validateLabels checks the labels of a session. Keys follow the rules of
+// metadata keys.
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("%w: at most %d labels are allowed", ErrInvalidLabels, maxLabels)
+	}
+
+	for key, value := range labels {
+		if !metadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("%w: invalid key %q", ErrInvalidLabels, key)
+		}
+		if len(value) > maxLabelValueLength || !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("%w: invalid value of %q", ErrInvalidLabels, key)
+		}
+	}
+
+	return nil
+}
+
+// LabelSelector matches sessions with a label, and with a value unless
+// AnyValue is set
+type LabelSelector struct {
+	Key      string
+	Value    string
+	AnyValue bool
+}
+
+// ParseLabelSelector parses a selector such as team=infra, or team for any
+// session with the label
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	key, value, hasValue := strings.Cut(selector, "=")
+	if !metadataKeyPattern.MatchString(key) {
+		return LabelSelector{}, fmt.Errorf("%w: invalid selector %q", ErrInvalidLabels, selector)
+	}
+	return LabelSelector{Key: key, Value: value, AnyValue: !hasValue}, nil
+}
+
+// SessionFilter selects sessions when listing them. Sessions must match every
+// metadata entry and label selector, and any of the statuses if there are any.
+type SessionFilter struct {
+	Metadata map[string]string
+	Labels   []LabelSelector
+	Statuses []types.SessionStatus
+	Retained *bool // Only ended sessions kept until their retention is up, or only the others
+}
+
+// Matches reports whether a session is selected by the filter
+func (f *SessionFilter) Matches(session *types.Session) bool {
+	if !MatchesMetadata(session.Metadata, f.Metadata) {
+		return false
+	}
+
+	for _, selector := range f.Labels {
+		value, exists := session.Labels[selector.Key]
+		if !exists || (!selector.AnyValue && value != selector.Value) {
+			return false
+		}
+	}
+
+	if f.Retained != nil && *f.Retained != (session.RetainedUntil != nil) {
+		return false
+	}
+
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, status := range f.Statuses {
+		if session.Status == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package terminal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// localePattern matches locale names such as C, POSIX, C.UTF-8, en_US.UTF-8,
+// de_DE.ISO-8859-1 and sr_RS@latin
+var localePattern = regexp.MustCompile(`^(C|POSIX|[a-z]{2,3}(_[A-Z]{2})?)(\.[A-Za-z0-9-]+)?(@[a-z]+)?$`)
+
+// localeCategories lists the LC_* variables sessions may set
+var localeCategories = map[string]bool{
+	"LC_ALL":            true,
+	"LC_CTYPE":          true,
+	"LC_NUMERIC":        true,
+	"LC_TIME":           true,
+	"LC_COLLATE":        true,
+	"LC_MONETARY":       true,
+	"LC_MESSAGES":       true,
+	"LC_PAPER":          true,
+	"LC_NAME":           true,
+	"LC_ADDRESS":        true,
+	"LC_TELEPHONE":      true,
+	"LC_MEASUREMENT":    true,
+	"LC_IDENTIFICATION": true,
+}
+
+// validateLocale checks locale and keyboard settings before a session is created
+func validateLocale(locale *types.LocaleSettings, keyboard *types.KeyboardSettings) error {
+	if locale != nil {
+		if locale.Lang != "" && !localePattern.MatchString(locale.Lang) {
+			return fmt.Errorf("invalid locale: %s", locale.Lang)
+		}
+		for category, value := range locale.Categories {
+			if !localeCategories[category] {
+				return fmt.Errorf("unsupported locale category: %s", category)
+			}
+			if !localePattern.MatchString(value) {
+				return fmt.Errorf("invalid locale for %s: %s", category, value)
+			}
+		}
+	}
+
+	if keyboard != nil {
+		switch keyboard.Backspace {
+		case "", "del", "bs":
+		default:
+			return fmt.Errorf("invalid backspace setting: %s", keyboard.Backspace)
+		}
+		switch keyboard.CursorKeys {
+		case "", "normal", "application":
+		default:
+			return fmt.Errorf("invalid cursor keys setting: %s", keyboard.CursorKeys)
+		}
+	}
+
+	return nil
+}
+
+// localeEnv returns env with LANG and LC_* set from the locale settings,
+// leaving values set explicitly in the environment untouched
+func localeEnv(env map[string]string, locale *types.LocaleSettings) map[string]string {
+	if locale == nil {
+		return env
+	}
+
+	merged := make(map[string]string, len(env)+len(locale.Categories)+1)
+	for key, value := range env {
+		merged[key] = value
+	}
+
+	if _, exists := merged["LANG"]; !exists && locale.Lang != "" {
+		merged["LANG"] = locale.Lang
+	}
+	for category, value := range locale.Categories {
+		if _, exists := merged[category]; !exists {
+			merged[category] = value
+		}
+	}
+
+	return merged
+}
+
+// cursorKeys maps normal-mode cursor sequences to application mode
+var cursorKeys = strings.NewReplacer(
+	"\x1b[A", "\x1bOA",
+	"\x1b[B", "\x1bOB",
+	"\x1b[C", "\x1bOC",
+	"\x1b[D", "\x1bOD",
+	"\x1b[H", "\x1bOH",
+	"\x1b[F", "\x1bOF",
+)
+
+// TransformInput applies a session's keyboard settings to client input
+func TransformInput(keyboard *types.KeyboardSettings, data string) string {
+	if keyboard == nil {
+		return data
+	}
+
+	if keyboard.Backspace == "bs" {
+		data = strings.ReplaceAll(data, "\x7f", "\x08")
+	}
+	if keyboard.CursorKeys == "application" {
+		data = cursorKeys.Replace(data)
+	}
+
+	return data
+}
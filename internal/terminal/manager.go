@@ -1,25 +1,114 @@
 package terminal
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"os"
+	"os/exec"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/piyushgupta53/webterm/internal/secrets"
+	"github.com/piyushgupta53/webterm/internal/terminal/recording"
+	"github.com/piyushgupta53/webterm/internal/tracing"
+	"github.com/piyushgupta53/webterm/internal/transcript"
 	"github.com/piyushgupta53/webterm/internal/types"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// UsageRecorder receives session usage for accounting and enforces quotas
+type UsageRecorder interface {
+	CheckQuota(user, tenant string) error
+	SessionStarted(session *types.Session)
+	SessionEnded(sessionID string, bytesIn, bytesOut int64, cpuSeconds float64)
+}
+
+// AdmissionChecker decides whether new sessions may be created
+type AdmissionChecker interface {
+	CheckNewSession() error
+}
+
+// SessionLimiter caps how many sessions exist at once, overall and per
+// user and client IP
+type SessionLimiter interface {
+	CheckSessionLimit(user, ip string) error
+	AddSession(sessionID, user, ip string)
+	RemoveSession(sessionID string)
+}
+
+// AdmissionCheckers refuses new sessions if any of its checkers does
+type AdmissionCheckers []AdmissionChecker
+
+// CheckNewSession returns the first refusal among the checkers
+func (c AdmissionCheckers) CheckNewSession() error {
+	for _, checker := range c {
+		if err := checker.CheckNewSession(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Manager handles the lifecycle of all terminal sessions
 type Manager struct {
-	sessions       map[string]*types.Session
-	sessionRunners map[string]*SessionRunner
-	pipeManager    *PipeManager
-	cleanupManager *CleanupManager
-	statusCallback func(sessionID string, status string) // Callback for status updates
-	mutex          sync.RWMutex
-	stopChan       chan struct{}
-	shutdownOnce   sync.Once
+	sessions           map[string]*types.Session
+	sessionRunners     map[string]*SessionRunner
+	broadcasts         map[string]string                      // Broadcast token to session ID
+	panes              map[string]*paneState                  // Open panes by pane key
+	paneCallback       func(sessionID, paneID, status string) // Told when panes open and close
+	sessionPipes       map[string]*sessionPipe                // Output forwarding between sessions by pipe ID
+	wallClocks         map[string]chan struct{}               // Cancels wall-clock limits by session ID
+	starting           map[string]chan struct{}               // Closed once sessions being started are running, by session ID
+	scopes             map[string]*sessionScope               // Lifetimes of running sessions, guarded by scopesMutex
+	scopesMutex        sync.Mutex
+	warningCallback    func(sessionID, message, level string) // Warns a session's clients
+	pipeManager        *PipeManager
+	cleanupManager     *CleanupManager
+	statusCallback     func(sessionID, status string, exitCode *int) // Callback for status updates
+	outputCallback     func(chunk *OutputChunk)                      // Told of output as it is written
+	startupCallback    func(timing *StartupTiming)                   // Told how long sessions took to start
+	sealer             *transcript.Sealer                            // Encrypts output files at rest, if set
+	recorders          map[string]*recording.Recorder                // Recordings of sessions by session ID
+	displayServer      string                                        // Xvnc binary serving session displays; empty disables them
+	displays           map[string]*displayServer                     // X servers of sessions by session ID
+	terminalState      bool                                          // Whether terminal state is kept for PTY sessions
+	screens            map[string]*Screen                            // Terminal state of sessions by session ID
+	serialDevices      []string                                      // Device patterns allowed for serial sessions
+	profiles           map[string]*types.Profile                     // Named session profiles
+	defaultProfile     string                                        // Profile applied when a request names none
+	homeProfile        string                                        // Profile users' home sessions are created from; empty disables them
+	homeMutex          sync.Mutex                                    // Serializes finding and creating home sessions
+	containerPool      *ContainerPool                                // Warm containers for container profiles
+	devicePolicy       DevicePolicy                                  // Device passthrough allowlist for containers
+	usageRecorder      UsageRecorder                                 // Usage accounting and quotas
+	admission          AdmissionChecker                              // Refuses new sessions, e.g. ahead of maintenance
+	sessionLimiter     SessionLimiter                                // Caps sessions overall and per user and client IP
+	launchPolicy       LaunchPolicy                                  // Shells and commands clients may ask for
+	runAsPolicy        RunAsPolicy                                   // Account sessions run as
+	envPolicy          EnvPolicy                                     // Environment variables clients may set
+	detachCallback     func(sessionID, reason string)                // Disconnects clients of sessions being terminated
+	terminating        map[string]chan struct{}                      // Closed once sessions being terminated are torn down, by session ID
+	capabilities       *Capabilities                                 // Platform features detected at startup
+	approvalRequired   bool                                          // Hold privileged sessions until approved
+	pendingRequests    map[string]*pendingRequest                    // Requests awaiting approval by session ID
+	secretsProvider    secrets.Provider                              // Issues credentials requested by profiles
+	credentials        map[string]*sessionCredentials                // Issued credentials by session ID
+	credentialsMutex   sync.Mutex
+	maxOutputRate      int64             // Bytes of output per second each session may write to disk
+	outputBufferSize   int               // Bytes of output sessions read at once by default
+	outputFlushLatency time.Duration     // How long sessions wait for more output by default
+	callbacks          map[string]string // Completion callback URL by session ID
+	callbacksMutex     sync.Mutex
+	callbacksPending   sync.WaitGroup
+	retention          RetentionPolicy // How long ended sessions stay listed
+	endedMutex         sync.Mutex      // Guards when sessions ended and are retained until
+	mutex              sync.RWMutex
+	stopChan           chan struct{}
+	shutdownOnce       sync.Once
 }
 
 // NewManager creates a new session manager
@@ -28,15 +117,33 @@ func NewManager(pipesDir string) *Manager {
 	cleanupManager := NewCleanupManager(pipeManager)
 
 	manager := &Manager{
-		sessions:       make(map[string]*types.Session),
-		sessionRunners: make(map[string]*SessionRunner),
-		pipeManager:    pipeManager,
-		cleanupManager: cleanupManager,
-		stopChan:       make(chan struct{}),
+		sessions:        make(map[string]*types.Session),
+		sessionRunners:  make(map[string]*SessionRunner),
+		broadcasts:      make(map[string]string),
+		panes:           make(map[string]*paneState),
+		sessionPipes:    make(map[string]*sessionPipe),
+		wallClocks:      make(map[string]chan struct{}),
+		starting:        make(map[string]chan struct{}),
+		scopes:          make(map[string]*sessionScope),
+		recorders:       make(map[string]*recording.Recorder),
+		displays:        make(map[string]*displayServer),
+		screens:         make(map[string]*Screen),
+		pipeManager:     pipeManager,
+		cleanupManager:  cleanupManager,
+		serialDevices:   DefaultSerialDevices,
+		profiles:        make(map[string]*types.Profile),
+		pendingRequests: make(map[string]*pendingRequest),
+		credentials:     make(map[string]*sessionCredentials),
+		callbacks:       make(map[string]string),
+		capabilities:    DetectCapabilities(pipesDir),
+		retention:       DefaultRetentionPolicy(),
+		envPolicy:       DefaultEnvPolicy(),
+		terminating:     make(map[string]chan struct{}),
+		stopChan:        make(chan struct{}),
 	}
 
-	// Start background cleanup routine
-	go manager.backgroundCleanup()
+	// Terminate idle sessions and remove ended ones after their retention
+	go manager.reapSessions()
 
 	// Clean up any orphaned resources from previous runs
 	if err := cleanupManager.CleanupOrphanedResources(); err != nil {
@@ -46,102 +153,586 @@ func NewManager(pipesDir string) *Manager {
 	return manager
 }
 
-// CreateSession creates a new terminal session
-func (m *Manager) CreateSession(req *types.SessionCreateRequest) (*types.Session, error) {
+// CreateSession creates a new terminal session, traced as a child of any
+// span in ctx
+func (m *Manager) CreateSession(ctx context.Context, req *types.SessionCreateRequest) (session *types.Session, err error) {
+	ctx, span := tracing.Start(ctx, "session.create")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to create session")
+		} else {
+			span.SetAttributes(tracing.AttrSessionID.String(session.ID), tracing.AttrBackend.String(string(session.Backend)))
+		}
+		span.End()
+	}()
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	// Check the environment the client asked for, before profiles add theirs
+	env, err := m.sanitizeEnv(req.Owner, req.Env)
+	if err != nil {
+		return nil, err
+	}
+	if len(env) != len(req.Env) {
+		sanitized := *req
+		sanitized.Env = env
+		req = &sanitized
+	}
+
+	// Fall back to the default profile so that every session can be forced
+	// into a specific backend (for example an ephemeral container)
+	if req.Profile == "" && m.defaultProfile != "" {
+		withDefault := *req
+		withDefault.Profile = m.defaultProfile
+		req = &withDefault
+	}
+
+	// Apply the requested profile, if any
+	req, profile, err := resolveProfile(req, m.profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse new sessions while the server is about to go down or low on disk
+	if m.admission != nil {
+		if err := m.admission.CheckNewSession(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Refuse new sessions once the owner's quota is used up
+	if m.usageRecorder != nil {
+		if err := m.usageRecorder.CheckQuota(req.Owner, req.Tenant); err != nil {
+			return nil, err
+		}
+	}
+
+	// Refuse new sessions past the server's, the owner's or the client's limit
+	if m.sessionLimiter != nil {
+		if err := m.sessionLimiter.CheckSessionLimit(req.Owner, req.ClientIP); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate unique session ID
 	sessionID := uuid.New().String()
 
+	backend := req.Backend
+	if backend == "" {
+		backend = types.SessionBackendPTY
+	}
+
+	// Pick TERM from the terminal capabilities the client declared. The
+	// terminfo database of containers cannot be inspected from the host.
+	var term string
+	var trueColor bool
+	if backend != types.SessionBackendSerial {
+		term, trueColor, err = resolveTerminal(req.Terminal, backend != types.SessionBackendContainer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateLocale(req.Locale, req.Keyboard); err != nil {
+		return nil, err
+	}
+
+	if err := validatePriority(req.Priority, backend); err != nil {
+		return nil, err
+	}
+
+	if err := validateAllocatePTY(req, backend); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkLaunch(req.Shell, req.Command, profile); err != nil {
+		return nil, err
+	}
+
+	workingDir, err := m.checkWorkingDir(req.WorkingDir, profile, backend != types.SessionBackendContainer)
+	if err != nil {
+		return nil, err
+	}
+	if workingDir != req.WorkingDir {
+		checked := *req
+		checked.WorkingDir = workingDir
+		req = &checked
+	}
+
+	if err := m.checkCapabilities(req, profile, backend); err != nil {
+		return nil, err
+	}
+
+	if err := validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+
+	if err := validateName(req.Name); err != nil {
+		return nil, err
+	}
+
+	if err := validateLabels(req.Labels); err != nil {
+		return nil, err
+	}
+
+	if err := validateCallbackURL(req.CallbackURL); err != nil {
+		return nil, err
+	}
+
+	if _, err := parseWallTime(req.MaxWallTime); err != nil {
+		return nil, err
+	}
+
+	buffering, err := m.resolveOutputBuffering(req.OutputBuffering)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := decodeKerberosTicket(req.KerberosTicket, profile); err != nil {
+		return nil, err
+	}
+
+	if err := m.validateDisplay(req, backend); err != nil {
+		return nil, err
+	}
+
+	runAs, err := m.resolveRunAs(req.Owner, backend)
+	if err != nil {
+		return nil, err
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"session_id":  sessionID,
+		"backend":     backend,
+		"owner":       req.Owner,
 		"shell":       req.Shell,
 		"command":     req.Command,
 		"working_dir": req.WorkingDir,
+		"metadata":    req.Metadata,
 	}).Info("Creating new session")
 
 	// Create new session object
-	session := &types.Session{
+	session = &types.Session{
 		ID:           sessionID,
 		Status:       types.SessionStatusStarting,
 		CreatedAt:    time.Now(),
 		LastActiveAt: time.Now(),
+		Owner:        req.Owner,
+		Tenant:       req.Tenant,
+		Metadata:     req.Metadata,
+		Name:         req.Name,
+		Labels:       req.Labels,
+		Home:         req.Home,
+		Profile:      req.Profile,
+		Backend:      backend,
+		Term:         term,
+		TrueColor:    trueColor,
+		Keyboard:     req.Keyboard,
 		Shell:        req.Shell,
 		Command:      req.Command,
 		WorkingDir:   req.WorkingDir,
+		AllocatePTY:  req.AllocatePTY,
+		RunAs:        runAs,
+
+		OutputBuffering: buffering,
+	}
+
+	// Privileged profiles wait for an admin before anything is spawned
+	if profile != nil && profile.Privileged && m.approvalRequired {
+		session.Status = types.SessionStatusPending
+		m.sessions[sessionID] = session
+		m.pendingRequests[sessionID] = &pendingRequest{req: req, profile: profile}
+		m.countSession(session, req)
+
+		logrus.WithFields(logrus.Fields{
+			"session_id": sessionID,
+			"profile":    req.Profile,
+			"owner":      req.Owner,
+		}).Info("Session awaiting approval")
+		return session, nil
 	}
 
+	// Count the session from now on, since it is listed while it starts
+	m.countSession(session, req)
+	if err := m.launchSession(ctx, session, req, profile, newStartupClock(req.ReceivedAt)); err != nil {
+		m.uncountSession(session)
+		return nil, err
+	}
+
+	logrus.WithField("session_id", sessionID).Info("Session created successfully")
+	return session, nil
+}
+
+// launchSession creates the pipes and backend for a session and starts
+// bridging its I/O, timing its stages on the clock (assumes mutex is held).
+// The session is listed as starting while its credentials are issued and its
+// backend starts, which releases the mutex meanwhile, since secrets providers
+// and containers can take a while to respond.
+func (m *Manager) launchSession(ctx context.Context, session *types.Session, req *types.SessionCreateRequest, profile *types.Profile, clock *startupClock) error {
+	// Sessions awaiting approval are listed already, and stay listed if they
+	// fail to start
+	_, listed := m.sessions[session.ID]
+
 	// Create named pipes
-	inputPipe, outputFile, err := m.pipeManager.CreateSessionPipes(sessionID)
+	clock.pipesStart = time.Now()
+	inputPipe, outputFile, err := m.pipeManager.CreateSessionPipes(session.ID)
+	clock.pipesDone = time.Now()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session pipes: %w", err)
+		return fmt.Errorf("failed to create session pipes: %w", err)
 	}
 
 	session.InputPipe = inputPipe
 	session.OutputFile = outputFile
 
-	// Create PTY config
-	ptyConfig := &PTYConfig{
-		Shell:      req.Shell,
-		Command:    req.Command,
-		WorkingDir: req.WorkingDir,
-		Env:        req.Env,
+	// Record from the first output, if asked to
+	if err := m.startRecording(session, req); err != nil {
+		m.pipeManager.CleanupSessionPipes(session.ID, inputPipe, outputFile)
+		return fmt.Errorf("failed to start recording: %w", err)
+	}
+
+	// Start the X server GUI applications are shown on, if asked to
+	if err := m.startDisplay(session, req); err != nil {
+		m.pipeManager.CleanupSessionPipes(session.ID, inputPipe, outputFile)
+		m.stopRecording(session.ID)
+		removeRecording(session)
+		return err
+	}
+
+	// List the session while its credentials are issued and its backend
+	// starts, so that it can be found and terminated meanwhile; its input
+	// waits until the runner is running
+	m.sessions[session.ID] = session
+	m.trackStarting(session.ID)
+	display := m.displays[session.ID]
+	m.mutex.Unlock()
+
+	// Fetch the short-lived credentials the profile asks for
+	var ptty *os.File
+	var process *exec.Cmd
+	creds, err := m.issueCredentials(session, req, profile)
+	if err == nil {
+		// Start the backend without holding up other sessions
+		_, backendSpan := tracing.Start(ctx, "session.start_backend", tracing.AttrSessionID.String(session.ID),
+			tracing.AttrBackend.String(string(session.Backend)))
+		clock.backendStart = time.Now()
+		ptty, process, err = m.startBackend(session, req, profile, creds, display)
+		clock.backendDone = time.Now()
+		if err != nil {
+			backendSpan.RecordError(err)
+			backendSpan.SetStatus(codes.Error, "failed to start backend")
+		}
+		backendSpan.End()
+
+		if err != nil && creds != nil {
+			m.releaseCredentials(session.ID, creds)
+		}
+	}
+
+	m.mutex.Lock()
+
+	// A session terminated or shut down meanwhile has had its pipes,
+	// recording and display cleaned up, but not what the backend started
+	if m.sessions[session.ID] != session || session.Status != types.SessionStatusStarting {
+		if err != nil {
+			return err
+		}
+
+		session.PTY = ptty
+		session.Process = process
+		if cleanupErr := m.cleanupManager.CleanupSession(session); cleanupErr != nil {
+			logrus.WithError(cleanupErr).WithField("session_id", session.ID).Error("Failed to clean up session")
+		}
+		session.PTY = nil
+		session.Process = nil
+
+		// Revoke credentials without holding up other sessions
+		if creds != nil {
+			go m.releaseCredentials(session.ID, creds)
+		}
+		return fmt.Errorf("%w: terminated while starting", ErrSessionEnded)
 	}
 
-	// Create PTY and start shell process
-	ptty, process, err := CreatePTY(ptyConfig)
 	if err != nil {
-		// Clean up pipes if PTY creation fails
-		m.pipeManager.CleanupSessionPipes(sessionID, inputPipe, outputFile)
-		return nil, fmt.Errorf("failed to create PTY: %w", err)
+		// Clean up pipes, recording and display if the credentials or the
+		// backend fail; credentials were released above
+		m.finishStarting(session.ID)
+		if !listed {
+			delete(m.sessions, session.ID)
+		}
+		m.pipeManager.CleanupSessionPipes(session.ID, inputPipe, outputFile)
+		m.stopRecording(session.ID)
+		removeRecording(session)
+		m.stopDisplay(session.ID)
+		return err
 	}
 
 	session.PTY = ptty
 	session.Process = process
+	m.trackCredentials(session.ID, creds)
+
+	// Lower the priority before the shell starts any jobs, so they inherit it
+	if req.Priority != nil {
+		if err := m.applyPriority(session, req.Priority); err != nil {
+			logrus.WithError(err).WithField("session_id", session.ID).Warn("Failed to set session priority")
+		}
+	}
 
-	// Store session
-	m.sessions[sessionID] = session
+	m.trackCallback(session.ID, req.CallbackURL)
+
+	// Validated when the session was requested
+	limit, _ := parseWallTime(req.MaxWallTime)
+	m.startWallClock(session, limit)
+	m.startScreen(session)
+
+	// Create session runner, whose goroutines end with the session
+	runner := NewSessionRunner(session, m.pipeManager, m.openScope(session.ID))
+	runner.SetOutputRateLimit(m.maxOutputRate)
+	runner.SetOutputBuffering(outputBuffering(session))
+	runner.SetOutputHandler(m.watchFirstOutput(session, clock, m.outputHandler(session.ID, "")))
+	runner.SetSealer(m.sealer)
+	runner.SetRecorder(m.recorders[session.ID])
+	runner.SetScreen(m.screens[session.ID])
+
+	// Track status changes for accounting and broadcasting
+	runner.SetStatusCallback(func(sessionID string, status string) {
+		m.handleRunnerStatus(session, runner, status)
+	})
 
-	// Create session runner
-	runner := NewSessionRunner(session, m.pipeManager)
+	m.sessionRunners[session.ID] = runner
 
-	// Set status callback if available
-	if m.statusCallback != nil {
-		runner.SetStatusCallback(m.statusCallback)
+	if m.usageRecorder != nil {
+		m.usageRecorder.SessionStarted(session)
 	}
 
-	m.sessionRunners[sessionID] = runner
-
-	// Give the shell process time to initialize before starting the session runner
+	// Start the runner. Its span outlives the creation span, until the
+	// prompt is asked for.
+	_, runnerSpan := tracing.Start(ctx, "session.runner_start", tracing.AttrSessionID.String(session.ID))
 	go func() {
-		// Wait a bit longer for the shell to fully initialize
-		time.Sleep(500 * time.Millisecond)
-
-		logrus.WithField("session_id", sessionID).Debug("Starting session runner after shell initialization")
+		defer runnerSpan.End()
 
 		if err := runner.Start(); err != nil {
-			logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to start session runner")
-			// Clean up on start failure
-			m.cleanupSession(sessionID)
+			runnerSpan.RecordError(err)
+			runnerSpan.SetStatus(codes.Error, "failed to start session runner")
+			logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to start session runner")
+			// Clean up on start failure, unless the session was ended meanwhile
+			m.mutex.Lock()
+			if _, exists := m.sessionRunners[session.ID]; exists {
+				m.cleanupSession(session.ID)
+			}
+			m.mutex.Unlock()
 			return
 		}
 
-		// Send initial newline to trigger shell prompt
-		time.Sleep(200 * time.Millisecond)
+		// Write a newline to trigger the shell prompt, ahead of anything
+		// typed while the session was starting. Commands without a PTY read
+		// the input as is, and show no prompt.
+		if session.HasPTY() {
+			if _, err := ptty.Write([]byte("\n")); err != nil {
+				logrus.WithError(err).WithField("session_id", session.ID).Debug("Failed to send initial newline")
+			}
+		}
+
+		// Let input and resizes queued while starting through
+		m.mutex.Lock()
+		m.finishStarting(session.ID)
+		m.mutex.Unlock()
+	}()
+
+	return nil
+}
+
+// startBackend opens the terminal device for a session according to its
+// backend, pointing X clients at display if it has one. It runs without the
+// mutex held.
+func (m *Manager) startBackend(session *types.Session, req *types.SessionCreateRequest, profile *types.Profile, creds *sessionCredentials, display *displayServer) (*os.File, *exec.Cmd, error) {
+	// Tell the shell which terminal it is talking to and which locale to use
+	if session.Backend != types.SessionBackendSerial {
+		withTerm := *req
+		withTerm.Env = localeEnv(terminalEnv(req.Env, session), req.Locale)
+		req = &withTerm
+		session.Term = req.Env["TERM"]
+		session.Locale = req.Env["LANG"]
+	}
+
+	switch session.Backend {
+	case types.SessionBackendPTY:
+		// Create PTY config
+		ptyConfig := &PTYConfig{
+			Shell:      req.Shell,
+			Command:    req.Command,
+			WorkingDir: req.WorkingDir,
+			Env:        display.env(creds.processEnv(req.Env)),
+		}
+
+		acct, err := m.sessionAccount(session)
+		if err != nil {
+			return nil, nil, err
+		}
+		acct.apply(ptyConfig)
+
+		if !session.HasPTY() {
+			return m.startCommand(session, ptyConfig)
+		}
+
+		// Create PTY and start shell process
+		ptty, process, err := CreatePTY(ptyConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create PTY: %w", err)
+		}
+		return ptty, process, nil
+
+	case types.SessionBackendSerial:
+		serialConfig := &SerialConfig{
+			Device:   req.SerialDevice,
+			BaudRate: req.BaudRate,
+		}
+
+		// Serial sessions have no local process, only the device
+		port, err := OpenSerial(serialConfig, m.serialDevices)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open serial device: %w", err)
+		}
+
+		session.SerialDevice = serialConfig.Device
+		session.BaudRate = serialConfig.BaudRate
+		return port, nil, nil
+
+	case types.SessionBackendSandbox:
+		if profile == nil || profile.Sandbox == nil {
+			return nil, nil, fmt.Errorf("sandbox backend requires a profile with sandbox options")
+		}
+
+		// Sandboxes can only deny all egress, by having no network
+		sandbox := *profile.Sandbox
+		if profile.Egress != nil {
+			sandbox.Network = false
+		}
+
+		command, err := buildSandboxCommand(&sandbox, req.Command)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build sandbox command: %w", err)
+		}
+
+		// Apply the profile's limits from the runtime's first instruction
+		command, err = withSandboxLimits(command, sandbox.Limits)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to apply sandbox limits: %w", err)
+		}
+
+		ptyConfig := &PTYConfig{
+			Command:    command,
+			WorkingDir: req.WorkingDir,
+			Env:        creds.processEnv(req.Env),
+		}
+
+		ptty, process, err := CreatePTY(ptyConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create sandbox PTY: %w", err)
+		}
 
-		logrus.WithField("session_id", sessionID).Debug("Sending initial newline to trigger shell prompt")
+		return ptty, process, nil
 
-		// Write a newline to trigger the shell prompt
-		if _, err := ptty.Write([]byte("\n")); err != nil {
-			logrus.WithError(err).WithField("session_id", sessionID).Debug("Failed to send initial newline")
+	case types.SessionBackendContainer:
+		if profile == nil || profile.Container == nil {
+			return nil, nil, fmt.Errorf("container backend requires a profile with container options")
+		}
+
+		var runtimePath, container string
+		var command []string
+		detached := true // Whether the container runs apart from the CLI attached to it
+
+		// Prefer a warm container, falling back to starting a new one.
+		// Secret files need a fresh container to be mounted into.
+		if name, ok := m.acquireWarmContainer(req.Profile, creds); ok {
+			var err error
+			runtimePath, err = resolveContainerRuntime(profile.Container.Runtime)
+			if err != nil {
+				return nil, nil, err
+			}
+			container = name
+			command = buildContainerExecCommand(runtimePath, container, req, creds)
 		} else {
-			logrus.WithField("session_id", sessionID).Debug("Initial newline sent successfully")
+			// Allowed domains are resolved, and the firewall installed, while
+			// the mutex is released for the backend to start
+			egress, err := resolveEgress(profile.Egress)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			container = containerName(session.ID)
+			if egress != nil && !egress.denyAll() {
+				// The firewall has to be in place before the shell starts
+				runtimePath, err = startFilteredContainer(profile.Container, m.devicePolicy, egress, session.ID, creds)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to start container: %w", err)
+				}
+				command = buildContainerExecCommand(runtimePath, container, req, creds)
+			} else {
+				runtimePath, command, err = buildContainerCommand(profile.Container, m.devicePolicy, egress, session.ID, req, creds)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to build container command: %w", err)
+				}
+				detached = false
+			}
 		}
-	}()
 
-	logrus.WithField("session_id", sessionID).Info("Session created successfully")
-	return session, nil
+		// The container CLI runs on the host; the working directory and
+		// environment are applied inside the container instead. Secrets are
+		// handed to the CLI through its environment.
+		ptty, process, err := CreatePTY(&PTYConfig{Command: command, Env: creds.processEnv(nil)})
+		if err != nil {
+			if detached {
+				removeContainer(runtimePath, container)
+			}
+			return nil, nil, fmt.Errorf("failed to create container PTY: %w", err)
+		}
+
+		session.Container = container
+		session.ContainerRuntime = runtimePath
+		return ptty, process, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported session backend: %s", session.Backend)
+	}
+}
+
+// acquireWarmContainer claims a pre-started container for a profile if pooling is enabled
+func (m *Manager) acquireWarmContainer(profileName string, creds *sessionCredentials) (string, bool) {
+	if m.containerPool == nil || (creds != nil && creds.dir != "") {
+		return "", false
+	}
+	return m.containerPool.Acquire(profileName)
+}
+
+// handleRunnerStatus reacts to status changes reported by a session runner
+func (m *Manager) handleRunnerStatus(session *types.Session, runner *SessionRunner, status string) {
+	if status == string(types.SessionStatusStopped) || status == string(types.SessionStatusError) {
+		m.markEnded(session, m.retention.Exited)
+		m.recordUsage(session, runner)
+		m.notifyCallback(session, runner, types.SessionStatus(status))
+		go m.revokeCredentials(session.ID)
+	}
+
+	if m.statusCallback != nil {
+		m.statusCallback(session.ID, status, session.ExitCode)
+	}
+}
+
+// recordUsage reports a finished session's usage to the usage recorder
+func (m *Manager) recordUsage(session *types.Session, runner *SessionRunner) {
+	if m.usageRecorder == nil {
+		return
+	}
+
+	var cpuSeconds float64
+	if session.Process != nil && session.Process.ProcessState != nil {
+		state := session.Process.ProcessState
+		cpuSeconds = (state.UserTime() + state.SystemTime()).Seconds()
+	}
+
+	m.usageRecorder.SessionEnded(session.ID, runner.GetBytesWritten(), runner.GetBytesRead(), cpuSeconds)
 }
 
 // GetSession retrieves a session by ID
@@ -170,8 +761,18 @@ func (m *Manager) ListSessions() []*types.Session {
 	return sessions
 }
 
-// TerminateSession terminates a session and cleans up its resources
-func (m *Manager) TerminateSession(sessionID string) error {
+// TerminateSession terminates a session and cleans up its resources, traced
+// as a child of any span in ctx
+func (m *Manager) TerminateSession(ctx context.Context, sessionID string) (err error) {
+	_, span := tracing.Start(ctx, "session.terminate", tracing.AttrSessionID.String(sessionID))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to terminate session")
+		}
+		span.End()
+	}()
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -180,37 +781,245 @@ func (m *Manager) TerminateSession(sessionID string) error {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	if !session.CanTerminate() {
-		return fmt.Errorf("session cannot be terminated in current state: %s", session.Status)
+	// A session terminated concurrently has ended by the time this gets the mutex
+	if _, terminating := m.terminating[sessionID]; !terminating && !session.CanTerminate() {
+		return fmt.Errorf("%w: session is %s", ErrSessionEnded, session.Status)
 	}
 
 	logrus.WithField("session_id", sessionID).Info("Terminating session")
 
-	session.Status = types.SessionStatusStopping
+	return m.terminate(session, DetachReasonTerminated)
+}
+
+// EnableBroadcast publishes a session at an unguessable read-only token.
+// Enabling an already broadcasting session returns its existing token.
+func (m *Manager) EnableBroadcast(sessionID string) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return "", fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if !session.IsActive() {
+		return "", fmt.Errorf("session cannot be broadcast in current state: %s", session.Status)
+	}
+
+	if session.Broadcasting {
+		return session.BroadcastToken, nil
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("failed to generate broadcast token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	session.Broadcasting = true
+	session.BroadcastToken = token
+	m.broadcasts[token] = sessionID
 
-	return m.cleanupSession(sessionID)
+	logrus.WithField("session_id", sessionID).Info("Session broadcast enabled")
+	return token, nil
 }
 
-// SetStatusCallback sets the callback function for status updates
-func (m *Manager) SetStatusCallback(callback func(sessionID string, status string)) {
+// DisableBroadcast revokes a session's broadcast token
+func (m *Manager) DisableBroadcast(sessionID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	m.revokeBroadcast(session)
+
+	logrus.WithField("session_id", sessionID).Info("Session broadcast disabled")
+	return nil
+}
+
+// ResolveBroadcast returns the session published at a broadcast token
+func (m *Manager) ResolveBroadcast(token string) (string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	sessionID, exists := m.broadcasts[token]
+	if !exists {
+		return "", fmt.Errorf("broadcast not found")
+	}
+
+	return sessionID, nil
+}
+
+// revokeBroadcast removes a session's broadcast token (assumes mutex is held)
+func (m *Manager) revokeBroadcast(session *types.Session) {
+	if session.BroadcastToken != "" {
+		delete(m.broadcasts, session.BroadcastToken)
+	}
+	session.Broadcasting = false
+	session.BroadcastToken = ""
+}
+
+// SetStatusCallback sets the callback function for status updates, which
+// carry the exit code of sessions whose shell has exited. It may be called
+// with the manager's lock held, so it must not call back into the manager.
+func (m *Manager) SetStatusCallback(callback func(sessionID, status string, exitCode *int)) {
 	m.statusCallback = callback
 }
 
+// SetSerialDevices sets the device patterns that serial sessions may open
+func (m *Manager) SetSerialDevices(patterns []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.serialDevices = patterns
+}
+
+// SetProfiles sets the named profiles available to session creation
+func (m *Manager) SetProfiles(profiles map[string]*types.Profile) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.profiles = profiles
+}
+
+// SetDefaultProfile sets the profile applied to requests that do not name one
+func (m *Manager) SetDefaultProfile(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.defaultProfile = name
+}
+
+// SetDevicePolicy sets the device passthrough allowlist for container sessions
+func (m *Manager) SetDevicePolicy(policy DevicePolicy) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.devicePolicy = policy
+}
+
+// SetUsageRecorder sets the recorder used for usage accounting and quotas
+func (m *Manager) SetUsageRecorder(recorder UsageRecorder) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.usageRecorder = recorder
+}
+
+// SetSessionLimiter sets the limits on how many sessions exist at once.
+// Sessions count against them from their creation until they end.
+func (m *Manager) SetSessionLimiter(limiter SessionLimiter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sessionLimiter = limiter
+}
+
+// countSession counts a new session against the session limits (assumes
+// mutex is held)
+func (m *Manager) countSession(session *types.Session, req *types.SessionCreateRequest) {
+	if m.sessionLimiter != nil {
+		m.sessionLimiter.AddSession(session.ID, session.Owner, req.ClientIP)
+	}
+}
+
+// uncountSession stops counting a session that failed to start against the
+// session limits (assumes mutex is held)
+func (m *Manager) uncountSession(session *types.Session) {
+	if m.sessionLimiter != nil {
+		m.sessionLimiter.RemoveSession(session.ID)
+	}
+}
+
+// SetAdmissionChecker sets the check consulted before creating sessions
+func (m *Manager) SetAdmissionChecker(checker AdmissionChecker) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.admission = checker
+}
+
+// SetDisplayServer sets the Xvnc binary serving the displays of sessions
+// that ask for one. Must be called before sessions are created.
+func (m *Manager) SetDisplayServer(path string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.displayServer = path
+}
+
+// SetApprovalRequired sets whether sessions with privileged profiles wait for approval
+func (m *Manager) SetApprovalRequired(required bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.approvalRequired = required
+}
+
+// SetSecretsProvider sets the provider issuing credentials requested by profiles
+func (m *Manager) SetSecretsProvider(provider secrets.Provider) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.secretsProvider = provider
+}
+
+// SetMaxOutputRate bounds how many bytes of output per second each session
+// and pane writes to disk; 0 means unlimited
+func (m *Manager) SetMaxOutputRate(bytesPerSecond int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.maxOutputRate = bytesPerSecond
+}
+
+// SetContainerPool sets the warm pool used by container-backed sessions
+func (m *Manager) SetContainerPool(pool *ContainerPool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.containerPool = pool
+}
+
 // cleanupSession performs cleanup for a session (assumes mutex is held)
 func (m *Manager) cleanupSession(sessionID string) error {
 	session := m.sessions[sessionID]
+	m.markEnded(session, m.retention.Terminated)
+
+	// Let paused processes see the termination signal
+	m.wakeSession(session)
+
+	// End the session's panes and pipes along with it
+	m.closeSessionPanes(session)
+	m.closeSessionPipes(sessionID)
+	m.stopWallClock(sessionID)
+
+	// Drop any request still awaiting approval, and release input waiting
+	// for the session to start
+	delete(m.pendingRequests, sessionID)
+	m.finishStarting(sessionID)
 
 	// Stop session runner
-	if runner, exists := m.sessionRunners[sessionID]; exists {
+	runner, hasRunner := m.sessionRunners[sessionID]
+	if hasRunner {
 		runner.Stop()
 		delete(m.sessionRunners, sessionID)
 	}
+	m.stopRecording(sessionID)
+	m.stopDisplay(sessionID)
+	delete(m.screens, sessionID)
+
+	// Tell whatever else still works for the session to exit
+	m.closeScope(sessionID)
 
 	// Cleanup resources
 	if err := m.cleanupManager.CleanupSession(session); err != nil {
 		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to cleanup session")
 	}
 
+	// Record usage and report completion now that the process has exited
+	if hasRunner {
+		m.recordUsage(session, runner)
+		m.notifyCallback(session, runner, types.SessionStatusStopped)
+	}
+
+	// Stop publishing the session
+	m.revokeBroadcast(session)
+
+	// Revoke credentials without holding up other sessions
+	go m.revokeCredentials(sessionID)
+
 	// Update session status
 	session.Status = types.SessionStatusStopped
 	session.PTY = nil
@@ -218,18 +1027,10 @@ func (m *Manager) cleanupSession(sessionID string) error {
 
 	// Broadcast status update if callback is set
 	if m.statusCallback != nil {
-		m.statusCallback(sessionID, string(types.SessionStatusStopped))
+		m.statusCallback(sessionID, string(types.SessionStatusStopped), session.ExitCode)
 	}
 
-	// Remove from active sessions after a delay
-	go func() {
-		time.Sleep(30 * time.Second)
-		m.mutex.Lock()
-		delete(m.sessions, sessionID)
-		m.mutex.Unlock()
-		logrus.WithField("session_id", sessionID).Debug("Session removed from memory")
-	}()
-
+	// The session stays listed until its retention is up, see reapOnce
 	return nil
 }
 
@@ -237,17 +1038,49 @@ func (m *Manager) cleanupSession(sessionID string) error {
 func (m *Manager) cleanupSessionImmediate(sessionID string) error {
 	session := m.sessions[sessionID]
 
+	// Let paused processes see the termination signal
+	m.wakeSession(session)
+
+	// End the session's panes and pipes along with it
+	m.closeSessionPanes(session)
+	m.closeSessionPipes(sessionID)
+	m.stopWallClock(sessionID)
+
+	// Drop any request still awaiting approval, and release input waiting
+	// for the session to start
+	delete(m.pendingRequests, sessionID)
+	m.finishStarting(sessionID)
+
 	// Stop session runner
-	if runner, exists := m.sessionRunners[sessionID]; exists {
+	runner, hasRunner := m.sessionRunners[sessionID]
+	if hasRunner {
 		runner.Stop()
 		delete(m.sessionRunners, sessionID)
 	}
+	m.stopRecording(sessionID)
+	m.stopDisplay(sessionID)
+	delete(m.screens, sessionID)
+
+	// Tell whatever else still works for the session to exit
+	m.closeScope(sessionID)
 
 	// Cleanup resources
 	if err := m.cleanupManager.CleanupSession(session); err != nil {
 		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to cleanup session")
 	}
 
+	// Record usage and report completion now that the process has exited
+	if hasRunner {
+		m.recordUsage(session, runner)
+		m.notifyCallback(session, runner, types.SessionStatusStopped)
+	}
+
+	// Stop publishing the session
+	m.revokeBroadcast(session)
+
+	// Revoke credentials before the server exits
+	m.revokeCredentials(sessionID)
+
 	// Update session status
 	session.Status = types.SessionStatusStopped
 	session.PTY = nil
@@ -255,49 +1088,12 @@ func (m *Manager) cleanupSessionImmediate(sessionID string) error {
 
 	// Immediately remove from active sessions
 	delete(m.sessions, sessionID)
+	removeRecording(session)
 	logrus.WithField("session_id", sessionID).Debug("Session immediately removed from memory")
 
 	return nil
 }
 
-// backgroundCleanup periodically cleans up inactive sessions
-func (m *Manager) backgroundCleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			m.cleanupInactiveSessions()
-		case <-m.stopChan:
-			return
-		}
-	}
-}
-
-// cleanupInactiveSessions removes sessions that have been inactive for too long
-func (m *Manager) cleanupInactiveSessions() {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	now := time.Now()
-	inactiveThreshold := 30 * time.Minute
-
-	for sessionID, session := range m.sessions {
-		if session.Status == types.SessionStatusStopped || session.Status == types.SessionStatusError {
-			// Clean up stopped sessions after 5 minutes
-			if now.Sub(session.LastActiveAt) > 5*time.Minute {
-				logrus.WithField("session_id", sessionID).Info("Cleaning up stopped session")
-				m.cleanupSession(sessionID)
-			}
-		} else if now.Sub(session.LastActiveAt) > inactiveThreshold {
-			// Clean up inactive sessions
-			logrus.WithField("session_id", sessionID).Info("Cleaning up inactive session")
-			m.cleanupSession(sessionID)
-		}
-	}
-}
-
 // Shutdown gracefully shuts down the session manager
 func (m *Manager) Shutdown() error {
 	var shutdownErr error
@@ -305,7 +1101,7 @@ func (m *Manager) Shutdown() error {
 	m.shutdownOnce.Do(func() {
 		logrus.Info("Shutting down session manager")
 
-		// Stop background cleanup routine
+		// Stop reaping sessions
 		close(m.stopChan)
 
 		m.mutex.Lock()
@@ -340,6 +1136,9 @@ func (m *Manager) Shutdown() error {
 			logrus.WithError(err).Error("Failed to cleanup orphaned resources during shutdown")
 		}
 
+		// Let completion callbacks for the terminated sessions go out
+		m.waitForCallbacks(callbackShutdownWait)
+
 		logrus.Info("Session manager shutdown completed")
 	})
 
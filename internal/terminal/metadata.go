@@ -0,0 +1,48 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Limits on session metadata, which is kept in memory and written to logs
+const (
+	maxMetadataEntries     = 32
+	maxMetadataValueLength = 256
+)
+
+// ErrInvalidMetadata is returned for metadata that does not fit the limits
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// metadataKeyPattern matches metadata keys such as ci.job or service_name
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// validateMetadata checks the metadata attached to a session
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxMetadataEntries {
+		return fmt.Errorf("%w: at most %d entries are allowed", ErrInvalidMetadata, maxMetadataEntries)
+	}
+
+	for key, value := range metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("%w: invalid key %q", ErrInvalidMetadata, key)
+		}
+		if len(value) > maxMetadataValueLength {
+			return fmt.Errorf("%w: value of %q is longer than %d bytes", ErrInvalidMetadata, key, maxMetadataValueLength)
+		}
+	}
+
+	return nil
+}
+
+// MatchesMetadata reports whether a session's metadata has every key and
+// value in filter
+func MatchesMetadata(metadata, filter map[string]string) bool {
+	for key, value := range filter {
+		if actual, exists := metadata[key]; !exists || actual != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrPTYRequired is returned for sessions that cannot run without a PTY
+var ErrPTYRequired = errors.New("allocate_pty: false needs a command on the pty backend")
+
+// validateAllocatePTY checks that only host commands are run without a PTY.
+// Shells, sandboxes and containers expect a terminal.
+func validateAllocatePTY(req *types.SessionCreateRequest, backend types.SessionBackend) error {
+	if req.AllocatePTY == nil || *req.AllocatePTY {
+		return nil
+	}
+	if backend != types.SessionBackendPTY || len(req.Command) == 0 {
+		return ErrPTYRequired
+	}
+	return nil
+}
+
+// StartCommand runs a command on plain pipes instead of a PTY. The returned
+// socket is joined to the command's stdin and stdout, so it can stand in for
+// a PTY, and stderr is returned separately.
+func StartCommand(config *PTYConfig) (*os.File, *os.File, *exec.Cmd, error) {
+	syscall.ForkLock.RLock()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err == nil {
+		syscall.CloseOnExec(fds[0])
+		syscall.CloseOnExec(fds[1])
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create stdio socket: %w", err)
+	}
+
+	// Our end is non-blocking so that closing it interrupts pending reads
+	if err := syscall.SetNonblock(fds[0], true); err != nil {
+		syscall.Close(fds[0])
+		syscall.Close(fds[1])
+		return nil, nil, nil, fmt.Errorf("failed to set up stdio socket: %w", err)
+	}
+	stdio := os.NewFile(uintptr(fds[0]), "stdio")
+	childStdio := os.NewFile(uintptr(fds[1]), "stdio")
+	defer childStdio.Close()
+
+	stderr, childStderr, err := os.Pipe()
+	if err != nil {
+		stdio.Close()
+		return nil, nil, nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	defer childStderr.Close()
+
+	shell, command := resolveShellCommand(config)
+
+	cmd := exec.Command(shell, command...)
+	cmd.Dir = resolveWorkingDirectory(config.WorkingDir)
+	cmd.Env = setupEnvironment(config.Env)
+	cmd.Stdin = childStdio
+	cmd.Stdout = childStdio
+	cmd.Stderr = childStderr
+	// A session of its own, so signals reach the whole process group
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Credential: config.Credential}
+
+	if err := cmd.Start(); err != nil {
+		stdio.Close()
+		stderr.Close()
+		return nil, nil, nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"command": cmd.Args,
+		"pid":     cmd.Process.Pid,
+	}).Info("Command started without PTY")
+
+	return stdio, stderr, cmd, nil
+}
+
+// closeStdin signals end of input to a command started without a PTY, while
+// its output can still be read
+func closeStdin(stdio *os.File) error {
+	conn, err := stdio.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var shutdownErr error
+	if err := conn.Control(func(fd uintptr) {
+		shutdownErr = syscall.Shutdown(int(fd), syscall.SHUT_WR)
+	}); err != nil {
+		return err
+	}
+	return shutdownErr
+}
+
+// startCommand runs a session's command without a PTY, keeping its stderr in
+// a file of its own next to the output file
+func (m *Manager) startCommand(session *types.Session, config *PTYConfig) (*os.File, *exec.Cmd, error) {
+	stderrFile, err := m.pipeManager.CreateStderrFile(session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stdio, stderr, process, err := StartCommand(config)
+	if err != nil {
+		os.Remove(stderrFile)
+		return nil, nil, err
+	}
+
+	session.Stderr = stderr
+	session.StderrFile = stderrFile
+	return stdio, process, nil
+}
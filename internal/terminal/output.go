@@ -0,0 +1,163 @@
+package terminal
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/piyushgupta53/webterm/internal/transcript"
+	"github.com/sirupsen/logrus"
+)
+
+// OutputChunk is output a session or pane has just appended to its output
+// file, pushed to the output callback as it is written
+type OutputChunk struct {
+	SessionID string
+	PaneID    string // Empty for the session's own shell
+	Stderr    bool   // Written to the stderr file of a session without a PTY
+	Data      []byte // Only valid until the callback returns
+	End       int64  // Offset in the file where Data ends
+
+	// Set when the file was truncated since the last write, such as to free
+	// disk space. Data then holds the whole file, from offset 0.
+	Truncated bool
+}
+
+// SetOutputCallback sets the function told of output as sessions and panes
+// write it, so it can be relayed without reading their output files. It runs
+// on the session's output goroutine, so it must not block. It must be called
+// before sessions are created.
+func (m *Manager) SetOutputCallback(callback func(chunk *OutputChunk)) {
+	m.outputCallback = callback
+}
+
+// SetSealer encrypts the output files of sessions and panes created from now
+// on; nil leaves them in plaintext. It must be called before sessions are
+// created.
+func (m *Manager) SetSealer(sealer *transcript.Sealer) {
+	m.sealer = sealer
+}
+
+// WriteTranscript writes the output of a session's shell to w
+func (m *Manager) WriteTranscript(w io.Writer, sessionID string) error {
+	m.mutex.RLock()
+	session, exists := m.sessions[sessionID]
+	m.mutex.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	_, err := m.sealer.Copy(w, session.OutputFile)
+	return err
+}
+
+// outputHandler passes the output of a session or pane to the output callback
+func (m *Manager) outputHandler(sessionID, paneID string) func(chunk *OutputChunk) {
+	return func(chunk *OutputChunk) {
+		if m.outputCallback == nil {
+			return
+		}
+		chunk.SessionID = sessionID
+		chunk.PaneID = paneID
+		m.outputCallback(chunk)
+	}
+}
+
+// outputWriter appends one stream of a session's output to its file, sealed
+// if output is encrypted at rest, and pushes each write to the session
+// runner's output handler
+type outputWriter struct {
+	file    *os.File
+	path    string
+	sealer  *transcript.Sealer
+	end     int64 // Where the output written so far ends
+	fileEnd int64 // Where the last write ended in the file
+	chunk   OutputChunk
+	handler func(chunk *OutputChunk)
+}
+
+// openOutput opens a session's output or stderr file for appending
+func (sr *SessionRunner) openOutput(path string, stderr bool) (*outputWriter, error) {
+	// Opened for reading too, to push the whole file after a truncation
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, pipeFileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &outputWriter{
+		file:    file,
+		path:    path,
+		sealer:  sr.sealer,
+		chunk:   OutputChunk{Stderr: stderr},
+		handler: sr.outputHandler,
+	}
+	if info, err := file.Stat(); err == nil {
+		w.fileEnd = info.Size()
+	}
+	w.end = w.size()
+	return w, nil
+}
+
+// write appends data to the file and pushes it to the handler
+func (w *outputWriter) write(data []byte) error {
+	// The file may have been emptied, such as to free disk space, leaving
+	// only a marker
+	truncated := false
+	if info, err := w.file.Stat(); err == nil && info.Size() < w.fileEnd {
+		truncated = true
+		w.end = w.size()
+	}
+
+	record := w.sealer.Seal(w.end, data)
+	if _, err := w.file.Write(record); err != nil {
+		return err
+	}
+
+	// Appending leaves the offset at the end of the file
+	fileEnd, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if !truncated && fileEnd-int64(len(record)) < w.fileEnd {
+		// Emptied between the check and the write
+		truncated = true
+		w.end = w.size() - int64(len(data))
+	}
+	w.fileEnd = fileEnd
+
+	start := w.end
+	w.end += int64(len(data))
+	if w.handler == nil {
+		return nil
+	}
+
+	w.chunk.Data = data
+	w.chunk.End = w.end
+	w.chunk.Truncated = truncated
+	if truncated && start > 0 {
+		// Whatever replaced the earlier output, such as a marker, comes first
+		whole, err := w.sealer.ReadRange(w.path, 0, start)
+		if err != nil {
+			return err
+		}
+		w.chunk.Data = append(whole, data...)
+	}
+
+	w.handler(&w.chunk)
+	return nil
+}
+
+// size returns how much output the file holds
+func (w *outputWriter) size() int64 {
+	size, err := w.sealer.Size(w.path)
+	if err != nil {
+		logrus.WithError(err).WithField("output_file", w.path).Warn("Failed to read output file size")
+	}
+	return size
+}
+
+// Close closes the file
+func (w *outputWriter) Close() error {
+	return w.file.Close()
+}
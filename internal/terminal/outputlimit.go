@@ -0,0 +1,68 @@
+package terminal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// outputLimiter bounds how fast a session's output is written to disk, so a
+// runaway command such as `yes` cannot fill it through the output file.
+// Output over the limit is dropped, with a marker where dropping begins.
+type outputLimiter struct {
+	rate     int64   // Bytes per second, also the largest burst
+	tokens   float64 // Bytes that may be written now
+	last     time.Time
+	dropping bool // Set while output is being dropped
+	dropped  int64
+	mutex    sync.Mutex
+}
+
+// newOutputLimiter returns a limiter allowing rate bytes per second, or nil
+// when rate is not positive
+func newOutputLimiter(rate int64) *outputLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &outputLimiter{rate: rate, tokens: float64(rate), last: time.Now()}
+}
+
+// admit returns how many of n bytes of output may be written now, and a
+// marker to write after them if the rest is the start of dropped output
+func (l *outputLimiter) admit(n int) (allowed int, marker []byte) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if l.tokens > float64(l.rate) {
+		l.tokens = float64(l.rate)
+	}
+	l.last = now
+
+	// Once over the limit, drop output until half a second's worth may be
+	// written again, so gaps are few and each is marked once
+	if l.dropping && l.tokens < float64(l.rate)/2 {
+		l.dropped += int64(n)
+		return 0, nil
+	}
+	l.dropping = false
+
+	allowed = n
+	if float64(allowed) > l.tokens {
+		allowed = int(l.tokens)
+		l.dropping = true
+		l.dropped += int64(n - allowed)
+		marker = []byte(fmt.Sprintf("\r\n[webterm: output dropped, over the limit of %d bytes/s]\r\n", l.rate))
+	}
+	l.tokens -= float64(allowed)
+
+	return allowed, marker
+}
+
+// droppedBytes returns how much output has been dropped in total
+func (l *outputLimiter) droppedBytes() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.dropped
+}
@@ -0,0 +1,352 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// maxPanesPerSession caps the panes a session may have open at once
+const maxPanesPerSession = 8
+
+var (
+	// ErrPaneNotFound is returned for panes that do not exist in a session
+	ErrPaneNotFound = errors.New("pane not found")
+	// ErrPanesUnavailable is returned for sessions that cannot open panes
+	ErrPanesUnavailable = errors.New("panes are only available for running pty and container sessions")
+	// ErrTooManyPanes is returned when a session already has the maximum number of panes
+	ErrTooManyPanes = errors.New("too many panes")
+)
+
+// paneState tracks the resources behind a pane. The pane's I/O is bridged by
+// a session runner working on a stand-in session that shares nothing with the
+// real one but its pipes and PTY.
+type paneState struct {
+	sessionID string
+	pane      *types.Pane
+	shadow    *types.Session
+	runner    *SessionRunner
+}
+
+// paneKey identifies a pane across sessions, and names its pipes
+func paneKey(sessionID, paneID string) string {
+	return sessionID + "-pane-" + paneID
+}
+
+// SetPaneCallback sets the function told when panes open and close
+func (m *Manager) SetPaneCallback(callback func(sessionID, paneID, status string)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.paneCallback = callback
+}
+
+// CreatePane starts another shell in a session with its own PTY
+func (m *Manager) CreatePane(sessionID string, req *types.PaneCreateRequest) (*types.Pane, error) {
+	m.mutex.Lock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.Status != types.SessionStatusRunning ||
+		(session.Backend != types.SessionBackendPTY && session.Backend != types.SessionBackendContainer) {
+		m.mutex.Unlock()
+		return nil, ErrPanesUnavailable
+	}
+	if len(session.Panes) >= maxPanesPerSession {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("%w: a session may have at most %d", ErrTooManyPanes, maxPanesPerSession)
+	}
+
+	// Panes run what the session's profile pins, like the session itself
+	profile := m.profiles[session.Profile]
+	if profile != nil {
+		pinned := *req
+		if pinned.Shell == "" {
+			pinned.Shell = profile.Shell
+		}
+		if len(pinned.Command) == 0 {
+			pinned.Command = profile.Command
+		}
+		req = &pinned
+	}
+	if err := m.checkLaunch(req.Shell, req.Command, profile); err != nil {
+		m.mutex.Unlock()
+		return nil, err
+	}
+	// Host panes start where their session did, checked when it was created
+	workingDir := session.WorkingDir
+	if req.WorkingDir != "" || session.Container != "" {
+		checked, err := m.checkWorkingDir(req.WorkingDir, nil, session.Container == "")
+		if err != nil {
+			m.mutex.Unlock()
+			return nil, err
+		}
+		workingDir = checked
+	}
+	env, err := m.sanitizeEnv(session.Owner, req.Env)
+	if err != nil {
+		m.mutex.Unlock()
+		return nil, err
+	}
+	if len(env) != len(req.Env) {
+		sanitized := *req
+		sanitized.Env = env
+		req = &sanitized
+	}
+
+	pane := &types.Pane{
+		ID:         uuid.New().String()[:8],
+		CreatedAt:  time.Now(),
+		Shell:      req.Shell,
+		Command:    req.Command,
+		WorkingDir: workingDir,
+	}
+
+	state, err := m.startPane(session, pane, req)
+	if err != nil {
+		m.mutex.Unlock()
+		return nil, err
+	}
+
+	// Replace rather than append, so readers of the old slice are unaffected
+	panes := make([]*types.Pane, 0, len(session.Panes)+1)
+	session.Panes = append(append(panes, session.Panes...), pane)
+	m.panes[paneKey(sessionID, pane.ID)] = state
+	callback := m.paneCallback
+	m.mutex.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"pane_id":    pane.ID,
+	}).Info("Pane created")
+
+	go func() {
+		if err := state.runner.Start(); err != nil {
+			logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to start pane runner")
+			m.ClosePane(sessionID, pane.ID)
+			return
+		}
+		if callback != nil {
+			callback(sessionID, pane.ID, string(types.SessionStatusRunning))
+		}
+	}()
+
+	return pane, nil
+}
+
+// startPane creates a pane's pipes and spawns its shell (assumes mutex is held)
+func (m *Manager) startPane(session *types.Session, pane *types.Pane, req *types.PaneCreateRequest) (*paneState, error) {
+	key := paneKey(session.ID, pane.ID)
+
+	// Panes count against the goroutine budget of their session
+	scope, err := m.scope(session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	inputPipe, outputFile, err := m.pipeManager.CreateSessionPipes(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pane pipes: %w", err)
+	}
+
+	// Panes share the session's terminal type, locale, credentials and display
+	env := make(map[string]string, len(req.Env)+2)
+	for name, value := range req.Env {
+		env[name] = value
+	}
+	if _, exists := env["TERM"]; !exists && session.Term != "" {
+		env["TERM"] = session.Term
+	}
+	if _, exists := env["LANG"]; !exists && session.Locale != "" {
+		env["LANG"] = session.Locale
+	}
+
+	m.credentialsMutex.Lock()
+	creds := m.credentials[session.ID]
+	m.credentialsMutex.Unlock()
+
+	config := &PTYConfig{
+		Shell:      pane.Shell,
+		Command:    pane.Command,
+		WorkingDir: pane.WorkingDir,
+		Env:        m.displays[session.ID].env(creds.processEnv(env)),
+	}
+
+	// Panes run as the same account as their session
+	acct, err := m.sessionAccount(session)
+	if err != nil {
+		m.pipeManager.CleanupSessionPipes(key, inputPipe, outputFile)
+		return nil, err
+	}
+	acct.apply(config)
+
+	// Container panes join the session's container
+	if session.Container != "" {
+		execReq := &types.SessionCreateRequest{
+			Shell:      pane.Shell,
+			Command:    pane.Command,
+			WorkingDir: pane.WorkingDir,
+			Env:        env,
+		}
+		config = &PTYConfig{
+			Command: buildContainerExecCommand(session.ContainerRuntime, session.Container, execReq, creds),
+			Env:     creds.processEnv(nil),
+		}
+	}
+
+	ptty, process, err := CreatePTY(config)
+	if err != nil {
+		m.pipeManager.CleanupSessionPipes(key, inputPipe, outputFile)
+		return nil, fmt.Errorf("failed to create pane PTY: %w", err)
+	}
+
+	pane.PTY = ptty
+	pane.Process = process
+	pane.InputPipe = inputPipe
+	pane.OutputFile = outputFile
+
+	shadow := &types.Session{
+		ID:         key,
+		Status:     types.SessionStatusStarting,
+		CreatedAt:  pane.CreatedAt,
+		Backend:    types.SessionBackendPTY,
+		InputPipe:  inputPipe,
+		OutputFile: outputFile,
+		PTY:        ptty,
+		Process:    process,
+	}
+
+	runner := NewSessionRunner(shadow, m.pipeManager, scope)
+	runner.SetOutputRateLimit(m.maxOutputRate)
+	runner.SetOutputBuffering(outputBuffering(session))
+	runner.SetOutputHandler(m.outputHandler(session.ID, pane.ID))
+	runner.SetSealer(m.sealer)
+	runner.SetStatusCallback(func(_ string, status string) {
+		if status == string(types.SessionStatusStopped) || status == string(types.SessionStatusError) {
+			// Closing stops the runner, which cannot happen on its own goroutine
+			go m.ClosePane(session.ID, pane.ID)
+		}
+	})
+
+	return &paneState{sessionID: session.ID, pane: pane, shadow: shadow, runner: runner}, nil
+}
+
+// GetPane retrieves a pane of a session
+func (m *Manager) GetPane(sessionID, paneID string) (*types.Pane, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	state, exists := m.panes[paneKey(sessionID, paneID)]
+	if !exists {
+		return nil, ErrPaneNotFound
+	}
+	return state.pane, nil
+}
+
+// ListPanes returns the open panes of a session
+func (m *Manager) ListPanes(sessionID string) ([]*types.Pane, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	return session.Panes, nil
+}
+
+// ClosePane ends a pane's shell and releases its resources
+func (m *Manager) ClosePane(sessionID, paneID string) error {
+	m.mutex.Lock()
+	state, exists := m.takePane(sessionID, paneID)
+	callback := m.paneCallback
+	m.mutex.Unlock()
+
+	if !exists {
+		return ErrPaneNotFound
+	}
+
+	m.releasePane(state)
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"pane_id":    paneID,
+	}).Info("Pane closed")
+
+	if callback != nil {
+		callback(sessionID, paneID, string(types.SessionStatusStopped))
+	}
+
+	return nil
+}
+
+// takePane removes a pane from its session (assumes mutex is held)
+func (m *Manager) takePane(sessionID, paneID string) (*paneState, bool) {
+	key := paneKey(sessionID, paneID)
+	state, exists := m.panes[key]
+	if !exists {
+		return nil, false
+	}
+	delete(m.panes, key)
+
+	if session, exists := m.sessions[sessionID]; exists {
+		panes := make([]*types.Pane, 0, len(session.Panes))
+		for _, pane := range session.Panes {
+			if pane.ID != paneID {
+				panes = append(panes, pane)
+			}
+		}
+		session.Panes = panes
+	}
+
+	return state, true
+}
+
+// releasePane stops a pane's runner and cleans up its shell and pipes
+func (m *Manager) releasePane(state *paneState) {
+	// Hang up the pane's shell first, as the runner waits for it to exit
+	if process := state.pane.Process; process != nil && process.Process != nil && process.ProcessState == nil {
+		signalProcessGroup(process.Process.Pid, syscall.SIGHUP)
+	}
+
+	state.runner.Stop()
+
+	if err := m.cleanupManager.CleanupSession(state.shadow); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"session_id": state.sessionID,
+			"pane_id":    state.pane.ID,
+		}).Error("Failed to cleanup pane")
+	}
+}
+
+// closeSessionPanes ends every pane of a session (assumes mutex is held)
+func (m *Manager) closeSessionPanes(session *types.Session) {
+	for _, pane := range session.Panes {
+		if state, exists := m.takePane(session.ID, pane.ID); exists {
+			m.releasePane(state)
+		}
+	}
+}
+
+// signalPanes stops or continues the processes of a session's host panes
+// (assumes mutex is held)
+func (m *Manager) signalPanes(session *types.Session, signal syscall.Signal) {
+	for _, pane := range session.Panes {
+		if pane.Process == nil || pane.Process.Process == nil {
+			continue
+		}
+		if err := signalProcessGroup(pane.Process.Process.Pid, signal); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"session_id": session.ID,
+				"pane_id":    pane.ID,
+			}).Warn("Failed to signal pane process group")
+		}
+	}
+}
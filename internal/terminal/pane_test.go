@@ -0,0 +1,68 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+func TestCreatePaneKeepsProfileCommand(t *testing.T) {
+	m := NewManager(t.TempDir())
+	defer m.Shutdown()
+
+	m.SetProfiles(map[string]*types.Profile{
+		"viewer": {Name: "viewer", Command: []string{"cat"}},
+	})
+
+	session, err := m.CreateSession(context.Background(), &types.SessionCreateRequest{Profile: "viewer"})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if started := m.SessionStarting(session.ID); started != nil {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("session did not start")
+		}
+	}
+
+	tests := []struct {
+		name    string
+		req     *types.PaneCreateRequest
+		wantErr error
+	}{
+		{
+			name:    "other command",
+			req:     &types.PaneCreateRequest{Command: []string{"sh"}},
+			wantErr: ErrCommandNotAllowed,
+		},
+		{
+			name: "pinned command",
+			req:  &types.PaneCreateRequest{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pane, err := m.CreatePane(session.ID, tt.req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreatePane error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("CreatePane: %v", err)
+			}
+			if !slices.Equal(pane.Command, []string{"cat"}) {
+				t.Errorf("pane command = %v, want [cat]", pane.Command)
+			}
+		})
+	}
+}
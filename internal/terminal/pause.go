@@ -0,0 +1,111 @@
+package terminal
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// PauseSession freezes a running session's processes until it is resumed
+func (m *Manager) PauseSession(sessionID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if session.Status != types.SessionStatusRunning {
+		return fmt.Errorf("session cannot be paused in current state: %s", session.Status)
+	}
+
+	if err := m.signalSession(session, true); err != nil {
+		return err
+	}
+
+	session.Status = types.SessionStatusPaused
+	logrus.WithField("session_id", sessionID).Info("Session paused")
+
+	if m.statusCallback != nil {
+		m.statusCallback(sessionID, string(types.SessionStatusPaused), nil)
+	}
+
+	return nil
+}
+
+// ResumeSession continues a paused session
+func (m *Manager) ResumeSession(sessionID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if session.Status != types.SessionStatusPaused {
+		return fmt.Errorf("session is not paused: %s", session.Status)
+	}
+
+	if err := m.signalSession(session, false); err != nil {
+		return err
+	}
+
+	session.Status = types.SessionStatusRunning
+	session.UpdateLastActive()
+	logrus.WithField("session_id", sessionID).Info("Session resumed")
+
+	if m.statusCallback != nil {
+		m.statusCallback(sessionID, string(types.SessionStatusRunning), nil)
+	}
+
+	return nil
+}
+
+// signalSession stops or continues a session's processes. Host processes are
+// signalled as a process group, since the PTY makes the shell a group leader;
+// containers are paused through their runtime, as stopping the CLI attached to
+// them would leave the container itself running.
+func (m *Manager) signalSession(session *types.Session, pause bool) error {
+	if session.Container != "" && session.ContainerRuntime != "" {
+		action := "unpause"
+		if pause {
+			action = "pause"
+		}
+		if _, err := runContainerCommand(30*time.Second, session.ContainerRuntime, action, session.Container); err != nil {
+			return fmt.Errorf("failed to %s container: %w", action, err)
+		}
+		return nil
+	}
+
+	if session.Process == nil || session.Process.Process == nil {
+		return fmt.Errorf("session has no process to signal")
+	}
+
+	signal := syscall.SIGCONT
+	if pause {
+		signal = syscall.SIGSTOP
+	}
+	if err := signalProcessGroup(session.Process.Process.Pid, signal); err != nil {
+		return fmt.Errorf("failed to signal session process group: %w", err)
+	}
+	m.signalPanes(session, signal)
+
+	return nil
+}
+
+// wakeSession resumes a paused session so that it can handle termination
+// signals (assumes mutex is held)
+func (m *Manager) wakeSession(session *types.Session) {
+	if session.Status != types.SessionStatusPaused {
+		return
+	}
+
+	if err := m.signalSession(session, false); err != nil {
+		logrus.WithError(err).WithField("session_id", session.ID).Warn("Failed to resume paused session before cleanup")
+	}
+}
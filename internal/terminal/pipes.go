@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"syscall"
 
 	"github.com/sirupsen/logrus"
 )
 
+// pipeFileMode restricts session pipes and output files to the server user
+const pipeFileMode = 0600
+
 // PipeManager handles creation and management of named pipes for sessions
 type PipeManager struct {
 	pipesDir string
@@ -24,7 +26,7 @@ func NewPipeManager(pipesDir string) *PipeManager {
 // CreateSessionPipes creates input and output pipes for a session
 func (pm *PipeManager) CreateSessionPipes(sessionID string) (inputPipe, outputFile string, err error) {
 	// Ensure pipe directory exists
-	if err := os.MkdirAll(pm.pipesDir, 0755); err != nil {
+	if err := os.MkdirAll(pm.pipesDir, 0700); err != nil {
 		return "", "", fmt.Errorf("failed to create pipes directory: %w", err)
 	}
 
@@ -38,13 +40,14 @@ func (pm *PipeManager) CreateSessionPipes(sessionID string) (inputPipe, outputFi
 		"output_file": outputFile,
 	}).Info("Creating session pipes")
 
-	// Create inpput FIFO pipe
-	if err := syscall.Mkfifo(inputPipe, 0622); err != nil {
+	// Create input FIFO pipe. Pipes are only opened by the server, so other
+	// local users get no access to what is typed into or printed by sessions.
+	if err := makeFIFO(inputPipe); err != nil {
 		return "", "", fmt.Errorf("failed to create input FIFO pipe: %w", err)
 	}
 
 	// Create output file (regular file)
-	outputFileHandle, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	outputFileHandle, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, pipeFileMode)
 	if err != nil {
 		// Clean up input pipe if output file creation fails
 		os.Remove(inputPipe)
@@ -61,6 +64,20 @@ func (pm *PipeManager) CreateSessionPipes(sessionID string) (inputPipe, outputFi
 	return inputPipe, outputFile, nil
 }
 
+// CreateStderrFile creates the file a session's stderr is written to, for
+// sessions that keep it apart from their output
+func (pm *PipeManager) CreateStderrFile(sessionID string) (string, error) {
+	stderrFile := filepath.Join(pm.pipesDir, fmt.Sprintf("%s.stderr", sessionID))
+
+	file, err := os.OpenFile(stderrFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, pipeFileMode)
+	if err != nil {
+		return "", fmt.Errorf("failed to create stderr file: %w", err)
+	}
+	file.Close()
+
+	return stderrFile, nil
+}
+
 // CleanupSessionPipes removes the pipes for a session
 func (pm *PipeManager) CleanupSessionPipes(sessionID, inputPipe, outputFile string) error {
 	logrus.WithFields(logrus.Fields{
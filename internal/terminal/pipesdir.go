@@ -0,0 +1,111 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInsecurePipesDir is returned for pipes directories other local users
+// could tamper with
+var ErrInsecurePipesDir = errors.New("insecure pipes directory")
+
+// ErrPipesDirLocked is returned when another server is using a pipes directory
+var ErrPipesDirLocked = errors.New("pipes directory is in use by another instance")
+
+// PreparePipesDir creates the pipes directory if needed and checks that it
+// belongs to the server user and is not writable by others, unless
+// allowInsecure is set. With unique set, a fresh subdirectory is created for
+// this run, so instances sharing a directory such as /tmp cannot collide.
+// It returns the directory sessions should use.
+func PreparePipesDir(dir string, allowInsecure, unique bool) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create pipes directory: %w", err)
+	}
+
+	if err := checkPipesDir(dir); err != nil {
+		if !allowInsecure {
+			return "", err
+		}
+		logrus.WithError(err).WithField("pipes_dir", dir).Warn("Using insecure pipes directory")
+	}
+
+	if !unique {
+		return dir, nil
+	}
+
+	runDir, err := os.MkdirTemp(dir, "run-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create pipes directory for this run: %w", err)
+	}
+
+	logrus.WithField("pipes_dir", runDir).Info("Using pipes directory unique to this run")
+	return runDir, nil
+}
+
+// checkPipesDir verifies the ownership and permissions of a pipes directory,
+// tightening them to 0700 if the server user owns it
+func checkPipesDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to inspect pipes directory: %w", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrInsecurePipesDir, dir)
+	}
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("%w: %s is owned by uid %d, not the server user", ErrInsecurePipesDir, dir, stat.Uid)
+	}
+
+	mode := info.Mode().Perm()
+	if mode&0022 != 0 {
+		return fmt.Errorf("%w: %s is writable by other users (mode %#o)", ErrInsecurePipesDir, dir, mode)
+	}
+
+	if mode != 0700 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return fmt.Errorf("failed to restrict pipes directory: %w", err)
+		}
+		logrus.WithFields(logrus.Fields{
+			"pipes_dir": dir,
+			"mode":      fmt.Sprintf("%#o", mode),
+		}).Info("Restricted pipes directory to the server user")
+	}
+
+	return nil
+}
+
+// LockPipesDir takes an exclusive lock on a pipes directory, held until the
+// returned file is closed, so that two servers never share one and remove
+// each other's pipes as leftovers
+func LockPipesDir(dir string) (*os.File, error) {
+	lock, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pipes directory: %w", err)
+	}
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lock.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", ErrPipesDirLocked, dir)
+		}
+		return nil, fmt.Errorf("failed to lock pipes directory: %w", err)
+	}
+
+	return lock, nil
+}
+
+// pipesDirInUse reports whether another server holds the lock on a directory
+func pipesDirInUse(dir string) bool {
+	lock, err := LockPipesDir(dir)
+	if err != nil {
+		return errors.Is(err, ErrPipesDirLocked)
+	}
+	lock.Close()
+	return false
+}
@@ -0,0 +1,326 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/piyushgupta53/webterm/internal/transcript"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxPipesPerSession caps the pipes a session may feed at once
+	maxPipesPerSession = 8
+	// maxPipeLine bounds how much of an unterminated line a pipe holds back
+	maxPipeLine = 64 * 1024
+	// pipePollInterval is how often pipes check their source for new output
+	pipePollInterval = 100 * time.Millisecond
+)
+
+var (
+	// ErrPipeNotFound is returned for pipes that do not exist
+	ErrPipeNotFound = errors.New("pipe not found")
+	// ErrInvalidPipe is returned for pipes that cannot be set up as requested
+	ErrInvalidPipe = errors.New("invalid pipe")
+	// ErrPipeLoop is returned for pipes that would feed a session its own output
+	ErrPipeLoop = errors.New("pipe would create a loop")
+	// ErrTooManyPipes is returned when a session already feeds the maximum number of pipes
+	ErrTooManyPipes = errors.New("too many pipes")
+)
+
+// ansiPattern matches CSI and OSC escape sequences and other two-byte escapes
+var ansiPattern = regexp.MustCompile(`\x1b(?:\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// sessionPipe is a running forwarder from one session's output to another's input
+type sessionPipe struct {
+	pipe       *types.SessionPipe
+	outputFile string
+	sealer     *transcript.Sealer
+	inputPipe  string
+	filter     *regexp.Regexp
+	position   int64 // Offset into the source's output already handled
+	pending    []byte
+	stopChan   chan struct{}
+}
+
+// CreatePipe starts forwarding new output of a session into another session's input
+func (m *Manager) CreatePipe(sourceID string, req *types.SessionPipeRequest) (*types.SessionPipe, error) {
+	var filter *regexp.Regexp
+	if req.Transform.Filter != "" {
+		var err error
+		if filter, err = regexp.Compile(req.Transform.Filter); err != nil {
+			return nil, fmt.Errorf("%w: bad filter: %v", ErrInvalidPipe, err)
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	source, exists := m.sessions[sourceID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sourceID)
+	}
+	target, exists := m.sessions[req.Target]
+	if !exists {
+		return nil, fmt.Errorf("%w: target session not found", ErrInvalidPipe)
+	}
+	if isEnded(source) || isEnded(target) {
+		return nil, fmt.Errorf("%w: both sessions must be running", ErrInvalidPipe)
+	}
+	if source.OutputFile == "" || target.InputPipe == "" {
+		return nil, fmt.Errorf("%w: sessions do not support piping", ErrInvalidPipe)
+	}
+	if m.pipeReaches(target.ID, source.ID) {
+		return nil, ErrPipeLoop
+	}
+
+	count := 0
+	for _, sp := range m.sessionPipes {
+		if sp.pipe.Source == sourceID {
+			count++
+		}
+	}
+	if count >= maxPipesPerSession {
+		return nil, fmt.Errorf("%w: a session may feed at most %d", ErrTooManyPipes, maxPipesPerSession)
+	}
+
+	// Only output produced from now on is forwarded
+	position, _ := m.sealer.Size(source.OutputFile)
+
+	sp := &sessionPipe{
+		pipe: &types.SessionPipe{
+			ID:        uuid.New().String()[:8],
+			Source:    sourceID,
+			Target:    target.ID,
+			Transform: req.Transform,
+			CreatedAt: time.Now(),
+		},
+		outputFile: source.OutputFile,
+		sealer:     m.sealer,
+		inputPipe:  target.InputPipe,
+		filter:     filter,
+		position:   position,
+		stopChan:   make(chan struct{}),
+	}
+
+	// Pipes count against the goroutine budget of their source
+	scope, err := m.scope(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := scope.spawn(sp.forward); err != nil {
+		return nil, err
+	}
+	m.sessionPipes[sp.pipe.ID] = sp
+
+	logrus.WithFields(logrus.Fields{
+		"pipe_id": sp.pipe.ID,
+		"source":  sourceID,
+		"target":  target.ID,
+	}).Info("Session pipe created")
+
+	return sp.pipe, nil
+}
+
+// ListPipes returns the pipes a session feeds or is fed by
+func (m *Manager) ListPipes(sessionID string) ([]*types.SessionPipe, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, exists := m.sessions[sessionID]; !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	pipes := []*types.SessionPipe{}
+	for _, sp := range m.sessionPipes {
+		if sp.pipe.Source == sessionID || sp.pipe.Target == sessionID {
+			pipes = append(pipes, sp.pipe)
+		}
+	}
+	return pipes, nil
+}
+
+// ClosePipe stops a pipe fed by a session
+func (m *Manager) ClosePipe(sessionID, pipeID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	sp, exists := m.sessionPipes[pipeID]
+	if !exists || sp.pipe.Source != sessionID {
+		return ErrPipeNotFound
+	}
+
+	m.stopPipe(sp)
+	return nil
+}
+
+// closeSessionPipes stops every pipe from or to a session (assumes mutex is held)
+func (m *Manager) closeSessionPipes(sessionID string) {
+	for _, sp := range m.sessionPipes {
+		if sp.pipe.Source == sessionID || sp.pipe.Target == sessionID {
+			m.stopPipe(sp)
+		}
+	}
+}
+
+// stopPipe stops a pipe's forwarder (assumes mutex is held)
+func (m *Manager) stopPipe(sp *sessionPipe) {
+	delete(m.sessionPipes, sp.pipe.ID)
+	close(sp.stopChan)
+
+	logrus.WithFields(logrus.Fields{
+		"pipe_id": sp.pipe.ID,
+		"source":  sp.pipe.Source,
+		"target":  sp.pipe.Target,
+	}).Info("Session pipe closed")
+}
+
+// pipeReaches reports whether output of from already flows into to, directly
+// or through other pipes (assumes mutex is held)
+func (m *Manager) pipeReaches(from, to string) bool {
+	visited := map[string]bool{}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		for _, sp := range m.sessionPipes {
+			if sp.pipe.Source == current {
+				queue = append(queue, sp.pipe.Target)
+			}
+		}
+	}
+	return false
+}
+
+// isEnded reports whether a session has stopped or failed
+func isEnded(session *types.Session) bool {
+	return session.Status == types.SessionStatusStopped || session.Status == types.SessionStatusError
+}
+
+// forward polls the source's output file and writes transformed lines to the
+// target's input pipe until stopped
+func (sp *sessionPipe) forward() {
+	ticker := time.NewTicker(pipePollInterval)
+	defer ticker.Stop()
+
+	var writer *os.File
+	defer func() {
+		if writer != nil {
+			writer.Close()
+		}
+	}()
+
+	logger := logrus.WithField("pipe_id", sp.pipe.ID)
+
+	for {
+		select {
+		case <-sp.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		data, err := sp.readOutput()
+		if err != nil {
+			logger.WithError(err).Warn("Failed to read piped session output")
+			continue
+		}
+
+		out := sp.transform(data)
+		if out == "" {
+			continue
+		}
+
+		// Opening without blocking fails rather than hangs if the target is
+		// not reading; the output is dropped and the next batch tries again
+		if writer == nil {
+			writer, err = os.OpenFile(sp.inputPipe, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+			if err != nil {
+				logger.WithError(err).Warn("Failed to open pipe target input")
+				writer = nil
+				continue
+			}
+		}
+
+		if _, err := writer.WriteString(out); err != nil {
+			logger.WithError(err).Warn("Failed to write to pipe target input")
+			writer.Close()
+			writer = nil
+		}
+	}
+}
+
+// readOutput returns output appended to the source since the last read
+func (sp *sessionPipe) readOutput() ([]byte, error) {
+	size, err := sp.sealer.Size(sp.outputFile)
+	if err != nil {
+		return nil, err
+	}
+	// Start over if the file was truncated to free disk space
+	if size < sp.position {
+		sp.position = 0
+	}
+	if size <= sp.position {
+		return nil, nil
+	}
+
+	data, err := sp.sealer.ReadRange(sp.outputFile, sp.position, size)
+	if err != nil {
+		return nil, err
+	}
+	sp.position += int64(len(data))
+
+	return data, nil
+}
+
+// transform splits output into lines and applies the pipe's transform,
+// holding back a trailing partial line until it is complete
+func (sp *sessionPipe) transform(data []byte) string {
+	sp.pending = append(sp.pending, data...)
+
+	var out strings.Builder
+	for {
+		i := bytes.IndexByte(sp.pending, '\n')
+		if i < 0 {
+			if len(sp.pending) < maxPipeLine {
+				break
+			}
+			// Forward overlong lines rather than buffering without bound
+			i = len(sp.pending)
+		}
+
+		line := string(bytes.TrimRight(sp.pending[:i], "\r"))
+		if i < len(sp.pending) {
+			i++
+		}
+		sp.pending = sp.pending[i:]
+
+		if sp.pipe.Transform.StripANSI {
+			line = ansiPattern.ReplaceAllString(line, "")
+		}
+		if sp.filter != nil && !sp.filter.MatchString(line) {
+			continue
+		}
+
+		out.WriteString(sp.pipe.Transform.Prefix)
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	return out.String()
+}
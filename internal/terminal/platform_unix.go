@@ -0,0 +1,40 @@
+//go:build unix
+
+package terminal
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// makeFIFO creates the named pipe input is written to sessions through
+func makeFIFO(path string) error {
+	return syscall.Mkfifo(path, pipeFileMode)
+}
+
+// signalProcessGroup sends a signal to every process in a group, such as a
+// shell and the jobs it started
+func signalProcessGroup(pgid int, signal syscall.Signal) error {
+	return syscall.Kill(-pgid, signal)
+}
+
+// foregroundProcessGroup asks the terminal which process group is in the
+// foreground. It avoids File.Fd, which would make the PTY blocking.
+func foregroundProcessGroup(ptyFile *os.File) (int, error) {
+	conn, err := ptyFile.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var pgrp int
+	var ioctlErr error
+	if err := conn.Control(func(fd uintptr) {
+		pgrp, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	}); err != nil {
+		return 0, err
+	}
+
+	return pgrp, ioctlErr
+}
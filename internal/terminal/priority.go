@@ -0,0 +1,85 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidPriority is returned for priorities sessions may not use
+var ErrInvalidPriority = errors.New("invalid priority")
+
+// validatePriority checks a priority before it is applied. Sessions may only
+// lower their priority, so that no terminal can starve the rest of the host.
+func validatePriority(priority *types.SessionPriority, backend types.SessionBackend) error {
+	if priority == nil {
+		return nil
+	}
+
+	switch backend {
+	case types.SessionBackendSerial, types.SessionBackendContainer:
+		return fmt.Errorf("%w: not supported for %s sessions", ErrInvalidPriority, backend)
+	}
+
+	if priority.Nice < 0 || priority.Nice > 19 {
+		return fmt.Errorf("%w: nice must be between 0 and 19", ErrInvalidPriority)
+	}
+
+	switch priority.IOClass {
+	case "", "best-effort", "idle":
+	default:
+		return fmt.Errorf("%w: unsupported io class %q", ErrInvalidPriority, priority.IOClass)
+	}
+
+	if priority.IOLevel < 0 || priority.IOLevel > 7 {
+		return fmt.Errorf("%w: io level must be between 0 and 7", ErrInvalidPriority)
+	}
+
+	return nil
+}
+
+// SetSessionPriority changes the CPU and IO priority of a session's processes
+func (m *Manager) SetSessionPriority(sessionID string, priority *types.SessionPriority) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if err := validatePriority(priority, session.Backend); err != nil {
+		return err
+	}
+
+	if !session.IsActive() {
+		return fmt.Errorf("session priority cannot be changed in current state: %s", session.Status)
+	}
+
+	return m.applyPriority(session, priority)
+}
+
+// applyPriority sets the priority of every process in the session started by
+// its shell, including jobs moved into their own process groups
+func (m *Manager) applyPriority(session *types.Session, priority *types.SessionPriority) error {
+	if session.Process == nil || session.Process.Process == nil {
+		return fmt.Errorf("session has no process to prioritize")
+	}
+
+	if err := setSessionPriority(session.Process.Process.Pid, priority); err != nil {
+		return fmt.Errorf("failed to set session priority: %w", err)
+	}
+
+	session.Priority = priority
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"nice":       priority.Nice,
+		"io_class":   priority.IOClass,
+		"io_level":   priority.IOLevel,
+	}).Info("Session priority set")
+
+	return nil
+}
@@ -0,0 +1,116 @@
+//go:build linux
+
+package terminal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set(2) constants
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// setSessionPriority applies a priority to every thread of every process in
+// the terminal session led by leader. Linux keeps nice values per thread.
+func setSessionPriority(leader int, priority *types.SessionPriority) error {
+	pids, err := sessionProcesses(leader)
+	if err != nil {
+		return err
+	}
+
+	var ioprio uintptr
+	switch priority.IOClass {
+	case "best-effort":
+		ioprio = ioprioClassBE<<ioprioClassShift | uintptr(priority.IOLevel)
+	case "idle":
+		ioprio = ioprioClassIdle << ioprioClassShift
+	}
+
+	for _, pid := range pids {
+		tids, err := processThreads(pid)
+		if err != nil {
+			continue // The process exited while we were looking
+		}
+
+		for _, tid := range tids {
+			if err := unix.Setpriority(unix.PRIO_PROCESS, tid, priority.Nice); err != nil && !errors.Is(err, unix.ESRCH) {
+				return err
+			}
+
+			if ioprio != 0 {
+				_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprio)
+				if errno != 0 && errno != unix.ESRCH {
+					return errno
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// sessionProcesses lists the processes whose session ID is leader
+func sessionProcesses(leader int) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+
+		// The command name may contain spaces, so parse after its closing paren:
+		// state ppid pgrp session ...
+		end := strings.LastIndexByte(string(stat), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 4 {
+			continue
+		}
+
+		if sid, err := strconv.Atoi(fields[3]); err == nil && sid == leader {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// processThreads lists the thread IDs of a process
+func processThreads(pid int) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "task"))
+	if err != nil {
+		return nil, err
+	}
+
+	tids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if tid, err := strconv.Atoi(entry.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+
+	return tids, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package terminal
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// setSessionPriority is not implemented on this platform
+func setSessionPriority(_ int, _ *types.SessionPriority) error {
+	return fmt.Errorf("session priority is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,61 @@
+package terminal
+
+import (
+	"fmt"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// resolveProfile merges a named profile into a create request.
+// Profile settings take precedence over values supplied by the client.
+func resolveProfile(req *types.SessionCreateRequest, profiles map[string]*types.Profile) (*types.SessionCreateRequest, *types.Profile, error) {
+	if req.Profile == "" {
+		// The sandbox backend only makes sense with admin-defined limits
+		if req.Backend == types.SessionBackendSandbox {
+			return nil, nil, fmt.Errorf("sandbox backend requires a profile")
+		}
+		return req, nil, nil
+	}
+
+	profile, exists := profiles[req.Profile]
+	if !exists {
+		return nil, nil, fmt.Errorf("profile not found: %s", req.Profile)
+	}
+
+	resolved := *req
+	if profile.Backend != "" {
+		resolved.Backend = profile.Backend
+	}
+	if profile.Shell != "" {
+		resolved.Shell = profile.Shell
+	}
+	if len(profile.Command) > 0 {
+		resolved.Command = profile.Command
+	}
+	if profile.WorkingDir != "" {
+		resolved.WorkingDir = profile.WorkingDir
+	}
+
+	if resolved.Locale == nil {
+		resolved.Locale = profile.Locale
+	}
+	if resolved.Keyboard == nil {
+		resolved.Keyboard = profile.Keyboard
+	}
+	if resolved.Priority == nil {
+		resolved.Priority = profile.Priority
+	}
+
+	if len(profile.Env) > 0 {
+		env := make(map[string]string, len(req.Env)+len(profile.Env))
+		for key, value := range req.Env {
+			env[key] = value
+		}
+		for key, value := range profile.Env {
+			env[key] = value
+		}
+		resolved.Env = env
+	}
+
+	return &resolved, profile, nil
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 
 	"github.com/creack/pty"
 	"github.com/sirupsen/logrus"
@@ -20,6 +21,9 @@ type PTYConfig struct {
 	Command    []string
 	WorkingDir string
 	Env        map[string]string
+
+	// Account the process runs as; nil runs it as the server's user
+	Credential *syscall.Credential
 }
 
 // CreatePTY creates a new PTY with the specified configuration
@@ -38,6 +42,11 @@ func CreatePTY(config *PTYConfig) (*os.File, *exec.Cmd, error) {
 	env := setupEnvironment(config.Env)
 	cmd.Env = env
 
+	// pty.Start adds the new session and controlling terminal to these
+	if config.Credential != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: config.Credential}
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"shell":       shell,
 		"command":     command,
@@ -154,10 +163,19 @@ func resolveWorkingDirectory(workingDir string) string {
 	}
 }
 
+// serverEnvPrefix marks the server's own configuration variables, which
+// may hold secrets such as bearer tokens
+const serverEnvPrefix = "WEBTERM_"
+
 // setupEnvironment prepares the environment variables for the shell
 func setupEnvironment(customEnv map[string]string) []string {
-	// Start with current environment
-	env := os.Environ()
+	// Start with the current environment, minus the server's configuration
+	var env []string
+	for _, envVar := range os.Environ() {
+		if !strings.HasPrefix(envVar, serverEnvPrefix) {
+			env = append(env, envVar)
+		}
+	}
 
 	// Add or override with custom env variables
 	for key, value := range customEnv {
@@ -0,0 +1,90 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// OwnedSessions returns the sessions of a user, including ended ones still
+// kept in memory
+func (m *Manager) OwnedSessions(owner string) []*types.Session {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var sessions []*types.Session
+	for _, session := range m.sessions {
+		if session.Owner == owner {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions
+}
+
+// SessionTranscripts returns the files on disk holding the output of a
+// session and its panes, and its recording
+func (m *Manager) SessionTranscripts(sessionID string) []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return nil
+	}
+	return sessionTranscripts(session)
+}
+
+// PurgeSession ends a session if it is still running, deletes its output
+// and forgets it at once, instead of keeping it for a while after it ends
+func (m *Manager) PurgeSession(sessionID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	// Wait for a termination under way to finish before removing the output
+	if _, terminating := m.terminating[sessionID]; terminating || session.CanTerminate() {
+		m.terminate(session, DetachReasonPurged)
+	}
+
+	// Cleanup removes these, unless it failed or the server was restarted
+	for _, path := range sessionTranscripts(session) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove session output: %w", err)
+		}
+	}
+
+	// Sessions that failed are counted until they are seen to have ended
+	if m.sessionLimiter != nil {
+		m.sessionLimiter.RemoveSession(sessionID)
+	}
+
+	delete(m.sessions, sessionID)
+	logrus.WithField("session_id", sessionID).Info("Session purged")
+	return nil
+}
+
+// sessionTranscripts returns the output files of a session and its panes,
+// and its recording, that exist (assumes mutex is held)
+func sessionTranscripts(session *types.Session) []string {
+	paths := []string{session.OutputFile, session.StderrFile, session.RecordingFile}
+	for _, pane := range session.Panes {
+		paths = append(paths, pane.OutputFile)
+	}
+
+	var transcripts []string
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			transcripts = append(transcripts, path)
+		}
+	}
+	return transcripts
+}
@@ -0,0 +1,125 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/piyushgupta53/webterm/internal/terminal/recording"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotRecorded is returned for the recording of a session not recorded
+var ErrNotRecorded = errors.New("session is not recorded")
+
+// RecordResize records a session's terminal being resized, if it is
+// recorded, and resizes its terminal state if it is kept
+func (m *Manager) RecordResize(sessionID string, rows, cols uint16) {
+	m.mutex.RLock()
+	recorder := m.recorders[sessionID]
+	screen := m.screens[sessionID]
+	m.mutex.RUnlock()
+
+	screen.Resize(rows, cols)
+
+	if err := recorder.Resize(rows, cols); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Warn("Failed to record resize")
+	}
+}
+
+// WriteRecording writes the asciicast recording of a session to w
+func (m *Manager) WriteRecording(w io.Writer, sessionID string) error {
+	m.mutex.RLock()
+	session, exists := m.sessions[sessionID]
+	m.mutex.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.RecordingFile == "" {
+		return ErrNotRecorded
+	}
+
+	_, err := m.sealer.Copy(w, session.RecordingFile)
+	return err
+}
+
+// OpenRecording returns a reader of a session's recording as recorded so far
+func (m *Manager) OpenRecording(sessionID string) (io.ReadCloser, error) {
+	m.mutex.RLock()
+	session, exists := m.sessions[sessionID]
+	m.mutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if session.RecordingFile == "" {
+		return nil, ErrNotRecorded
+	}
+
+	// Decrypted as it is read, rather than all at once
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := m.sealer.Copy(writer, session.RecordingFile)
+		writer.CloseWithError(err)
+	}()
+	return reader, nil
+}
+
+// startRecording creates the recording of a session that asked for one, in
+// the pipes directory (assumes mutex is held)
+func (m *Manager) startRecording(session *types.Session, req *types.SessionCreateRequest) error {
+	if !req.Record {
+		return nil
+	}
+
+	header := recording.Header{
+		Width:     80,
+		Height:    24,
+		Timestamp: session.CreatedAt.Unix(),
+		Env:       map[string]string{"TERM": session.Term},
+	}
+	if req.Shell != "" {
+		header.Env["SHELL"] = req.Shell
+	}
+
+	path := filepath.Join(m.pipeManager.GetPipesDir(), session.ID+".cast")
+	recorder, err := recording.NewRecorder(path, header, req.RecordInput, m.sealer)
+	if err != nil {
+		return err
+	}
+
+	m.recorders[session.ID] = recorder
+	session.Recording = true
+	session.RecordingFile = path
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"input":      req.RecordInput,
+	}).Info("Recording session")
+	return nil
+}
+
+// stopRecording finishes a session's recording, which can still be
+// downloaded until the session is forgotten (assumes mutex is held)
+func (m *Manager) stopRecording(sessionID string) {
+	if recorder, exists := m.recorders[sessionID]; exists {
+		if err := recorder.Close(); err != nil {
+			logrus.WithError(err).WithField("session_id", sessionID).Warn("Failed to close recording")
+		}
+		delete(m.recorders, sessionID)
+	}
+}
+
+// removeRecording deletes a session's recording
+func removeRecording(session *types.Session) {
+	if session.RecordingFile == "" {
+		return
+	}
+	if err := os.Remove(session.RecordingFile); err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).WithField("session_id", session.ID).Error("Failed to remove recording")
+	}
+}
@@ -0,0 +1,187 @@
+// Package recording records session output to asciicast v2 files, which
+// asciinema and its web player can replay
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/piyushgupta53/webterm/internal/transcript"
+)
+
+// ContentType is the media type of asciicast files
+const ContentType = "application/x-asciicast"
+
+// Event codes of asciicast v2
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+// Header is the first line of an asciicast v2 file
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"` // Unix time the recording started
+	Env       map[string]string `json:"env,omitempty"`       // SHELL and TERM
+}
+
+// Recorder appends the events of a session to an asciicast file, sealed if
+// output is encrypted at rest. A nil Recorder records nothing.
+type Recorder struct {
+	mutex  sync.Mutex
+	file   *os.File
+	sealer *transcript.Sealer
+	size   int64 // Bytes of the recording written so far
+	start  time.Time
+	input  bool // Record input as well as output
+	closed bool
+
+	// The start of a UTF-8 character split across writes, held back until
+	// the rest of it arrives
+	partialOutput []byte
+	partialInput  []byte
+}
+
+// NewRecorder creates an asciicast file at path and writes its header. With
+// input, what is typed into the session is recorded too.
+func NewRecorder(path string, header Header, input bool, sealer *transcript.Sealer) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	r := &Recorder{
+		file:   file,
+		sealer: sealer,
+		start:  time.Now(),
+		input:  input,
+	}
+
+	header.Version = 2
+	if header.Timestamp == 0 {
+		header.Timestamp = r.start.Unix()
+	}
+	if err := r.writeLine(header); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return r, nil
+}
+
+// Output records output of the session
+func (r *Recorder) Output(data []byte) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.record(EventOutput, &r.partialOutput, data)
+}
+
+// Input records input to the session, if the recorder records input
+func (r *Recorder) Input(data []byte) error {
+	if r == nil || !r.input {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.record(EventInput, &r.partialInput, data)
+}
+
+// Resize records the terminal being resized
+func (r *Recorder) Resize(rows, cols uint16) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return nil
+	}
+	return r.writeLine(Event{Time: r.elapsed(), Code: EventResize, Data: fmt.Sprintf("%dx%d", cols, rows)})
+}
+
+// Close stops recording. Events recorded afterwards are dropped.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.file.Close()
+}
+
+// record writes an event, holding back a trailing partial character so it is
+// not replaced by U+FFFD (assumes mutex is held)
+func (r *Recorder) record(code string, partial *[]byte, data []byte) error {
+	if r.closed {
+		return nil
+	}
+
+	if len(*partial) > 0 {
+		data = append(*partial, data...)
+		*partial = nil
+	}
+	if cut := incompleteSuffix(data); cut < len(data) {
+		*partial = append([]byte(nil), data[cut:]...)
+		data = data[:cut]
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	return r.writeLine(Event{Time: r.elapsed(), Code: code, Data: string(data)})
+}
+
+// elapsed returns the seconds since recording started, to the microsecond
+func (r *Recorder) elapsed() float64 {
+	return math.Round(time.Since(r.start).Seconds()*1e6) / 1e6
+}
+
+// writeLine appends a value as a line of JSON (assumes mutex is held)
+func (r *Recorder) writeLine(value interface{}) error {
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+
+	if _, err := r.file.Write(r.sealer.Seal(r.size, line.Bytes())); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	r.size += int64(line.Len())
+	return nil
+}
+
+// incompleteSuffix returns where a UTF-8 character cut off at the end of data
+// starts, or len(data) if there is none
+func incompleteSuffix(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,32 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrResizeUnavailable is returned for sessions without a terminal to resize
+var ErrResizeUnavailable = errors.New("only running sessions with a pty can be resized")
+
+// ResizeSession sets the window size of a session's PTY and records it
+func (m *Manager) ResizeSession(sessionID string, rows, cols uint16) error {
+	m.mutex.RLock()
+	session, exists := m.sessions[sessionID]
+	m.mutex.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	// Commands run without a PTY have no window size
+	if session.PTY == nil || !session.HasPTY() || !session.IsActive() {
+		return ErrResizeUnavailable
+	}
+
+	if err := SetPTYSize(session.PTY, rows, cols); err != nil {
+		return fmt.Errorf("failed to resize pty: %w", err)
+	}
+
+	m.RecordResize(sessionID, rows, cols)
+	return nil
+}
@@ -0,0 +1,38 @@
+package terminal
+
+import (
+	"fmt"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// GetSessionResources reports a session's live I/O and process usage. Process
+// figures are only available for sessions whose processes run on the host.
+func (m *Manager) GetSessionResources(sessionID string) (*types.SessionResources, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	resources := &types.SessionResources{
+		Goroutines: m.sessionGoroutines(sessionID),
+	}
+
+	if runner, exists := m.sessionRunners[sessionID]; exists {
+		resources.BytesIn = runner.GetBytesWritten()
+		resources.BytesOut = runner.GetBytesRead()
+		resources.OutputDropped = runner.GetOutputDropped()
+	}
+
+	if session.Container == "" && session.Process != nil && session.Process.Process != nil && session.IsActive() {
+		if err := readSessionUsage(session.Process.Process.Pid, resources); err != nil {
+			logrus.WithError(err).WithField("session_id", sessionID).Debug("Failed to read session process usage")
+		}
+	}
+
+	return resources, nil
+}
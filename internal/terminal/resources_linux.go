@@ -0,0 +1,55 @@
+//go:build linux
+
+package terminal
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc, which is 100 on
+// every architecture Linux supports
+const clockTicks = 100
+
+// readSessionUsage sums CPU time and resident memory over the processes in
+// the terminal session led by leader
+func readSessionUsage(leader int, resources *types.SessionResources) error {
+	pids, err := sessionProcesses(leader)
+	if err != nil {
+		return err
+	}
+
+	pageSize := int64(os.Getpagesize())
+
+	for _, pid := range pids {
+		stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+		if err != nil {
+			continue // The process exited while we were looking
+		}
+
+		// Fields after the command name: utime and stime are the 12th and
+		// 13th, rss (in pages) the 22nd
+		end := strings.LastIndexByte(string(stat), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 22 {
+			continue
+		}
+
+		utime, _ := strconv.ParseInt(fields[11], 10, 64)
+		stime, _ := strconv.ParseInt(fields[12], 10, 64)
+		rss, _ := strconv.ParseInt(fields[21], 10, 64)
+
+		resources.Processes++
+		resources.CPUSeconds += float64(utime+stime) / clockTicks
+		resources.MemoryBytes += rss * pageSize
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package terminal
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// readSessionUsage is not implemented on this platform
+func readSessionUsage(_ int, _ *types.SessionResources) error {
+	return fmt.Errorf("process usage is not supported on %s", runtime.GOOS)
+}
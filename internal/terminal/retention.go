@@ -0,0 +1,143 @@
+package terminal
+
+import (
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultTerminatedRetention is how long terminated sessions stay listed
+	DefaultTerminatedRetention = 30 * time.Second
+	// DefaultExitedRetention is how long sessions whose shell exited stay listed
+	DefaultExitedRetention = 5 * time.Minute
+
+	// idleSessionTimeout is how long a running session may go without
+	// activity before it is terminated
+	idleSessionTimeout = 30 * time.Minute
+
+	// reapInterval is how often ended and idle sessions are looked for, and
+	// so how late past its retention an ended session may be removed
+	reapInterval = 10 * time.Second
+)
+
+// RetentionPolicy sets how long sessions stay listed after they end, so
+// clients can still read their exit code, output and recording. Retained
+// sessions are removed once their time is up.
+type RetentionPolicy struct {
+	Terminated time.Duration // Ended by a user, an admin, their wall-clock limit or idleness
+	Exited     time.Duration // Ended by their shell or command exiting, or failing to start
+}
+
+// DefaultRetentionPolicy returns the retention used unless configured otherwise
+func DefaultRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		Terminated: DefaultTerminatedRetention,
+		Exited:     DefaultExitedRetention,
+	}
+}
+
+// SetRetentionPolicy sets how long ended sessions stay listed. It must be
+// called before sessions are created.
+func (m *Manager) SetRetentionPolicy(policy RetentionPolicy) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.retention = policy
+}
+
+// markEnded records when a session ended and until when it is retained,
+// and stops counting it against the session limits. Only the first call
+// counts, so a session terminated by the server keeps the retention of
+// terminated sessions when its shell exits in turn.
+func (m *Manager) markEnded(session *types.Session, retention time.Duration) {
+	m.endedMutex.Lock()
+	defer m.endedMutex.Unlock()
+
+	if session.EndedAt != nil {
+		return
+	}
+
+	ended := time.Now()
+	until := ended.Add(retention)
+	session.EndedAt = &ended
+	session.RetainedUntil = &until
+
+	if m.sessionLimiter != nil {
+		m.sessionLimiter.RemoveSession(session.ID)
+	}
+}
+
+// retainedUntil returns when an ended session is removed, or false if it
+// has not ended
+func (m *Manager) retainedUntil(session *types.Session) (time.Time, bool) {
+	m.endedMutex.Lock()
+	defer m.endedMutex.Unlock()
+
+	if session.RetainedUntil == nil {
+		return time.Time{}, false
+	}
+	return *session.RetainedUntil, true
+}
+
+// reapSessions periodically terminates idle sessions and removes ended
+// ones whose retention is up
+func (m *Manager) reapSessions() {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.reapOnce(time.Now())
+		case <-m.stopChan:
+			return
+		}
+	}
+}
+
+// reapOnce makes one pass over the sessions
+func (m *Manager) reapOnce(now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var idle []*types.Session
+	for sessionID, session := range m.sessions {
+		until, ended := m.retainedUntil(session)
+
+		// Sessions failing without a runner to report it are retained from
+		// when they are first seen
+		if !ended && (session.Status == types.SessionStatusStopped || session.Status == types.SessionStatusError) {
+			m.markEnded(session, m.retention.Exited)
+			continue
+		}
+
+		if !ended {
+			if session.CanTerminate() && now.Sub(session.LastActiveAt) > idleSessionTimeout {
+				idle = append(idle, session)
+			}
+			continue
+		}
+
+		if now.Before(until) {
+			continue
+		}
+
+		// Sessions whose shell exited still hold their runner and pipes
+		if _, exists := m.sessionRunners[sessionID]; exists {
+			m.cleanupSession(sessionID)
+		}
+		delete(m.sessions, sessionID)
+		removeRecording(session)
+		logrus.WithField("session_id", sessionID).Info("Removed ended session after its retention")
+	}
+
+	// Terminating releases the mutex while clients are detached, so it waits
+	// until the sessions are no longer being iterated
+	for _, session := range idle {
+		if session.CanTerminate() {
+			logrus.WithField("session_id", session.ID).Info("Terminating idle session")
+			m.terminate(session, DetachReasonIdle)
+		}
+	}
+}
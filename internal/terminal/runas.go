@@ -0,0 +1,169 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoAccount is returned when a session has no system account to run as
+var ErrNoAccount = errors.New("no account to run the session as")
+
+// minAccountUID is the lowest UID sessions may run as. Lower ones, root
+// included, belong to the system and its services.
+const minAccountUID = 1000
+
+// RunAsPolicy chooses the system account the processes of sessions on the
+// pty backend, and of their panes, run as. Sandboxes and containers isolate
+// their sessions themselves, and their runtimes keep running as the
+// server's user.
+type RunAsPolicy struct {
+	User    string // Account every session runs as, by name or UID; empty runs them as the server's user
+	Group   string // Group sessions run as, by name or GID; empty uses the account's primary group
+	AsOwner bool   // Run each session as the account named like its owner instead
+}
+
+// account is a system account processes are started as
+type account struct {
+	name       string
+	home       string
+	credential *syscall.Credential // nil when it is the server's own account
+}
+
+// SetRunAsPolicy sets the account sessions run as, checking that a fixed
+// account exists
+func (m *Manager) SetRunAsPolicy(policy RunAsPolicy) error {
+	if policy.User != "" && !policy.AsOwner {
+		if _, err := lookupAccount(policy.User, policy.Group, true); err != nil {
+			return err
+		}
+	}
+	if (policy.User != "" || policy.AsOwner) && os.Geteuid() != 0 {
+		logrus.Warn("Sessions are to run as another account, which needs the server to run as root or with CAP_SETUID and CAP_SETGID")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.runAsPolicy = policy
+	return nil
+}
+
+// resolveRunAs returns the name of the account a new session runs as, or
+// empty for the server's user. Owners are looked up by name only, so an
+// owner named like a UID does not run as that UID's account. (assumes mutex
+// is held)
+func (m *Manager) resolveRunAs(owner string, backend types.SessionBackend) (string, error) {
+	if backend != types.SessionBackendPTY {
+		return "", nil
+	}
+
+	if !m.runAsPolicy.AsOwner {
+		return m.runAsPolicy.User, nil
+	}
+
+	acct, err := lookupAccount(owner, m.runAsPolicy.Group, false)
+	if err != nil {
+		return "", err
+	}
+	return acct.name, nil
+}
+
+// sessionAccount returns the account a session's processes run as, nil for
+// the server's user. The policy is set before sessions are created, so this
+// needs no mutex.
+func (m *Manager) sessionAccount(session *types.Session) (*account, error) {
+	if session.RunAs == "" {
+		return nil, nil
+	}
+	return lookupAccount(session.RunAs, m.runAsPolicy.Group, !m.runAsPolicy.AsOwner)
+}
+
+// lookupAccount resolves a system account by name, or by UID as well when
+// byUID is set, running with its primary group or the given one and its
+// supplementary groups. Root and system accounts are refused.
+func lookupAccount(name, group string, byUID bool) (*account, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		if _, numeric := strconv.Atoi(name); numeric != nil || !byUID {
+			return nil, fmt.Errorf("%w: no system account %s", ErrNoAccount, name)
+		}
+		if u, err = user.LookupId(name); err != nil {
+			return nil, fmt.Errorf("%w: no system account with UID %s", ErrNoAccount, name)
+		}
+	}
+
+	gid := u.Gid
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return nil, fmt.Errorf("%w: no group %s", ErrNoAccount, group)
+			}
+		}
+		gid = g.Gid
+	}
+
+	uid64, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: account %s has UID %s", ErrNoAccount, u.Username, u.Uid)
+	}
+	if uid64 < minAccountUID {
+		return nil, fmt.Errorf("%w: sessions may not run as system account %s with UID %d", ErrNoAccount, u.Username, uid64)
+	}
+	gid64, err := strconv.ParseUint(gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: account %s has GID %s", ErrNoAccount, u.Username, gid)
+	}
+
+	acct := &account{name: u.Username, home: u.HomeDir}
+	if int(uid64) == os.Geteuid() && int(gid64) == os.Getegid() {
+		return acct, nil
+	}
+
+	var groups []uint32
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if n, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(n))
+			}
+		}
+	}
+
+	acct.credential = &syscall.Credential{Uid: uint32(uid64), Gid: uint32(gid64), Groups: groups}
+	return acct, nil
+}
+
+// apply makes a process started with config run as the account, in its
+// home directory, or / without one, unless another was asked for, and with
+// the variables naming it unless the client set them
+func (a *account) apply(config *PTYConfig) {
+	if a == nil {
+		return
+	}
+
+	config.Credential = a.credential
+	if config.WorkingDir == "" {
+		// Not the server user's home, which the account may not enter
+		config.WorkingDir = "/"
+		if stat, err := os.Stat(a.home); err == nil && stat.IsDir() {
+			config.WorkingDir = a.home
+		}
+	}
+
+	env := make(map[string]string, len(config.Env)+3)
+	for name, value := range config.Env {
+		env[name] = value
+	}
+	for name, value := range map[string]string{"HOME": a.home, "USER": a.name, "LOGNAME": a.name} {
+		if _, exists := env[name]; !exists {
+			env[name] = value
+		}
+	}
+	config.Env = env
+}
@@ -0,0 +1,79 @@
+package terminal
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// buildSandboxCommand wraps a command so that it runs inside the configured sandbox
+func buildSandboxCommand(opts *types.SandboxOptions, command []string) ([]string, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("profile has no sandbox options")
+	}
+
+	switch opts.Runtime {
+	case types.SandboxRuntimeGVisor:
+		if len(command) == 0 {
+			command = []string{"/bin/sh", "-i"}
+		}
+
+		runtimePath, err := resolveSandboxRuntime(opts.RuntimePath, "runsc")
+		if err != nil {
+			return nil, err
+		}
+
+		network := "none"
+		if opts.Network {
+			network = "host"
+		}
+
+		args := []string{runtimePath, "--rootless", "--network=" + network}
+		if opts.Limits.MaxMemoryMB > 0 {
+			args = append(args, fmt.Sprintf("--total-memory=%d", opts.Limits.MaxMemoryMB*1024*1024))
+		}
+
+		args = append(args, "do")
+		return append(args, command...), nil
+
+	case types.SandboxRuntimeWASI:
+		if opts.Module == "" {
+			return nil, fmt.Errorf("wasi sandbox requires a module")
+		}
+
+		runtimePath, err := resolveSandboxRuntime(opts.RuntimePath, "wasmtime")
+		if err != nil {
+			return nil, err
+		}
+
+		args := []string{runtimePath, "run"}
+		if opts.Limits.MaxMemoryMB > 0 {
+			args = append(args, "-W", fmt.Sprintf("max-memory-size=%d", opts.Limits.MaxMemoryMB*1024*1024))
+		}
+		if opts.Network {
+			args = append(args, "-S", "inherit-network")
+		}
+
+		// Arguments after the module are passed to the WebAssembly program
+		args = append(args, opts.Module)
+		return append(args, command...), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported sandbox runtime: %s", opts.Runtime)
+	}
+}
+
+// resolveSandboxRuntime locates the sandbox runtime binary
+func resolveSandboxRuntime(configured, fallback string) (string, error) {
+	name := configured
+	if name == "" {
+		name = fallback
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("sandbox runtime not found: %s", name)
+	}
+	return path, nil
+}
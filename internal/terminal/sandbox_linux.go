@@ -0,0 +1,39 @@
+//go:build linux
+
+package terminal
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// withSandboxLimits wraps a sandbox command in prlimit, so that its resource
+// limits are in place before the runtime is executed rather than set on it
+// once it is already running. Memory is limited by the runtime itself, since
+// both runsc and wasmtime reserve far more address space than they use.
+func withSandboxLimits(command []string, limits types.SandboxLimits) ([]string, error) {
+	var args []string
+	if limits.MaxCPUSeconds > 0 {
+		args = append(args, fmt.Sprintf("--cpu=%d", limits.MaxCPUSeconds))
+	}
+	if limits.MaxProcesses > 0 {
+		args = append(args, fmt.Sprintf("--nproc=%d", limits.MaxProcesses))
+	}
+	if limits.MaxFileSizeMB > 0 {
+		args = append(args, fmt.Sprintf("--fsize=%d", uint64(limits.MaxFileSizeMB)*1024*1024))
+	}
+	if len(args) == 0 {
+		return command, nil
+	}
+
+	prlimit, err := exec.LookPath("prlimit")
+	if err != nil {
+		return nil, fmt.Errorf("sandbox limits require prlimit from util-linux: %w", err)
+	}
+
+	wrapped := append([]string{prlimit}, args...)
+	wrapped = append(wrapped, "--")
+	return append(wrapped, command...), nil
+}
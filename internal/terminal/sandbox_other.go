@@ -0,0 +1,18 @@
+//go:build !linux
+
+package terminal
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// withSandboxLimits is not implemented on this platform
+func withSandboxLimits(command []string, limits types.SandboxLimits) ([]string, error) {
+	if limits.MaxCPUSeconds == 0 && limits.MaxProcesses == 0 && limits.MaxFileSizeMB == 0 {
+		return command, nil
+	}
+	return nil, fmt.Errorf("sandbox limits are not supported on %s", runtime.GOOS)
+}
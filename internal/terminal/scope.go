@@ -0,0 +1,104 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxSessionGoroutines bounds the goroutines running for one session,
+// including those of its panes and pipes
+const maxSessionGoroutines = 64
+
+var (
+	// ErrGoroutineBudget is returned when a session already runs as many
+	// goroutines as it may
+	ErrGoroutineBudget = errors.New("session goroutine budget exhausted")
+	// ErrSessionEnded is returned when starting work for a session that has
+	// been terminated
+	ErrSessionEnded = errors.New("session has ended")
+)
+
+// sessionScope is the lifetime of a session. Its context is cancelled when
+// the session is terminated, and it counts the goroutines working for it.
+type sessionScope struct {
+	sessionID  string
+	ctx        context.Context
+	cancel     context.CancelFunc
+	goroutines int32 // atomic
+}
+
+// spawn runs fn on a new goroutine counted against the session's budget
+func (s *sessionScope) spawn(fn func()) error {
+	if s.ctx.Err() != nil {
+		return ErrSessionEnded
+	}
+
+	if atomic.AddInt32(&s.goroutines, 1) > maxSessionGoroutines {
+		atomic.AddInt32(&s.goroutines, -1)
+		logrus.WithField("session_id", s.sessionID).Warn("Session goroutine budget exhausted")
+		return fmt.Errorf("%w: at most %d", ErrGoroutineBudget, maxSessionGoroutines)
+	}
+
+	go func() {
+		defer atomic.AddInt32(&s.goroutines, -1)
+		fn()
+	}()
+	return nil
+}
+
+// openScope starts the lifetime of a session
+func (m *Manager) openScope(sessionID string) *sessionScope {
+	m.scopesMutex.Lock()
+	defer m.scopesMutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scope := &sessionScope{sessionID: sessionID, ctx: ctx, cancel: cancel}
+	m.scopes[sessionID] = scope
+	return scope
+}
+
+// closeScope cancels the context of a session, telling its goroutines to exit
+func (m *Manager) closeScope(sessionID string) {
+	m.scopesMutex.Lock()
+	defer m.scopesMutex.Unlock()
+
+	if scope, exists := m.scopes[sessionID]; exists {
+		scope.cancel()
+		delete(m.scopes, sessionID)
+	}
+}
+
+// scope returns the lifetime of a running session
+func (m *Manager) scope(sessionID string) (*sessionScope, error) {
+	m.scopesMutex.Lock()
+	defer m.scopesMutex.Unlock()
+
+	scope, exists := m.scopes[sessionID]
+	if !exists {
+		return nil, ErrSessionEnded
+	}
+	return scope, nil
+}
+
+// SessionContext returns a context that is cancelled when a session is
+// terminated
+func (m *Manager) SessionContext(sessionID string) (context.Context, error) {
+	scope, err := m.scope(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return scope.ctx, nil
+}
+
+// sessionGoroutines returns how many goroutines are working for a session
+func (m *Manager) sessionGoroutines(sessionID string) int {
+	scope, err := m.scope(sessionID)
+	if err != nil {
+		return 0
+	}
+	return int(atomic.LoadInt32(&scope.goroutines))
+}
@@ -0,0 +1,407 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/hinshun/vt10x"
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// ErrNoScreen is returned for the screen of a session whose terminal state
+// is not kept
+var ErrNoScreen = errors.New("terminal state is only kept for pty sessions on servers with WEBTERM_TERMINAL_STATE")
+
+// Glyph attributes, as vt10x sets them
+const (
+	glyphReverse = 1 << iota
+	glyphUnderline
+	glyphBold
+	_ // Line drawing
+	glyphItalic
+	glyphBlink
+)
+
+// Screen is the state of a session's terminal, kept by emulating it as
+// output is written. A nil Screen keeps nothing.
+type Screen struct {
+	mutex   sync.Mutex
+	vt      vt10x.Terminal
+	partial []byte // Incomplete UTF-8 character at the end of the last write
+}
+
+// newScreen creates the state of a terminal of the default size
+func newScreen() *Screen {
+	return &Screen{vt: vt10x.New(vt10x.WithSize(80, 24))}
+}
+
+// SetTerminalState sets whether the state of the terminals of PTY sessions
+// is kept on the server. It must be called before sessions are created.
+func (m *Manager) SetTerminalState(enabled bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.terminalState = enabled
+}
+
+// startScreen starts keeping the state of a session's terminal, if enabled
+// (assumes mutex is held)
+func (m *Manager) startScreen(session *types.Session) {
+	if m.terminalState && session.HasPTY() {
+		m.screens[session.ID] = newScreen()
+	}
+}
+
+// Screen returns the state of a session's terminal
+func (m *Manager) Screen(sessionID string) (*Screen, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, exists := m.sessions[sessionID]; !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	screen := m.screens[sessionID]
+	if screen == nil {
+		return nil, ErrNoScreen
+	}
+	return screen, nil
+}
+
+// Write feeds output of the session to the emulator
+func (s *Screen) Write(data []byte) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.partial) > 0 {
+		data = append(s.partial, data...)
+		s.partial = nil
+	}
+
+	// A character split across writes is held back until it is whole
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				s.partial = append([]byte(nil), data[i:]...)
+				data = data[:i]
+			}
+			break
+		}
+	}
+
+	s.vt.Write(data)
+}
+
+// Resize changes the size of the terminal
+func (s *Screen) Resize(rows, cols uint16) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vt.Resize(int(cols), int(rows))
+}
+
+// ScreenFrame is the contents of a terminal at one point, each row rendered
+// as the text and escape sequences that draw it
+type ScreenFrame struct {
+	Rows          int
+	Cols          int
+	Lines         []string
+	CursorRow     int
+	CursorCol     int
+	CursorVisible bool
+	AltScreen     bool
+
+	cells [][]vt10x.Glyph
+}
+
+// Frame captures the contents of the terminal
+func (s *Screen) Frame() *ScreenFrame {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cols, rows := s.vt.Size()
+	cursor := s.vt.Cursor()
+	frame := &ScreenFrame{
+		Rows:          rows,
+		Cols:          cols,
+		Lines:         make([]string, rows),
+		CursorRow:     cursor.Y,
+		CursorCol:     cursor.X,
+		CursorVisible: s.vt.CursorVisible(),
+		AltScreen:     s.vt.Mode()&vt10x.ModeAltScreen != 0,
+		cells:         make([][]vt10x.Glyph, rows),
+	}
+
+	var line strings.Builder
+	for y := 0; y < rows; y++ {
+		frame.cells[y] = make([]vt10x.Glyph, cols)
+		for x := range frame.cells[y] {
+			frame.cells[y][x] = s.vt.Cell(x, y)
+		}
+
+		// Trailing blanks are left to the erase before the row is drawn
+		width := cols
+		for width > 0 && blank(frame.cells[y][width-1]) {
+			width--
+		}
+
+		line.Reset()
+		var current vt10x.Glyph
+		for x := 0; x < width; x++ {
+			cell := frame.cells[y][x]
+			if x == 0 || cell.FG != current.FG || cell.BG != current.BG || cell.Mode != current.Mode {
+				line.WriteString(sgr(cell))
+				current = cell
+			}
+			line.WriteRune(glyphChar(cell))
+		}
+		if width > 0 {
+			line.WriteString("\x1b[0m")
+		}
+		frame.Lines[y] = line.String()
+	}
+
+	return frame
+}
+
+// Snapshot returns the contents of the terminal as text, and the attributes
+// of each cell if cells is set
+func (s *Screen) Snapshot(cells bool) *types.ScreenSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cols, rows := s.vt.Size()
+	cursor := s.vt.Cursor()
+	snapshot := &types.ScreenSnapshot{
+		Rows:          rows,
+		Cols:          cols,
+		Lines:         make([]string, rows),
+		CursorRow:     cursor.Y,
+		CursorCol:     cursor.X,
+		CursorVisible: s.vt.CursorVisible(),
+		AltScreen:     s.vt.Mode()&vt10x.ModeAltScreen != 0,
+		Title:         s.vt.Title(),
+	}
+	if cells {
+		snapshot.Cells = make([][]types.ScreenCell, rows)
+	}
+
+	var line strings.Builder
+	for y := 0; y < rows; y++ {
+		line.Reset()
+		for x := 0; x < cols; x++ {
+			cell := s.vt.Cell(x, y)
+			line.WriteRune(glyphChar(cell))
+
+			if cells {
+				snapshot.Cells[y] = append(snapshot.Cells[y], types.ScreenCell{
+					Char:        string(glyphChar(cell)),
+					ScreenStyle: glyphStyle(cell),
+				})
+			}
+		}
+		snapshot.Lines[y] = strings.TrimRight(line.String(), " ")
+	}
+
+	return snapshot
+}
+
+// Render returns the escape sequences that draw the whole frame on a
+// terminal, whatever it showed before
+func (f *ScreenFrame) Render() []byte {
+	var out strings.Builder
+	if f.AltScreen {
+		out.WriteString("\x1b[?1049h")
+	} else {
+		out.WriteString("\x1b[?1049l")
+	}
+	out.WriteString("\x1b[H\x1b[2J")
+	for y, line := range f.Lines {
+		if line != "" {
+			f.drawLine(&out, y, line)
+		}
+	}
+	f.drawCursor(&out)
+	return []byte(out.String())
+}
+
+// Diff returns the escape sequences that turn a terminal showing prev into
+// one showing the frame, redrawing only the rows that changed. It returns
+// nil if nothing changed, and the whole frame if there is no prev or the
+// terminal was resized or switched screens.
+func (f *ScreenFrame) Diff(prev *ScreenFrame) []byte {
+	if prev == nil || prev.Rows != f.Rows || prev.Cols != f.Cols || prev.AltScreen != f.AltScreen {
+		return f.Render()
+	}
+
+	var out strings.Builder
+	for y, line := range f.Lines {
+		if line != prev.Lines[y] {
+			f.drawLine(&out, y, line)
+		}
+	}
+	if out.Len() == 0 && !f.CursorMoved(prev) {
+		return nil
+	}
+	f.drawCursor(&out)
+	return []byte(out.String())
+}
+
+// CellDiff returns the runs of cells that turn a screen showing prev into
+// one showing the frame. With reset, the screen is to be cleared first, and
+// the runs draw the whole frame; that is the case if there is no prev or the
+// terminal was resized or switched screens.
+func (f *ScreenFrame) CellDiff(prev *ScreenFrame) (spans []types.ScreenSpan, reset bool) {
+	reset = prev == nil || prev.Rows != f.Rows || prev.Cols != f.Cols || prev.AltScreen != f.AltScreen
+
+	for y, row := range f.cells {
+		var span *types.ScreenSpan
+		var spanStyle vt10x.Glyph
+		spanEnd := 0
+
+		// A cleared screen needs no trailing blanks drawn
+		width := len(row)
+		for reset && width > 0 && blank(row[width-1]) {
+			width--
+		}
+
+		for x, cell := range row[:width] {
+			if !reset && sameGlyph(cell, prev.cells[y][x]) {
+				span = nil
+				continue
+			}
+
+			if span == nil || spanEnd != x || !sameStyle(cell, spanStyle) {
+				spans = append(spans, types.ScreenSpan{Row: y, Col: x, ScreenStyle: glyphStyle(cell)})
+				span = &spans[len(spans)-1]
+				spanStyle = cell
+			}
+			span.Text += string(glyphChar(cell))
+			spanEnd = x + 1
+		}
+	}
+
+	return spans, reset
+}
+
+// CursorMoved reports whether the cursor is elsewhere than in prev
+func (f *ScreenFrame) CursorMoved(prev *ScreenFrame) bool {
+	return prev == nil || f.CursorRow != prev.CursorRow || f.CursorCol != prev.CursorCol || f.CursorVisible != prev.CursorVisible
+}
+
+// drawLine erases a row and draws a line on it
+func (f *ScreenFrame) drawLine(out *strings.Builder, y int, line string) {
+	fmt.Fprintf(out, "\x1b[%d;1H\x1b[0m\x1b[2K%s", y+1, line)
+}
+
+// drawCursor moves the cursor to where the frame has it
+func (f *ScreenFrame) drawCursor(out *strings.Builder) {
+	fmt.Fprintf(out, "\x1b[%d;%dH", f.CursorRow+1, f.CursorCol+1)
+	if f.CursorVisible {
+		out.WriteString("\x1b[?25h")
+	} else {
+		out.WriteString("\x1b[?25l")
+	}
+}
+
+// glyphChar returns the character of a cell, blank if it has none
+func glyphChar(cell vt10x.Glyph) rune {
+	if cell.Char == 0 {
+		return ' '
+	}
+	return cell.Char
+}
+
+// blank reports whether a cell shows nothing
+func blank(cell vt10x.Glyph) bool {
+	return glyphChar(cell) == ' ' && cell.BG == vt10x.DefaultBG && cell.Mode&(glyphReverse|glyphUnderline) == 0
+}
+
+// sameGlyph reports whether two cells look the same
+func sameGlyph(a, b vt10x.Glyph) bool {
+	return glyphChar(a) == glyphChar(b) && sameStyle(a, b)
+}
+
+// sameStyle reports whether two cells have the same attributes
+func sameStyle(a, b vt10x.Glyph) bool {
+	return a.FG == b.FG && a.BG == b.BG && a.Mode == b.Mode
+}
+
+// glyphStyle returns the attributes of a cell
+func glyphStyle(cell vt10x.Glyph) types.ScreenStyle {
+	return types.ScreenStyle{
+		FG:        colorName(cell.FG),
+		BG:        colorName(cell.BG),
+		Bold:      cell.Mode&glyphBold != 0,
+		Italic:    cell.Mode&glyphItalic != 0,
+		Underline: cell.Mode&glyphUnderline != 0,
+		Reverse:   cell.Mode&glyphReverse != 0,
+		Blink:     cell.Mode&glyphBlink != 0,
+	}
+}
+
+// sgr returns the escape sequence selecting the attributes of a cell
+func sgr(cell vt10x.Glyph) string {
+	params := []string{"0"}
+	for _, attr := range []struct {
+		mode  int16
+		param string
+	}{
+		{glyphBold, "1"},
+		{glyphItalic, "3"},
+		{glyphUnderline, "4"},
+		{glyphBlink, "5"},
+		{glyphReverse, "7"},
+	} {
+		if cell.Mode&attr.mode != 0 {
+			params = append(params, attr.param)
+		}
+	}
+
+	if color := colorParams(cell.FG, 30, 90, 38); color != "" {
+		params = append(params, color)
+	}
+	if color := colorParams(cell.BG, 40, 100, 48); color != "" {
+		params = append(params, color)
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// colorParams returns the SGR parameters selecting a color, given the bases
+// of the standard and bright colors and the extended color parameter
+func colorParams(color vt10x.Color, base, brightBase, extended int) string {
+	switch {
+	case color < 8:
+		return strconv.Itoa(base + int(color))
+	case color < 16:
+		return strconv.Itoa(brightBase + int(color) - 8)
+	case color < 256:
+		return fmt.Sprintf("%d;5;%d", extended, color)
+	case color < 1<<24:
+		return fmt.Sprintf("%d;2;%d;%d;%d", extended, color>>16&0xff, color>>8&0xff, color&0xff)
+	default:
+		return "" // The terminal's default color
+	}
+}
+
+// colorName names a color as a palette index or #rrggbb, or empty for the
+// terminal's default color
+func colorName(color vt10x.Color) string {
+	switch {
+	case color < 256:
+		return strconv.Itoa(int(color))
+	case color < 1<<24:
+		return fmt.Sprintf("#%06x", uint32(color))
+	default:
+		return ""
+	}
+}
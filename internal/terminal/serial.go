@@ -0,0 +1,75 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultBaudRate is used when a serial session does not specify a baud rate
+const DefaultBaudRate = 115200
+
+// DefaultSerialDevices lists the device patterns that may be opened by default
+var DefaultSerialDevices = []string{"/dev/ttyUSB*", "/dev/ttyACM*", "/dev/ttyS*"}
+
+// SerialConfig holds configuration for opening a serial device
+type SerialConfig struct {
+	Device   string
+	BaudRate int
+}
+
+// OpenSerial opens a serial device in raw mode with the configured baud rate
+func OpenSerial(config *SerialConfig, allowedDevices []string) (*os.File, error) {
+	if config.Device == "" {
+		return nil, fmt.Errorf("serial device is required")
+	}
+
+	if config.BaudRate == 0 {
+		config.BaudRate = DefaultBaudRate
+	}
+
+	if !isDeviceAllowed(config.Device, allowedDevices) {
+		return nil, fmt.Errorf("serial device not allowed: %s", config.Device)
+	}
+
+	// Only character devices can be serial ports
+	stat, err := os.Stat(config.Device)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat serial device: %w", err)
+	}
+	if stat.Mode()&os.ModeCharDevice == 0 {
+		return nil, fmt.Errorf("not a character device: %s", config.Device)
+	}
+
+	// O_NOCTTY keeps the device from becoming our controlling terminal
+	port, err := os.OpenFile(config.Device, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open serial device: %w", err)
+	}
+
+	if err := configureSerialPort(port, config.BaudRate); err != nil {
+		port.Close()
+		return nil, fmt.Errorf("failed to configure serial device: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"device":    config.Device,
+		"baud_rate": config.BaudRate,
+	}).Info("Serial device opened successfully")
+
+	return port, nil
+}
+
+// isDeviceAllowed checks the device path against the allowed patterns
+func isDeviceAllowed(device string, allowedDevices []string) bool {
+	device = filepath.Clean(device)
+	for _, pattern := range allowedDevices {
+		if matched, err := filepath.Match(pattern, device); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
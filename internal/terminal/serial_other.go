@@ -0,0 +1,14 @@
+//go:build !linux
+
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// configureSerialPort is not implemented on this platform
+func configureSerialPort(_ *os.File, _ int) error {
+	return fmt.Errorf("serial backend is not supported on %s", runtime.GOOS)
+}
@@ -7,23 +7,45 @@ import (
 	"os"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/piyushgupta53/webterm/internal/logging"
 	"github.com/piyushgupta53/webterm/internal/performance"
+	"github.com/piyushgupta53/webterm/internal/terminal/recording"
+	"github.com/piyushgupta53/webterm/internal/transcript"
 	"github.com/piyushgupta53/webterm/internal/types"
 	"github.com/sirupsen/logrus"
 )
 
+// readBufferSize is the size of the pooled buffers the output bridges read
+// into; larger buffers are allocated for sessions that ask for them
+const readBufferSize = DefaultOutputBufferSize
+
+// readBuffers is shared by the output bridges of all sessions
+var readBuffers = performance.NewBufferPool(readBufferSize)
+
 // SessionRunner handles individual session operations with enhanced features
 type SessionRunner struct {
 	session     *types.Session
 	pipeManager *PipeManager
+	scope       *sessionScope // Lifetime and goroutine budget of the session
 	stopChan    chan struct{}
 	stopped     int32 // atomic for thread safety
 	wg          sync.WaitGroup
 
-	// Performance optimizations
-	outputBuffer *performance.OutputBuffer
+	// Told of output as it is written; nil when nothing relays it
+	outputHandler func(chunk *OutputChunk)
+
+	// Encrypts the output and stderr files; nil leaves them in plaintext
+	sealer *transcript.Sealer
+
+	// Records output and input to an asciicast file; nil when not recording
+	recorder *recording.Recorder
+
+	// Emulates the terminal to keep its state; nil when it is not kept
+	screen *Screen
+
 	lastActivity int64 // atomic timestamp
 	bytesRead    int64 // atomic
 	bytesWritten int64 // atomic
@@ -35,13 +57,25 @@ type SessionRunner struct {
 
 	// Status callback
 	statusCallback func(sessionID string, status string)
+
+	// Set once a command without a PTY has been sent end of input
+	stdinClosed int32 // atomic
+
+	// Bounds the rate output is written to disk; nil when unlimited
+	outputLimiter *outputLimiter
+
+	// Bytes of output read at once, and how long to wait for more output
+	// before writing it
+	bufferSize   int
+	flushLatency time.Duration
 }
 
 // NewSessionRunner creates a new session runner
-func NewSessionRunner(session *types.Session, pipeManager *PipeManager) *SessionRunner {
+func NewSessionRunner(session *types.Session, pipeManager *PipeManager, scope *sessionScope) *SessionRunner {
 	sr := &SessionRunner{
 		session:        session,
 		pipeManager:    pipeManager,
+		scope:          scope,
 		stopChan:       make(chan struct{}),
 		stopped:        0,
 		wg:             sync.WaitGroup{},
@@ -52,11 +86,7 @@ func NewSessionRunner(session *types.Session, pipeManager *PipeManager) *Session
 		maxRetries:     3,
 		retryCount:     0,
 		statusCallback: nil,
-	}
-
-	// Initialize output buffer if available
-	if outputBuffer := performance.NewOutputBuffer(4096, 50*time.Millisecond, sr.handleOutputData); outputBuffer != nil {
-		sr.outputBuffer = outputBuffer
+		bufferSize:     DefaultOutputBufferSize,
 	}
 
 	return sr
@@ -67,6 +97,36 @@ func (sr *SessionRunner) SetStatusCallback(callback func(sessionID string, statu
 	sr.statusCallback = callback
 }
 
+// SetOutputHandler sets the function told of output as it is written to the
+// output and stderr files. It must be called before Start.
+func (sr *SessionRunner) SetOutputHandler(handler func(chunk *OutputChunk)) {
+	sr.outputHandler = handler
+}
+
+// SetSealer encrypts the output and stderr files at rest. It must be called
+// before Start.
+func (sr *SessionRunner) SetSealer(sealer *transcript.Sealer) {
+	sr.sealer = sealer
+}
+
+// SetRecorder records the session's output and input. It must be called
+// before Start.
+func (sr *SessionRunner) SetRecorder(recorder *recording.Recorder) {
+	sr.recorder = recorder
+}
+
+// SetScreen keeps the state of the session's terminal from its output. It
+// must be called before Start.
+func (sr *SessionRunner) SetScreen(screen *Screen) {
+	sr.screen = screen
+}
+
+// SetOutputRateLimit bounds how many bytes of output per second are written
+// to disk; 0 means unlimited. It must be called before Start.
+func (sr *SessionRunner) SetOutputRateLimit(bytesPerSecond int64) {
+	sr.outputLimiter = newOutputLimiter(bytesPerSecond)
+}
+
 // Start begins the session I/O bridging with enhanced error handling
 func (sr *SessionRunner) Start() error {
 	if atomic.LoadInt32(&sr.stopped) == 1 {
@@ -75,21 +135,30 @@ func (sr *SessionRunner) Start() error {
 
 	logrus.WithField("session_id", sr.session.ID).Info("Starting enhanced session I/O bridging")
 
-	// Start PTY output to file bridging with retry
-	sr.wg.Add(1)
-	go sr.bridgePTYOutputToFileWithRetry()
+	goroutines := []func(){
+		sr.bridgePTYOutputToFileWithRetry, // PTY output to file bridging with retry
+		sr.bridgeInputPipeToPTYWithRetry,  // Input pipe to PTY bridging with retry
+		sr.monitorProcess,                 // Monitor process status
+		sr.handleErrors,                   // Handle errors
+	}
 
-	// Start input pipe to PTY bridging with retry
-	sr.wg.Add(1)
-	go sr.bridgeInputPipeToPTYWithRetry()
+	// Copy the stderr of commands run without a PTY to its own file
+	if sr.session.Stderr != nil {
+		goroutines = append(goroutines, sr.bridgeStderrToFile)
+	}
 
-	// Monitor process status
-	sr.wg.Add(1)
-	go sr.monitorProcess()
+	for _, fn := range goroutines {
+		sr.wg.Add(1)
+		if err := sr.scope.spawn(fn); err != nil {
+			sr.wg.Done()
+			return fmt.Errorf("failed to start session runner: %w", err)
+		}
+	}
 
-	// Handle errors
-	sr.wg.Add(1)
-	go sr.handleErrors()
+	// Stop along with the session, should nothing else stop the runner first
+	if err := sr.scope.spawn(sr.stopWithSession); err != nil {
+		return fmt.Errorf("failed to start session runner: %w", err)
+	}
 
 	sr.session.Status = types.SessionStatusRunning
 	sr.session.UpdateLastActive()
@@ -99,9 +168,6 @@ func (sr *SessionRunner) Start() error {
 
 	logrus.WithField("session_id", sr.session.ID).Info("Enhanced session runner started successfully")
 
-	// Add a small delay to allow shell to start and produce initial output
-	time.Sleep(100 * time.Millisecond)
-
 	return nil
 }
 
@@ -113,13 +179,16 @@ func (sr *SessionRunner) Stop() {
 
 	logrus.WithField("session_id", sr.session.ID).Info("Stopping enhanced session runner")
 
-	// Flush output buffer before stopping
-	if sr.outputBuffer != nil {
-		sr.outputBuffer.Flush()
-	}
-
 	close(sr.stopChan)
 
+	// The input bridge waits for a writer to open the input pipe; opening and
+	// closing it wakes the bridge so it can see the runner is stopping
+	if sr.session.InputPipe != "" {
+		if writer, err := os.OpenFile(sr.session.InputPipe, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			writer.Close()
+		}
+	}
+
 	// Wait for all goroutines to complete with timeout
 	done := make(chan struct{})
 	go func() {
@@ -135,6 +204,15 @@ func (sr *SessionRunner) Stop() {
 	}
 }
 
+// stopWithSession stops the runner once its session's context is cancelled
+func (sr *SessionRunner) stopWithSession() {
+	select {
+	case <-sr.scope.ctx.Done():
+		sr.Stop()
+	case <-sr.stopChan:
+	}
+}
+
 // bridgePTYOutputToFileWithRetry wraps the bridge with retry logic
 func (sr *SessionRunner) bridgePTYOutputToFileWithRetry() {
 	defer func() {
@@ -180,14 +258,18 @@ func (sr *SessionRunner) bridgePTYOutputToFile() error {
 	logrus.WithField("session_id", sr.session.ID).Info("Starting enhanced PTY output bridge")
 
 	// Open output file for writing
-	outputFile, err := os.OpenFile(sr.session.OutputFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	outputFile, err := sr.openOutput(sr.session.OutputFile, false)
 	if err != nil {
 		return fmt.Errorf("failed to open output file: %w", err)
 	}
 	defer outputFile.Close()
 
+	writeOutput, flushOutput := sr.batchOutput(outputFile)
+	defer flushOutput()
+
 	// Use larger buffer for better performance
-	buffer := make([]byte, 8192)
+	buffer := readBuffers.Get(sr.bufferSize)
+	defer readBuffers.Put(buffer)
 
 	for {
 		select {
@@ -206,44 +288,116 @@ func (sr *SessionRunner) bridgePTYOutputToFile() error {
 			}
 
 			if n > 0 {
-				// Write to output file
-				if _, err := outputFile.Write(buffer[:n]); err != nil {
+				// Write to output file, which pushes it to attached clients
+				if err := writeOutput(buffer[:n]); err != nil {
 					return fmt.Errorf("error writing to output file: %w", err)
 				}
 
-				// Flush to ensure data is written immediately
-				if err := outputFile.Sync(); err != nil {
-					logrus.WithError(err).WithField("session_id", sr.session.ID).Warn("Error syncing output file")
-				}
-
 				// Update statistics
 				atomic.AddInt64(&sr.bytesRead, int64(n))
 				atomic.StoreInt64(&sr.lastActivity, time.Now().Unix())
 
 				logrus.WithFields(logrus.Fields{
-					"session_id": sr.session.ID,
-					"bytes_read": n,
-					"data":       string(buffer[:n]),
+					"session_id":      sr.session.ID,
+					"bytes_read":      n,
+					logging.FieldData: string(buffer[:n]),
 				}).Info("PTY output written to file")
 
 				sr.session.UpdateLastActive()
+			}
+		}
+	}
+}
 
-				// Use output buffer for additional processing (e.g., WebSocket broadcasting)
-				if sr.outputBuffer != nil {
-					sr.outputBuffer.Write(buffer[:n])
-				}
+// bridgeStderrToFile copies the stderr of a command run without a PTY to
+// the session's stderr file until the command closes it
+func (sr *SessionRunner) bridgeStderrToFile() {
+	defer sr.wg.Done()
+
+	stderrFile, err := sr.openOutput(sr.session.StderrFile, true)
+	if err != nil {
+		sr.errorChan <- fmt.Errorf("failed to open stderr file: %w", err)
+		return
+	}
+	defer stderrFile.Close()
+
+	writeOutput, flushOutput := sr.batchOutput(stderrFile)
+	defer flushOutput()
+
+	buffer := readBuffers.Get(sr.bufferSize)
+	defer readBuffers.Put(buffer)
+
+	for {
+		n, err := sr.session.Stderr.Read(buffer)
+		if n > 0 {
+			if err := writeOutput(buffer[:n]); err != nil {
+				sr.errorChan <- fmt.Errorf("error writing to stderr file: %w", err)
+				return
+			}
+			atomic.AddInt64(&sr.bytesRead, int64(n))
+			atomic.StoreInt64(&sr.lastActivity, time.Now().Unix())
+			sr.session.UpdateLastActive()
+		}
+		if err != nil {
+			if err != io.EOF && atomic.LoadInt32(&sr.stopped) == 0 {
+				logrus.WithError(err).WithField("session_id", sr.session.ID).Warn("Error reading stderr")
 			}
+			return
 		}
 	}
 }
 
-// handleOutputData handles buffered output data
-func (sr *SessionRunner) handleOutputData(data []byte) {
-	// This can be used for WebSocket broadcasting or other real-time features
-	logrus.WithFields(logrus.Fields{
-		"session_id": sr.session.ID,
-		"data_size":  len(data),
-	}).Debug("Handling buffered output data")
+// writeOutput writes output to file within the session's output rate limit,
+// dropping what is over it
+func (sr *SessionRunner) writeOutput(file *outputWriter, data []byte) error {
+	if sr.outputLimiter == nil {
+		return sr.write(file, data)
+	}
+
+	allowed, marker := sr.outputLimiter.admit(len(data))
+	if allowed > 0 {
+		if err := sr.write(file, data[:allowed]); err != nil {
+			return err
+		}
+	}
+	if marker != nil {
+		if err := sr.write(file, marker); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write writes output to file and to the session's recording. The terminal
+// state is updated first, so it includes the output once it is relayed.
+func (sr *SessionRunner) write(file *outputWriter, data []byte) error {
+	if !file.chunk.Stderr {
+		sr.screen.Write(data)
+	}
+
+	if err := file.write(data); err != nil {
+		return err
+	}
+
+	// A failing recording does not hold up the session
+	if err := sr.recorder.Output(data); err != nil {
+		logrus.WithError(err).WithField("session_id", sr.session.ID).Warn("Failed to record output")
+	}
+	return nil
+}
+
+// closeStdin sends end of input to a command run without a PTY
+func (sr *SessionRunner) closeStdin() {
+	if !atomic.CompareAndSwapInt32(&sr.stdinClosed, 0, 1) {
+		return
+	}
+
+	if err := closeStdin(sr.session.PTY); err != nil {
+		logrus.WithError(err).WithField("session_id", sr.session.ID).Warn("Failed to close command stdin")
+		return
+	}
+
+	logrus.WithField("session_id", sr.session.ID).Debug("Command stdin closed")
 }
 
 // bridgeInputPipeToPTYWithRetry wraps the input bridge with retry logic
@@ -281,9 +435,9 @@ func (sr *SessionRunner) bridgeInputPipeToPTYWithRetry() {
 			return
 		}
 
-		// Success, reset retry count
+		// The last writer closed the pipe; reopen it for the next one, such as
+		// a reconnecting client or a session pipe
 		retryCount = 0
-		break
 	}
 }
 
@@ -306,6 +460,9 @@ func (sr *SessionRunner) bridgeInputPipeToPTY() error {
 	// Use buffered reader for better performance
 	reader := bufio.NewReader(inputFile)
 
+	// Read individual bytes instead of waiting for newlines
+	data := make([]byte, 1)
+
 	// Read continuously from the pipe
 	for {
 		select {
@@ -313,8 +470,6 @@ func (sr *SessionRunner) bridgeInputPipeToPTY() error {
 			logrus.WithField("session_id", sr.session.ID).Debug("Input pipe bridge stopping")
 			return nil
 		default:
-			// Read individual bytes instead of waiting for newlines
-			data := make([]byte, 1)
 			n, err := reader.Read(data)
 			if err != nil {
 				if err == io.EOF {
@@ -326,24 +481,43 @@ func (sr *SessionRunner) bridgeInputPipeToPTY() error {
 
 			if n > 0 {
 				logrus.WithFields(logrus.Fields{
-					"session_id": sr.session.ID,
-					"bytes_read": n,
-					"data":       string(data[:n]),
+					"session_id":      sr.session.ID,
+					"bytes_read":      n,
+					logging.FieldData: string(data[:n]),
 				}).Debug("Input read from pipe")
 
+				// Without a PTY there is no line discipline to turn Enter into
+				// a newline or Ctrl-D into end of input, so do it here
+				if !sr.session.HasPTY() {
+					if data[0] == 0x04 {
+						sr.closeStdin()
+						continue
+					}
+					if data[0] == '\r' {
+						data[0] = '\n'
+					}
+					if atomic.LoadInt32(&sr.stdinClosed) == 1 {
+						continue
+					}
+				}
+
 				// Write to PTY
 				if _, err := sr.session.PTY.Write(data[:n]); err != nil {
 					return fmt.Errorf("error writing to PTY: %w", err)
 				}
 
+				if err := sr.recorder.Input(data[:n]); err != nil {
+					logrus.WithError(err).WithField("session_id", sr.session.ID).Warn("Failed to record input")
+				}
+
 				// Update statistics
 				atomic.AddInt64(&sr.bytesWritten, int64(n))
 				atomic.StoreInt64(&sr.lastActivity, time.Now().Unix())
 
 				logrus.WithFields(logrus.Fields{
-					"session_id":    sr.session.ID,
-					"bytes_written": n,
-					"data":          string(data[:n]),
+					"session_id":      sr.session.ID,
+					"bytes_written":   n,
+					logging.FieldData: string(data[:n]),
 				}).Debug("Input written to PTY")
 
 				sr.session.UpdateLastActive()
@@ -366,6 +540,16 @@ func (sr *SessionRunner) monitorProcess() {
 
 	logrus.WithField("session_id", sr.session.ID).Debug("Starting enhanced process monitor")
 
+	// Serial sessions have no process; they run until stopped
+	if sr.session.Process == nil {
+		<-sr.stopChan
+		return
+	}
+
+	if sr.session.Process.Process != nil {
+		sr.session.PID = sr.session.Process.Process.Pid
+	}
+
 	// Wait for process to exit
 	err := sr.session.Process.Wait()
 
@@ -375,6 +559,10 @@ func (sr *SessionRunner) monitorProcess() {
 	}).Info("Shell process exited")
 
 	// Update session status
+	if state := sr.session.Process.ProcessState; state != nil && state.Exited() {
+		exitCode := state.ExitCode()
+		sr.session.ExitCode = &exitCode
+	}
 	sr.session.Status = types.SessionStatusStopped
 	if err != nil {
 		sr.session.ErrorMessage = err.Error()
@@ -434,6 +622,15 @@ func (sr *SessionRunner) GetLastActivity() time.Time {
 	return time.Unix(atomic.LoadInt64(&sr.lastActivity), 0)
 }
 
+// GetOutputDropped returns how many bytes of output were dropped by the
+// output rate limit
+func (sr *SessionRunner) GetOutputDropped() int64 {
+	if sr.outputLimiter == nil {
+		return 0
+	}
+	return sr.outputLimiter.droppedBytes()
+}
+
 // GetBytesRead returns the total bytes read
 func (sr *SessionRunner) GetBytesRead() int64 {
 	return atomic.LoadInt64(&sr.bytesRead)
@@ -0,0 +1,74 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrInvalidSignal is returned for signals sessions cannot be sent
+	ErrInvalidSignal = errors.New("invalid signal, must be SIGINT, SIGTERM, SIGKILL or SIGHUP")
+	// ErrSignalUnavailable is returned for sessions without a host process to signal
+	ErrSignalUnavailable = errors.New("signals can only be sent to running pty and sandbox sessions")
+)
+
+// sessionSignals are the signals that may be sent to a session by name
+var sessionSignals = map[string]syscall.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGHUP":  syscall.SIGHUP,
+}
+
+// SignalSession sends a named signal, such as SIGINT, to the process group of
+// a session's shell and to the job running in its foreground. The SIG prefix
+// may be left out.
+func (m *Manager) SignalSession(sessionID, name string) error {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	signal, ok := sessionSignals[name]
+	if !ok {
+		return ErrInvalidSignal
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	// The processes of container sessions are not children of the server;
+	// signalling the CLI attached to them would not reach the shell
+	if session.Container != "" || !session.IsActive() || session.Process == nil || session.Process.Process == nil {
+		return ErrSignalUnavailable
+	}
+
+	shellPid := session.Process.Process.Pid
+	if err := signalProcessGroup(shellPid, signal); err != nil {
+		return fmt.Errorf("failed to signal session process group: %w", err)
+	}
+
+	// A job the shell runs in the foreground has a process group of its own,
+	// which a key such as Ctrl+C would signal
+	if session.PTY != nil {
+		if pgrp, err := foregroundProcessGroup(session.PTY); err == nil && pgrp > 0 && pgrp != shellPid {
+			if err := signalProcessGroup(pgrp, signal); err != nil {
+				logrus.WithError(err).WithField("session_id", sessionID).Warn("Failed to signal foreground process group")
+			}
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"signal":     name,
+	}).Info("Session signalled")
+	return nil
+}
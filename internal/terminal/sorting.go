@@ -0,0 +1,63 @@
+package terminal
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// ErrInvalidSort is returned for sort orders sessions cannot be listed in
+var ErrInvalidSort = errors.New("invalid sort, must be created_at, last_active_at, name or status, optionally prefixed with -")
+
+// sessionSortKeys compare two sessions by a field
+var sessionSortKeys = map[string]func(a, b *types.Session) int{
+	"created_at": func(a, b *types.Session) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	},
+	"last_active_at": func(a, b *types.Session) int {
+		return a.LastActiveAt.Compare(b.LastActiveAt)
+	},
+	"name": func(a, b *types.Session) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"status": func(a, b *types.Session) int {
+		return strings.Compare(string(a.Status), string(b.Status))
+	},
+}
+
+// SessionSort is the order sessions are listed in
+type SessionSort struct {
+	Key        string
+	Descending bool
+}
+
+// ParseSessionSort parses a sort order such as created_at, or -created_at
+// for newest first. An empty order sorts by creation time.
+func ParseSessionSort(order string) (SessionSort, error) {
+	if order == "" {
+		return SessionSort{Key: "created_at"}, nil
+	}
+
+	key := strings.TrimPrefix(order, "-")
+	if _, ok := sessionSortKeys[key]; !ok {
+		return SessionSort{}, ErrInvalidSort
+	}
+	return SessionSort{Key: key, Descending: key != order}, nil
+}
+
+// Sort orders sessions, breaking ties by ID so that pages are stable
+func (s SessionSort) Sort(sessions []*types.Session) {
+	compare := sessionSortKeys[s.Key]
+	sort.Slice(sessions, func(i, j int) bool {
+		c := compare(sessions[i], sessions[j])
+		if c == 0 {
+			c = strings.Compare(sessions[i].ID, sessions[j].ID)
+		}
+		if s.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
@@ -0,0 +1,104 @@
+package terminal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// SessionStarting returns a channel closed once a session that is still
+// starting is running, or has failed to start. It returns nil for sessions
+// that are not starting, whose input can be written straight away.
+func (m *Manager) SessionStarting(sessionID string) <-chan struct{} {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if started, exists := m.starting[sessionID]; exists {
+		return started
+	}
+	return nil
+}
+
+// trackStarting records that a session is starting until its runner is
+// running (assumes mutex is held)
+func (m *Manager) trackStarting(sessionID string) {
+	m.starting[sessionID] = make(chan struct{})
+}
+
+// finishStarting tells whatever waits for a session to start that it is
+// running or has failed (assumes mutex is held)
+func (m *Manager) finishStarting(sessionID string) {
+	if started, exists := m.starting[sessionID]; exists {
+		close(started)
+		delete(m.starting, sessionID)
+	}
+}
+
+// StartupTiming is how long a session took from its request to its first
+// output, the prompt of a shell, and how long its main stages took. The
+// rest is spent checking the request and preparing recordings, displays
+// and credentials.
+type StartupTiming struct {
+	SessionID    string
+	Backend      types.SessionBackend
+	Pipes        time.Duration // Creating the session's pipes
+	PTYStart     time.Duration // Spawning its PTY and process, or opening its device
+	FirstRead    time.Duration // From then until the first output was read
+	TimeToPrompt time.Duration // From the request until the first output was relayed
+}
+
+// SetStartupCallback sets the function told how long sessions took to
+// start, once their first output is relayed. Sessions that never write
+// anything are not reported.
+func (m *Manager) SetStartupCallback(callback func(timing *StartupTiming)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.startupCallback = callback
+}
+
+// startupClock times the stages of a session starting. It is written while
+// the session is launched and read once its first output is relayed, from
+// the runner's goroutines.
+type startupClock struct {
+	began        time.Time
+	pipesStart   time.Time
+	pipesDone    time.Time
+	backendStart time.Time
+	backendDone  time.Time
+	reported     sync.Once
+}
+
+// newStartupClock starts timing a session requested at began, or now if
+// the time the request was received is unknown
+func newStartupClock(began time.Time) *startupClock {
+	if began.IsZero() {
+		began = time.Now()
+	}
+	return &startupClock{began: began}
+}
+
+// watchFirstOutput wraps a session's output handler to report its startup
+// timing with its first output (assumes mutex is held)
+func (m *Manager) watchFirstOutput(session *types.Session, clock *startupClock, handler func(chunk *OutputChunk)) func(chunk *OutputChunk) {
+	callback := m.startupCallback
+	if callback == nil {
+		return handler
+	}
+
+	return func(chunk *OutputChunk) {
+		handler(chunk)
+
+		clock.reported.Do(func() {
+			now := time.Now()
+			callback(&StartupTiming{
+				SessionID:    session.ID,
+				Backend:      session.Backend,
+				Pipes:        clock.pipesDone.Sub(clock.pipesStart),
+				PTYStart:     clock.backendDone.Sub(clock.backendStart),
+				FirstRead:    now.Sub(clock.backendDone),
+				TimeToPrompt: now.Sub(clock.began),
+			})
+		})
+	}
+}
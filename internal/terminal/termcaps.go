@@ -0,0 +1,110 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTerm is the TERM used when the client declares nothing
+const DefaultTerm = "xterm-256color"
+
+// supportedTerms lists the terminal types clients may ask for
+var supportedTerms = map[string]bool{
+	"xterm":           true,
+	"xterm-16color":   true,
+	"xterm-256color":  true,
+	"xterm-direct":    true,
+	"screen":          true,
+	"screen-256color": true,
+	"tmux":            true,
+	"tmux-256color":   true,
+	"linux":           true,
+	"vt100":           true,
+	"vt220":           true,
+}
+
+// terminfoDirs are searched for compiled terminfo entries on the host
+var terminfoDirs = []string{"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo"}
+
+// resolveTerminal picks TERM for a session from the client's declared
+// capabilities and reports whether the shell should be told about truecolor
+// support. onHost is false for backends whose terminfo database cannot be
+// inspected, such as containers, where xterm-direct is only used on request.
+func resolveTerminal(caps *types.TerminalCapabilities, onHost bool) (string, bool, error) {
+	if caps == nil {
+		return DefaultTerm, false, nil
+	}
+
+	trueColor := false
+	switch caps.Color {
+	case "":
+	case "16", "256":
+	case "truecolor", "24bit":
+		trueColor = true
+	default:
+		return "", false, fmt.Errorf("unsupported color depth: %s", caps.Color)
+	}
+
+	term := caps.Term
+	if term != "" {
+		if !supportedTerms[term] {
+			return "", false, fmt.Errorf("unsupported terminal type: %s", term)
+		}
+		if onHost && !hasTerminfo(term) {
+			logrus.WithField("term", term).Warn("No terminfo entry for requested terminal type, using default")
+			term = DefaultTerm
+		}
+	} else {
+		switch {
+		case trueColor && onHost && hasTerminfo("xterm-direct"):
+			term = "xterm-direct"
+		case caps.Color == "16":
+			term = "xterm"
+		default:
+			term = DefaultTerm
+		}
+	}
+
+	return term, trueColor || strings.HasSuffix(term, "-direct"), nil
+}
+
+// hasTerminfo reports whether the host has a terminfo entry for term
+func hasTerminfo(term string) bool {
+	dirs := terminfoDirs
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append([]string{dir}, dirs...)
+	}
+
+	for _, dir := range dirs {
+		// Entries live under their first letter, or its hex code on some systems
+		for _, sub := range []string{term[:1], fmt.Sprintf("%x", term[0])} {
+			if _, err := os.Stat(filepath.Join(dir, sub, term)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// terminalEnv returns env with TERM and COLORTERM set for the session,
+// leaving values set explicitly by the request or profile untouched
+func terminalEnv(env map[string]string, session *types.Session) map[string]string {
+	merged := make(map[string]string, len(env)+2)
+	for key, value := range env {
+		merged[key] = value
+	}
+
+	if _, exists := merged["TERM"]; !exists {
+		merged["TERM"] = session.Term
+	}
+	if _, exists := merged["COLORTERM"]; !exists && session.TrueColor {
+		merged["COLORTERM"] = "truecolor"
+	}
+
+	return merged
+}
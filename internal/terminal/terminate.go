@@ -0,0 +1,59 @@
+package terminal
+
+import (
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// Reasons clients are given when their session is terminated
+const (
+	DetachReasonTerminated = "Session terminated"
+	DetachReasonTimeLimit  = "Session reached its time limit"
+	DetachReasonIdle       = "Session closed after being idle"
+	DetachReasonPurged     = "Session purged"
+)
+
+// SetDetachCallback sets the function that disconnects a session's clients
+// with a reason before the session is torn down. It is called without the
+// manager's lock held, and must return once nothing attached to the
+// session reads its output or writes its input anymore.
+func (m *Manager) SetDetachCallback(callback func(sessionID, reason string)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.detachCallback = callback
+}
+
+// terminate ends a session in order: it is marked stopping, its clients
+// are detached with the reason, then its runner is stopped and its files
+// removed, so nothing attached to it races the removal. Terminating a
+// session already being terminated waits for that to finish. The mutex is
+// released while clients are detached (assumes mutex is held).
+func (m *Manager) terminate(session *types.Session, reason string) error {
+	if done, terminating := m.terminating[session.ID]; terminating {
+		m.mutex.Unlock()
+		<-done
+		m.mutex.Lock()
+		return nil
+	}
+
+	done := make(chan struct{})
+	m.terminating[session.ID] = done
+	defer func() {
+		delete(m.terminating, session.ID)
+		close(done)
+	}()
+
+	m.wakeSession(session)
+	session.Status = types.SessionStatusStopping
+
+	if callback := m.detachCallback; callback != nil {
+		m.mutex.Unlock()
+		callback(session.ID, reason)
+		m.mutex.Lock()
+	}
+
+	// The session may have been removed meanwhile, once its shell exited
+	if m.sessions[session.ID] != session {
+		return nil
+	}
+	return m.cleanupSession(session.ID)
+}
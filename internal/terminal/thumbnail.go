@@ -0,0 +1,92 @@
+package terminal
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/hinshun/vt10x"
+)
+
+// Thumbnail cell sizes in pixels, and the largest scale a caller may ask for
+const (
+	thumbnailCellWidth  = 1
+	thumbnailCellHeight = 2
+	MaxThumbnailScale   = 8
+)
+
+// Colors of the terminal's default foreground and background in thumbnails
+var (
+	thumbnailDefaultFG = color.RGBA{R: 0xd0, G: 0xd0, B: 0xd0, A: 0xff}
+	thumbnailDefaultBG = color.RGBA{A: 0xff}
+)
+
+// standardColors are the RGB values of the 16 standard and bright colors,
+// as xterm shows them
+var standardColors = [16]color.RGBA{
+	{0x00, 0x00, 0x00, 0xff}, {0xcd, 0x00, 0x00, 0xff}, {0x00, 0xcd, 0x00, 0xff}, {0xcd, 0xcd, 0x00, 0xff},
+	{0x00, 0x00, 0xee, 0xff}, {0xcd, 0x00, 0xcd, 0xff}, {0x00, 0xcd, 0xcd, 0xff}, {0xe5, 0xe5, 0xe5, 0xff},
+	{0x7f, 0x7f, 0x7f, 0xff}, {0xff, 0x00, 0x00, 0xff}, {0x00, 0xff, 0x00, 0xff}, {0xff, 0xff, 0x00, 0xff},
+	{0x5c, 0x5c, 0xff, 0xff}, {0xff, 0x00, 0xff, 0xff}, {0x00, 0xff, 0xff, 0xff}, {0xff, 0xff, 0xff, 0xff},
+}
+
+// Thumbnail draws the terminal as an image for previews, each cell a block
+// of its background color with a bar of its foreground color where it shows
+// a character. Cells are scale pixels wide and twice as high; scale is
+// clamped to between 1 and MaxThumbnailScale.
+func (s *Screen) Thumbnail(scale int) *image.RGBA {
+	scale = max(1, min(scale, MaxThumbnailScale))
+	cellWidth, cellHeight := thumbnailCellWidth*scale, thumbnailCellHeight*scale
+
+	frame := s.Frame()
+	img := image.NewRGBA(image.Rect(0, 0, frame.Cols*cellWidth, frame.Rows*cellHeight))
+
+	for y, row := range frame.cells {
+		for x, cell := range row {
+			fg, bg := rgb(cell.FG, thumbnailDefaultFG), rgb(cell.BG, thumbnailDefaultBG)
+			if cell.Mode&glyphReverse != 0 {
+				fg, bg = bg, fg
+			}
+			if frame.CursorVisible && y == frame.CursorRow && x == frame.CursorCol {
+				fg, bg = bg, fg
+			}
+
+			bounds := image.Rect(x*cellWidth, y*cellHeight, (x+1)*cellWidth, (y+1)*cellHeight)
+			draw.Draw(img, bounds, image.NewUniform(bg), image.Point{}, draw.Src)
+
+			// Characters fill the middle of the cell, leaving line spacing
+			if glyphChar(cell) != ' ' {
+				glyph := image.Rect(bounds.Min.X, bounds.Min.Y+cellHeight/4, bounds.Max.X, bounds.Max.Y-cellHeight/4)
+				draw.Draw(img, glyph, image.NewUniform(fg), image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return img
+}
+
+// rgb returns the RGB value of a terminal color, or def for the terminal's
+// default color
+func rgb(c vt10x.Color, def color.RGBA) color.RGBA {
+	switch {
+	case c < 16:
+		return standardColors[c]
+	case c < 232:
+		// 6x6x6 color cube
+		level := func(n vt10x.Color) uint8 {
+			if n == 0 {
+				return 0
+			}
+			return uint8(55 + n*40)
+		}
+		n := c - 16
+		return color.RGBA{R: level(n / 36), G: level(n / 6 % 6), B: level(n % 6), A: 0xff}
+	case c < 256:
+		gray := uint8(8 + (c-232)*10)
+		return color.RGBA{R: gray, G: gray, B: gray, A: 0xff}
+	case c < 1<<24:
+		return color.RGBA{R: uint8(c >> 16), G: uint8(c >> 8), B: uint8(c), A: 0xff}
+	default:
+		return def
+	}
+}
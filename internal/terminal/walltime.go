@@ -0,0 +1,136 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// TerminationReasonTimeout is recorded for sessions closed at their wall-clock limit
+const TerminationReasonTimeout = "timeout"
+
+// ErrInvalidWallTime is returned for wall-clock limits that cannot be parsed
+var ErrInvalidWallTime = errors.New("invalid max_wall_time")
+
+// wallTimeWarnings are how long before its limit a session's clients are warned
+var wallTimeWarnings = []time.Duration{60 * time.Second, 10 * time.Second}
+
+// parseWallTime parses a session's wall-clock limit; empty means no limit
+func parseWallTime(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+
+	limit, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidWallTime, err)
+	}
+	if limit < time.Second {
+		return 0, fmt.Errorf("%w: must be at least 1s", ErrInvalidWallTime)
+	}
+	return limit, nil
+}
+
+// SetWarningCallback sets the function used to warn a session's clients,
+// e.g. that the session is about to reach its time limit
+func (m *Manager) SetWarningCallback(callback func(sessionID, message, level string)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.warningCallback = callback
+}
+
+// startWallClock closes a session once its limit has passed (assumes mutex is held)
+func (m *Manager) startWallClock(session *types.Session, limit time.Duration) {
+	if limit == 0 {
+		return
+	}
+
+	deadline := time.Now().Add(limit)
+	session.ExpiresAt = &deadline
+
+	cancel := make(chan struct{})
+	m.wallClocks[session.ID] = cancel
+	go m.wallClock(session.ID, deadline, cancel)
+}
+
+// stopWallClock cancels a session's wall-clock limit (assumes mutex is held)
+func (m *Manager) stopWallClock(sessionID string) {
+	if cancel, exists := m.wallClocks[sessionID]; exists {
+		close(cancel)
+		delete(m.wallClocks, sessionID)
+	}
+}
+
+// wallClock warns a session's clients ahead of its deadline, then closes it
+func (m *Manager) wallClock(sessionID string, deadline time.Time, cancel chan struct{}) {
+	for _, before := range wallTimeWarnings {
+		warnAt := deadline.Add(-before)
+		if !warnAt.After(time.Now()) {
+			continue
+		}
+
+		if !wait(time.Until(warnAt), cancel) {
+			return
+		}
+
+		level := "warning"
+		if before <= 10*time.Second {
+			level = "critical"
+		}
+		m.warnSession(sessionID, fmt.Sprintf("This session reaches its time limit in %d seconds and will be closed", int(before.Seconds())), level)
+	}
+
+	if !wait(time.Until(deadline), cancel) {
+		return
+	}
+
+	m.warnSession(sessionID, "This session has reached its time limit and is being closed", "critical")
+	m.expireSession(sessionID)
+}
+
+// expireSession terminates a session that has reached its wall-clock limit
+func (m *Manager) expireSession(sessionID string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.wallClocks, sessionID)
+
+	session, exists := m.sessions[sessionID]
+	if !exists || !session.CanTerminate() {
+		return
+	}
+
+	logrus.WithField("session_id", sessionID).Info("Session reached its time limit, terminating")
+
+	session.TerminationReason = TerminationReasonTimeout
+	if err := m.terminate(session, DetachReasonTimeLimit); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to terminate expired session")
+	}
+}
+
+// warnSession passes a warning to the session's clients
+func (m *Manager) warnSession(sessionID, message, level string) {
+	m.mutex.RLock()
+	callback := m.warningCallback
+	m.mutex.RUnlock()
+
+	if callback != nil {
+		callback(sessionID, message, level)
+	}
+}
+
+// wait sleeps for d, returning false if cancelled first
+func wait(d time.Duration, cancel chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-cancel:
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// ErrWorkingDirNotAllowed is returned for working directories outside the
+// allowed roots
+var ErrWorkingDirNotAllowed = errors.New("working directory not allowed")
+
+// checkWorkingDir checks the working directory of a request against the
+// roots of the launch policy, skipping one its profile set, and returns the
+// directory to start in. Directories on the host must exist and are checked
+// after resolving symbolic links, so a link cannot lead out of a root, and the
+// resolved directory is returned so that the process starts where it was
+// checked. Without a directory, host sessions start in the first root that is
+// not a pattern. Those inside a container are checked as given, and start in
+// their image's directory without one (assumes mutex is held).
+func (m *Manager) checkWorkingDir(dir string, profile *types.Profile, host bool) (string, error) {
+	roots := m.launchPolicy.WorkingDirRoots
+	if len(roots) == 0 || (profile != nil && profile.WorkingDir != "") {
+		return dir, nil
+	}
+
+	if dir == "" {
+		if !host {
+			return "", nil
+		}
+		// Otherwise the server's home or working directory would be used,
+		// which may lie outside every root
+		if dir = defaultRoot(roots); dir == "" {
+			return "", fmt.Errorf("%w: a working directory within %s is required", ErrWorkingDirNotAllowed, strings.Join(roots, ", "))
+		}
+	}
+
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%w: %s is not an absolute path", ErrWorkingDirNotAllowed, dir)
+	}
+
+	requested := dir
+	dir = filepath.Clean(dir)
+	if host {
+		// A missing directory would be replaced by the home directory,
+		// which may lie outside every root
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s does not exist", ErrWorkingDirNotAllowed, requested)
+		}
+		dir = resolved
+	}
+
+	if !withinRoots(dir, roots) {
+		return "", fmt.Errorf("%w: %s", ErrWorkingDirNotAllowed, requested)
+	}
+	return dir, nil
+}
+
+// defaultRoot returns the first root that names a single directory rather
+// than a pattern, or "" if every root is a pattern
+func defaultRoot(roots []string) string {
+	for _, root := range roots {
+		if !strings.ContainsAny(root, `*?[\`) {
+			return filepath.Clean(root)
+		}
+	}
+	return ""
+}
+
+// withinRoots reports whether a clean absolute path is, or is beneath, a
+// directory matching one of the root patterns
+func withinRoots(dir string, roots []string) bool {
+	for {
+		for _, root := range roots {
+			if matched, err := filepath.Match(filepath.Clean(root), dir); err == nil && matched {
+				return true
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
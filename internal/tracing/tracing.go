@@ -0,0 +1,98 @@
+// Package tracing sets up OpenTelemetry tracing, exporting spans of request
+// handling, WebSocket messages and session lifecycles over OTLP
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName names the server in traces
+const serviceName = "webterm"
+
+// instrumentationName names the tracer spans are created by
+const instrumentationName = "github.com/piyushgupta53/webterm"
+
+// enabled is set once Setup has installed an exporter
+var enabled atomic.Bool
+
+// noopSpan records nothing; it is the span of a context without one
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// Attribute keys shared by spans
+const (
+	AttrSessionID = attribute.Key("webterm.session.id")
+	AttrClientID  = attribute.Key("webterm.client.id")
+	AttrBackend   = attribute.Key("webterm.session.backend")
+	AttrPane      = attribute.Key("webterm.pane.id")
+	AttrBytes     = attribute.Key("webterm.bytes")
+)
+
+// Setup exports spans to an OTLP/HTTP endpoint, sampling root spans at the
+// given ratio. The returned function flushes and stops the exporter. Until
+// Setup is called, spans are created by a no-op tracer and cost little.
+func Setup(ctx context.Context, endpoint string, sampleRatio float64, version string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", version),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	enabled.Store(true)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// Enabled reports whether spans are exported. Hot paths check it before
+// starting spans, as building their attributes allocates even when the
+// tracer is a no-op.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// NoopSpan returns a span that records nothing, for hot paths to use while
+// tracing is disabled
+func NoopSpan() trace.Span {
+	return noopSpan
+}
+
+// Tracer returns the tracer of the server's spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span as a child of any span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Extract returns a context carrying the trace a request's headers
+// propagate, such as from a traceparent header
+func Extract(r *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+}
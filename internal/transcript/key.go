@@ -0,0 +1,48 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// ParseKey decodes a transcript key given as KeySize raw bytes, or encoded
+// in hex or base64, such as by `openssl rand -hex 32`
+func ParseKey(data []byte) ([]byte, error) {
+	if len(data) == KeySize {
+		return data, nil
+	}
+
+	text := string(bytes.TrimSpace(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("transcript key must be %d bytes, raw or encoded in hex or base64", KeySize)
+}
+
+// LoadSealer creates a sealer with the key in keyFile. With unwrap, the file
+// holds the key encrypted by a key management service, which unwrap decrypts.
+func LoadSealer(keyFile string, unwrap func(ciphertext string) ([]byte, error)) (*Sealer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if unwrap != nil {
+		if data, err = unwrap(string(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewSealer(key)
+}
@@ -0,0 +1,241 @@
+package transcript
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// KeySize is the size of transcript keys, for AES-256
+const KeySize = 32
+
+const (
+	// nonceSize is the size of the random nonce of each record
+	nonceSize = 12
+
+	// headerSize is the plaintext length and nonce before each record's
+	// ciphertext
+	headerSize = 4 + nonceSize
+
+	// maxRecordSize bounds the plaintext of one record, so a corrupt length
+	// is not allocated
+	maxRecordSize = 1 << 24
+)
+
+// ErrCorrupt is returned for sealed transcripts that fail to decrypt
+var ErrCorrupt = errors.New("transcript is corrupt or sealed with another key")
+
+// Sealer encrypts session output files at rest with AES-256-GCM. Each write
+// is sealed as its own record, authenticated together with its offset in
+// the output, so records cannot be altered, reordered or dropped unnoticed.
+// A nil Sealer leaves output files in plaintext.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer creates a sealer using a key of KeySize bytes
+func NewSealer(key []byte) (*Sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("transcript key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{aead: aead}, nil
+}
+
+// Seal returns what to append to an output file holding offset bytes of
+// output, to add data to it
+func (s *Sealer) Seal(offset int64, data []byte) []byte {
+	if s == nil {
+		return data
+	}
+
+	record := make([]byte, headerSize, headerSize+len(data)+s.aead.Overhead())
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	nonce := record[4:headerSize]
+	if _, err := rand.Read(nonce); err != nil {
+		// The system's randomness is gone; reusing a nonce would be worse
+		panic(fmt.Sprintf("failed to generate transcript nonce: %v", err))
+	}
+
+	return s.aead.Seal(record, nonce, data, additionalData(offset, len(data)))
+}
+
+// Size returns how many bytes of output a file holds, or 0 if it does not
+// exist. A record still being written is not counted.
+func (s *Sealer) Size(path string) (int64, error) {
+	if s == nil {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return 0, nil
+			}
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+
+	return s.scan(path, func(*os.File, int64, int64, int) (bool, error) {
+		return true, nil
+	})
+}
+
+// ReadRange returns the output in a file from start up to end, or up to
+// where the file ends if sooner
+func (s *Sealer) ReadRange(path string, start, end int64) ([]byte, error) {
+	if start < 0 {
+		start = 0
+	}
+	if end <= start {
+		return nil, nil
+	}
+
+	if s == nil {
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		defer file.Close()
+
+		data := make([]byte, end-start)
+		n, err := file.ReadAt(data, start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return data[:n], nil
+	}
+
+	var output []byte
+	_, err := s.scan(path, func(file *os.File, position, offset int64, length int) (bool, error) {
+		if offset+int64(length) <= start {
+			return true, nil
+		}
+		if offset >= end {
+			return false, nil
+		}
+
+		data, err := s.open(file, position, offset, length)
+		if err != nil {
+			return false, err
+		}
+		from := max(start-offset, 0)
+		to := min(end-offset, int64(length))
+		output = append(output, data[from:to]...)
+		return true, nil
+	})
+	return output, err
+}
+
+// scan calls visit with the file position, output offset and length of each
+// complete record in a sealed file until it returns false, returning the
+// size of the output visited
+func (s *Sealer) scan(path string, visit func(file *os.File, position, offset int64, length int) (bool, error)) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 4)
+	var position, offset int64
+	for position+headerSize <= info.Size() {
+		if _, err := file.ReadAt(header, position); err != nil {
+			return 0, err
+		}
+		length := int(binary.BigEndian.Uint32(header))
+		if length > maxRecordSize {
+			return 0, ErrCorrupt
+		}
+
+		recordSize := int64(headerSize + length + s.aead.Overhead())
+		if position+recordSize > info.Size() {
+			break // Still being written
+		}
+
+		more, err := visit(file, position, offset, length)
+		if err != nil {
+			return 0, err
+		}
+		if !more {
+			break
+		}
+
+		position += recordSize
+		offset += int64(length)
+	}
+
+	return offset, nil
+}
+
+// open decrypts the record at a file position
+func (s *Sealer) open(file *os.File, position, offset int64, length int) ([]byte, error) {
+	record := make([]byte, headerSize+length+s.aead.Overhead())
+	if _, err := file.ReadAt(record, position); err != nil {
+		return nil, err
+	}
+
+	data, err := s.aead.Open(nil, record[4:headerSize], record[headerSize:], additionalData(offset, length))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return data, nil
+}
+
+// additionalData binds a record to its place in the output
+func additionalData(offset int64, length int) []byte {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint64(data, uint64(offset))
+	binary.BigEndian.PutUint32(data[8:], uint32(length))
+	return data
+}
+
+// Copy writes all the output in a file to w, returning how much it wrote
+func (s *Sealer) Copy(w io.Writer, path string) (int64, error) {
+	if s == nil {
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return 0, nil
+			}
+			return 0, err
+		}
+		defer file.Close()
+		return io.Copy(w, file)
+	}
+
+	var written int64
+	_, err := s.scan(path, func(file *os.File, position, offset int64, length int) (bool, error) {
+		data, err := s.open(file, position, offset, length)
+		if err != nil {
+			return false, err
+		}
+		n, err := w.Write(data)
+		written += int64(n)
+		return err == nil, err
+	})
+	return written, err
+}
@@ -0,0 +1,19 @@
+package types
+
+import "time"
+
+// Approval is a pending request to start a session with a privileged profile
+type Approval struct {
+	ID          string            `json:"id"` // Same as the pending session's ID
+	Requester   string            `json:"requester"`
+	Tenant      string            `json:"tenant,omitempty"`
+	Profile     string            `json:"profile"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	RequestedAt time.Time         `json:"requested_at"`
+}
+
+// ApprovalListResponse represents the response for listing pending approvals
+type ApprovalListResponse struct {
+	Approvals []Approval `json:"approvals"`
+	Count     int        `json:"count"`
+}
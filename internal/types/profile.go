@@ -0,0 +1,98 @@
+package types
+
+// SandboxRuntime identifies the isolation technology used by the sandbox backend
+type SandboxRuntime string
+
+const (
+	// SandboxRuntimeGVisor runs commands under gVisor's runsc
+	SandboxRuntimeGVisor SandboxRuntime = "gvisor"
+	// SandboxRuntimeWASI runs a WebAssembly module under a WASI runtime
+	SandboxRuntimeWASI SandboxRuntime = "wasi"
+)
+
+// Profile is a named, admin-defined template for creating sessions
+type Profile struct {
+	Name       string            `json:"name"`
+	Backend    SessionBackend    `json:"backend,omitempty"`
+	Shell      string            `json:"shell,omitempty"`
+	Command    []string          `json:"command,omitempty"`
+	WorkingDir string            `json:"working_dir,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+
+	// Defaults for sessions that do not choose their own
+	Locale   *LocaleSettings   `json:"locale,omitempty"`
+	Keyboard *KeyboardSettings `json:"keyboard,omitempty"`
+	Priority *SessionPriority  `json:"priority,omitempty"`
+
+	// Privileged profiles need an admin's approval before the shell is spawned
+	Privileged bool `json:"privileged,omitempty"`
+
+	// Short-lived credentials issued at session start and revoked at its end
+	Secrets []SecretSpec `json:"secrets,omitempty"`
+
+	// Kerberos tickets obtained or forwarded into the session at its start
+	Kerberos *KerberosOptions `json:"kerberos,omitempty"`
+
+	// Sandbox backend options
+	Sandbox *SandboxOptions `json:"sandbox,omitempty"`
+
+	// Container backend options
+	Container *ContainerOptions `json:"container,omitempty"`
+
+	// Network destinations the session's processes may reach, for container
+	// and sandbox profiles
+	Egress *EgressPolicy `json:"egress,omitempty"`
+}
+
+// EgressPolicy denies a session's processes all outgoing network traffic
+// except to the allowed destinations
+type EgressPolicy struct {
+	Allow []string `json:"allow,omitempty"` // IPv4 addresses, CIDRs or domain names
+}
+
+// SecretSpec requests credentials from the secrets provider for a session
+type SecretSpec struct {
+	Path  string            `json:"path"`            // Provider path; {user} and {tenant} are substituted
+	Env   map[string]string `json:"env,omitempty"`   // Environment variable name to secret field
+	Files map[string]string `json:"files,omitempty"` // File name in the session's secrets directory to secret field
+}
+
+// KerberosOptions gives sessions a Kerberos credential cache, either
+// obtained from a keytab on the server or forwarded by the client
+type KerberosOptions struct {
+	Principal       string `json:"principal,omitempty"`        // Principal to obtain tickets for; {user} and {tenant} are substituted
+	Keytab          string `json:"keytab,omitempty"`           // Keytab on the server holding the principal's keys
+	AllowDelegation bool   `json:"allow_delegation,omitempty"` // Accept a ticket cache forwarded in the create request
+}
+
+// SandboxOptions configures the sandbox backend for a profile
+type SandboxOptions struct {
+	Runtime     SandboxRuntime `json:"runtime"`
+	RuntimePath string         `json:"runtime_path,omitempty"` // Defaults to runsc or wasmtime on PATH
+	Module      string         `json:"module,omitempty"`       // WebAssembly module for the wasi runtime
+	Network     bool           `json:"network,omitempty"`      // Allow network access from the sandbox
+	Limits      SandboxLimits  `json:"limits"`
+}
+
+// SandboxLimits bounds the resources a sandboxed session may consume
+type SandboxLimits struct {
+	MaxMemoryMB   int `json:"max_memory_mb,omitempty"`
+	MaxCPUSeconds int `json:"max_cpu_seconds,omitempty"`
+	MaxProcesses  int `json:"max_processes,omitempty"`
+	MaxFileSizeMB int `json:"max_file_size_mb,omitempty"`
+}
+
+// ContainerOptions configures the ephemeral container created for each session
+type ContainerOptions struct {
+	Runtime string   `json:"runtime,omitempty"` // Container CLI, defaults to docker
+	Image   string   `json:"image"`
+	Mounts  []string `json:"mounts,omitempty"`  // Bind mounts as host:container[:ro]
+	Network string   `json:"network,omitempty"` // Container network, defaults to none
+	Devices []string `json:"devices,omitempty"` // Host devices to pass through, globs allowed
+	GPUs    string   `json:"gpus,omitempty"`    // GPU request passed to --gpus, e.g. "all"
+
+	// Name resolution inside the container, e.g. to point it at staging
+	Hosts     map[string]string `json:"hosts,omitempty"`      // Host name to address, added to /etc/hosts
+	DNS       []string          `json:"dns,omitempty"`        // Name servers replacing the host's
+	DNSSearch []string          `json:"dns_search,omitempty"` // Search domains for short names
+}
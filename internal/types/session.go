@@ -10,10 +10,14 @@ import (
 type SessionStatus string
 
 const (
+	// SessionStatusPending indicates session is waiting for an admin to approve it
+	SessionStatusPending SessionStatus = "pending"
 	// SessionStatusStarting indicates session is being initialized
 	SessionStatusStarting SessionStatus = "starting"
 	// SessionStatusRunning indicates session is active and ready
 	SessionStatusRunning SessionStatus = "running"
+	// SessionStatusPaused indicates session processes are stopped until resumed
+	SessionStatusPaused SessionStatus = "paused"
 	// SessionStatusStopping indicates session is being terminated
 	SessionStatusStopping SessionStatus = "stopping"
 	// SessionStatusStopped indicates session has been terminated
@@ -22,6 +26,20 @@ const (
 	SessionStatusError SessionStatus = "error"
 )
 
+// SessionBackend identifies what a session is attached to
+type SessionBackend string
+
+const (
+	// SessionBackendPTY runs a shell or command on a local PTY
+	SessionBackendPTY SessionBackend = "pty"
+	// SessionBackendSerial attaches to a local serial device
+	SessionBackendSerial SessionBackend = "serial"
+	// SessionBackendSandbox runs a command inside a gVisor or WASI sandbox
+	SessionBackendSandbox SessionBackend = "sandbox"
+	// SessionBackendContainer runs the shell in an ephemeral container
+	SessionBackendContainer SessionBackend = "container"
+)
+
 // Session represents a terminal session with its associated resources
 type Session struct {
 	// Basic session information
@@ -30,50 +48,375 @@ type Session struct {
 	CreatedAt    time.Time     `json:"created_at"`
 	LastActiveAt time.Time     `json:"last_active_at"`
 
+	// Ownership information
+	Owner  string `json:"owner,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+
+	// Free-form tags set by the creator, e.g. {"origin": "ci", "job": "1234"}
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Display name and labels for finding the session among others, e.g.
+	// {"team": "infra"}
+	Name   string            `json:"name,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Created from the home profile for its owner to return to on every visit
+	Home bool `json:"home,omitempty"`
+
+	// Backend information
+	Profile      string         `json:"profile,omitempty"`
+	Backend      SessionBackend `json:"backend"`
+	SerialDevice string         `json:"serial_device,omitempty"`
+	BaudRate     int            `json:"baud_rate,omitempty"`
+	Container    string         `json:"container,omitempty"`
+
+	// Terminal type negotiated with the client
+	Term      string `json:"term,omitempty"`
+	TrueColor bool   `json:"true_color,omitempty"`
+
+	// Locale and keyboard input handling
+	Locale   string            `json:"locale,omitempty"`
+	Keyboard *KeyboardSettings `json:"keyboard,omitempty"`
+
+	// Scheduling priority applied to the session's processes
+	Priority *SessionPriority `json:"priority,omitempty"`
+
+	// How output is read and batched
+	OutputBuffering *OutputBuffering `json:"output_buffering,omitempty"`
+
 	// Shell information
 	Shell      string   `json:"shell"`
 	Command    []string `json:"command"`
 	WorkingDir string   `json:"working_dir"`
 
-	// Named pipes paths
-	InputPipe  string `json:"input_pipe"`
-	OutputFile string `json:"output_file"`
+	// Set to false for commands run on plain pipes instead of a PTY
+	AllocatePTY *bool `json:"allocate_pty,omitempty"`
+
+	// System account the session's processes run as; empty for the
+	// server's user
+	RunAs string `json:"run_as,omitempty"`
+
+	// Additional shells running in the session, each with its own PTY
+	Panes []*Pane `json:"panes,omitempty"`
+
+	// Read-only broadcast information
+	Broadcasting   bool   `json:"broadcasting"`
+	BroadcastToken string `json:"-"`
+
+	// Named pipes paths, only shown to admins
+	InputPipe  string `json:"-"`
+	OutputFile string `json:"-"`
+	StderrFile string `json:"-"` // Only for sessions without a PTY
+
+	// Set when the session's output is recorded to an asciicast file
+	Recording     bool   `json:"recording,omitempty"`
+	RecordingFile string `json:"-"`
 
 	// Internal resources (not serialized to JSON)
-	PTY     *os.File  `json:"-"`
-	Process *exec.Cmd `json:"-"`
+	PTY              *os.File  `json:"-"` // Without a PTY, a socket joined to the command's stdin and stdout
+	Stderr           *os.File  `json:"-"` // Read end of the command's stderr without a PTY
+	Process          *exec.Cmd `json:"-"`
+	ContainerRuntime string    `json:"-"`
 
 	// Error information
 	ErrorMessage string `json:"error_message,omitempty"`
+
+	// Wall-clock limit: when the session is closed, and why it was ended by
+	// the server, e.g. "timeout"
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	TerminationReason string     `json:"termination_reason,omitempty"`
+
+	// When the session ended, and until when it stays listed before it is
+	// removed; set once it has ended
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+	RetainedUntil *time.Time `json:"retained_until,omitempty"`
+
+	// X display of the session's GUI applications, e.g. ":100"
+	Display string `json:"display,omitempty"`
+
+	// Process ID of the shell, or of the container CLI for container sessions,
+	// and its exit code once it has exited normally
+	PID      int  `json:"pid,omitempty"`
+	ExitCode *int `json:"exit_code,omitempty"`
 }
 
 // SessionCreateRequest represents a request to create a new session
 type SessionCreateRequest struct {
+	Profile    string            `json:"profile,omitempty"`
+	Backend    SessionBackend    `json:"backend,omitempty"`
 	Shell      string            `json:"shell,omitempty"`
 	Command    []string          `json:"command,omitempty"`
 	WorkingDir string            `json:"working_dir,omitempty"`
 	Env        map[string]string `json:"env,omitempty"`
+
+	// Set to false to run the command on plain pipes, with stderr kept apart
+	// from stdout, for programs that misbehave under a TTY
+	AllocatePTY *bool `json:"allocate_pty,omitempty"`
+
+	// Capabilities of the client's terminal emulator
+	Terminal *TerminalCapabilities `json:"terminal,omitempty"`
+
+	// Locale and keyboard input handling, defaulting to the profile's
+	Locale   *LocaleSettings   `json:"locale,omitempty"`
+	Keyboard *KeyboardSettings `json:"keyboard,omitempty"`
+
+	// CPU and IO priority, defaulting to the profile's
+	Priority *SessionPriority `json:"priority,omitempty"`
+
+	// Output buffer size and flush latency, defaulting to the server's
+	OutputBuffering *OutputBuffering `json:"output_buffering,omitempty"`
+
+	// Free-form tags describing where the session came from and why
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Display name and labels to find the session by when listing sessions
+	Name   string            `json:"name,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// URL to POST a SessionCompletion to once the session stops
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	// Close the session after this long, as a Go duration such as "30m"
+	MaxWallTime string `json:"max_wall_time,omitempty"`
+
+	// Record the session's output, and optionally its input, to an asciicast
+	// file that can be downloaded while the session is known
+	Record      bool `json:"record,omitempty"`
+	RecordInput bool `json:"record_input,omitempty"`
+
+	// Start an X server for GUI applications, shown over VNC
+	Display bool `json:"display,omitempty"`
+
+	// Kerberos credential cache forwarded by the client, base64 encoded, for
+	// profiles that allow delegation
+	KerberosTicket string `json:"kerberos_ticket,omitempty"`
+
+	// Serial backend options
+	SerialDevice string `json:"serial_device,omitempty"`
+	BaudRate     int    `json:"baud_rate,omitempty"`
+
+	// Set by the server from the authenticated identity
+	Owner  string `json:"-"`
+	Tenant string `json:"-"`
+
+	// Set by the server to the IP address the request came from, and when
+	// it was received
+	ClientIP   string    `json:"-"`
+	ReceivedAt time.Time `json:"-"`
+
+	// Set by the server for sessions created as their owner's home session
+	Home bool `json:"-"`
 }
 
-// SessionListResponse represents the response for listing sessions
+// Pane is an additional shell within a session, addressed by its ID in
+// WebSocket messages. Panes end with their session.
+type Pane struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Shell      string    `json:"shell,omitempty"`
+	Command    []string  `json:"command,omitempty"`
+	WorkingDir string    `json:"working_dir,omitempty"`
+
+	// Internal resources (not serialized to JSON)
+	PTY        *os.File  `json:"-"`
+	Process    *exec.Cmd `json:"-"`
+	InputPipe  string    `json:"-"`
+	OutputFile string    `json:"-"`
+}
+
+// PaneCreateRequest represents a request to open a pane in a session
+type PaneCreateRequest struct {
+	Shell      string            `json:"shell,omitempty"`
+	Command    []string          `json:"command,omitempty"`
+	WorkingDir string            `json:"working_dir,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+}
+
+// SessionPipe forwards the output of one session into the input of another.
+// Pipes end when either session does.
+type SessionPipe struct {
+	ID        string        `json:"id"`
+	Source    string        `json:"source"`
+	Target    string        `json:"target"`
+	Transform PipeTransform `json:"transform"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// PipeTransform controls how piped output is rewritten. Output is forwarded
+// a line at a time, with line endings normalized to "\n".
+type PipeTransform struct {
+	StripANSI bool   `json:"strip_ansi,omitempty"` // Remove escape sequences such as colors
+	Filter    string `json:"filter,omitempty"`     // Only forward lines matching this regular expression
+	Prefix    string `json:"prefix,omitempty"`     // Prepended to every forwarded line
+}
+
+// SessionPipeRequest represents a request to pipe a session into another
+type SessionPipeRequest struct {
+	Target    string        `json:"target"`
+	Transform PipeTransform `json:"transform"`
+}
+
+// SessionCompletion is posted to a session's callback URL when it stops
+type SessionCompletion struct {
+	SessionID       string            `json:"session_id"`
+	Status          SessionStatus     `json:"status"`
+	ExitCode        *int              `json:"exit_code,omitempty"` // Absent if the process was killed by a signal
+	Error           string            `json:"error,omitempty"`
+	Reason          string            `json:"termination_reason,omitempty"`
+	Owner           string            `json:"owner,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	EndedAt         time.Time         `json:"ended_at"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	OutputBytes     int64             `json:"output_bytes"`
+}
+
+// TerminalCapabilities describes what the client's terminal emulator supports
+type TerminalCapabilities struct {
+	Term  string `json:"term,omitempty"`  // Preferred TERM, e.g. xterm-256color or xterm-direct
+	Color string `json:"color,omitempty"` // Color depth: "16", "256" or "truecolor"
+}
+
+// LocaleSettings selects the locale of a session
+type LocaleSettings struct {
+	Lang       string            `json:"lang,omitempty"`       // LANG, e.g. de_DE.UTF-8
+	Categories map[string]string `json:"categories,omitempty"` // LC_* overrides, e.g. {"LC_TIME": "en_GB.UTF-8"}
+}
+
+// KeyboardSettings controls how keystrokes are translated before reaching a session
+type KeyboardSettings struct {
+	Backspace  string `json:"backspace,omitempty"`   // "del" (default) sends ^?, "bs" sends ^H
+	CursorKeys string `json:"cursor_keys,omitempty"` // "normal" (default) or "application" to always send SS3 sequences
+}
+
+// SessionPriority sets the CPU and IO scheduling priority of a session's processes
+type SessionPriority struct {
+	Nice    int    `json:"nice"`               // CPU nice value, 0 (default) to 19 (lowest)
+	IOClass string `json:"io_class,omitempty"` // "best-effort" or "idle"; empty leaves IO priority unchanged
+	IOLevel int    `json:"io_level,omitempty"` // Best-effort level, 0 (highest) to 7 (lowest)
+}
+
+// OutputBuffering sets how a session's output is read and batched before it
+// is written and relayed to clients. Larger buffers and a flush latency suit
+// throughput, such as CI logs; the defaults suit interactive typing.
+type OutputBuffering struct {
+	BufferSize   int    `json:"buffer_size,omitempty"`   // Bytes of output read at once
+	FlushLatency string `json:"flush_latency,omitempty"` // How long to wait for more output, as a Go duration such as "20ms"
+}
+
+// SessionResources reports the live resource usage of a session
+type SessionResources struct {
+	Processes   int     `json:"processes"`
+	CPUSeconds  float64 `json:"cpu_seconds"`
+	MemoryBytes int64   `json:"memory_bytes"`
+	BytesIn     int64   `json:"bytes_in"`
+	BytesOut    int64   `json:"bytes_out"`
+	Goroutines  int     `json:"goroutines"` // Server goroutines working for the session
+
+	// Output not written to disk for exceeding the output rate limit
+	OutputDropped int64 `json:"output_dropped_bytes"`
+}
+
+// PathCompletion is one filesystem entry matching a partial path
+type PathCompletion struct {
+	Value string `json:"value"` // The completed path, e.g. src/main.go or src/internal/
+	Type  string `json:"type"`  // "file" or "directory"
+}
+
+// PathCompletions lists the completions of a partial path in a session
+type PathCompletions struct {
+	Cwd         string           `json:"cwd"` // Directory relative paths were resolved against
+	Completions []PathCompletion `json:"completions"`
+	Truncated   bool             `json:"truncated,omitempty"`
+}
+
+// SessionUpdateRequest represents changes to a running session
+type SessionUpdateRequest struct {
+	Priority *SessionPriority `json:"priority,omitempty"`
+}
+
+// SessionResizeRequest represents new terminal dimensions for a session
+type SessionResizeRequest struct {
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+}
+
+// ScreenSnapshot is what a session's terminal shows, as emulated on the
+// server. Lines hold the text of each row, without trailing blanks.
+type ScreenSnapshot struct {
+	Rows          int            `json:"rows"`
+	Cols          int            `json:"cols"`
+	Lines         []string       `json:"lines"`
+	CursorRow     int            `json:"cursor_row"`
+	CursorCol     int            `json:"cursor_col"`
+	CursorVisible bool           `json:"cursor_visible"`
+	AltScreen     bool           `json:"alt_screen"`
+	Title         string         `json:"title,omitempty"`
+	Cells         [][]ScreenCell `json:"cells,omitempty"`
+}
+
+// ScreenCell is one character of a screen and its attributes
+type ScreenCell struct {
+	Char string `json:"char"`
+	ScreenStyle
+}
+
+// ScreenSpan is a run of characters on one row of a screen that share their
+// attributes, starting at Col
+type ScreenSpan struct {
+	Row  int    `json:"row"`
+	Col  int    `json:"col"`
+	Text string `json:"text"`
+	ScreenStyle
+}
+
+// ScreenStyle is how characters of a screen are drawn. Colors are a palette
+// index or #rrggbb, and empty for the terminal's default.
+type ScreenStyle struct {
+	FG        string `json:"fg,omitempty"`
+	BG        string `json:"bg,omitempty"`
+	Bold      bool   `json:"bold,omitempty"`
+	Italic    bool   `json:"italic,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+	Reverse   bool   `json:"reverse,omitempty"`
+	Blink     bool   `json:"blink,omitempty"`
+}
+
+// SessionSignalRequest names a signal to send to a session's shell
+type SessionSignalRequest struct {
+	Signal string `json:"signal"`
+}
+
+// SessionListResponse represents the response for listing sessions: a page
+// of Count sessions starting at Offset, out of Total matching the filters
 type SessionListResponse struct {
-	Sessions []Session `json:"sessions"`
-	Count    int       `json:"count"`
+	Sessions []SessionSnapshot `json:"sessions"`
+	Count    int               `json:"count"`
+	Total    int               `json:"total"`
+	Retained int               `json:"retained"` // Sessions among the total that have ended and are only kept for a while
+	Limit    int               `json:"limit"`
+	Offset   int               `json:"offset"`
 }
 
 // SessionResponse represents a single session response
 type SessionResponse struct {
-	Session Session `json:"session"`
+	Session SessionSnapshot `json:"session"`
 }
 
 // IsActive returns true if the session is in an active state
 func (s *Session) IsActive() bool {
-	return s.Status == SessionStatusStarting || s.Status == SessionStatusRunning
+	return s.Status == SessionStatusStarting || s.Status == SessionStatusRunning || s.Status == SessionStatusPaused
 }
 
 // CanTerminate returns true if the session can be terminated
 func (s *Session) CanTerminate() bool {
-	return s.Status == SessionStatusStarting || s.Status == SessionStatusRunning
+	return s.Status == SessionStatusPending || s.Status == SessionStatusStarting || s.Status == SessionStatusRunning ||
+		s.Status == SessionStatusPaused
+}
+
+// HasPTY reports whether the session runs on a PTY rather than plain pipes
+func (s *Session) HasPTY() bool {
+	return s.AllocatePTY == nil || *s.AllocatePTY
 }
 
 // UpdateLastActive updates the last active timestamp
@@ -0,0 +1,124 @@
+package types
+
+import "time"
+
+// SessionSnapshot is a session as returned by the API: a copy of its public
+// state taken when the response is built, leaving out server internals such
+// as pipe paths, plus figures computed at that moment
+type SessionSnapshot struct {
+	ID           string        `json:"id"`
+	Status       SessionStatus `json:"status"`
+	CreatedAt    time.Time     `json:"created_at"`
+	LastActiveAt time.Time     `json:"last_active_at"`
+
+	Owner    string            `json:"owner,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Home     bool              `json:"home,omitempty"`
+
+	Profile      string         `json:"profile,omitempty"`
+	Backend      SessionBackend `json:"backend"`
+	SerialDevice string         `json:"serial_device,omitempty"`
+	BaudRate     int            `json:"baud_rate,omitempty"`
+	Container    string         `json:"container,omitempty"`
+
+	Term      string            `json:"term,omitempty"`
+	TrueColor bool              `json:"true_color,omitempty"`
+	Locale    string            `json:"locale,omitempty"`
+	Keyboard  *KeyboardSettings `json:"keyboard,omitempty"`
+	Priority  *SessionPriority  `json:"priority,omitempty"`
+
+	OutputBuffering *OutputBuffering `json:"output_buffering,omitempty"`
+
+	Shell       string   `json:"shell"`
+	Command     []string `json:"command"`
+	WorkingDir  string   `json:"working_dir"`
+	AllocatePTY *bool    `json:"allocate_pty,omitempty"`
+	RunAs       string   `json:"run_as,omitempty"`
+	Panes       []Pane   `json:"panes,omitempty"`
+
+	Broadcasting bool `json:"broadcasting"`
+	Recording    bool `json:"recording,omitempty"`
+
+	Display string `json:"display,omitempty"`
+
+	PID               int        `json:"pid,omitempty"`
+	ErrorMessage      string     `json:"error_message,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	TerminationReason string     `json:"termination_reason,omitempty"`
+	EndedAt           *time.Time `json:"ended_at,omitempty"`
+	RetainedUntil     *time.Time `json:"retained_until,omitempty"` // Set for ended sessions, which are removed then
+
+	// Computed when the snapshot is taken
+	UptimeSeconds float64 `json:"uptime_seconds,omitempty"` // Only for active sessions
+	ExitCode      *int    `json:"exit_code,omitempty"`      // Once the process has exited normally
+	ClientCount   int     `json:"client_count"`             // Connected clients, including broadcast viewers
+}
+
+// NewSessionSnapshot copies a session for an API response
+func NewSessionSnapshot(session *Session, clientCount int) SessionSnapshot {
+	snapshot := SessionSnapshot{
+		ID:                session.ID,
+		Status:            session.Status,
+		CreatedAt:         session.CreatedAt,
+		LastActiveAt:      session.LastActiveAt,
+		Owner:             session.Owner,
+		Tenant:            session.Tenant,
+		Metadata:          copyStringMap(session.Metadata),
+		Name:              session.Name,
+		Home:              session.Home,
+		Labels:            copyStringMap(session.Labels),
+		Profile:           session.Profile,
+		Backend:           session.Backend,
+		SerialDevice:      session.SerialDevice,
+		BaudRate:          session.BaudRate,
+		Container:         session.Container,
+		Term:              session.Term,
+		TrueColor:         session.TrueColor,
+		Locale:            session.Locale,
+		Keyboard:          session.Keyboard,
+		Priority:          session.Priority,
+		OutputBuffering:   session.OutputBuffering,
+		Shell:             session.Shell,
+		Command:           append([]string(nil), session.Command...),
+		WorkingDir:        session.WorkingDir,
+		AllocatePTY:       session.AllocatePTY,
+		RunAs:             session.RunAs,
+		Broadcasting:      session.Broadcasting,
+		Recording:         session.Recording,
+		Display:           session.Display,
+		PID:               session.PID,
+		ErrorMessage:      session.ErrorMessage,
+		ExpiresAt:         session.ExpiresAt,
+		TerminationReason: session.TerminationReason,
+		EndedAt:           session.EndedAt,
+		RetainedUntil:     session.RetainedUntil,
+		ExitCode:          session.ExitCode,
+		ClientCount:       clientCount,
+	}
+
+	for _, pane := range session.Panes {
+		snapshot.Panes = append(snapshot.Panes, *pane)
+	}
+
+	if session.IsActive() {
+		snapshot.UptimeSeconds = time.Since(session.CreatedAt).Seconds()
+	}
+
+	return snapshot
+}
+
+// copyStringMap returns a copy of m, or nil if it is empty
+func copyStringMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
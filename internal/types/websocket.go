@@ -14,12 +14,24 @@ const (
 	MessageTypeResize MessageType = "resize" // Terminal resize request
 	MessageTypePing   MessageType = "ping"   // Ping for connection health
 
+	// Types a stored snippet into the session; data is the snippet ID
+	MessageTypeRunSnippet MessageType = "run_snippet"
+
 	// Server to client messages
 	MessageTypeOutput    MessageType = "output"    // Terminal output to client
 	MessageTypeStatus    MessageType = "status"    // Session status updates
 	MessageTypeError     MessageType = "error"     // Error messages
 	MessageTypePong      MessageType = "pong"      // Pong response to ping
 	MessageTypeConnected MessageType = "connected" // Connection confirmation
+	MessageTypeLocked    MessageType = "locked"    // Session locked after inactivity
+	MessageTypeUnlocked  MessageType = "unlocked"  // Session unlocked after re-authentication
+	MessageTypeBanner    MessageType = "banner"    // Announcement shown above the terminal
+	MessageTypeResume    MessageType = "resume"    // Token for resuming the connection after a drop
+	MessageTypeImage     MessageType = "image"     // Inline image from the output
+	MessageTypeScreen    MessageType = "screen"    // Changes to the terminal's screen, as output
+	MessageTypeCells     MessageType = "cells"     // Changes to the terminal's screen, as cells
+	MessageTypePresence  MessageType = "presence"  // Clients attached to the session
+	MessageTypeClosing   MessageType = "closing"   // Why the server is closing the connection, sent last
 )
 
 // WebSocketMessage represents a message sent over WebSocket
@@ -29,15 +41,98 @@ type WebSocketMessage struct {
 	SessionID string      `json:"session_id,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
 
-	// For resize messages
+	// For resize messages, and screen messages with the size of the screen
 	Rows int `json:"rows,omitempty"`
 	Cols int `json:"cols,omitempty"`
 
-	// For status messages
-	Status string `json:"status,omitempty"`
+	// For status messages, with the exit code once the process has exited
+	// normally
+	Status   string `json:"status,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
 
 	// For error messages
 	Error string `json:"error,omitempty"`
+
+	// For closing messages: the close code the connection is closed with,
+	// and why
+	CloseCode   int    `json:"close_code,omitempty"`
+	CloseReason string `json:"reason,omitempty"`
+
+	// For closing messages of a server shutting down: seconds to wait
+	// before reconnecting, and another node to reconnect to meanwhile
+	RetryAfter   int    `json:"retry_after,omitempty"`
+	ReconnectURL string `json:"reconnect_url,omitempty"`
+
+	// For banner messages: "info", "warning" or "critical"
+	Level string `json:"level,omitempty"`
+
+	// Pane of the session the message is for; empty means the session's own shell
+	Pane string `json:"pane,omitempty"`
+
+	// For output of sessions without a PTY: "stdout" or "stderr"
+	Channel string `json:"channel,omitempty"`
+
+	// For output replayed from before the client attached
+	Scrollback bool `json:"scrollback,omitempty"`
+
+	// For output messages: where the output ends in its stream, which a
+	// reconnecting client passes back to receive only what it missed
+	Offset int64 `json:"offset,omitempty"`
+
+	// For image messages: "sixel" or "iterm2", and the ID of the image.
+	// Data holds the base64 escape sequence, and is left out for images
+	// already sent to the client.
+	ImageFormat string `json:"format,omitempty"`
+	ImageID     string `json:"image_id,omitempty"`
+
+	// For cells messages: the cells that changed, and where the cursor is.
+	// Reset means the screen is to be cleared before drawing them.
+	Spans  []ScreenSpan  `json:"spans,omitempty"`
+	Cursor *ScreenCursor `json:"cursor,omitempty"`
+	Reset  bool          `json:"reset,omitempty"`
+
+	// For resume messages: the token a reconnecting client passes back
+	ResumeToken string `json:"resume_token,omitempty"`
+
+	// For live output and pong messages: the server's monotonic clock, in
+	// nanoseconds since the epoch given in the connected message
+	Monotonic int64 `json:"monotonic_ns,omitempty"`
+
+	// For connected messages: the server clock behind monotonic timestamps
+	Clock *ClockInfo `json:"clock,omitempty"`
+
+	// For presence messages: who is attached to the session
+	Presence *Presence `json:"presence,omitempty"`
+}
+
+// Presence lists the clients attached to a session. Broadcast viewers are
+// only counted.
+type Presence struct {
+	Clients []PresenceClient `json:"clients"`
+	Viewers int              `json:"viewers"`
+}
+
+// PresenceClient is a client attached to a session
+type PresenceClient struct {
+	ClientID string `json:"client_id"`
+	User     string `json:"user,omitempty"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// ScreenCursor is the position of the cursor on a screen
+type ScreenCursor struct {
+	Row     int  `json:"row"`
+	Col     int  `json:"col"`
+	Visible bool `json:"visible"`
+}
+
+// ClockInfo relates the server's monotonic timestamps to wall-clock time.
+// Monotonic time never jumps when the server's wall clock is adjusted, so
+// differences between timestamps are exact.
+type ClockInfo struct {
+	Epoch     time.Time `json:"epoch"`        // Wall-clock time at monotonic zero
+	Monotonic int64     `json:"monotonic_ns"` // Monotonic time when the message was sent
+	WallTime  time.Time `json:"wall_time"`    // Wall-clock time when the message was sent
 }
 
 // NewWebSocketMessage creates a new WebSocket message
@@ -78,6 +173,29 @@ func NewOutputMessage(sessionID, data string) *WebSocketMessage {
 	}
 }
 
+// NewBannerMessage creates a banner message
+func NewBannerMessage(sessionID, text, level string) *WebSocketMessage {
+	return &WebSocketMessage{
+		Type:      MessageTypeBanner,
+		SessionID: sessionID,
+		Data:      text,
+		Level:     level,
+		Timestamp: time.Now(),
+	}
+}
+
+// NewClosingMessage creates a message telling the client why the server is
+// closing its connection
+func NewClosingMessage(sessionID string, code int, reason string) *WebSocketMessage {
+	return &WebSocketMessage{
+		Type:        MessageTypeClosing,
+		SessionID:   sessionID,
+		CloseCode:   code,
+		CloseReason: reason,
+		Timestamp:   time.Now(),
+	}
+}
+
 // ToJSON converts the message to JSON
 func (m *WebSocketMessage) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
@@ -95,9 +213,10 @@ func FromJSON(data []byte) (*WebSocketMessage, error) {
 // IsValid checks if the message is valid
 func (m *WebSocketMessage) IsValid() bool {
 	switch m.Type {
-	case MessageTypeInput, MessageTypeResize, MessageTypePing:
+	case MessageTypeInput, MessageTypeResize, MessageTypePing, MessageTypeRunSnippet:
 		return true // Client messages
-	case MessageTypeOutput, MessageTypeStatus, MessageTypeError, MessageTypePong, MessageTypeConnected:
+	case MessageTypeOutput, MessageTypeStatus, MessageTypeError, MessageTypePong, MessageTypeConnected,
+		MessageTypeLocked, MessageTypeUnlocked, MessageTypeBanner, MessageTypeResume, MessageTypePresence, MessageTypeClosing:
 		return true // Server messages
 	default:
 		return false
@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"time"
+)
+
+// ClientInfo describes a client attached to a session
+type ClientInfo struct {
+	ID              string    `json:"id"`
+	User            string    `json:"user,omitempty"`
+	RemoteAddr      string    `json:"remote_addr"`
+	UserAgent       string    `json:"user_agent"`
+	ConnectedAt     time.Time `json:"connected_at"`
+	ReadOnly        bool      `json:"read_only"`
+	BroadcastViewer bool      `json:"broadcast_viewer"`
+}
+
+// SessionActivity describes what the hub knows about a session's clients
+type SessionActivity struct {
+	Clients   []ClientInfo `json:"clients"`
+	Locked    bool         `json:"locked"`
+	LastInput *time.Time   `json:"last_input,omitempty"`
+}
+
+// kickRequest asks the hub to disconnect a client
+type kickRequest struct {
+	clientID string
+	reply    chan bool
+}
+
+// Activity returns a snapshot of attached clients and lock state by session ID
+func (h *Hub) Activity() map[string]*SessionActivity {
+	reply := make(chan map[string]*SessionActivity, 1)
+	h.activityRequests <- reply
+	return <-reply
+}
+
+// KickClient disconnects a client, reporting whether it was found
+func (h *Hub) KickClient(clientID string) bool {
+	reply := make(chan bool, 1)
+	h.kickRequests <- &kickRequest{clientID: clientID, reply: reply}
+	return <-reply
+}
+
+// snapshotActivity copies the hub's per-session state for other goroutines
+func (h *Hub) snapshotActivity() map[string]*SessionActivity {
+	activity := make(map[string]*SessionActivity)
+
+	get := func(sessionID string) *SessionActivity {
+		if activity[sessionID] == nil {
+			activity[sessionID] = &SessionActivity{Clients: []ClientInfo{}}
+		}
+		return activity[sessionID]
+	}
+
+	for sessionID, sessionClients := range h.clients {
+		entry := get(sessionID)
+		for client := range sessionClients {
+			entry.Clients = append(entry.Clients, ClientInfo{
+				ID:              client.id,
+				User:            client.user,
+				RemoteAddr:      client.remoteAddr,
+				UserAgent:       client.userAgent,
+				ConnectedAt:     client.connectedAt,
+				ReadOnly:        client.readOnly.Load(),
+				BroadcastViewer: client.broadcastViewer,
+			})
+		}
+	}
+
+	for sessionID, lastInput := range h.lastInput {
+		lastInput := lastInput
+		get(sessionID).LastInput = &lastInput
+	}
+
+	for sessionID := range h.lockedSessions {
+		get(sessionID).Locked = true
+	}
+
+	return activity
+}
+
+// kickClient disconnects a client by ID
+func (h *Hub) kickClient(clientID string) bool {
+	for _, sessionClients := range h.clients {
+		for client := range sessionClients {
+			if client.id == clientID {
+				client.setClose(CloseKicked, "Disconnected by an administrator")
+				h.removeClient(client)
+				h.revokeResumeToken(client)
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"fmt"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Banner levels, which clients use to style announcements
+const (
+	BannerLevelInfo     = "info"
+	BannerLevelWarning  = "warning"
+	BannerLevelCritical = "critical"
+)
+
+// bannerRequest asks the hub to show a banner to the clients of some sessions
+type bannerRequest struct {
+	sessionIDs []string // Empty means every session
+	text       string
+	level      string
+	reply      chan int
+}
+
+// ValidateBannerLevel checks that a banner level is one clients know how to show
+func ValidateBannerLevel(level string) error {
+	switch level {
+	case BannerLevelInfo, BannerLevelWarning, BannerLevelCritical:
+		return nil
+	default:
+		return fmt.Errorf("invalid banner level: %s", level)
+	}
+}
+
+// SetMOTD sets the message of the day shown to clients as they attach. It
+// must be called before Run.
+func (h *Hub) SetMOTD(motd string) {
+	h.motd = motd
+}
+
+// SendBanner shows a banner to the clients of the given sessions, or of every
+// session when none are given, and returns how many clients it reached
+func (h *Hub) SendBanner(sessionIDs []string, text, level string) int {
+	reply := make(chan int, 1)
+	h.bannerRequests <- &bannerRequest{sessionIDs: sessionIDs, text: text, level: level, reply: reply}
+	return <-reply
+}
+
+// sendBanner delivers a banner to the clients of the requested sessions
+func (h *Hub) sendBanner(request *bannerRequest) int {
+	sessionIDs := request.sessionIDs
+	if len(sessionIDs) == 0 {
+		for sessionID := range h.clients {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+
+	delivered := 0
+	for _, sessionID := range sessionIDs {
+		sessionClients := h.clients[sessionID]
+		if len(sessionClients) == 0 {
+			continue
+		}
+
+		h.broadcast(sessionID, types.NewBannerMessage(sessionID, request.text, request.level))
+		delivered += len(sessionClients)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"sessions": len(sessionIDs),
+		"clients":  delivered,
+		"level":    request.level,
+	}).Info("Banner sent")
+
+	return delivered
+}
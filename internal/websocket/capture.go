@@ -0,0 +1,245 @@
+package websocket
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Capture frame directions
+const (
+	CaptureWSIn   = "ws_in"   // Message received from a client
+	CaptureWSOut  = "ws_out"  // Message sent to a client
+	CapturePTYIn  = "pty_in"  // Input written to the session's shell or a pane
+	CapturePTYOut = "pty_out" // Output read from the session's shell or a pane
+)
+
+const (
+	// MaxCaptureDuration bounds how long a capture may record
+	MaxCaptureDuration = 5 * time.Minute
+
+	// maxCaptureFrames and maxCapturePayloadBytes bound the size of a capture;
+	// frames beyond them are dropped and the capture marked truncated
+	maxCaptureFrames       = 100000
+	maxCapturePayloadBytes = 16 << 20
+
+	// maxCaptures is how many captures, running or finished, are kept for
+	// download
+	maxCaptures = 16
+)
+
+var (
+	// ErrCaptureRunning is returned when a session is already being captured
+	ErrCaptureRunning = errors.New("a capture is already running for this session")
+	// ErrCaptureNotFound is returned for unknown capture IDs
+	ErrCaptureNotFound = errors.New("capture not found")
+)
+
+// CaptureFrame is one message or chunk of terminal data seen during a capture
+type CaptureFrame struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Pane      string    `json:"pane,omitempty"`
+	Size      int       `json:"size"`
+	Payload   []byte    `json:"payload,omitempty"` // Base64 encoded, only when payloads are captured
+}
+
+// Capture records the traffic of one session for a limited time, for
+// protocol debugging
+type Capture struct {
+	ID        string         `json:"id"`
+	SessionID string         `json:"session_id"`
+	Owner     string         `json:"owner,omitempty"` // Owner of the captured session
+	StartedBy string         `json:"started_by,omitempty"`
+	StartedAt time.Time      `json:"started_at"`
+	EndsAt    time.Time      `json:"ends_at"`
+	Payloads  bool           `json:"payloads"`
+	Complete  bool           `json:"complete"`
+	Truncated bool           `json:"truncated,omitempty"`
+	Frames    []CaptureFrame `json:"frames"`
+
+	payloadBytes int
+	mutex        sync.Mutex
+}
+
+// StartCapture records the traffic of a session owned by owner for the
+// given duration, including payloads if requested
+func (h *Hub) StartCapture(sessionID, owner, startedBy string, duration time.Duration, payloads bool) (*Capture, error) {
+	h.captureMutex.Lock()
+	defer h.captureMutex.Unlock()
+
+	if _, running := h.activeCaptures[sessionID]; running {
+		return nil, ErrCaptureRunning
+	}
+
+	now := time.Now()
+	capture := &Capture{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Owner:     owner,
+		StartedBy: startedBy,
+		StartedAt: now,
+		EndsAt:    now.Add(duration),
+		Payloads:  payloads,
+		Frames:    []CaptureFrame{},
+	}
+
+	h.dropOldCaptures()
+	h.captures[capture.ID] = capture
+	h.activeCaptures[sessionID] = capture
+
+	time.AfterFunc(duration, func() {
+		h.finishCapture(capture)
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"capture_id": capture.ID,
+		"duration":   duration.String(),
+		"payloads":   payloads,
+	}).Info("Traffic capture started")
+
+	return capture, nil
+}
+
+// GetCapture returns a copy of a capture, which may still be running
+func (h *Hub) GetCapture(captureID string) (*Capture, error) {
+	h.captureMutex.Lock()
+	capture, exists := h.captures[captureID]
+	h.captureMutex.Unlock()
+
+	if !exists {
+		return nil, ErrCaptureNotFound
+	}
+
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+
+	return &Capture{
+		ID:        capture.ID,
+		SessionID: capture.SessionID,
+		Owner:     capture.Owner,
+		StartedBy: capture.StartedBy,
+		StartedAt: capture.StartedAt,
+		EndsAt:    capture.EndsAt,
+		Payloads:  capture.Payloads,
+		Complete:  capture.Complete,
+		Truncated: capture.Truncated,
+		Frames:    append([]CaptureFrame(nil), capture.Frames...),
+	}, nil
+}
+
+// PurgeCaptures deletes the captures of a user's sessions, running or
+// finished, returning their IDs. With dryRun, they are only listed.
+func (h *Hub) PurgeCaptures(owner string, dryRun bool) []string {
+	h.captureMutex.Lock()
+	defer h.captureMutex.Unlock()
+
+	captureIDs := []string{}
+	for captureID, capture := range h.captures {
+		if capture.Owner != owner {
+			continue
+		}
+		captureIDs = append(captureIDs, captureID)
+		if dryRun {
+			continue
+		}
+
+		delete(h.captures, captureID)
+		if h.activeCaptures[capture.SessionID] == capture {
+			delete(h.activeCaptures, capture.SessionID)
+		}
+	}
+	sort.Strings(captureIDs)
+	return captureIDs
+}
+
+// finishCapture stops recording once a capture's time is up
+func (h *Hub) finishCapture(capture *Capture) {
+	h.captureMutex.Lock()
+	if h.activeCaptures[capture.SessionID] == capture {
+		delete(h.activeCaptures, capture.SessionID)
+	}
+	h.captureMutex.Unlock()
+
+	capture.mutex.Lock()
+	capture.Complete = true
+	frames := len(capture.Frames)
+	capture.mutex.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": capture.SessionID,
+		"capture_id": capture.ID,
+		"frames":     frames,
+	}).Info("Traffic capture finished")
+}
+
+// dropOldCaptures makes room for a new capture by forgetting the oldest
+// finished ones (assumes captureMutex is held)
+func (h *Hub) dropOldCaptures() {
+	if len(h.captures) < maxCaptures {
+		return
+	}
+
+	finished := make([]*Capture, 0, len(h.captures))
+	for _, capture := range h.captures {
+		if h.activeCaptures[capture.SessionID] != capture {
+			finished = append(finished, capture)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].StartedAt.Before(finished[j].StartedAt)
+	})
+
+	for _, capture := range finished {
+		if len(h.captures) < maxCaptures {
+			break
+		}
+		delete(h.captures, capture.ID)
+	}
+}
+
+// recordFrame adds traffic to the session's running capture, if any
+func (h *Hub) recordFrame(sessionID, direction, clientID, pane string, data []byte) {
+	h.captureMutex.Lock()
+	capture, exists := h.activeCaptures[sessionID]
+	h.captureMutex.Unlock()
+
+	if !exists {
+		return
+	}
+
+	capture.record(CaptureFrame{
+		Time:      time.Now(),
+		Direction: direction,
+		ClientID:  clientID,
+		Pane:      pane,
+		Size:      len(data),
+	}, data)
+}
+
+// record appends a frame, with its payload if the capture keeps them
+func (c *Capture) record(frame CaptureFrame, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Complete {
+		return
+	}
+
+	if len(c.Frames) >= maxCaptureFrames || (c.Payloads && c.payloadBytes+len(data) > maxCapturePayloadBytes) {
+		c.Truncated = true
+		return
+	}
+
+	if c.Payloads {
+		frame.Payload = append([]byte(nil), data...)
+		c.payloadBytes += len(data)
+	}
+	c.Frames = append(c.Frames, frame)
+}
@@ -1,11 +1,14 @@
 package websocket
 
 import (
+	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/piyushgupta53/webterm/internal/types"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -22,10 +25,10 @@ const (
 	maxMessageSize = 512
 )
 
-// Client represents a WebSocket client connection
+// Client represents a browser connection to a session
 type Client struct {
-	// Websocket connection
-	conn *websocket.Conn
+	// Connection to the browser, usually a WebSocket
+	transport Transport
 
 	// Hub that manages this client
 	hub *Hub
@@ -33,12 +36,53 @@ type Client struct {
 	// Session ID this client is connected to
 	sessionID string
 
-	// Buffered channel of outbound messages
-	send chan *types.WebSocketMessage
+	// Buffered channel of outbound messages, already encoded
+	send chan []byte
 
 	// Client identifier
 	id string
 
+	// Read-only clients receive output but cannot send input or resize. Set
+	// by the hub as the client registers, while its read pump may be running.
+	readOnly atomic.Bool
+
+	// Whether the client joined through a public broadcast link
+	broadcastViewer bool
+
+	// Authenticated user, empty for broadcast viewers
+	user string
+
+	// Images sent to a client that receives inline images as image
+	// messages; nil for clients that receive them as output
+	images *sentImages
+
+	// How the client is sent changes to its session's screen instead of
+	// output; empty for clients sent output
+	screenUpdates ScreenUpdates
+
+	// Set when a screen update could not be sent, so the client is sent the
+	// whole screen next. Guarded by the session's output watcher.
+	screenStale bool
+
+	// Token this client can resume with, and the token and output offsets
+	// it resumes from
+	resumeToken        string
+	resumeFrom         string
+	resumeOffset       int64
+	resumeStderrOffset int64
+
+	// Trace of the request the client connected with, linked from the
+	// spans of its messages
+	traceLink trace.SpanContext
+
+	// Close code and reason sent in the close frame, set by the hub before
+	// it closes the send channel; zero for a normal closure
+	closeCode   int
+	closeReason string
+
+	// When and where to reconnect, told to clients closed by a shutdown
+	reconnect *ReconnectAdvice
+
 	// Connection metadata
 	remoteAddr  string
 	userAgent   string
@@ -47,33 +91,65 @@ type Client struct {
 
 // NewClient creates a new WebSocket client
 func NewClient(conn *websocket.Conn, hub *Hub, sessionID, clientID, userAgent string) *Client {
+	return NewTransportClient(newWSTransport(conn), hub, sessionID, clientID, userAgent)
+}
 
+// NewTransportClient creates a client that talks to the browser over an arbitrary transport
+func NewTransportClient(transport Transport, hub *Hub, sessionID, clientID, userAgent string) *Client {
 	return &Client{
-		conn:        conn,
+		transport:   transport,
 		hub:         hub,
 		sessionID:   sessionID,
 		id:          clientID,
-		send:        make(chan *types.WebSocketMessage, 256), // Buffered channel to prevent blocking
-		remoteAddr:  conn.RemoteAddr().String(),
+		send:        make(chan []byte, 256), // Buffered channel to prevent blocking
+		remoteAddr:  transport.RemoteAddr(),
 		userAgent:   userAgent,
 		connectedAt: time.Now(),
 	}
 }
 
-// readPump pumps messages from the WebSocket connection to the hub
+// NewBroadcastViewer creates a read-only client joining through a broadcast link
+func NewBroadcastViewer(conn *websocket.Conn, hub *Hub, sessionID, clientID, userAgent string) *Client {
+	client := NewClient(conn, hub, sessionID, clientID, userAgent)
+	client.readOnly.Store(true)
+	client.broadcastViewer = true
+	return client
+}
+
+// SetUser records the authenticated user behind the client. It must be
+// called before the client is registered.
+func (c *Client) SetUser(user string) {
+	c.user = user
+}
+
+// SetReadOnly has the client receive output and status but have its input
+// and resize messages rejected, for observers of a session. It must be called
+// before the client is registered.
+func (c *Client) SetReadOnly() {
+	c.readOnly.Store(true)
+}
+
+// EnableInlineImages has Sixel and iTerm2 inline images in the output sent
+// to the client as image messages. It must be called before the client is
+// registered.
+func (c *Client) EnableInlineImages() {
+	c.images = &sentImages{}
+}
+
+// SetScreenUpdates has the client sent the changes to its session's screen
+// instead of the output, where the terminal state is kept. It must be called
+// before the client is registered.
+func (c *Client) SetScreenUpdates(updates ScreenUpdates) {
+	c.screenUpdates = updates
+}
+
+// readPump pumps messages from the transport to the hub
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
-		c.conn.Close()
+		c.transport.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
-		return nil
-	})
-
 	logrus.WithFields(logrus.Fields{
 		"client_id":   c.id,
 		"session_id":  c.sessionID,
@@ -81,8 +157,8 @@ func (c *Client) readPump() {
 	}).Info("Starting WebSocket read pump")
 
 	for {
-		// Read message from websocket
-		_, messageData, err := c.conn.ReadMessage()
+		// Read message from transport
+		messageData, err := c.transport.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				logrus.WithError(err).WithFields(logrus.Fields{
@@ -92,6 +168,7 @@ func (c *Client) readPump() {
 			}
 			break
 		}
+		c.hub.recordFrame(c.sessionID, CaptureWSIn, c.id, "", messageData)
 
 		// Parse message
 		message, err := types.FromJSON(messageData)
@@ -111,14 +188,29 @@ func (c *Client) readPump() {
 		// Set session ID from client context
 		message.SessionID = c.sessionID
 
-		// Handle message based on type
+		// Read-only clients may only ping
+		if c.readOnly.Load() && (message.Type == types.MessageTypeInput || message.Type == types.MessageTypeResize ||
+			message.Type == types.MessageTypeRunSnippet) {
+			c.sendError("Read-only connection")
+			continue
+		}
+
+		// Handle message based on type. Pings are too frequent to trace.
 		switch message.Type {
 		case types.MessageTypeInput:
-			c.handleInputMessage(message)
+			ctx, span := c.startMessageSpan(message)
+			c.handleInputMessage(ctx, message)
+			span.End()
 		case types.MessageTypeResize:
-			c.handleResizeMessage(message)
+			ctx, span := c.startMessageSpan(message)
+			c.handleResizeMessage(ctx, message)
+			span.End()
 		case types.MessageTypePing:
 			c.handlePingMessage(message)
+		case types.MessageTypeRunSnippet:
+			ctx, span := c.startMessageSpan(message)
+			c.handleRunSnippetMessage(ctx, message)
+			span.End()
 		default:
 			logrus.WithFields(logrus.Fields{
 				"client_id":    c.id,
@@ -128,12 +220,12 @@ func (c *Client) readPump() {
 	}
 }
 
-// writePump pumps messages from the hub to the WebSocket connection
+// writePump pumps messages from the hub to the transport
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
-		c.conn.Close()
+		c.transport.Close()
 	}()
 
 	logrus.WithFields(logrus.Fields{
@@ -144,30 +236,24 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		case messageData, ok := <-c.send:
 			if !ok {
 				// hub closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				if notifier, ok := c.transport.(closeNotifier); ok {
+					notifier.NotifyClose(c.closeFrame())
+				}
 				return
 			}
 
-			// Convert message to JSON
-			messageData, err := message.ToJSON()
-			if err != nil {
-				logrus.WithError(err).WithField("client_id", c.id).Error("Failed to marshal message")
-				continue
-			}
-
 			// Send message
-			if err := c.conn.WriteMessage(websocket.TextMessage, messageData); err != nil {
+			c.hub.recordFrame(c.sessionID, CaptureWSOut, c.id, "", messageData)
+			if err := c.transport.WriteMessage(messageData); err != nil {
 				logrus.WithError(err).WithField("client_id", c.id).Error("Failed to write WebSocket message")
 				return
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.transport.Ping(); err != nil {
 				return
 			}
 		}
@@ -175,10 +261,14 @@ func (c *Client) writePump() {
 }
 
 // handleInputMessage processes input messages from the client
-func (c *Client) handleInputMessage(message *types.WebSocketMessage) {
+func (c *Client) handleInputMessage(ctx context.Context, message *types.WebSocketMessage) {
 	// Send input to session's input pipe
 	sessionInput := &SessionInput{
+		Ctx:       ctx,
 		SessionID: c.sessionID,
+		Pane:      message.Pane,
+		ClientID:  c.id,
+		User:      c.user,
 		Data:      message.Data,
 	}
 
@@ -186,10 +276,13 @@ func (c *Client) handleInputMessage(message *types.WebSocketMessage) {
 }
 
 // handleResizeMessage processes resize messages from the client
-func (c *Client) handleResizeMessage(message *types.WebSocketMessage) {
+func (c *Client) handleResizeMessage(ctx context.Context, message *types.WebSocketMessage) {
 	// Send resize request to session
 	c.hub.sessionResize <- &SessionResize{
+		Ctx:       ctx,
 		SessionID: c.sessionID,
+		Pane:      message.Pane,
+		User:      c.user,
 		Rows:      uint16(message.Rows),
 		Cols:      uint16(message.Cols),
 	}
@@ -201,33 +294,37 @@ func (c *Client) handlePingMessage(_ *types.WebSocketMessage) {
 	pongMessage := &types.WebSocketMessage{
 		Type:      types.MessageTypePong,
 		Timestamp: time.Now(),
+		Monotonic: monotonicNow(),
 	}
 
-	select {
-	case c.send <- pongMessage:
-	default:
-		logrus.WithField("client_id", c.id).Warn("Client send channel is full, dropping pong message")
-	}
+	c.SendMessage(pongMessage)
 }
 
 // sendError sends an error message to the client
 func (c *Client) sendError(errorMsg string) {
-	message := types.NewErrorMessage(errorMsg)
-
-	select {
-	case c.send <- message:
-	default:
-		logrus.WithField("client_id", c.id).Warn("Client send channel is full, dropping error message")
-	}
+	c.SendMessage(types.NewErrorMessage(errorMsg))
 }
 
 // SendMessage sends a message to the client
 func (c *Client) SendMessage(message *types.WebSocketMessage) {
+	messageData, err := message.ToJSON()
+	if err != nil {
+		logrus.WithError(err).WithField("client_id", c.id).Error("Failed to marshal message")
+		return
+	}
+	c.sendEncoded(messageData)
+}
+
+// sendEncoded sends a message that has already been encoded, which may be
+// shared with other clients, and reports whether it was queued
+func (c *Client) sendEncoded(messageData []byte) bool {
 	select {
-	case c.send <- message:
+	case c.send <- messageData:
+		return true
 	default:
 		// Client's send channel is full, log warning but don't close
 		logrus.WithField("client_id", c.id).Warn("Client send channel is full, dropping message")
+		return false
 	}
 }
 
@@ -243,6 +340,7 @@ func (c *Client) Run() {
 		Type:      types.MessageTypeConnected,
 		SessionID: c.sessionID,
 		Timestamp: time.Now(),
+		Clock:     newClockInfo(),
 	}
 	c.SendMessage(connectedMessage)
 
@@ -0,0 +1,27 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// clockEpoch is monotonic zero for the timestamps on messages to clients
+var clockEpoch = time.Now()
+
+// monotonicNow returns the nanoseconds since clockEpoch, read from the
+// monotonic clock
+func monotonicNow() int64 {
+	return int64(time.Since(clockEpoch))
+}
+
+// newClockInfo describes the server clock for the connected message, so
+// clients can place output in time and estimate their offset from it
+func newClockInfo() *types.ClockInfo {
+	now := time.Now()
+	return &types.ClockInfo{
+		Epoch:     clockEpoch,
+		Monotonic: int64(now.Sub(clockEpoch)),
+		WallTime:  now,
+	}
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+// Close codes the server gives clients it disconnects, in the range
+// RFC 6455 leaves to applications. Clients should not reconnect after any
+// of them except CloseServerShutdown.
+const (
+	CloseSessionTerminated = 4000 // The session was terminated
+	CloseSessionTimeLimit  = 4001 // The session reached its wall-clock limit
+	CloseSessionIdle       = 4002 // The session was closed after being idle
+	CloseSessionPurged     = 4003 // The session was purged with its owner's data
+	CloseKicked            = 4004 // An administrator disconnected the client
+	CloseBroadcastRevoked  = 4005 // The broadcast link was revoked
+	CloseSessionNotFound   = 4006 // The session does not exist
+	CloseSessionPending    = 4007 // The session is awaiting approval
+	CloseSessionStopping   = 4008 // The session is being terminated
+
+	// CloseServerShutdown is sent when the server shuts down; clients may
+	// reconnect once it is back
+	CloseServerShutdown = websocket.CloseGoingAway
+)
+
+// maxCloseReason is how many bytes of reason fit in a close frame
+const maxCloseReason = 123
+
+// detachCloseCode returns the close code for the reason a session is being
+// terminated
+func detachCloseCode(reason string) int {
+	switch reason {
+	case terminal.DetachReasonTimeLimit:
+		return CloseSessionTimeLimit
+	case terminal.DetachReasonIdle:
+		return CloseSessionIdle
+	case terminal.DetachReasonPurged:
+		return CloseSessionPurged
+	default:
+		return CloseSessionTerminated
+	}
+}
+
+// setClose tells the client why it is about to be disconnected, with a
+// closing message now and a close frame carrying the code and reason once
+// it is closed. Transports without close frames only deliver the message.
+func (c *Client) setClose(code int, reason string) {
+	message := types.NewClosingMessage(c.sessionID, code, reason)
+	if c.reconnect != nil {
+		message.RetryAfter = c.reconnect.retryAfterSeconds()
+		message.ReconnectURL = c.reconnect.URL
+	}
+	c.SendMessage(message)
+	c.closeCode = code
+	c.closeReason = reason
+}
+
+// closeWith closes a client that is not registered with its session,
+// telling it why
+func (c *Client) closeWith(code int, reason string) {
+	c.setClose(code, reason)
+	c.Close()
+}
+
+// closeFrame returns the payload of the close frame sent when the client is
+// closed, with a normal closure unless closeWith gave a reason. The reason
+// is JSON when the client was advised how to reconnect.
+func (c *Client) closeFrame() []byte {
+	if c.closeCode == 0 {
+		return websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	}
+	if c.reconnect != nil {
+		return websocket.FormatCloseMessage(c.closeCode, c.closeAdviceReason())
+	}
+
+	reason := c.closeReason
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+		for !utf8.ValidString(reason) {
+			reason = reason[:len(reason)-1]
+		}
+	}
+	return websocket.FormatCloseMessage(c.closeCode, reason)
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// detachRequest asks the hub to let go of a session being terminated
+type detachRequest struct {
+	sessionID string
+	reason    string
+	reply     chan struct{}
+}
+
+// DetachSession disconnects the clients of a session being terminated,
+// telling them why, and stops relaying its output and writing its input.
+// It returns once the hub no longer reads or writes the session's files,
+// so they can be removed.
+func (h *Hub) DetachSession(sessionID, reason string) {
+	request := &detachRequest{sessionID: sessionID, reason: reason, reply: make(chan struct{}, 1)}
+	select {
+	case h.detachRequests <- request:
+		<-request.reply
+	case <-h.stopChan:
+		// The hub has let go of every session
+	}
+}
+
+// detachSession disconnects a session's clients and closes what the hub
+// holds open for it
+func (h *Hub) detachSession(sessionID, reason string) {
+	clients := len(h.clients[sessionID])
+	for client := range h.clients[sessionID] {
+		client.setClose(detachCloseCode(reason), reason)
+		h.removeClient(client)
+		h.revokeResumeToken(client)
+	}
+
+	// Without clients these are released already, unless input was written
+	// while none was attached
+	h.stopOutputWatcher(sessionID)
+	h.closeInputWriter(sessionID)
+	h.stopPaneWatchers(sessionID)
+	delete(h.startQueues, sessionID)
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"clients":    clients,
+		"reason":     reason,
+	}).Info("Detached session being terminated")
+}
@@ -1,23 +1,41 @@
 package websocket
 
 import (
+	"context"
+	"errors"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/piyushgupta53/webterm/internal/audit"
+	"github.com/piyushgupta53/webterm/internal/auth"
+	"github.com/piyushgupta53/webterm/internal/logging"
+	"github.com/piyushgupta53/webterm/internal/snippets"
 	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/tracing"
+	"github.com/piyushgupta53/webterm/internal/transcript"
 	"github.com/piyushgupta53/webterm/internal/types"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SessionInput represents input data for a session
 type SessionInput struct {
+	Ctx       context.Context // Trace of the message it came from; may be nil
 	SessionID string
+	Pane      string // Empty for the session's own shell
+	ClientID  string // Empty for input not sent by a client
+	User      string // User of the client sending it
 	Data      string
 }
 
 // SessionResize represents a resize request for a session
 type SessionResize struct {
+	Ctx       context.Context // Trace of the message it came from; may be nil
 	SessionID string
+	Pane      string // Empty for the session's own shell
+	User      string // User of the client sending it
 	Rows      uint16
 	Cols      uint16
 }
@@ -50,29 +68,127 @@ type Hub struct {
 
 	// Input pipe writers for sessions (kept open for the session lifetime)
 	inputWriters map[string]*os.File
-}
 
-// OutputWatcher watches a session's output file and broadcasts changes
-type OutputWatcher struct {
-	sessionID    string
-	outputFile   string
-	hub          *Hub
-	stopChan     chan struct{}
-	lastPosition int64
+	// Broadcast revocation requests by session ID
+	revokeBroadcast chan string
+
+	// Client and broadcast viewer counts by session ID, readable from other goroutines
+	clientCounts map[string]int
+	viewerCounts map[string]int
+	viewerMutex  sync.RWMutex
+
+	// Output watchers by session and pane, looked up as output is pushed
+	// from session goroutines
+	watchers   map[outputKey]*OutputWatcher
+	watchMutex sync.Mutex
+
+	// Traffic captures by capture ID, and the running one by session ID,
+	// recorded from client goroutines
+	captures       map[string]*Capture
+	activeCaptures map[string]*Capture
+	captureMutex   sync.Mutex
+
+	// Sessions are locked after this long without input; zero disables locking
+	idleLockTimeout time.Duration
+
+	// Time of the last input by session ID
+	lastInput map[string]time.Time
+
+	// Locked sessions, kept across reconnects until unlocked
+	lockedSessions map[string]bool
+
+	// Lock and unlock requests, and questions whether a session is locked
+	lockRequests chan *lockRequest
+	lockQueries  chan *lockQuery
+
+	// Activity snapshots and client disconnects requested by operators
+	activityRequests chan chan map[string]*SessionActivity
+	kickRequests     chan *kickRequest
+
+	// Message of the day sent to clients as they attach
+	motd string
+
+	// What clients are told when the server shuts down
+	reconnectAdvice ReconnectAdvice
+
+	// Bytes of earlier output replayed to clients as they attach
+	scrollbackBytes int64
+
+	// Resume tokens issued to clients, by token
+	resumeGrants map[string]*resumeGrant
+
+	// Banner announcements to deliver to clients
+	bannerRequests chan *bannerRequest
+
+	// Users' stored snippets, typed in on request; nil disables snippets
+	snippetStore *snippets.Store
+
+	// Output watchers and input pipe writers of panes by session and pane ID
+	paneWatchers map[string]map[string]*OutputWatcher
+	paneWriters  map[string]map[string]*os.File
+
+	// Panes opened and closed by the session manager
+	paneEvents chan *paneEvent
+
+	// Encrypts output files at rest; nil leaves them in plaintext
+	sealer *transcript.Sealer
+
+	// Roles of users attaching to sessions; nil lets every user type
+	roles *auth.Roles
+
+	// Recent activity of sessions by minute
+	timelines timelines
+
+	// Traced input awaiting its echo, by session
+	echoes echoes
+
+	// Records every input write for compliance; nil records nothing
+	inputAudit *audit.InputLog
+
+	// Input and resizes held until their session is running, by session ID,
+	// and the sessions that have started since
+	startQueues    map[string][]*queuedStart
+	sessionStarted chan string
+
+	// Sessions to let go of before they are torn down
+	detachRequests chan *detachRequest
 }
 
 // NewHub creates a new WebSocket hub
 func NewHub(sessionManager *terminal.Manager) *Hub {
 	return &Hub{
-		clients:        make(map[string]map[*Client]bool),
-		register:       make(chan *Client),
-		unregister:     make(chan *Client),
-		sessionInput:   make(chan *SessionInput),
-		sessionResize:  make(chan *SessionResize),
-		sessionManager: sessionManager,
-		stopChan:       make(chan struct{}),
-		outputWatchers: make(map[string]*OutputWatcher),
-		inputWriters:   make(map[string]*os.File),
+		clients:         make(map[string]map[*Client]bool),
+		register:        make(chan *Client),
+		unregister:      make(chan *Client),
+		sessionInput:    make(chan *SessionInput),
+		sessionResize:   make(chan *SessionResize),
+		sessionManager:  sessionManager,
+		stopChan:        make(chan struct{}),
+		outputWatchers:  make(map[string]*OutputWatcher),
+		inputWriters:    make(map[string]*os.File),
+		revokeBroadcast: make(chan string),
+		clientCounts:    make(map[string]int),
+		viewerCounts:    make(map[string]int),
+		captures:        make(map[string]*Capture),
+		watchers:        make(map[outputKey]*OutputWatcher),
+		activeCaptures:  make(map[string]*Capture),
+		lastInput:       make(map[string]time.Time),
+		lockedSessions:  make(map[string]bool),
+		lockRequests:    make(chan *lockRequest),
+		lockQueries:     make(chan *lockQuery),
+		resumeGrants:    make(map[string]*resumeGrant),
+
+		activityRequests: make(chan chan map[string]*SessionActivity),
+		kickRequests:     make(chan *kickRequest),
+		bannerRequests:   make(chan *bannerRequest),
+
+		paneWatchers: make(map[string]map[string]*OutputWatcher),
+		paneWriters:  make(map[string]map[string]*os.File),
+		paneEvents:   make(chan *paneEvent),
+
+		startQueues:    make(map[string][]*queuedStart),
+		sessionStarted: make(chan string),
+		detachRequests: make(chan *detachRequest),
 	}
 }
 
@@ -80,6 +196,17 @@ func NewHub(sessionManager *terminal.Manager) *Hub {
 func (h *Hub) Run() {
 	logrus.Info("Starting WebSocket hub")
 
+	// Check for idle sessions only when locking is enabled
+	var lockCheck <-chan time.Time
+	if h.idleLockTimeout > 0 {
+		ticker := time.NewTicker(lockCheckInterval)
+		defer ticker.Stop()
+		lockCheck = ticker.C
+	}
+
+	pruneTicker := time.NewTicker(timelinePruneInterval)
+	defer pruneTicker.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
@@ -94,6 +221,40 @@ func (h *Hub) Run() {
 		case resize := <-h.sessionResize:
 			h.handleSessionResize(resize)
 
+		case sessionID := <-h.revokeBroadcast:
+			h.disconnectBroadcastViewers(sessionID)
+
+		case request := <-h.lockRequests:
+			h.setSessionLocked(request.sessionID, request.locked)
+
+		case query := <-h.lockQueries:
+			query.reply <- h.lockedSessions[query.sessionID]
+
+		case <-lockCheck:
+			h.lockIdleSessions()
+
+		case <-pruneTicker.C:
+			h.pruneTimelines()
+
+		case reply := <-h.activityRequests:
+			reply <- h.snapshotActivity()
+
+		case request := <-h.kickRequests:
+			request.reply <- h.kickClient(request.clientID)
+
+		case request := <-h.bannerRequests:
+			request.reply <- h.sendBanner(request)
+
+		case event := <-h.paneEvents:
+			h.handlePaneEvent(event)
+
+		case sessionID := <-h.sessionStarted:
+			h.releaseStartQueue(sessionID)
+
+		case request := <-h.detachRequests:
+			h.detachSession(request.sessionID, request.reason)
+			request.reply <- struct{}{}
+
 		case <-h.stopChan:
 			logrus.Info("Stopping WebSocket hub")
 			h.shutdown()
@@ -114,28 +275,68 @@ func (h *Hub) registerClient(client *Client) {
 	session, err := h.sessionManager.GetSession(client.sessionID)
 	if err != nil {
 		logrus.WithError(err).WithField("session_id", client.sessionID).Error("Session not found for client")
-		client.sendError("Session not found")
-		client.Close()
+		client.closeWith(CloseSessionNotFound, "Session not found")
 		return
 	}
 
-	// Initialize clients map for session if needed
-	if h.clients[client.sessionID] == nil {
-		h.clients[client.sessionID] = make(map[*Client]bool)
+	// Nothing is attached to a session until it has been approved
+	if session.Status == types.SessionStatusPending {
+		client.closeWith(CloseSessionPending, "Session is awaiting approval")
+		return
 	}
 
-	// Add client to session
-	h.clients[client.sessionID][client] = true
+	// Sessions being terminated have detached their clients already
+	if session.Status == types.SessionStatusStopping {
+		client.closeWith(CloseSessionStopping, "Session is being terminated")
+		return
+	}
+
+	// Users whose role does not allow input attach read-only
+	if !client.broadcastViewer && !h.roles.CanInput(client.user, session.Owner) {
+		client.readOnly.Store(true)
+	}
 
 	// Start output watcher for session if this is the first client
-	if len(h.clients[client.sessionID]) == 1 {
+	if len(h.clients[client.sessionID]) == 0 {
 		h.startOutputWatcher(session)
+		h.startPaneWatchers(session)
 	}
 
 	// Send session status to client
 	statusMessage := types.NewStatusMessage(client.sessionID, string(session.Status))
+	statusMessage.ExitCode = session.ExitCode
 	client.SendMessage(statusMessage)
 
+	// Output the client has not seen is sent before any live output
+	resumed := h.redeemResumeToken(client)
+	h.attachOutput(client, resumed)
+	if !client.broadcastViewer {
+		h.issueResumeToken(client)
+	}
+
+	// Initialize clients map for session if needed
+	if h.clients[client.sessionID] == nil {
+		h.clients[client.sessionID] = make(map[*Client]bool)
+	}
+
+	// Add client to session
+	h.clients[client.sessionID][client] = true
+	h.updateClientCount(client, 1)
+	h.recordClientEvent(client, TimelineEventAttach)
+	h.broadcastPresence(client.sessionID)
+
+	if h.motd != "" {
+		client.SendMessage(types.NewBannerMessage(client.sessionID, h.motd, BannerLevelInfo))
+	}
+
+	// Connecting counts as activity, but does not unlock a locked session.
+	// Observers that cannot type do not count.
+	if h.lockedSessions[client.sessionID] {
+		client.SendMessage(newLockMessage(client.sessionID, true))
+	} else if !client.readOnly.Load() {
+		h.lastInput[client.sessionID] = time.Now()
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"session_id":    client.sessionID,
 		"client_count":  len(h.clients[client.sessionID]),
@@ -154,15 +355,7 @@ func (h *Hub) unregisterClient(client *Client) {
 	// Remove client from session
 	if sessionClients, exists := h.clients[client.sessionID]; exists {
 		if _, clientExists := sessionClients[client]; clientExists {
-			delete(sessionClients, client)
-			client.Close()
-
-			// Stop output watcher and close input writer if no more clients for this session
-			if len(sessionClients) == 0 {
-				h.stopOutputWatcher(client.sessionID)
-				h.closeInputWriter(client.sessionID)
-				delete(h.clients, client.sessionID)
-			}
+			h.removeClient(client)
 		}
 	}
 
@@ -172,14 +365,143 @@ func (h *Hub) unregisterClient(client *Client) {
 	}).Info("Client unregistered successfully")
 }
 
+// removeClient detaches a registered client from its session and closes it
+func (h *Hub) removeClient(client *Client) {
+	sessionClients := h.clients[client.sessionID]
+	delete(sessionClients, client)
+	h.detachOutput(client)
+	h.releaseResumeToken(client)
+	client.Close()
+	h.updateClientCount(client, -1)
+	h.recordClientEvent(client, TimelineEventDetach)
+	h.broadcastPresence(client.sessionID)
+
+	// Stop output watcher and close input writer if no more clients for this session
+	if len(sessionClients) == 0 {
+		h.stopOutputWatcher(client.sessionID)
+		h.closeInputWriter(client.sessionID)
+		h.stopPaneWatchers(client.sessionID)
+		delete(h.clients, client.sessionID)
+		delete(h.lastInput, client.sessionID)
+	}
+}
+
+// disconnectBroadcastViewers disconnects every broadcast viewer of a session
+func (h *Hub) disconnectBroadcastViewers(sessionID string) {
+	for client := range h.clients[sessionID] {
+		if client.broadcastViewer {
+			client.setClose(CloseBroadcastRevoked, "Broadcast revoked")
+			h.removeClient(client)
+		}
+	}
+
+	logrus.WithField("session_id", sessionID).Info("Broadcast viewers disconnected")
+}
+
+// updateClientCount adjusts the client and broadcast viewer counts of a
+// client's session
+func (h *Hub) updateClientCount(client *Client, delta int) {
+	h.viewerMutex.Lock()
+	defer h.viewerMutex.Unlock()
+
+	adjustCount(h.clientCounts, client.sessionID, delta)
+	if client.broadcastViewer {
+		adjustCount(h.viewerCounts, client.sessionID, delta)
+	}
+}
+
+// adjustCount adds delta to a per-session count, dropping it at zero
+func adjustCount(counts map[string]int, sessionID string, delta int) {
+	counts[sessionID] += delta
+	if counts[sessionID] <= 0 {
+		delete(counts, sessionID)
+	}
+}
+
+// GetClientCount returns the number of clients connected to a session,
+// including broadcast viewers
+func (h *Hub) GetClientCount(sessionID string) int {
+	h.viewerMutex.RLock()
+	defer h.viewerMutex.RUnlock()
+	return h.clientCounts[sessionID]
+}
+
+// GetViewerCount returns the number of broadcast viewers watching a session
+func (h *Hub) GetViewerCount(sessionID string) int {
+	h.viewerMutex.RLock()
+	defer h.viewerMutex.RUnlock()
+	return h.viewerCounts[sessionID]
+}
+
+// GetTotalViewerCount returns the number of broadcast viewers across all sessions
+func (h *Hub) GetTotalViewerCount() int {
+	h.viewerMutex.RLock()
+	defer h.viewerMutex.RUnlock()
+
+	total := 0
+	for _, count := range h.viewerCounts {
+		total += count
+	}
+	return total
+}
+
+// RevokeBroadcast disconnects the broadcast viewers of a session
+func (h *Hub) RevokeBroadcast(sessionID string) {
+	h.revokeBroadcast <- sessionID
+}
+
+// ResolveBroadcast returns the session published at a broadcast token
+func (h *Hub) ResolveBroadcast(token string) (string, error) {
+	return h.sessionManager.ResolveBroadcast(token)
+}
+
 // handleSessionInput handles input from clients to sessions
 func (h *Hub) handleSessionInput(input *SessionInput) {
+	span := tracing.NoopSpan()
+	if tracing.Enabled() {
+		_, span = tracing.Start(messageContext(input.Ctx), "hub.input",
+			tracing.AttrSessionID.String(input.SessionID), tracing.AttrBytes.Int(len(input.Data)))
+	}
+	defer span.End()
+
 	logrus.WithFields(logrus.Fields{
-		"session_id": input.SessionID,
-		"data_len":   len(input.Data),
-		"data":       input.Data, // Log the actual input data
+		"session_id":      input.SessionID,
+		"data_len":        len(input.Data),
+		logging.FieldData: input.Data, // Redacted outside audit mode
 	}).Info("Handling session input")
 
+	// Input sent before a client was made read-only at registration is
+	// still refused by role
+	if !h.canInput(input.SessionID, input.User) {
+		logrus.WithField("session_id", input.SessionID).Debug("Dropping input from user without input role")
+		return
+	}
+
+	// Locked sessions reject input until the user re-authenticates
+	if h.lockedSessions[input.SessionID] {
+		logrus.WithField("session_id", input.SessionID).Debug("Dropping input for locked session")
+		return
+	}
+	h.lastInput[input.SessionID] = time.Now()
+	h.recordInput(input.SessionID, input.Data)
+	h.inputAudit.Record(input.SessionID, input.Pane, input.ClientID, input.User, input.Data)
+
+	if input.Pane != "" {
+		h.writePaneInput(input)
+		return
+	}
+
+	// Hold input until the session is running, so none is lost or typed
+	// ahead of the prompt
+	if h.queueUntilStarted(input.SessionID, &queuedStart{input: input}) {
+		return
+	}
+
+	h.writeSessionInput(input, span)
+}
+
+// writeSessionInput writes input to a session's own shell
+func (h *Hub) writeSessionInput(input *SessionInput, span trace.Span) {
 	// Get session
 	session, err := h.sessionManager.GetSession(input.SessionID)
 	if err != nil {
@@ -205,76 +527,101 @@ func (h *Hub) handleSessionInput(input *SessionInput) {
 		}).Info("Input pipe opened for writing")
 	}
 
-	// Write to the input pipe
-	if _, err := inputFile.WriteString(input.Data); err != nil {
+	// Write to the input pipe, translated per the session's keyboard settings
+	data := terminal.TransformInput(session.Keyboard, input.Data)
+	h.recordFrame(input.SessionID, CapturePTYIn, "", "", []byte(data))
+	if _, err := inputFile.WriteString(data); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to write to input pipe")
 		logrus.WithError(err).WithField("session_id", input.SessionID).Error("Failed to write to input pipe")
 		return
 	}
+	h.awaitEcho(input.SessionID, span.SpanContext())
 
 	logrus.WithFields(logrus.Fields{
-		"session_id": input.SessionID,
-		"data_len":   len(input.Data),
-		"data":       input.Data,
+		"session_id":      input.SessionID,
+		"data_len":        len(input.Data),
+		logging.FieldData: input.Data,
 	}).Info("Input written to session successfully")
 }
 
+// canInput reports whether a user's role lets them type into, or resize, a
+// session
+func (h *Hub) canInput(sessionID, user string) bool {
+	owner := ""
+	if session, err := h.sessionManager.GetSession(sessionID); err == nil {
+		owner = session.Owner
+	}
+	return h.roles.CanInput(user, owner)
+}
+
 // handleSessionResize handles resize requests for sessions
 func (h *Hub) handleSessionResize(resize *SessionResize) {
+	span := tracing.NoopSpan()
+	if tracing.Enabled() {
+		_, span = tracing.Start(messageContext(resize.Ctx), "hub.resize", tracing.AttrSessionID.String(resize.SessionID))
+	}
+	defer span.End()
+
 	logrus.WithFields(logrus.Fields{
 		"session_id": resize.SessionID,
 		"rows":       resize.Rows,
 		"cols":       resize.Cols,
 	}).Debug("Handling session resize")
 
-	// Get session
-	session, err := h.sessionManager.GetSession(resize.SessionID)
-	if err != nil {
-		logrus.WithError(err).WithField("session_id", resize.SessionID).Error("Session not found for resize")
+	if !h.canInput(resize.SessionID, resize.User) {
+		return
+	}
+
+	if resize.Pane != "" {
+		h.resizePane(resize)
+		return
+	}
+
+	// Keep resizes in order with input held for a starting session
+	if h.queueUntilStarted(resize.SessionID, &queuedStart{resize: resize}) {
 		return
 	}
 
-	// Resize PTY
-	if session.PTY != nil {
-		if err := terminal.SetPTYSize(session.PTY, resize.Rows, resize.Cols); err != nil {
+	h.resizeSession(resize)
+}
+
+// resizeSession sets the window size of a session's own shell
+func (h *Hub) resizeSession(resize *SessionResize) {
+	// Sessions without a PTY have no window size to set
+	if err := h.sessionManager.ResizeSession(resize.SessionID, resize.Rows, resize.Cols); err != nil {
+		if !errors.Is(err, terminal.ErrResizeUnavailable) {
 			logrus.WithError(err).WithField("session_id", resize.SessionID).Error("Failed to resize PTY")
-			return
 		}
-
-		logrus.WithField("session_id", resize.SessionID).Debug("PTY resized successfully")
+		return
 	}
+
+	logrus.WithField("session_id", resize.SessionID).Debug("PTY resized successfully")
 }
 
 // startOutputWatcher starts watching a session's output file
 func (h *Hub) startOutputWatcher(session *types.Session) {
 	logrus.WithField("session_id", session.ID).Info("Starting output watcher")
 
-	// Get current file size to start reading from the current position
-	var lastPosition int64 = 0
-	if fileInfo, err := os.Stat(session.OutputFile); err == nil {
-		lastPosition = fileInfo.Size()
-		logrus.WithFields(logrus.Fields{
-			"session_id": session.ID,
-			"file_size":  lastPosition,
-		}).Debug("Output file exists, starting from current size")
+	watcher := h.newOutputWatcher(session.ID, "", session.OutputFile)
+	if !session.HasPTY() {
+		watcher.stream = "stdout"
+		watcher.stderrFile = session.StderrFile
+		size := h.outputSize(session.StderrFile)
+		watcher.stderr = streamPosition{relayed: size, written: size}
 	}
-
-	watcher := &OutputWatcher{
-		sessionID:    session.ID,
-		outputFile:   session.OutputFile,
-		hub:          h,
-		stopChan:     make(chan struct{}),
-		lastPosition: lastPosition,
+	if screen, err := h.sessionManager.Screen(session.ID); err == nil {
+		watcher.screen = screen
 	}
-
 	h.outputWatchers[session.ID] = watcher
-	go watcher.watch()
+	h.watch(watcher)
 }
 
 // stopOutputWatcher stops watching a session's output file
 func (h *Hub) stopOutputWatcher(sessionID string) {
 	if watcher, exists := h.outputWatchers[sessionID]; exists {
 		logrus.WithField("session_id", sessionID).Info("Stopping output watcher")
-		close(watcher.stopChan)
+		h.unwatch(watcher)
 		delete(h.outputWatchers, sessionID)
 	}
 }
@@ -290,21 +637,33 @@ func (h *Hub) closeInputWriter(sessionID string) {
 
 // broadcast sends a message to all clients of a session
 func (h *Hub) broadcast(sessionID string, message *types.WebSocketMessage) {
-	if sessionClients, exists := h.clients[sessionID]; exists {
-		for client := range sessionClients {
-			client.SendMessage(message)
-		}
+	sessionClients, exists := h.clients[sessionID]
+	if !exists || len(sessionClients) == 0 {
+		return
+	}
+
+	// Encode once, however many clients are watching
+	messageData, err := message.ToJSON()
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to marshal broadcast message")
+		return
+	}
+
+	for client := range sessionClients {
+		client.sendEncoded(messageData)
 	}
 }
 
-// BroadcastSessionStatus broadcasts a session status update to all clients of that session
-func (h *Hub) BroadcastSessionStatus(sessionID string, status string) {
+// BroadcastSessionStatus broadcasts a session status update to all clients
+// of that session, with the exit code of its shell once it has exited
+func (h *Hub) BroadcastSessionStatus(sessionID, status string, exitCode *int) {
 	logrus.WithFields(logrus.Fields{
 		"session_id": sessionID,
 		"status":     status,
 	}).Info("Broadcasting session status update")
 
 	statusMessage := types.NewStatusMessage(sessionID, status)
+	statusMessage.ExitCode = exitCode
 	h.broadcast(sessionID, statusMessage)
 }
 
@@ -324,10 +683,16 @@ func (h *Hub) shutdown() {
 		h.stopOutputWatcher(sessionID)
 	}
 
+	// Stop pane watchers and close pane input writers
+	for sessionID := range h.paneWatchers {
+		h.stopPaneWatchers(sessionID)
+	}
+
 	// Close all client connections
 	for _, sessionClients := range h.clients {
 		for client := range sessionClients {
-			client.Close()
+			client.adviseReconnect(h.reconnectAdvice)
+			client.closeWith(CloseServerShutdown, "Server shutting down")
 		}
 	}
 
@@ -341,6 +706,11 @@ func (h *Hub) shutdown() {
 	h.outputWatchers = make(map[string]*OutputWatcher)
 	h.clients = make(map[string]map[*Client]bool)
 	h.inputWriters = make(map[string]*os.File)
+
+	h.viewerMutex.Lock()
+	h.clientCounts = make(map[string]int)
+	h.viewerCounts = make(map[string]int)
+	h.viewerMutex.Unlock()
 }
 
 // Stop stops the hub
@@ -363,89 +733,40 @@ func (h *Hub) RegisterClient(client *Client) {
 	h.register <- client
 }
 
-// UnregisterClient unregisters a client from the hub
-func (h *Hub) UnregisterClient(client *Client) {
-	h.unregister <- client
+// SetSealer sets how session output files are encrypted at rest. It must be
+// called before Run.
+func (h *Hub) SetSealer(sealer *transcript.Sealer) {
+	h.sealer = sealer
 }
 
-// watch monitors the output file for changes and broadcasts them
-func (ow *OutputWatcher) watch() {
-	logrus.WithField("session_id", ow.sessionID).Debug("Starting output file watcher")
-
-	ticker := time.NewTicker(100 * time.Millisecond) // Check every 100ms
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ow.stopChan:
-			logrus.WithField("session_id", ow.sessionID).Debug("Output watcher stopped")
-			return
+// SetInputAudit sets the log every write of input to a session is recorded
+// in. It must be called before Run.
+func (h *Hub) SetInputAudit(log *audit.InputLog) {
+	h.inputAudit = log
+}
 
-		case <-ticker.C:
-			if err := ow.checkForOutput(); err != nil {
-				logrus.WithError(err).WithField("session_id", ow.sessionID).Error("Error checking output file")
-			}
-		}
-	}
+// InputAudit returns the log input writes are recorded in, or nil
+func (h *Hub) InputAudit() *audit.InputLog {
+	return h.inputAudit
 }
 
-// checkForOutput checks for new output in the file
-func (ow *OutputWatcher) checkForOutput() error {
-	// Get file info
-	fileInfo, err := os.Stat(ow.outputFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // File doesn't exist yet
-		}
-		return err
-	}
+// SetRoles sets the roles that decide which users may type into the
+// sessions they attach to. It must be called before Run.
+func (h *Hub) SetRoles(roles *auth.Roles) {
+	h.roles = roles
+}
 
-	// Check if file has grown
-	currentSize := fileInfo.Size()
-	if currentSize <= ow.lastPosition {
-		return nil // No new data
-	}
+// UnregisterClient unregisters a client from the hub
+func (h *Hub) UnregisterClient(client *Client) {
+	h.unregister <- client
+}
 
-	logrus.WithFields(logrus.Fields{
-		"session_id":    ow.sessionID,
-		"current_size":  currentSize,
-		"last_position": ow.lastPosition,
-		"new_bytes":     currentSize - ow.lastPosition,
-	}).Debug("Detected new output in file")
-
-	// Read new data
-	file, err := os.Open(ow.outputFile)
+// outputSize returns how much output a file holds, or 0 if it cannot be read
+func (h *Hub) outputSize(path string) int64 {
+	size, err := h.sealer.Size(path)
 	if err != nil {
-		return err
+		logrus.WithError(err).WithField("path", path).Warn("Failed to read output size")
+		return 0
 	}
-	defer file.Close()
-
-	// Seek to last position
-	if _, err := file.Seek(ow.lastPosition, 0); err != nil {
-		return err
-	}
-
-	// Read new data
-	buffer := make([]byte, currentSize-ow.lastPosition)
-	n, err := file.Read(buffer)
-	if err != nil && err != os.ErrClosed {
-		return err
-	}
-
-	if n > 0 {
-		// Broadcast new output to all clients
-		outputMessage := types.NewOutputMessage(ow.sessionID, string(buffer[:n]))
-		ow.hub.broadcast(ow.sessionID, outputMessage)
-
-		// Update last position
-		ow.lastPosition = currentSize
-
-		logrus.WithFields(logrus.Fields{
-			"session_id": ow.sessionID,
-			"bytes_read": n,
-			"data":       string(buffer[:n]),
-		}).Info("Broadcasted new output")
-	}
-
-	return nil
+	return size
 }
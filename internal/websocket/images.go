@@ -0,0 +1,277 @@
+package websocket
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+)
+
+const (
+	// maxInlineImage bounds the size of an inline image sequence relayed as
+	// an image message; larger ones are relayed as output
+	maxInlineImage = 4 * 1024 * 1024
+	// maxSentImages bounds the images a client is remembered to have
+	// received, after which repeats are sent in full again
+	maxSentImages = 256
+)
+
+// Inline image formats
+const (
+	imageFormatSixel  = "sixel"
+	imageFormatITerm2 = "iterm2"
+)
+
+// iterm2ImagePrefix starts the OSC 1337 sequence of an iTerm2 inline image
+var iterm2ImagePrefix = []byte("\x1b]1337;File=")
+
+// States of an imageScanner
+const (
+	scanGround      = iota
+	scanEscape      // After an ESC
+	scanSixelParams // After ESC P, in the parameters before q
+	scanOSCPrefix   // After ESC ], matching the iTerm2 prefix
+	scanImage       // Inside an image, up to its terminator
+	scanOversize    // Inside an image too large to relay, up to its terminator
+)
+
+// outputPart is a piece of output split by an imageScanner: text, or a
+// complete inline image sequence. End is where it ends in its stream.
+type outputPart struct {
+	data   []byte
+	format string // Empty for text
+	end    int64
+}
+
+// imageScanner splits a stream of output into text and Sixel or iTerm2
+// inline image sequences. Sequences may be split across chunks; what might
+// be the start of one is held back until it is known.
+type imageScanner struct {
+	state   int
+	format  string
+	pending []byte // Sequence held back
+	escaped bool   // The last byte of an image was ESC
+}
+
+// scan splits data, ending at end in its stream, into parts. Held back
+// bytes are relayed as part of later parts.
+func (s *imageScanner) scan(data []byte, end int64) []outputPart {
+	var parts []outputPart
+	start := end - int64(len(data))
+	textStart := 0 // Start of the text in data not yet in a part
+
+	// addText adds held back bytes that turned out not to be an image, and
+	// the text in data up to i, as a part
+	addText := func(held []byte, i int) {
+		text := data[textStart:i]
+		if len(held) > 0 {
+			text = append(append([]byte(nil), held...), text...)
+		}
+		if len(text) > 0 {
+			parts = append(parts, outputPart{data: text, end: start + int64(i)})
+		}
+		textStart = i
+	}
+
+	for i := 0; i < len(data); {
+		b := data[i]
+		notImage := false
+
+		switch s.state {
+		case scanGround:
+			next := bytes.IndexByte(data[i:], 0x1b)
+			if next < 0 {
+				i = len(data)
+				continue
+			}
+			addText(nil, i+next)
+			s.pending = append(s.pending[:0], 0x1b)
+			s.state = scanEscape
+			i += next + 1
+			textStart = i
+			continue
+
+		case scanEscape:
+			s.pending = append(s.pending, b)
+			switch b {
+			case 'P':
+				s.state = scanSixelParams
+			case ']':
+				s.state = scanOSCPrefix
+			default:
+				notImage = true
+			}
+
+		case scanSixelParams:
+			s.pending = append(s.pending, b)
+			switch {
+			case b >= '0' && b <= '9' || b == ';':
+			case b == 'q':
+				s.begin(imageFormatSixel)
+			default:
+				notImage = true
+			}
+
+		case scanOSCPrefix:
+			s.pending = append(s.pending, b)
+			if !bytes.HasPrefix(iterm2ImagePrefix, s.pending) {
+				notImage = true
+			} else if len(s.pending) == len(iterm2ImagePrefix) {
+				s.begin(imageFormatITerm2)
+			}
+
+		case scanImage:
+			s.pending = append(s.pending, b)
+			i++
+			textStart = i
+
+			if s.terminates(b) {
+				parts = append(parts, outputPart{data: s.pending, format: s.format, end: start + int64(i)})
+				s.pending = nil
+				s.state = scanGround
+			} else if len(s.pending) > maxInlineImage {
+				// Relayed as output, as it would be without the scanner
+				addText(s.pending, i)
+				s.pending = nil
+				s.state = scanOversize
+			}
+			continue
+
+		case scanOversize:
+			if s.terminates(b) {
+				s.state = scanGround
+			}
+			i++
+			continue
+		}
+
+		i++
+		textStart = i
+		if notImage {
+			held := s.pending
+			s.pending = nil
+			s.state = scanGround
+			if b == 0x1b {
+				// The ESC may start another sequence
+				held = held[:len(held)-1]
+				s.pending = []byte{0x1b}
+				s.state = scanEscape
+			}
+			if len(held) > 0 {
+				parts = append(parts, outputPart{data: held, end: start + int64(i) - int64(len(s.pending))})
+			}
+		}
+	}
+
+	if s.state == scanGround || s.state == scanOversize {
+		addText(nil, len(data))
+	}
+	return mergeText(parts)
+}
+
+// mergeText joins adjacent text parts
+func mergeText(parts []outputPart) []outputPart {
+	merged := parts[:0]
+	for _, part := range parts {
+		if last := len(merged) - 1; last >= 0 && merged[last].format == "" && part.format == "" {
+			merged[last].data = append(append([]byte(nil), merged[last].data...), part.data...)
+			merged[last].end = part.end
+			continue
+		}
+		merged = append(merged, part)
+	}
+	return merged
+}
+
+// begin starts collecting an image
+func (s *imageScanner) begin(format string) {
+	s.state = scanImage
+	s.format = format
+	s.escaped = false
+}
+
+// terminates reports whether a byte of an image ends it. Images end with
+// ST (ESC \), and iTerm2 images also with BEL.
+func (s *imageScanner) terminates(b byte) bool {
+	terminated := (s.escaped && b == '\\') || (b == 0x07 && s.format == imageFormatITerm2)
+	s.escaped = b == 0x1b
+	return terminated
+}
+
+// imageID identifies the contents of an inline image sequence
+func imageID(sequence []byte) string {
+	sum := sha256.Sum256(sequence)
+	return hex.EncodeToString(sum[:8])
+}
+
+// sentImages remembers the images a client has received, so repeats of
+// them are sent without their data. Watchers of different panes share it.
+type sentImages struct {
+	mutex sync.Mutex
+	ids   map[string]bool
+}
+
+// remember records that an image is sent, and reports whether it already was
+func (s *sentImages) remember(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ids[id] {
+		return true
+	}
+	if s.ids == nil || len(s.ids) >= maxSentImages {
+		s.ids = make(map[string]bool)
+	}
+	s.ids[id] = true
+	return false
+}
+
+// encodedPart is an output part encoded as a message. Images are also
+// encoded without their data, for clients that already have them.
+type encodedPart struct {
+	message []byte
+	cached  []byte
+	imageID string
+}
+
+// encodeParts encodes output split into text and images
+func (ow *OutputWatcher) encodeParts(parts []outputPart, stream string) ([]encodedPart, error) {
+	encoded := make([]encodedPart, 0, len(parts))
+	for _, part := range parts {
+		if part.format == "" {
+			message, err := ow.outputMessage(part.data, part.end, stream)
+			if err != nil {
+				return nil, err
+			}
+			encoded = append(encoded, encodedPart{message: message})
+			continue
+		}
+
+		id := imageID(part.data)
+		message := types.WebSocketMessage{
+			Type:        types.MessageTypeImage,
+			SessionID:   ow.sessionID,
+			Pane:        ow.paneID,
+			ImageFormat: part.format,
+			ImageID:     id,
+			Offset:      part.end,
+			Timestamp:   time.Now(),
+			Monotonic:   monotonicNow(),
+		}
+		cached, err := message.ToJSON()
+		if err != nil {
+			return nil, err
+		}
+		message.Data = base64.StdEncoding.EncodeToString(part.data)
+		full, err := message.ToJSON()
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, encodedPart{message: full, cached: cached, imageID: id})
+	}
+	return encoded, nil
+}
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// lockCheckInterval is how often sessions are checked for inactivity
+const lockCheckInterval = 10 * time.Second
+
+// lockRequest asks the hub to lock or unlock a session
+type lockRequest struct {
+	sessionID string
+	locked    bool
+}
+
+// lockQuery asks the hub whether a session is locked
+type lockQuery struct {
+	sessionID string
+	reply     chan bool
+}
+
+// SetIdleLockTimeout locks sessions after timeout without input. It must be
+// called before Run; zero disables locking.
+func (h *Hub) SetIdleLockTimeout(timeout time.Duration) {
+	h.idleLockTimeout = timeout
+}
+
+// LockSession blanks a session for its clients and rejects their input
+func (h *Hub) LockSession(sessionID string) {
+	h.lockRequests <- &lockRequest{sessionID: sessionID, locked: true}
+}
+
+// UnlockSession resumes a locked session once its user has re-authenticated
+func (h *Hub) UnlockSession(sessionID string) {
+	h.lockRequests <- &lockRequest{sessionID: sessionID, locked: false}
+}
+
+// IsLocked reports whether a session is locked
+func (h *Hub) IsLocked(sessionID string) bool {
+	reply := make(chan bool, 1)
+	h.lockQueries <- &lockQuery{sessionID: sessionID, reply: reply}
+	return <-reply
+}
+
+// lockIdleSessions locks sessions whose clients have been idle for too long
+// and forgets locks of sessions that no longer exist
+func (h *Hub) lockIdleSessions() {
+	now := time.Now()
+	for sessionID, lastInput := range h.lastInput {
+		if !h.lockedSessions[sessionID] && now.Sub(lastInput) > h.idleLockTimeout {
+			h.setSessionLocked(sessionID, true)
+		}
+	}
+
+	for sessionID := range h.lockedSessions {
+		if _, err := h.sessionManager.GetSession(sessionID); err != nil {
+			delete(h.lockedSessions, sessionID)
+		}
+	}
+}
+
+// setSessionLocked pauses or resumes output for a session and notifies its clients
+func (h *Hub) setSessionLocked(sessionID string, locked bool) {
+	if h.lockedSessions[sessionID] == locked {
+		return
+	}
+
+	if locked {
+		h.lockedSessions[sessionID] = true
+	} else {
+		delete(h.lockedSessions, sessionID)
+		if len(h.clients[sessionID]) > 0 {
+			h.lastInput[sessionID] = time.Now()
+		}
+	}
+
+	// Output written while locked is held back and delivered on unlock,
+	// after the clients have been told
+	if locked {
+		h.pauseOutput(sessionID, true)
+	}
+	h.broadcast(sessionID, newLockMessage(sessionID, locked))
+	if !locked {
+		h.pauseOutput(sessionID, false)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"locked":     locked,
+	}).Info("Session lock changed")
+}
+
+// pauseOutput holds back or resumes relaying the output of a session
+func (h *Hub) pauseOutput(sessionID string, paused bool) {
+	if watcher, exists := h.outputWatchers[sessionID]; exists {
+		watcher.setPaused(paused)
+	}
+	for _, watcher := range h.paneWatchers[sessionID] {
+		watcher.setPaused(paused)
+	}
+}
+
+// newLockMessage creates a locked or unlocked message for a session
+func newLockMessage(sessionID string, locked bool) *types.WebSocketMessage {
+	messageType := types.MessageTypeUnlocked
+	if locked {
+		messageType = types.MessageTypeLocked
+	}
+
+	message := types.NewWebSocketMessage(messageType, "")
+	message.SessionID = sessionID
+	return message
+}
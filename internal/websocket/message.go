@@ -57,7 +57,7 @@ func (mh *MessageHandler) ProcessMessage(client *Client, messageData []byte) err
 }
 
 // handleInput process terminal input messages
-func (mh *MessageHandler) handleInput(_ *Client, message *types.WebSocketMessage) error {
+func (mh *MessageHandler) handleInput(client *Client, message *types.WebSocketMessage) error {
 	if message.Data == "" {
 		return nil
 	}
@@ -65,6 +65,8 @@ func (mh *MessageHandler) handleInput(_ *Client, message *types.WebSocketMessage
 	// Send to session input channel
 	input := &SessionInput{
 		SessionID: message.SessionID,
+		ClientID:  client.id,
+		User:      client.user,
 		Data:      message.Data,
 	}
 
@@ -85,6 +87,7 @@ func (mh *MessageHandler) handleResize(client *Client, message *types.WebSocketM
 	// Send to session resize channel
 	resize := &SessionResize{
 		SessionID: client.sessionID,
+		User:      client.user,
 		Rows:      uint16(message.Rows),
 		Cols:      uint16(message.Cols),
 	}
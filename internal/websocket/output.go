@@ -0,0 +1,304 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/logging"
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// outputMessages are reused for every chunk of output relayed
+var outputMessages = sync.Pool{New: func() interface{} { return new(types.WebSocketMessage) }}
+
+// outputKey identifies the output of a session or one of its panes
+type outputKey struct {
+	sessionID string
+	paneID    string
+}
+
+// OutputWatcher relays the output of a session or pane to the clients
+// attached to it, as the session manager pushes it. Output is also in the
+// output file, which clients that just attached are caught up from.
+type OutputWatcher struct {
+	sessionID  string
+	paneID     string // Empty for the session's own shell
+	outputFile string
+	hub        *Hub
+
+	// Sessions without a PTY label their output by stream and have stderr
+	// in a separate file
+	stream     string
+	stderrFile string
+
+	mutex   sync.Mutex
+	clients map[*Client]bool
+	output  streamPosition
+	stderr  streamPosition
+	paused  bool // Set while the session is locked
+	stopped bool
+
+	// Catch-ups of clients that attached while the session is locked
+	catchUps map[*Client]*catchUp
+
+	// Finds inline images in the output
+	images imageScanner
+
+	// State of the session's terminal, if kept, and the frame last sent to
+	// clients receiving screen updates
+	screen *terminal.Screen
+	frame  *terminal.ScreenFrame
+}
+
+// streamPosition tracks how far the output in one file has been relayed
+type streamPosition struct {
+	relayed int64 // Where the output sent to clients ends
+	written int64 // Where the output written to the file ends
+}
+
+// newOutputWatcher creates a watcher relaying output written from now on
+func (h *Hub) newOutputWatcher(sessionID, paneID, outputFile string) *OutputWatcher {
+	size := h.outputSize(outputFile)
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"pane_id":    paneID,
+		"file_size":  size,
+	}).Debug("Relaying output from the current end of the output file")
+
+	return &OutputWatcher{
+		sessionID:  sessionID,
+		paneID:     paneID,
+		outputFile: outputFile,
+		hub:        h,
+		clients:    make(map[*Client]bool),
+		output:     streamPosition{relayed: size, written: size},
+		paused:     h.lockedSessions[sessionID],
+		catchUps:   make(map[*Client]*catchUp),
+	}
+}
+
+// HandleOutput relays output a session or pane has just written to the
+// clients attached to it. It is called on the session's output goroutine.
+func (h *Hub) HandleOutput(chunk *terminal.OutputChunk) {
+	// A truncated file's chunk repeats output already counted
+	if !chunk.Truncated {
+		h.recordOutput(chunk.SessionID, len(chunk.Data))
+		h.traceEcho(chunk)
+	}
+
+	h.watchMutex.Lock()
+	watcher := h.watchers[outputKey{chunk.SessionID, chunk.PaneID}]
+	h.watchMutex.Unlock()
+
+	if watcher != nil {
+		watcher.push(chunk)
+	}
+}
+
+// watch has output pushed for a watcher's session or pane relayed by it
+func (h *Hub) watch(watcher *OutputWatcher) {
+	h.watchMutex.Lock()
+	defer h.watchMutex.Unlock()
+	h.watchers[outputKey{watcher.sessionID, watcher.paneID}] = watcher
+}
+
+// unwatch stops a watcher relaying output
+func (h *Hub) unwatch(watcher *OutputWatcher) {
+	h.watchMutex.Lock()
+	delete(h.watchers, outputKey{watcher.sessionID, watcher.paneID})
+	h.watchMutex.Unlock()
+
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	watcher.stopped = true
+	watcher.clients = make(map[*Client]bool)
+	watcher.catchUps = make(map[*Client]*catchUp)
+}
+
+// attach starts relaying output to a client, after sending it any catch-up
+func (ow *OutputWatcher) attach(client *Client, pending *catchUp) {
+	ow.mutex.Lock()
+	defer ow.mutex.Unlock()
+
+	if pending != nil {
+		if ow.paused {
+			ow.catchUps[client] = pending
+		} else {
+			ow.sendCatchUp(client, pending)
+		}
+	}
+	ow.clients[client] = true
+}
+
+// detach stops relaying output to a client. Once it returns, nothing more
+// is sent to the client, so it can be closed.
+func (ow *OutputWatcher) detach(client *Client) {
+	ow.mutex.Lock()
+	defer ow.mutex.Unlock()
+	delete(ow.clients, client)
+	delete(ow.catchUps, client)
+}
+
+// setPaused holds output back while the session is locked, and relays what
+// was held back once it is unlocked
+func (ow *OutputWatcher) setPaused(paused bool) {
+	ow.mutex.Lock()
+	defer ow.mutex.Unlock()
+
+	if ow.paused == paused {
+		return
+	}
+	ow.paused = paused
+	if paused {
+		return
+	}
+
+	for client, pending := range ow.catchUps {
+		ow.sendCatchUp(client, pending)
+		delete(ow.catchUps, client)
+	}
+	ow.relayWritten(&ow.output, ow.outputFile, ow.stream)
+	if ow.stderrFile != "" {
+		ow.relayWritten(&ow.stderr, ow.stderrFile, "stderr")
+	}
+}
+
+// push relays output just written to one of the watched files
+func (ow *OutputWatcher) push(chunk *terminal.OutputChunk) {
+	ow.mutex.Lock()
+	defer ow.mutex.Unlock()
+
+	if ow.stopped {
+		return
+	}
+
+	position, path, stream := &ow.output, ow.outputFile, ow.stream
+	if chunk.Stderr {
+		position, path, stream = &ow.stderr, ow.stderrFile, "stderr"
+	}
+
+	// Start over if the file was truncated to free disk space
+	if chunk.Truncated {
+		position.relayed = 0
+	}
+	position.written = chunk.End
+
+	if ow.paused || chunk.End <= position.relayed {
+		return // Held back, or already sent with a catch-up
+	}
+
+	data := chunk.Data
+	start := chunk.End - int64(len(data))
+	switch {
+	case start < position.relayed:
+		data = data[position.relayed-start:]
+	case start > position.relayed:
+		ow.relayWritten(position, path, stream)
+		return
+	}
+
+	ow.relay(position, data, chunk.End, stream)
+}
+
+// relayWritten relays output in a file written but not yet relayed
+func (ow *OutputWatcher) relayWritten(position *streamPosition, path, stream string) {
+	if position.written <= position.relayed {
+		return
+	}
+
+	data, err := ow.hub.readRange(path, position.relayed, position.written, false)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", ow.sessionID).Error("Failed to read held back output")
+		return
+	}
+	ow.relay(position, data, position.relayed+int64(len(data)), stream)
+}
+
+// relay sends output ending at end to every attached client
+func (ow *OutputWatcher) relay(position *streamPosition, data []byte, end int64, stream string) {
+	position.relayed = end
+	if len(data) == 0 {
+		return
+	}
+
+	ow.hub.recordFrame(ow.sessionID, CapturePTYOut, "", ow.paneID, data)
+
+	// Inline images are only looked for in the main stream, and are split
+	// out for the clients that asked for them
+	var parts []outputPart
+	if position == &ow.output {
+		parts = ow.images.scan(data, end)
+		if len(parts) == 1 && parts[0].format == "" && parts[0].end == end && len(parts[0].data) == len(data) {
+			parts = nil // Nothing to split
+		}
+	}
+
+	if len(ow.clients) == 0 {
+		return
+	}
+
+	// Encoded once for every client, so the message can be reused
+	messageData, err := ow.outputMessage(data, end, stream)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", ow.sessionID).Error("Failed to marshal output message")
+		return
+	}
+
+	var split []encodedPart
+	screenClients := false
+	for client := range ow.clients {
+		if ow.receivesScreen(client) {
+			screenClients = true
+			continue
+		}
+		if client.images == nil || parts == nil {
+			client.sendEncoded(messageData)
+			continue
+		}
+
+		if split == nil {
+			if split, err = ow.encodeParts(parts, stream); err != nil {
+				logrus.WithError(err).WithField("session_id", ow.sessionID).Error("Failed to marshal image messages")
+				return
+			}
+		}
+		for _, part := range split {
+			if part.imageID != "" && client.images.remember(part.imageID) {
+				client.sendEncoded(part.cached)
+			} else {
+				client.sendEncoded(part.message)
+			}
+		}
+	}
+
+	if screenClients {
+		ow.sendScreenUpdate()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id":      ow.sessionID,
+		"bytes":           len(data),
+		logging.FieldData: string(data),
+	}).Info("Broadcasted new output")
+}
+
+// outputMessage encodes an output message
+func (ow *OutputWatcher) outputMessage(data []byte, end int64, stream string) ([]byte, error) {
+	outputMessage := outputMessages.Get().(*types.WebSocketMessage)
+	*outputMessage = types.WebSocketMessage{
+		Type:      types.MessageTypeOutput,
+		SessionID: ow.sessionID,
+		Data:      string(data),
+		Pane:      ow.paneID,
+		Channel:   stream,
+		Offset:    end,
+		Timestamp: time.Now(),
+		Monotonic: monotonicNow(),
+	}
+	messageData, err := outputMessage.ToJSON()
+	*outputMessage = types.WebSocketMessage{}
+	outputMessages.Put(outputMessage)
+	return messageData, err
+}
@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"os"
+
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// paneEvent reports that a pane of a session opened or closed
+type paneEvent struct {
+	sessionID string
+	paneID    string
+	status    string
+}
+
+// HandlePaneStatus tells the clients of a session that one of its panes
+// opened or closed, and starts or stops relaying the pane's output
+func (h *Hub) HandlePaneStatus(sessionID, paneID, status string) {
+	select {
+	case h.paneEvents <- &paneEvent{sessionID: sessionID, paneID: paneID, status: status}:
+	case <-h.stopChan:
+	}
+}
+
+// handlePaneEvent relays a pane opening or closing to the session's clients
+func (h *Hub) handlePaneEvent(event *paneEvent) {
+	if len(h.clients[event.sessionID]) == 0 {
+		return
+	}
+
+	if event.status == string(types.SessionStatusRunning) {
+		if pane, err := h.sessionManager.GetPane(event.sessionID, event.paneID); err == nil {
+			h.startPaneWatcher(event.sessionID, pane)
+		}
+	} else {
+		h.stopPaneWatcher(event.sessionID, event.paneID)
+	}
+
+	message := types.NewStatusMessage(event.sessionID, event.status)
+	message.Pane = event.paneID
+	h.broadcast(event.sessionID, message)
+}
+
+// startPaneWatchers starts relaying the output of every pane of a session
+func (h *Hub) startPaneWatchers(session *types.Session) {
+	panes, err := h.sessionManager.ListPanes(session.ID)
+	if err != nil {
+		return
+	}
+
+	for _, pane := range panes {
+		h.startPaneWatcher(session.ID, pane)
+	}
+}
+
+// startPaneWatcher starts relaying the output of a pane
+func (h *Hub) startPaneWatcher(sessionID string, pane *types.Pane) {
+	if _, exists := h.paneWatchers[sessionID][pane.ID]; exists {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"pane_id":    pane.ID,
+	}).Info("Starting pane output watcher")
+
+	if h.paneWatchers[sessionID] == nil {
+		h.paneWatchers[sessionID] = make(map[string]*OutputWatcher)
+	}
+
+	watcher := h.newOutputWatcher(sessionID, pane.ID, pane.OutputFile)
+	h.paneWatchers[sessionID][pane.ID] = watcher
+	h.watch(watcher)
+
+	// Clients already attached get the pane's output from now on
+	for client := range h.clients[sessionID] {
+		watcher.attach(client, nil)
+	}
+}
+
+// stopPaneWatcher stops relaying a pane's output and closes its input writer
+func (h *Hub) stopPaneWatcher(sessionID, paneID string) {
+	if watcher, exists := h.paneWatchers[sessionID][paneID]; exists {
+		h.unwatch(watcher)
+		delete(h.paneWatchers[sessionID], paneID)
+		if len(h.paneWatchers[sessionID]) == 0 {
+			delete(h.paneWatchers, sessionID)
+		}
+	}
+
+	if inputFile, exists := h.paneWriters[sessionID][paneID]; exists {
+		inputFile.Close()
+		delete(h.paneWriters[sessionID], paneID)
+		if len(h.paneWriters[sessionID]) == 0 {
+			delete(h.paneWriters, sessionID)
+		}
+	}
+}
+
+// stopPaneWatchers stops relaying every pane of a session
+func (h *Hub) stopPaneWatchers(sessionID string) {
+	for paneID := range h.paneWatchers[sessionID] {
+		h.stopPaneWatcher(sessionID, paneID)
+	}
+	for paneID := range h.paneWriters[sessionID] {
+		h.stopPaneWatcher(sessionID, paneID)
+	}
+}
+
+// writePaneInput writes client input to a pane's input pipe
+func (h *Hub) writePaneInput(input *SessionInput) {
+	session, err := h.sessionManager.GetSession(input.SessionID)
+	if err != nil {
+		return
+	}
+
+	pane, err := h.sessionManager.GetPane(input.SessionID, input.Pane)
+	if err != nil {
+		logrus.WithField("session_id", input.SessionID).Debug("Dropping input for unknown pane")
+		return
+	}
+
+	inputFile, exists := h.paneWriters[input.SessionID][input.Pane]
+	if !exists {
+		// Opening blocks until the pane's runner is reading
+		inputFile, err = os.OpenFile(pane.InputPipe, os.O_WRONLY, 0)
+		if err != nil {
+			logrus.WithError(err).WithField("session_id", input.SessionID).Error("Failed to open pane input pipe")
+			return
+		}
+
+		if h.paneWriters[input.SessionID] == nil {
+			h.paneWriters[input.SessionID] = make(map[string]*os.File)
+		}
+		h.paneWriters[input.SessionID][input.Pane] = inputFile
+	}
+
+	data := terminal.TransformInput(session.Keyboard, input.Data)
+	h.recordFrame(input.SessionID, CapturePTYIn, "", input.Pane, []byte(data))
+	if _, err := inputFile.WriteString(data); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"session_id": input.SessionID,
+			"pane_id":    input.Pane,
+		}).Error("Failed to write to pane input pipe")
+	}
+}
+
+// resizePane resizes a pane's PTY
+func (h *Hub) resizePane(resize *SessionResize) {
+	pane, err := h.sessionManager.GetPane(resize.SessionID, resize.Pane)
+	if err != nil || pane.PTY == nil {
+		return
+	}
+
+	if err := terminal.SetPTYSize(pane.PTY, resize.Rows, resize.Cols); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"session_id": resize.SessionID,
+			"pane_id":    resize.Pane,
+		}).Error("Failed to resize pane PTY")
+	}
+}
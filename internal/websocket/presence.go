@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newPresenceMessage lists the clients attached to a session. Broadcast
+// viewers are anonymous, so they are counted rather than listed.
+func (h *Hub) newPresenceMessage(sessionID string) *types.WebSocketMessage {
+	message := &types.WebSocketMessage{
+		Type:      types.MessageTypePresence,
+		SessionID: sessionID,
+		Presence:  &types.Presence{Clients: []types.PresenceClient{}},
+		Timestamp: time.Now(),
+	}
+
+	for client := range h.clients[sessionID] {
+		if client.broadcastViewer {
+			message.Presence.Viewers++
+			continue
+		}
+		message.Presence.Clients = append(message.Presence.Clients, types.PresenceClient{
+			ClientID: client.id,
+			User:     client.user,
+			ReadOnly: client.readOnly.Load(),
+		})
+	}
+
+	// Sorted so that the list does not shuffle between updates
+	sort.Slice(message.Presence.Clients, func(i, j int) bool {
+		return message.Presence.Clients[i].ClientID < message.Presence.Clients[j].ClientID
+	})
+
+	return message
+}
+
+// broadcastPresence tells the clients of a session, other than broadcast
+// viewers, who is attached to it
+func (h *Hub) broadcastPresence(sessionID string) {
+	sessionClients := h.clients[sessionID]
+	if len(sessionClients) == 0 {
+		return
+	}
+
+	messageData, err := h.newPresenceMessage(sessionID).ToJSON()
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to marshal presence message")
+		return
+	}
+
+	for client := range sessionClients {
+		if !client.broadcastViewer {
+			client.sendEncoded(messageData)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// ReconnectAdvice tells clients disconnected by a server shutdown when and
+// where to reconnect
+type ReconnectAdvice struct {
+	RetryAfter time.Duration // How long clients should wait before reconnecting
+	URL        string        // Another node to reconnect to meanwhile; empty means this one
+}
+
+// closeAdvice is the reason of a close frame carrying reconnect advice
+type closeAdvice struct {
+	Reason       string `json:"reason,omitempty"`
+	RetryAfter   int    `json:"retry_after"`
+	ReconnectURL string `json:"reconnect_url,omitempty"`
+}
+
+// SetReconnectAdvice sets what clients are told when the server shuts
+// down. It must be called before Run.
+func (h *Hub) SetReconnectAdvice(advice ReconnectAdvice) {
+	h.reconnectAdvice = advice
+}
+
+// retryAfterSeconds rounds the advised delay up to whole seconds, as
+// clients are told it
+func (a ReconnectAdvice) retryAfterSeconds() int {
+	return int(math.Ceil(a.RetryAfter.Seconds()))
+}
+
+// adviseReconnect makes the client's closing message and close frame tell
+// it when and where to reconnect. It must be called before the client is
+// closed.
+func (c *Client) adviseReconnect(advice ReconnectAdvice) {
+	c.reconnect = &advice
+}
+
+// closeAdviceReason encodes the reason and reconnect advice of a close
+// frame as JSON, leaving out the reason and then the URL if they do not
+// fit; the closing message sent before the frame has them in full
+func (c *Client) closeAdviceReason() string {
+	payload := closeAdvice{
+		Reason:       c.closeReason,
+		RetryAfter:   c.reconnect.retryAfterSeconds(),
+		ReconnectURL: c.reconnect.URL,
+	}
+
+	for {
+		encoded, err := json.Marshal(payload)
+		if err == nil && len(encoded) <= maxCloseReason {
+			return string(encoded)
+		}
+
+		switch {
+		case payload.Reason != "":
+			payload.Reason = ""
+		case payload.ReconnectURL != "":
+			payload.ReconnectURL = ""
+		default:
+			return ""
+		}
+	}
+}
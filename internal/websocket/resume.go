@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// resumeTokenTTL is how long after disconnecting a client can resume
+const resumeTokenTTL = 5 * time.Minute
+
+// resumeGrant lets the holder of a resume token reattach to a session and
+// receive only the output it missed
+type resumeGrant struct {
+	sessionID string
+	user      string
+	expires   time.Time // Zero while the client it was issued to is attached
+}
+
+// SetResume makes a reconnecting client resume from a token it was issued
+// and the output offsets it had reached. It must be called before the client
+// is registered.
+func (c *Client) SetResume(token string, offset, stderrOffset int64) {
+	c.resumeFrom = token
+	c.resumeOffset = offset
+	c.resumeStderrOffset = stderrOffset
+}
+
+// redeemResumeToken checks the token a client resumes from, which can be
+// used once, by the same user, for the same session
+func (h *Hub) redeemResumeToken(client *Client) bool {
+	if client.resumeFrom == "" {
+		return false
+	}
+
+	grant, exists := h.resumeGrants[client.resumeFrom]
+	if !exists || grant.sessionID != client.sessionID || grant.user != client.user ||
+		(!grant.expires.IsZero() && time.Now().After(grant.expires)) {
+		logrus.WithFields(logrus.Fields{
+			"client_id":  client.id,
+			"session_id": client.sessionID,
+		}).Debug("Resume token not accepted, replaying scrollback")
+		return false
+	}
+
+	delete(h.resumeGrants, client.resumeFrom)
+	return true
+}
+
+// issueResumeToken gives a client the token to resume with if its
+// connection drops
+func (h *Hub) issueResumeToken(client *Client) {
+	now := time.Now()
+	for token, grant := range h.resumeGrants {
+		if !grant.expires.IsZero() && now.After(grant.expires) {
+			delete(h.resumeGrants, token)
+		}
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		logrus.WithError(err).WithField("client_id", client.id).Error("Failed to generate resume token")
+		return
+	}
+	client.resumeToken = base64.RawURLEncoding.EncodeToString(tokenBytes)
+	h.resumeGrants[client.resumeToken] = &resumeGrant{sessionID: client.sessionID, user: client.user}
+
+	client.SendMessage(&types.WebSocketMessage{
+		Type:        types.MessageTypeResume,
+		SessionID:   client.sessionID,
+		ResumeToken: client.resumeToken,
+		Timestamp:   now,
+	})
+}
+
+// releaseResumeToken starts the time a disconnected client has to resume
+func (h *Hub) releaseResumeToken(client *Client) {
+	if grant, exists := h.resumeGrants[client.resumeToken]; exists {
+		grant.expires = time.Now().Add(resumeTokenTTL)
+	}
+}
+
+// revokeResumeToken keeps a client from resuming, such as after it was
+// disconnected by an operator
+func (h *Hub) revokeResumeToken(client *Client) {
+	delete(h.resumeGrants, client.resumeToken)
+}
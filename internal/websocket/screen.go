@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ScreenUpdates is how a client is sent changes to its session's screen
+type ScreenUpdates string
+
+const (
+	// ScreenUpdatesOutput sends the escape sequences redrawing the rows
+	// that changed, which are written to a terminal like output
+	ScreenUpdatesOutput ScreenUpdates = "diff"
+	// ScreenUpdatesCells sends the cells that changed, for clients that
+	// draw the screen themselves
+	ScreenUpdatesCells ScreenUpdates = "cells"
+)
+
+// receivesScreen reports whether a client is sent screen updates instead of
+// the watcher's output
+func (ow *OutputWatcher) receivesScreen(client *Client) bool {
+	return client.screenUpdates != "" && ow.screen != nil
+}
+
+// sendScreen sends a client that receives screen updates the whole screen,
+// after bringing the other clients up to date so that they all continue
+// from the same frame. The watcher's mutex must be held.
+func (ow *OutputWatcher) sendScreen(client *Client) {
+	ow.sendScreenUpdate()
+	if ow.frame == nil {
+		ow.frame = ow.screen.Frame()
+	}
+	ow.sendFrame(client)
+}
+
+// sendFrame sends a client the whole of the last frame. The watcher's mutex
+// must be held.
+func (ow *OutputWatcher) sendFrame(client *Client) {
+	message := ow.screenMessage(ow.frame, ow.frame.Render())
+	if client.screenUpdates == ScreenUpdatesCells {
+		spans, _ := ow.frame.CellDiff(nil)
+		message = ow.cellsMessage(ow.frame, spans, true)
+	}
+
+	messageData, err := message.ToJSON()
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", ow.sessionID).Error("Failed to marshal screen message")
+		return
+	}
+	client.screenStale = !client.sendEncoded(messageData)
+}
+
+// sendScreenUpdate sends the changes since the last frame to the clients
+// receiving screen updates. The watcher's mutex must be held.
+func (ow *OutputWatcher) sendScreenUpdate() {
+	if ow.frame == nil {
+		return // No client has been sent a frame yet
+	}
+
+	frame := ow.screen.Frame()
+	prev := ow.frame
+	ow.frame = frame
+
+	// Each kind of update is encoded once, and only if a client needs it
+	updates := make(map[ScreenUpdates][]byte)
+	encode := func(updates ScreenUpdates) ([]byte, error) {
+		var message *types.WebSocketMessage
+		if updates == ScreenUpdatesCells {
+			spans, reset := frame.CellDiff(prev)
+			if len(spans) == 0 && !reset && !frame.CursorMoved(prev) {
+				return nil, nil
+			}
+			message = ow.cellsMessage(frame, spans, reset)
+		} else {
+			diff := frame.Diff(prev)
+			if diff == nil {
+				return nil, nil
+			}
+			message = ow.screenMessage(frame, diff)
+		}
+		return message.ToJSON()
+	}
+
+	for client := range ow.clients {
+		if !ow.receivesScreen(client) {
+			continue
+		}
+
+		// A client that missed an update is sent the whole screen, so a
+		// slow one skips to the latest frame rather than falling behind
+		if client.screenStale {
+			ow.sendFrame(client)
+			continue
+		}
+
+		messageData, encoded := updates[client.screenUpdates]
+		if !encoded {
+			var err error
+			if messageData, err = encode(client.screenUpdates); err != nil {
+				logrus.WithError(err).WithField("session_id", ow.sessionID).Error("Failed to marshal screen message")
+				return
+			}
+			updates[client.screenUpdates] = messageData
+		}
+		if messageData != nil && !client.sendEncoded(messageData) {
+			client.screenStale = true
+		}
+	}
+}
+
+// sendMissedScreen sends a client attaching to a full-screen program the
+// screen instead of the scrollback
+func (ow *OutputWatcher) sendMissedScreen(client *Client, frame *terminal.ScreenFrame, end int64) {
+	client.SendMessage(&types.WebSocketMessage{
+		Type:       types.MessageTypeOutput,
+		SessionID:  ow.sessionID,
+		Data:       string(frame.Render()),
+		Scrollback: true,
+		Offset:     end,
+		Timestamp:  time.Now(),
+	})
+}
+
+// screenMessage creates a screen message drawing changes to a frame
+func (ow *OutputWatcher) screenMessage(frame *terminal.ScreenFrame, data []byte) *types.WebSocketMessage {
+	return &types.WebSocketMessage{
+		Type:      types.MessageTypeScreen,
+		SessionID: ow.sessionID,
+		Data:      string(data),
+		Rows:      frame.Rows,
+		Cols:      frame.Cols,
+		Timestamp: time.Now(),
+		Monotonic: monotonicNow(),
+	}
+}
+
+// cellsMessage creates a cells message drawing changes to a frame
+func (ow *OutputWatcher) cellsMessage(frame *terminal.ScreenFrame, spans []types.ScreenSpan, reset bool) *types.WebSocketMessage {
+	return &types.WebSocketMessage{
+		Type:      types.MessageTypeCells,
+		SessionID: ow.sessionID,
+		Rows:      frame.Rows,
+		Cols:      frame.Cols,
+		Spans:     spans,
+		Reset:     reset,
+		Cursor: &types.ScreenCursor{
+			Row:     frame.CursorRow,
+			Col:     frame.CursorCol,
+			Visible: frame.CursorVisible,
+		},
+		Timestamp: time.Now(),
+		Monotonic: monotonicNow(),
+	}
+}
@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// fromScrollback starts a catch-up at the scrollback instead of an offset
+const fromScrollback = -1
+
+// maxCatchUpBytes is the most missed output sent to a resuming client. A
+// client that missed more gets the scrollback instead.
+const maxCatchUpBytes = 1 << 20
+
+// catchUp is output a client attaching to a watched session has not seen:
+// the scrollback, or what it missed while reconnecting. It is sent before
+// any live output, so nothing arrives twice or out of order.
+type catchUp struct {
+	from       int64 // Offset in the output file, or fromScrollback
+	stderrFrom int64 // The same for the stderr file
+}
+
+// SetScrollback sets how many bytes of earlier output are replayed to clients
+// as they attach; zero disables replay. It must be called before Run.
+func (h *Hub) SetScrollback(bytes int64) {
+	h.scrollbackBytes = bytes
+}
+
+// attachOutput has the watchers of a client's session relay output to it,
+// after the output it has not seen, from the offsets it resumes from or else
+// the scrollback
+func (h *Hub) attachOutput(client *Client, resumed bool) {
+	if watcher, exists := h.outputWatchers[client.sessionID]; exists {
+		pending := &catchUp{from: fromScrollback, stderrFrom: fromScrollback}
+		if resumed {
+			pending.from = client.resumeOffset
+			pending.stderrFrom = client.resumeStderrOffset
+		}
+		watcher.attach(client, pending)
+	}
+
+	// Pane output is replayed but not resumed
+	for _, watcher := range h.paneWatchers[client.sessionID] {
+		watcher.attach(client, &catchUp{from: fromScrollback, stderrFrom: fromScrollback})
+	}
+}
+
+// detachOutput stops relaying output to a client leaving its session. Once
+// it returns, nothing more is sent to the client, so it can be closed.
+func (h *Hub) detachOutput(client *Client) {
+	if watcher, exists := h.outputWatchers[client.sessionID]; exists {
+		watcher.detach(client)
+	}
+	for _, watcher := range h.paneWatchers[client.sessionID] {
+		watcher.detach(client)
+	}
+}
+
+// sendCatchUp sends a client its catch-up from each watched file, up to
+// where live output continues. The watcher's mutex must be held.
+func (ow *OutputWatcher) sendCatchUp(client *Client, pending *catchUp) {
+	if ow.receivesScreen(client) {
+		ow.sendScreen(client)
+		return
+	}
+
+	ow.sendMissed(client, ow.outputFile, pending.from, ow.output.relayed, ow.stream)
+	if ow.stderrFile != "" {
+		ow.sendMissed(client, ow.stderrFile, pending.stderrFrom, ow.stderr.relayed, "stderr")
+	}
+}
+
+// sendMissed sends a client a file's output from an offset up to end. An
+// offset that is gone or too far back falls back to the scrollback.
+func (ow *OutputWatcher) sendMissed(client *Client, path string, from, end int64, stream string) {
+	scrollback := from == fromScrollback || from > end || end-from > maxCatchUpBytes
+	if scrollback {
+		if ow.hub.scrollbackBytes <= 0 {
+			return
+		}
+		from = end - ow.hub.scrollbackBytes
+
+		// Replaying the output of a full-screen program would not redraw
+		// it, so the screen is drawn instead
+		if ow.screen != nil && stream != "stderr" {
+			if frame := ow.screen.Frame(); frame.AltScreen {
+				ow.sendMissedScreen(client, frame, end)
+				return
+			}
+		}
+	}
+
+	data, err := ow.hub.readRange(path, from, end, scrollback)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"session_id": ow.sessionID,
+			"pane_id":    ow.paneID,
+		}).Warn("Failed to read missed output")
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	client.SendMessage(&types.WebSocketMessage{
+		Type:       types.MessageTypeOutput,
+		SessionID:  ow.sessionID,
+		Data:       string(data),
+		Pane:       ow.paneID,
+		Channel:    stream,
+		Scrollback: scrollback,
+		Offset:     end,
+		Timestamp:  time.Now(),
+	})
+}
+
+// readRange reads the output in a file from start up to end. With trim, a
+// partial first line is dropped when start is not the beginning of the
+// output, so replay does not begin inside an escape sequence or a multi-byte
+// character.
+func (h *Hub) readRange(path string, start, end int64, trim bool) ([]byte, error) {
+	data, err := h.sealer.ReadRange(path, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if trim && start > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"context"
+
+	"github.com/piyushgupta53/webterm/internal/snippets"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// SetSnippetStore sets where run_snippet messages look up snippets. It must
+// be called before Run.
+func (h *Hub) SetSnippetStore(store *snippets.Store) {
+	h.snippetStore = store
+}
+
+// handleRunSnippetMessage types one of the client's stored snippets into the session
+func (c *Client) handleRunSnippetMessage(ctx context.Context, message *types.WebSocketMessage) {
+	if c.hub.snippetStore == nil {
+		c.sendError("Snippets are not available")
+		return
+	}
+
+	snippet, err := c.hub.snippetStore.Get(c.user, message.Data)
+	if err != nil {
+		c.sendError("Snippet not found")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"client_id":  c.id,
+		"session_id": c.sessionID,
+		"snippet_id": snippet.ID,
+	}).Debug("Running snippet")
+
+	c.hub.sessionInput <- &SessionInput{
+		Ctx:       ctx,
+		SessionID: c.sessionID,
+		Pane:      message.Pane,
+		ClientID:  c.id,
+		User:      c.user,
+		Data:      snippet.Content,
+	}
+}
@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"github.com/piyushgupta53/webterm/internal/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// maxStartQueue bounds the input and resizes held for a starting session
+const maxStartQueue = 1024
+
+// queuedStart is input or a resize held until its session is running
+type queuedStart struct {
+	input  *SessionInput
+	resize *SessionResize
+}
+
+// queueUntilStarted holds input or a resize for a session that is still
+// starting, and reports whether it did. Once anything is held for a session,
+// everything after it is held too, so it is applied in the order received.
+func (h *Hub) queueUntilStarted(sessionID string, op *queuedStart) bool {
+	queue, queued := h.startQueues[sessionID]
+	if !queued {
+		started := h.sessionManager.SessionStarting(sessionID)
+		if started == nil {
+			return false
+		}
+
+		go func() {
+			<-started
+			select {
+			case h.sessionStarted <- sessionID:
+			case <-h.stopChan:
+			}
+		}()
+	}
+
+	if len(queue) >= maxStartQueue {
+		logrus.WithField("session_id", sessionID).Warn("Dropping input for session still starting")
+		return true
+	}
+	h.startQueues[sessionID] = append(queue, op)
+	return true
+}
+
+// releaseStartQueue applies what was held for a session now that it is
+// running, or drops it if the session failed to start
+func (h *Hub) releaseStartQueue(sessionID string) {
+	queue := h.startQueues[sessionID]
+	delete(h.startQueues, sessionID)
+
+	if session, err := h.sessionManager.GetSession(sessionID); err != nil || !session.IsActive() {
+		logrus.WithFields(logrus.Fields{
+			"session_id": sessionID,
+			"queued":     len(queue),
+		}).Warn("Session failed to start, dropping queued input")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"queued":     len(queue),
+	}).Debug("Session started, releasing queued input")
+
+	for _, op := range queue {
+		if op.resize != nil {
+			h.resizeSession(op.resize)
+			continue
+		}
+
+		span := tracing.NoopSpan()
+		if tracing.Enabled() {
+			_, span = tracing.Start(messageContext(op.input.Ctx), "hub.input",
+				tracing.AttrSessionID.String(sessionID), tracing.AttrBytes.Int(len(op.input.Data)))
+		}
+		h.writeSessionInput(op.input, span)
+		span.End()
+	}
+}
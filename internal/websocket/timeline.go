@@ -0,0 +1,194 @@
+package websocket
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TimelineBucket is how much activity each bucket of a timeline covers
+	TimelineBucket = time.Minute
+
+	// TimelineWindow is how far back timelines go
+	TimelineWindow = time.Hour
+
+	// maxTimelineEvents is how many attach and detach events each timeline keeps
+	maxTimelineEvents = 100
+
+	// timelinePruneInterval is how often timelines of removed sessions are dropped
+	timelinePruneInterval = time.Minute
+)
+
+// Timeline event types
+const (
+	TimelineEventAttach = "attach"
+	TimelineEventDetach = "detach"
+)
+
+// ActivityBucket is the activity of a session during one minute
+type ActivityBucket struct {
+	Start    time.Time `json:"start"`
+	BytesIn  int64     `json:"bytes_in"`
+	BytesOut int64     `json:"bytes_out"`
+	Commands int       `json:"commands"` // Lines of input entered
+}
+
+// TimelineEvent is a client attaching to or detaching from a session
+type TimelineEvent struct {
+	Time            time.Time `json:"time"`
+	Type            string    `json:"type"`
+	ClientID        string    `json:"client_id"`
+	User            string    `json:"user,omitempty"`
+	BroadcastViewer bool      `json:"broadcast_viewer,omitempty"`
+}
+
+// SessionTimeline is the recent activity of a session, for sparklines
+type SessionTimeline struct {
+	SessionID     string           `json:"session_id"`
+	BucketSeconds int              `json:"bucket_seconds"`
+	Buckets       []ActivityBucket `json:"buckets"`
+	Events        []TimelineEvent  `json:"events"`
+}
+
+// timeline records the activity of one session. Buckets hold only minutes
+// with activity, oldest first.
+type timeline struct {
+	buckets []ActivityBucket
+	events  []TimelineEvent
+}
+
+// bucket returns the bucket of the current minute, dropping buckets that
+// have left the window
+func (t *timeline) bucket(now time.Time) *ActivityBucket {
+	start := now.Truncate(TimelineBucket)
+	if n := len(t.buckets); n > 0 && t.buckets[n-1].Start.Equal(start) {
+		return &t.buckets[n-1]
+	}
+
+	cutoff := start.Add(-TimelineWindow)
+	kept := 0
+	for kept < len(t.buckets) && !t.buckets[kept].Start.After(cutoff) {
+		kept++
+	}
+	t.buckets = append(t.buckets[kept:], ActivityBucket{Start: start})
+	return &t.buckets[len(t.buckets)-1]
+}
+
+// timelines holds the timelines of sessions, recorded from the hub and from
+// session output goroutines
+type timelines struct {
+	mutex    sync.Mutex
+	sessions map[string]*timeline
+}
+
+// get returns the timeline of a session, creating it if needed (assumes
+// mutex is held)
+func (ts *timelines) get(sessionID string) *timeline {
+	if ts.sessions == nil {
+		ts.sessions = make(map[string]*timeline)
+	}
+	t := ts.sessions[sessionID]
+	if t == nil {
+		t = &timeline{}
+		ts.sessions[sessionID] = t
+	}
+	return t
+}
+
+// recordInput counts input typed into a session, and each line it enters
+// as a command
+func (h *Hub) recordInput(sessionID, data string) {
+	h.timelines.mutex.Lock()
+	defer h.timelines.mutex.Unlock()
+
+	bucket := h.timelines.get(sessionID).bucket(time.Now())
+	bucket.BytesIn += int64(len(data))
+	bucket.Commands += strings.Count(data, "\r") + strings.Count(data, "\n")
+}
+
+// recordOutput counts output written by a session
+func (h *Hub) recordOutput(sessionID string, bytes int) {
+	h.timelines.mutex.Lock()
+	defer h.timelines.mutex.Unlock()
+
+	h.timelines.get(sessionID).bucket(time.Now()).BytesOut += int64(bytes)
+}
+
+// recordClientEvent notes a client attaching to or detaching from its session
+func (h *Hub) recordClientEvent(client *Client, eventType string) {
+	h.timelines.mutex.Lock()
+	defer h.timelines.mutex.Unlock()
+
+	t := h.timelines.get(client.sessionID)
+	t.events = append(t.events, TimelineEvent{
+		Time:            time.Now(),
+		Type:            eventType,
+		ClientID:        client.id,
+		User:            client.user,
+		BroadcastViewer: client.broadcastViewer,
+	})
+	if len(t.events) > maxTimelineEvents {
+		t.events = append(t.events[:0], t.events[len(t.events)-maxTimelineEvents:]...)
+	}
+}
+
+// Timeline returns the activity of a session over the last window, at most
+// TimelineWindow, with a bucket for every minute of it
+func (h *Hub) Timeline(sessionID string, window time.Duration) *SessionTimeline {
+	window = min(window, TimelineWindow)
+	now := time.Now()
+	first := now.Truncate(TimelineBucket).Add(-window + TimelineBucket)
+
+	result := &SessionTimeline{
+		SessionID:     sessionID,
+		BucketSeconds: int(TimelineBucket / time.Second),
+		Buckets:       []ActivityBucket{},
+		Events:        []TimelineEvent{},
+	}
+
+	h.timelines.mutex.Lock()
+	defer h.timelines.mutex.Unlock()
+
+	var recorded []ActivityBucket
+	if t := h.timelines.sessions[sessionID]; t != nil {
+		recorded = t.buckets
+		for _, event := range t.events {
+			if !event.Time.Before(first) {
+				result.Events = append(result.Events, event)
+			}
+		}
+	}
+
+	// Minutes without activity get empty buckets
+	for start := first; !start.After(now); start = start.Add(TimelineBucket) {
+		for len(recorded) > 0 && recorded[0].Start.Before(start) {
+			recorded = recorded[1:]
+		}
+		if len(recorded) > 0 && recorded[0].Start.Equal(start) {
+			result.Buckets = append(result.Buckets, recorded[0])
+		} else {
+			result.Buckets = append(result.Buckets, ActivityBucket{Start: start})
+		}
+	}
+
+	return result
+}
+
+// pruneTimelines drops the timelines of sessions that have been removed
+func (h *Hub) pruneTimelines() {
+	h.timelines.mutex.Lock()
+	sessionIDs := make([]string, 0, len(h.timelines.sessions))
+	for sessionID := range h.timelines.sessions {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	h.timelines.mutex.Unlock()
+
+	for _, sessionID := range sessionIDs {
+		if _, err := h.sessionManager.GetSession(sessionID); err != nil {
+			h.timelines.mutex.Lock()
+			delete(h.timelines.sessions, sessionID)
+			h.timelines.mutex.Unlock()
+		}
+	}
+}
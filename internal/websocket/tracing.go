@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/terminal"
+	"github.com/piyushgupta53/webterm/internal/tracing"
+	"github.com/piyushgupta53/webterm/internal/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// echoTimeout is how long after input its session's output is still taken
+// as the echo of it
+const echoTimeout = 5 * time.Second
+
+// pendingEcho is traced input whose session has not written output since
+type pendingEcho struct {
+	input  trace.SpanContext
+	sentAt time.Time
+}
+
+// echoes holds the latest traced input of each session, written by the hub
+// and read by session output goroutines
+type echoes struct {
+	mutex    sync.Mutex
+	sessions map[string]pendingEcho
+}
+
+// SetTraceLink links the spans of the client's messages to the trace of the
+// request it connected with. It must be called before the client is
+// registered.
+func (c *Client) SetTraceLink(link trace.SpanContext) {
+	c.traceLink = link
+}
+
+// startMessageSpan starts the span of a message from the client. Connections
+// last too long to parent their messages' spans, so they are linked instead.
+// While tracing is disabled, it returns a no-op span.
+func (c *Client) startMessageSpan(message *types.WebSocketMessage) (context.Context, trace.Span) {
+	if !tracing.Enabled() {
+		return context.Background(), tracing.NoopSpan()
+	}
+
+	options := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			tracing.AttrSessionID.String(c.sessionID),
+			tracing.AttrClientID.String(c.id),
+			tracing.AttrBytes.Int(len(message.Data)),
+		),
+	}
+	if message.Pane != "" {
+		options = append(options, trace.WithAttributes(tracing.AttrPane.String(message.Pane)))
+	}
+	if c.traceLink.IsValid() {
+		options = append(options, trace.WithLinks(trace.Link{SpanContext: c.traceLink}))
+	}
+
+	return tracing.Tracer().Start(context.Background(), "websocket."+string(message.Type), options...)
+}
+
+// messageContext returns the trace context input or a resize came with
+func messageContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// awaitEcho has the next output of a session traced as the echo of input
+func (h *Hub) awaitEcho(sessionID string, input trace.SpanContext) {
+	if !input.IsSampled() {
+		return
+	}
+
+	h.echoes.mutex.Lock()
+	defer h.echoes.mutex.Unlock()
+
+	if h.echoes.sessions == nil {
+		h.echoes.sessions = make(map[string]pendingEcho)
+	}
+	h.echoes.sessions[sessionID] = pendingEcho{input: input, sentAt: time.Now()}
+}
+
+// traceEcho records a span from traced input to the first output its
+// session wrote after it, which is how long a keystroke took to echo
+func (h *Hub) traceEcho(chunk *terminal.OutputChunk) {
+	if chunk.PaneID != "" {
+		return
+	}
+
+	h.echoes.mutex.Lock()
+	pending, exists := h.echoes.sessions[chunk.SessionID]
+	delete(h.echoes.sessions, chunk.SessionID)
+	h.echoes.mutex.Unlock()
+
+	if !exists || time.Since(pending.sentAt) > echoTimeout {
+		return
+	}
+
+	_, span := tracing.Tracer().Start(trace.ContextWithSpanContext(context.Background(), pending.input), "session.echo",
+		trace.WithTimestamp(pending.sentAt),
+		trace.WithAttributes(
+			tracing.AttrSessionID.String(chunk.SessionID),
+			tracing.AttrBytes.Int(len(chunk.Data)),
+		))
+	span.End()
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transport carries JSON-encoded messages between a client and the browser
+type Transport interface {
+	// ReadMessage blocks until the next inbound message arrives
+	ReadMessage() ([]byte, error)
+
+	// WriteMessage sends a single outbound message. The data may be shared
+	// with other clients and must not be modified.
+	WriteMessage(data []byte) error
+
+	// Ping checks that the peer is still alive
+	Ping() error
+
+	// Close closes the underlying connection
+	Close() error
+
+	// RemoteAddr returns the address of the peer
+	RemoteAddr() string
+}
+
+// closeNotifier is implemented by transports that can tell the peer the hub closed the connection
+type closeNotifier interface {
+	NotifyClose(frame []byte) error
+}
+
+// wsTransport adapts a gorilla WebSocket connection to the Transport interface
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+// newWSTransport wraps conn, applying the read limit and pong deadline handling
+func newWSTransport(conn *websocket.Conn) *wsTransport {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	return &wsTransport{conn: conn}
+}
+
+func (t *wsTransport) ReadMessage() ([]byte, error) {
+	_, data, err := t.conn.ReadMessage()
+	return data, err
+}
+
+func (t *wsTransport) WriteMessage(data []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (t *wsTransport) Ping() error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+// NotifyClose sends a close frame with the given payload to the peer
+func (t *wsTransport) NotifyClose(frame []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(websocket.CloseMessage, frame)
+}
+
+func (t *wsTransport) Close() error {
+	return t.conn.Close()
+}
+
+func (t *wsTransport) RemoteAddr() string {
+	return t.conn.RemoteAddr().String()
+}
@@ -0,0 +1,173 @@
+package webtransport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/piyushgupta53/webterm/internal/types"
+	wt "github.com/quic-go/webtransport-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Time allowed to write a message to the stream
+	writeWait = 10 * time.Second
+
+	// Maximum size of a single message on the stream
+	maxMessageSize = 64 * 1024
+
+	// Error code sent when the server closes the session
+	sessionClosedCode wt.SessionErrorCode = 0
+)
+
+// Transport carries terminal messages over a WebTransport session. Messages
+// on the session's bidirectional stream are newline-delimited JSON; the
+// browser may also send resize and ping messages as datagrams, and other
+// datagrams are dropped.
+type Transport struct {
+	session *wt.Session
+	stream  *wt.Stream
+
+	incoming  chan []byte
+	readErr   chan error
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTransport starts reading messages from the stream and datagrams of a session
+func NewTransport(session *wt.Session, stream *wt.Stream) *Transport {
+	t := &Transport{
+		session:  session,
+		stream:   stream,
+		incoming: make(chan []byte, 64),
+		readErr:  make(chan error, 1),
+		closed:   make(chan struct{}),
+	}
+
+	go t.readStream()
+	go t.readDatagrams()
+
+	return t
+}
+
+// readStream splits the stream into newline-delimited messages
+func (t *Transport) readStream() {
+	scanner := bufio.NewScanner(t.stream)
+	scanner.Buffer(make([]byte, 4096), maxMessageSize)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if !t.deliver(append([]byte(nil), scanner.Bytes()...)) {
+			return
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+
+	select {
+	case t.readErr <- err:
+	default:
+	}
+}
+
+// readDatagrams forwards resize and ping datagrams until the session ends.
+// Datagrams may be lost or reordered, so anything else, input above all, is
+// dropped rather than delivered out of order with the stream.
+func (t *Transport) readDatagrams() {
+	for {
+		data, err := t.session.ReceiveDatagram(context.Background())
+		if err != nil {
+			return
+		}
+		if !datagramAllowed(data) {
+			logrus.WithField("remote_addr", t.RemoteAddr()).Debug("Dropped datagram that is not a resize or ping")
+			continue
+		}
+		if !t.deliver(data) {
+			return
+		}
+	}
+}
+
+// datagramAllowed reports whether data is a message that may be sent as a datagram
+func datagramAllowed(data []byte) bool {
+	var message struct {
+		Type types.MessageType `json:"type"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return false
+	}
+	return message.Type == types.MessageTypeResize || message.Type == types.MessageTypePing
+}
+
+// deliver queues an inbound message, returning false once the transport is closed
+func (t *Transport) deliver(data []byte) bool {
+	select {
+	case t.incoming <- data:
+		return true
+	case <-t.closed:
+		return false
+	}
+}
+
+// ReadMessage blocks until the next message from the stream or a datagram
+// arrives. Messages queued before the stream ended are returned before its
+// error.
+func (t *Transport) ReadMessage() ([]byte, error) {
+	select {
+	case data := <-t.incoming:
+		return data, nil
+	case err := <-t.readErr:
+		select {
+		case data := <-t.incoming:
+			// Keep the error for once the queue is drained
+			t.readErr <- err
+			return data, nil
+		default:
+			return nil, err
+		}
+	case <-t.closed:
+		return nil, io.EOF
+	}
+}
+
+// WriteMessage writes a newline-terminated message to the stream
+func (t *Transport) WriteMessage(data []byte) error {
+	t.stream.SetWriteDeadline(time.Now().Add(writeWait))
+	// The data is shared with other clients, so it is copied rather than appended to
+	frame := make([]byte, len(data)+1)
+	copy(frame, data)
+	frame[len(data)] = '\n'
+
+	_, err := t.stream.Write(frame)
+	return err
+}
+
+// Ping reports whether the session is still open; QUIC keeps the connection alive itself
+func (t *Transport) Ping() error {
+	return t.session.Context().Err()
+}
+
+// Close ends the WebTransport session
+func (t *Transport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		err = t.session.CloseWithError(sessionClosedCode, "")
+	})
+	return err
+}
+
+// RemoteAddr returns the address of the browser
+func (t *Transport) RemoteAddr() string {
+	return t.session.RemoteAddr().String()
+}
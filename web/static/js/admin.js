@@ -0,0 +1,195 @@
+// Operator dashboard listing live sessions and their clients
+class AdminDashboard {
+  constructor() {
+    this.apiBaseUrl = "/api/admin";
+    this.refreshInterval = 5000;
+    this.elements = {
+      summary: document.getElementById("dashboard-summary"),
+      sessions: document.getElementById("dashboard-sessions"),
+      bannerForm: document.getElementById("banner-form"),
+      bannerMessage: document.getElementById("banner-message"),
+      bannerLevel: document.getElementById("banner-level"),
+    };
+  }
+
+  start() {
+    this.elements.bannerForm.addEventListener("submit", (event) => {
+      event.preventDefault();
+      this.sendBanner();
+    });
+
+    this.refresh();
+    setInterval(() => this.refresh(), this.refreshInterval);
+  }
+
+  async refresh() {
+    try {
+      const response = await fetch(`${this.apiBaseUrl}/sessions`);
+      if (response.status === 403) {
+        this.elements.summary.textContent = "Admin privileges required";
+        return;
+      }
+      if (!response.ok) {
+        throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+      }
+
+      const data = await response.json();
+      this.elements.summary.textContent = `${data.session_count} sessions, ${data.client_count} clients`;
+      this.render(data.sessions);
+
+      const info = await fetch("/api/server/info").then((r) => r.json());
+      if (info.maintenance.scheduled) {
+        const at = new Date(info.maintenance.shutdown_at).toLocaleTimeString();
+        this.elements.summary.textContent += `, maintenance at ${at}${
+          info.maintenance.sessions_blocked ? " (new sessions blocked)" : ""
+        }`;
+      }
+    } catch (error) {
+      console.error("Failed to load dashboard:", error);
+      this.elements.summary.textContent = `Failed to load: ${error.message}`;
+    }
+  }
+
+  render(sessions) {
+    const body = this.elements.sessions;
+    body.innerHTML = "";
+
+    sessions.forEach((session) => {
+      const resources = session.resources || {};
+      const row = document.createElement("tr");
+
+      [
+        session.id.substring(0, 8),
+        session.owner || "-",
+        session.locked ? `${session.status} (locked)` : session.status,
+        session.backend,
+        String(session.clients.length),
+        resources.processes ? `${resources.cpu_seconds.toFixed(1)}s` : "-",
+        resources.processes ? this.formatBytes(resources.memory_bytes) : "-",
+        `${this.formatBytes(resources.bytes_in || 0)} / ${this.formatBytes(
+          resources.bytes_out || 0
+        )}`,
+        session.last_input ? this.formatIdle(session.last_input) : "-",
+      ].forEach((value) => {
+        const cell = document.createElement("td");
+        cell.textContent = value;
+        row.appendChild(cell);
+      });
+
+      const actions = document.createElement("td");
+      if (session.status !== "stopped" && session.status !== "error") {
+        actions.appendChild(
+          this.button("Terminate", () => this.terminateSession(session))
+        );
+      }
+      row.appendChild(actions);
+      body.appendChild(row);
+
+      session.clients.forEach((client) => {
+        body.appendChild(this.clientRow(client));
+      });
+    });
+  }
+
+  clientRow(client) {
+    const row = document.createElement("tr");
+    row.className = "dashboard-client";
+
+    const cell = document.createElement("td");
+    cell.colSpan = 9;
+    const kind = client.broadcast_viewer
+      ? "viewer"
+      : client.read_only
+      ? "read-only"
+      : "client";
+    cell.textContent = `↳ ${kind} ${client.user || ""} from ${
+      client.remote_addr
+    }, connected ${new Date(client.connected_at).toLocaleTimeString()}`;
+    cell.title = client.user_agent;
+    row.appendChild(cell);
+
+    const actions = document.createElement("td");
+    actions.appendChild(this.button("Kick", () => this.kickClient(client)));
+    row.appendChild(actions);
+
+    return row;
+  }
+
+  button(label, onClick) {
+    const button = document.createElement("button");
+    button.className = "btn btn-danger";
+    button.textContent = label;
+    button.addEventListener("click", onClick);
+    return button;
+  }
+
+  async sendBanner() {
+    try {
+      const response = await fetch(`${this.apiBaseUrl}/banner`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({
+          message: this.elements.bannerMessage.value,
+          level: this.elements.bannerLevel.value,
+        }),
+      });
+      if (!response.ok) {
+        throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+      }
+
+      const data = await response.json();
+      this.elements.bannerMessage.value = "";
+      alert(`Banner sent to ${data.delivered} clients`);
+    } catch (error) {
+      alert(`Failed to send banner: ${error.message}`);
+    }
+  }
+
+  async terminateSession(session) {
+    if (!confirm(`Terminate session ${session.id} of ${session.owner}?`)) {
+      return;
+    }
+    await this.send(`${this.apiBaseUrl}/sessions/${session.id}`);
+  }
+
+  async kickClient(client) {
+    await this.send(`${this.apiBaseUrl}/clients/${client.id}`);
+  }
+
+  async send(url) {
+    try {
+      const response = await fetch(url, { method: "DELETE" });
+      if (!response.ok) {
+        throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+      }
+    } catch (error) {
+      alert(`Request failed: ${error.message}`);
+    }
+    this.refresh();
+  }
+
+  formatBytes(bytes) {
+    const units = ["B", "KB", "MB", "GB"];
+    let value = bytes;
+    let unit = 0;
+    while (value >= 1024 && unit < units.length - 1) {
+      value /= 1024;
+      unit++;
+    }
+    return `${value.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`;
+  }
+
+  formatIdle(lastInput) {
+    const seconds = Math.max(
+      0,
+      Math.floor((Date.now() - new Date(lastInput).getTime()) / 1000)
+    );
+    if (seconds < 60) return `${seconds}s`;
+    if (seconds < 3600) return `${Math.floor(seconds / 60)}m`;
+    return `${Math.floor(seconds / 3600)}h`;
+  }
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+  new AdminDashboard().start();
+});
@@ -47,7 +47,21 @@ class WebTermApp {
       connectionStatus: document.getElementById("connection-status"),
       terminalContainer: document.getElementById("terminal-container"),
       terminalPlaceholder: document.getElementById("terminal-placeholder"),
+      terminalLock: document.getElementById("terminal-lock"),
+      unlockForm: document.getElementById("unlock-form"),
+      unlockPassword: document.getElementById("unlock-password"),
+      sessionPresence: document.getElementById("current-session-presence"),
+      terminalBanner: document.getElementById("terminal-banner"),
+      terminalBannerText: document.getElementById("terminal-banner-text"),
+      terminalBannerClose: document.getElementById("terminal-banner-close"),
+      snippetsButton: document.getElementById("terminal-snippets"),
+      snippetPalette: document.getElementById("snippet-palette"),
+      snippetFilter: document.getElementById("snippet-filter"),
+      snippetList: document.getElementById("snippet-list"),
     };
+
+    // Snippets loaded for the command palette
+    this.snippets = [];
   }
 
   setupEventHandlers() {
@@ -65,6 +79,30 @@ class WebTermApp {
       this.updateConnectionStatus
     );
 
+    // Command palette of stored snippets
+    this.elements.snippetsButton.addEventListener("click", () => {
+      this.toggleSnippetPalette();
+    });
+    this.elements.snippetFilter.addEventListener("input", () => {
+      this.renderSnippets();
+    });
+    this.elements.snippetFilter.addEventListener("keydown", (event) => {
+      if (event.key === "Enter") {
+        const first = this.filteredSnippets()[0];
+        if (first) {
+          this.runSnippet(first.id);
+        }
+      } else if (event.key === "Escape") {
+        this.closeSnippetPalette();
+      }
+    });
+    document.addEventListener("keydown", (event) => {
+      if (event.ctrlKey && event.shiftKey && event.key.toLowerCase() === "p") {
+        event.preventDefault();
+        this.toggleSnippetPalette();
+      }
+    });
+
     // Window events
     window.addEventListener("beforeunload", () => {
       this.cleanup();
@@ -104,6 +142,15 @@ class WebTermApp {
       this.setConnectionStatus("disconnected");
     });
 
+    this.websocketClient.on("closing", (data) => {
+      if (this.sessionManager && data.reason) {
+        const message = data.reconnectUrl
+          ? `${data.reason}, continue at ${data.reconnectUrl}`
+          : data.reason;
+        this.sessionManager.showNotification(message, "info");
+      }
+    });
+
     this.websocketClient.on("error", (error) => {
       console.error("WebSocket error:", error);
       this.setConnectionStatus("error");
@@ -117,6 +164,46 @@ class WebTermApp {
       }
     });
 
+    // Blank the terminal while the session is locked
+    this.websocketClient.on("locked", () => {
+      this.elements.terminalLock.classList.remove("hidden");
+      this.elements.unlockPassword.focus();
+    });
+
+    this.websocketClient.on("unlocked", () => {
+      this.elements.terminalLock.classList.add("hidden");
+      this.elements.unlockPassword.value = "";
+    });
+
+    this.elements.unlockForm.addEventListener("submit", (event) => {
+      event.preventDefault();
+      this.unlockSession();
+    });
+
+    // Show operator announcements and the message of the day
+    this.websocketClient.on("banner", (data) => {
+      this.elements.terminalBannerText.textContent = data.text;
+      this.elements.terminalBanner.className = `terminal-banner ${
+        data.level || "info"
+      }`;
+    });
+
+    // Show who else is attached to the session
+    this.websocketClient.on("presence", (data) => {
+      const names = data.clients.map(
+        (client) => client.user || client.client_id.slice(0, 8)
+      );
+      let text = names.length > 1 ? `👥 ${names.join(", ")}` : "";
+      if (data.viewers > 0) {
+        text += ` 👁 ${data.viewers}`;
+      }
+      this.elements.sessionPresence.textContent = text.trim();
+    });
+
+    this.elements.terminalBannerClose.addEventListener("click", () => {
+      this.elements.terminalBanner.classList.add("hidden");
+    });
+
     // Handle session termination
     this.websocketClient.on("session_terminated", (data) => {
       console.log("Session terminated via WebSocket:", data);
@@ -146,6 +233,118 @@ class WebTermApp {
     });
   }
 
+  async unlockSession() {
+    const sessionId = this.sessionManager?.currentSessionId;
+    if (!sessionId) {
+      return;
+    }
+
+    try {
+      const response = await fetch(`/api/sessions/${sessionId}/unlock`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ password: this.elements.unlockPassword.value }),
+      });
+
+      if (!response.ok) {
+        throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+      }
+    } catch (error) {
+      console.error("Failed to unlock session:", error);
+      this.sessionManager.showNotification(
+        `Failed to unlock session: ${error.message}`,
+        "error"
+      );
+      this.elements.unlockPassword.select();
+    }
+  }
+
+  async toggleSnippetPalette() {
+    if (!this.elements.snippetPalette.classList.contains("hidden")) {
+      this.closeSnippetPalette();
+      return;
+    }
+
+    try {
+      const response = await fetch("/api/snippets");
+      if (!response.ok) {
+        throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+      }
+      this.snippets = (await response.json()).snippets;
+    } catch (error) {
+      console.error("Failed to load snippets:", error);
+      this.sessionManager.showNotification(
+        `Failed to load snippets: ${error.message}`,
+        "error"
+      );
+      return;
+    }
+
+    this.elements.snippetFilter.value = "";
+    this.renderSnippets();
+    this.elements.snippetPalette.classList.remove("hidden");
+    this.elements.snippetFilter.focus();
+  }
+
+  closeSnippetPalette() {
+    this.elements.snippetPalette.classList.add("hidden");
+    if (this.terminalManager) {
+      this.terminalManager.focus();
+    }
+  }
+
+  filteredSnippets() {
+    const filter = this.elements.snippetFilter.value.toLowerCase();
+    return this.snippets.filter(
+      (snippet) =>
+        snippet.name.toLowerCase().includes(filter) ||
+        (snippet.description || "").toLowerCase().includes(filter)
+    );
+  }
+
+  renderSnippets() {
+    const list = this.elements.snippetList;
+    list.innerHTML = "";
+
+    const snippets = this.filteredSnippets();
+    if (snippets.length === 0) {
+      const empty = document.createElement("li");
+      empty.textContent = this.snippets.length
+        ? "No matching snippets"
+        : "No snippets yet; add them with POST /api/snippets";
+      list.appendChild(empty);
+      return;
+    }
+
+    snippets.forEach((snippet, index) => {
+      const item = document.createElement("li");
+      item.textContent = snippet.name;
+      item.title = snippet.content;
+      if (index === 0) {
+        item.classList.add("selected");
+      }
+      if (snippet.description) {
+        const description = document.createElement("span");
+        description.className = "snippet-description";
+        description.textContent = snippet.description;
+        item.appendChild(description);
+      }
+      item.addEventListener("click", () => this.runSnippet(snippet.id));
+      list.appendChild(item);
+    });
+  }
+
+  runSnippet(id) {
+    if (!this.websocketClient.runSnippet(id)) {
+      this.sessionManager.showNotification(
+        "Connect to a session to run snippets",
+        "error"
+      );
+      return;
+    }
+    this.closeSnippetPalette();
+  }
+
   setupInitialState() {
     // Set initial connection status
     this.setConnectionStatus("disconnected");
@@ -161,6 +360,9 @@ class WebTermApp {
   async handleSessionSwitch(event) {
     const { sessionId, session } = event.detail;
 
+    // The new session reports its own lock state once connected
+    this.elements.terminalLock.classList.add("hidden");
+
     try {
       console.log("Switching to session:", sessionId);
 
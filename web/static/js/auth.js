@@ -0,0 +1,52 @@
+// Bearer token support. Opening a page with ?access_token=... keeps the token
+// for this tab, removes it from the address bar, and sends it with every API
+// request and terminal connection.
+const WebTermAuth = {
+  storageKey: "webterm_access_token",
+
+  init() {
+    const params = new URLSearchParams(window.location.search);
+    const token = params.get("access_token");
+    if (token) {
+      sessionStorage.setItem(this.storageKey, token);
+      params.delete("access_token");
+      const query = params.toString();
+      window.history.replaceState(
+        null,
+        "",
+        window.location.pathname + (query ? `?${query}` : "") + window.location.hash
+      );
+    }
+
+    // Add the token to requests to this server that do not carry credentials
+    const originalFetch = window.fetch.bind(window);
+    window.fetch = (input, init = {}) => {
+      const stored = this.token();
+      if (stored && typeof input === "string" && input.startsWith("/")) {
+        const headers = new Headers(init.headers || {});
+        if (!headers.has("Authorization")) {
+          headers.set("Authorization", `Bearer ${stored}`);
+        }
+        init = { ...init, headers };
+      }
+      return originalFetch(input, init);
+    };
+  },
+
+  token() {
+    return sessionStorage.getItem(this.storageKey);
+  },
+
+  // Browsers cannot set headers on WebSocket and WebTransport connections,
+  // so the token goes in the URL
+  withToken(url) {
+    const stored = this.token();
+    if (!stored) {
+      return url;
+    }
+    const separator = url.includes("?") ? "&" : "?";
+    return `${url}${separator}access_token=${encodeURIComponent(stored)}`;
+  },
+};
+
+WebTermAuth.init();
@@ -19,7 +19,7 @@ class SessionManager {
   initialize() {
     this.bindElements();
     this.setupEventHandlers();
-    this.loadSessions();
+    this.loadSessions().then(() => this.openHomeSession());
 
     console.log("Session manager initialized");
   }
@@ -40,6 +40,7 @@ class SessionManager {
       // Terminal controls
       terminalClear: document.getElementById("terminal-clear"),
       terminalDisconnect: document.getElementById("terminal-disconnect"),
+      terminalPause: document.getElementById("terminal-pause"),
       terminalTerminate: document.getElementById("terminal-terminate"),
 
       // Modal
@@ -91,6 +92,9 @@ class SessionManager {
     this.elements.terminalDisconnect?.addEventListener("click", () =>
       this.disconnectSession()
     );
+    this.elements.terminalPause?.addEventListener("click", () =>
+      this.togglePauseCurrentSession()
+    );
     this.elements.terminalTerminate?.addEventListener("click", () =>
       this.terminateCurrentSession()
     );
@@ -149,6 +153,48 @@ class SessionManager {
     }
   }
 
+  // Reattach to the user's home session, which the server creates on the
+  // first visit when a home profile is configured
+  async openHomeSession() {
+    try {
+      const response = await fetch(`${this.apiBaseUrl}/sessions/home`, {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify({ terminal: { color: "truecolor" } }),
+      });
+
+      // Home sessions are not enabled on this server
+      if (response.status === 404) {
+        return null;
+      }
+      if (!response.ok) {
+        throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+      }
+
+      const data = await response.json();
+      const session = data.session;
+
+      this.sessions.set(session.id, session);
+      this.updateSessionsList(Array.from(this.sessions.values()));
+      this.updateSessionTabs();
+
+      if (!this.currentSessionId && session.status !== "pending") {
+        await this.switchToSession(session.id);
+      }
+
+      return session;
+    } catch (error) {
+      console.error("Failed to open home session:", error);
+      this.showNotification(
+        `Failed to open home session: ${error.message}`,
+        "error"
+      );
+      return null;
+    }
+  }
+
   async createSession(config = {}) {
     try {
       this.showLoading("Creating session...");
@@ -162,6 +208,8 @@ class SessionManager {
           shell: config.shell || "",
           working_dir: config.workingDir || "",
           env: config.env || {},
+          // xterm.js renders 24-bit color
+          terminal: { color: "truecolor" },
         }),
       });
 
@@ -253,6 +301,33 @@ class SessionManager {
     }
   }
 
+  async togglePauseCurrentSession() {
+    const session = this.sessions.get(this.currentSessionId);
+    if (!session) return;
+
+    const action = session.status === "paused" ? "resume" : "pause";
+
+    try {
+      const response = await fetch(
+        `${this.apiBaseUrl}/sessions/${session.id}/${action}`,
+        { method: "POST" }
+      );
+
+      if (!response.ok) {
+        throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+      }
+
+      const data = await response.json();
+      this.updateSessionStatus(session.id, data.session.status);
+    } catch (error) {
+      console.error(`Failed to ${action} session:`, error);
+      this.showNotification(
+        `Failed to ${action} session: ${error.message}`,
+        "error"
+      );
+    }
+  }
+
   async switchToSession(sessionId) {
     if (this.currentSessionId === sessionId) {
       return;
@@ -268,6 +343,9 @@ class SessionManager {
       if (session.status === "stopped" || session.status === "error") {
         throw new Error(`Cannot switch to session in ${session.status} state`);
       }
+      if (session.status === "pending") {
+        throw new Error("Session is awaiting admin approval");
+      }
 
       this.currentSessionId = sessionId;
 
@@ -294,7 +372,10 @@ class SessionManager {
 
       // If switching failed, try to switch to another available session
       const availableSessions = Array.from(this.sessions.values()).filter(
-        (s) => s.status === "running" || s.status === "starting"
+        (s) =>
+          s.status === "running" ||
+          s.status === "starting" ||
+          s.status === "paused"
       );
 
       if (availableSessions.length > 0) {
@@ -329,13 +410,15 @@ class SessionManager {
         // Filter out stopped/error sessions for the dropdown
         const activeSessions = sessions.filter(
           (session) =>
-            session.status === "running" || session.status === "starting"
+            session.status === "running" ||
+            session.status === "starting" ||
+            session.status === "paused"
         );
 
         activeSessions.forEach((session) => {
           const option = document.createElement("option");
           option.value = session.id;
-          option.textContent = `${session.id.substring(0, 8)}... (${
+          option.textContent = `${this.sessionLabel(session)} (${
             session.status
           })`;
           this.elements.sessionSelect.appendChild(option);
@@ -346,7 +429,8 @@ class SessionManager {
           const currentSession = this.sessions.get(this.currentSessionId);
           if (
             currentSession.status === "running" ||
-            currentSession.status === "starting"
+            currentSession.status === "starting" ||
+            currentSession.status === "paused"
           ) {
             this.elements.sessionSelect.value = this.currentSessionId;
           }
@@ -358,16 +442,28 @@ class SessionManager {
     this.updateSessionTabs();
   }
 
+  // Sessions are shown by name, or by the start of their ID
+  sessionLabel(session) {
+    return session.name || session.id.substring(0, 8) + "...";
+  }
+
   updateCurrentSessionInfo(session) {
     if (this.elements.currentSessionId) {
-      this.elements.currentSessionId.textContent =
-        session.id.substring(0, 8) + "...";
+      this.elements.currentSessionId.textContent = this.sessionLabel(session);
     }
 
     if (this.elements.currentSessionStatus) {
       this.elements.currentSessionStatus.textContent = "●";
       this.elements.currentSessionStatus.className = `session-status ${session.status}`;
     }
+
+    if (this.elements.terminalPause) {
+      const paused = session.status === "paused";
+      this.elements.terminalPause.textContent = paused ? "▶️" : "⏸️";
+      this.elements.terminalPause.title = paused
+        ? "Resume session"
+        : "Pause session";
+    }
   }
 
   updateSessionStatus(sessionId, status) {
@@ -389,6 +485,7 @@ class SessionManager {
     const controls = [
       this.elements.terminalClear,
       this.elements.terminalDisconnect,
+      this.elements.terminalPause,
       this.elements.terminalTerminate,
     ];
 
@@ -413,9 +510,10 @@ class SessionManager {
     tab.classList.add(statusClass);
 
     tab.innerHTML = `
-            <span class="tab-label">${session.id.substring(0, 8)}...</span>
+            <span class="tab-label"></span>
             <button class="tab-close" data-session-id="${session.id}">×</button>
         `;
+    tab.querySelector(".tab-label").textContent = this.sessionLabel(session);
 
     // Tab click handler
     tab.addEventListener("click", (e) => {
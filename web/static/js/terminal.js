@@ -100,6 +100,12 @@ class TerminalManager {
         this.writeOutput(data);
       });
 
+      this.websocketClient.on("reset", () => {
+        if (this.terminal) {
+          this.terminal.reset();
+        }
+      });
+
       this.websocketClient.on("disconnected", () => {
         this.showConnectionStatus("Disconnected", "error");
       });
@@ -0,0 +1,101 @@
+// Output of sessions without a PTY arrives with bare newlines and stderr as
+// a channel of its own; give it carriage returns and show stderr in red
+function formatStreamOutput(message) {
+  if (!message.channel) {
+    return message.data;
+  }
+  const data = message.data.replace(/\r?\n/g, "\r\n");
+  return message.channel === "stderr" ? `\x1b[31m${data}\x1b[0m` : data;
+}
+
+// Read-only viewer for broadcast sessions
+class BroadcastViewer {
+  constructor(token) {
+    this.token = token;
+    this.ws = null;
+    this.terminal = null;
+    this.fitAddon = null;
+    this.ended = false;
+  }
+
+  start() {
+    this.terminal = new Terminal({
+      cursorBlink: false,
+      disableStdin: true,
+      fontFamily: 'Monaco, Menlo, "Ubuntu Mono", monospace',
+      fontSize: 14,
+      theme: {
+        background: "#000000",
+        foreground: "#ffffff",
+      },
+    });
+
+    this.fitAddon = new FitAddon.FitAddon();
+    this.terminal.loadAddon(this.fitAddon);
+    this.terminal.open(document.getElementById("terminal"));
+    this.fitAddon.fit();
+
+    window.addEventListener("resize", () => this.fitAddon.fit());
+
+    this.connect();
+  }
+
+  connect() {
+    const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
+    const url = `${protocol}//${window.location.host}/api/ws?broadcast=${encodeURIComponent(this.token)}`;
+
+    this.ws = new WebSocket(url);
+
+    this.ws.onopen = () => this.setStatus("Watching", "connected");
+
+    this.ws.onmessage = (event) => {
+      const message = JSON.parse(event.data);
+      switch (message.type) {
+        case "output":
+          if (!message.pane) {
+            this.terminal.write(formatStreamOutput(message));
+          }
+          break;
+        case "status":
+          if (!message.pane && (message.status === "stopped" || message.status === "error")) {
+            this.end("Session ended");
+          }
+          break;
+        case "error":
+          this.end(message.error);
+          break;
+        case "closing":
+          this.end(message.reason);
+          break;
+        case "banner":
+          this.setStatus(message.data, "connected");
+          break;
+      }
+    };
+
+    this.ws.onclose = () => {
+      if (!this.ended) {
+        this.end("Broadcast ended");
+      }
+    };
+  }
+
+  end(reason) {
+    this.ended = true;
+    this.setStatus(reason, "disconnected");
+    if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+      this.ws.close();
+    }
+  }
+
+  setStatus(text, state) {
+    document.getElementById("connection-status").textContent = text;
+    document.getElementById("connection-indicator").className =
+      `status-indicator ${state}`;
+  }
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+  const token = window.location.pathname.split("/").pop();
+  new BroadcastViewer(token).start();
+});
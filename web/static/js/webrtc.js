@@ -0,0 +1,74 @@
+// WebRTC data channel transport, used in place of a WebSocket when the server supports it
+class WebRTCTransport {
+  // Resolves to the server's WebRTC config, or null when WebRTC is unavailable
+  static async getConfig() {
+    if (!window.RTCPeerConnection) {
+      return null;
+    }
+
+    if (WebRTCTransport.config === undefined) {
+      try {
+        const response = await fetch("/api/webrtc");
+        WebRTCTransport.config = response.ok ? await response.json() : null;
+      } catch (error) {
+        WebRTCTransport.config = null;
+      }
+    }
+
+    return WebRTCTransport.config;
+  }
+
+  // Negotiates a data channel for the session. The returned channel exposes the
+  // same onopen/onmessage/onclose/onerror/send/close interface as a WebSocket.
+  static async connect(sessionId, config) {
+    const iceServers = config.ice_servers.length
+      ? [{ urls: config.ice_servers }]
+      : [];
+    const pc = new RTCPeerConnection({ iceServers });
+    const channel = pc.createDataChannel(config.channel_label, {
+      ordered: true,
+    });
+
+    channel.addEventListener("close", () => pc.close());
+
+    try {
+      await pc.setLocalDescription(await pc.createOffer());
+      await WebRTCTransport.waitForGathering(pc);
+
+      const response = await fetch(`/api/sessions/${sessionId}/webrtc`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(pc.localDescription),
+      });
+      if (!response.ok) {
+        throw new Error(`Signaling failed: ${response.status}`);
+      }
+
+      await pc.setRemoteDescription(await response.json());
+    } catch (error) {
+      pc.close();
+      throw error;
+    }
+
+    return channel;
+  }
+
+  static waitForGathering(pc) {
+    if (pc.iceGatheringState === "complete") {
+      return Promise.resolve();
+    }
+
+    return new Promise((resolve) => {
+      const timeout = setTimeout(resolve, 5000);
+      pc.addEventListener("icegatheringstatechange", () => {
+        if (pc.iceGatheringState === "complete") {
+          clearTimeout(timeout);
+          resolve();
+        }
+      });
+    });
+  }
+}
+
+// Export for use in other modules
+window.WebRTCTransport = WebRTCTransport;
@@ -1,3 +1,13 @@
+// Output of sessions without a PTY arrives with bare newlines and stderr as
+// a channel of its own; give it carriage returns and show stderr in red
+function formatStreamOutput(message) {
+  if (!message.channel) {
+    return message.data;
+  }
+  const data = message.data.replace(/\r?\n/g, "\r\n");
+  return message.channel === "stderr" ? `\x1b[31m${data}\x1b[0m` : data;
+}
+
 // WebSocket client for real-time terminal communication
 class WebSocketClient {
   constructor() {
@@ -11,6 +21,13 @@ class WebSocketClient {
     this.messageHandlers = new Map();
     this.connectionCallbacks = new Set();
     this.terminated = false; // Flag to prevent reconnection for terminated sessions
+    this.scrollbackReceived = false; // Set once replayed output arrives on a connection
+    this.serverClock = null; // Server monotonic time (ms) at a local performance.now()
+    this.latency = null; // One-way latency estimate (ms) from the last ping
+    this.pingSentAt = null;
+    this.resume = null; // Token and output offsets to resume the session with
+    this.webrtcFailed = false; // Skip WebRTC after a failed negotiation
+    this.webTransportFailed = false; // Skip WebTransport after a failed connection
 
     // Heartbeat
     this.pingInterval = null;
@@ -58,19 +75,69 @@ class WebSocketClient {
 
     this.connecting = true;
     this.sessionId = sessionId;
+    if (this.resume && this.resume.sessionId !== sessionId) {
+      this.resume = null;
+    }
 
-    return new Promise((resolve, reject) => {
-      const wsUrl = `ws://${window.location.host}/api/ws?session=${sessionId}`;
-      this.ws = new WebSocket(wsUrl);
+    return this.openTransport(sessionId).then(
+      (transport) =>
+        new Promise((resolve, reject) => {
+          this.ws = transport;
+          this.setupEventHandlers(resolve, reject);
+        })
+    );
+  }
 
-      this.setupEventHandlers(resolve, reject);
-    });
+  // Prefers WebTransport, then a WebRTC data channel when the server offers
+  // one, falling back to a WebSocket
+  async openTransport(sessionId) {
+    if (WebTransportSocket.isSupported() && !this.webTransportFailed) {
+      try {
+        const socket = await WebTransportSocket.connect(sessionId, this.resumeQuery());
+        console.log("Using WebTransport for session:", sessionId);
+        return socket;
+      } catch (error) {
+        console.warn("WebTransport unavailable:", error);
+        this.webTransportFailed = true;
+      }
+    }
+
+    const rtcConfig = await WebRTCTransport.getConfig();
+    if (rtcConfig && !this.webrtcFailed) {
+      try {
+        const channel = await WebRTCTransport.connect(sessionId, rtcConfig);
+        console.log("Using WebRTC data channel for session:", sessionId);
+        return channel;
+      } catch (error) {
+        console.warn("WebRTC unavailable, falling back to WebSocket:", error);
+        this.webrtcFailed = true;
+      }
+    }
+
+    const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
+    const wsUrl = WebTermAuth.withToken(
+      `${protocol}//${window.location.host}/api/ws?session=${sessionId}${this.resumeQuery()}`
+    );
+    return new WebSocket(wsUrl);
+  }
+
+  // Query parameters for receiving only the output missed while reconnecting
+  resumeQuery() {
+    if (!this.resume || !this.resume.token) {
+      return "";
+    }
+    const { token, offset, stderrOffset } = this.resume;
+    return `&resume=${encodeURIComponent(token)}&offset=${offset}&stderr_offset=${stderrOffset}`;
   }
 
   setupEventHandlers(resolve, reject) {
     const connectionTimeout = setTimeout(() => {
       if (this.connecting) {
         this.connecting = false;
+        // A data channel that never opens usually means ICE failed
+        if (window.RTCDataChannel && this.ws instanceof RTCDataChannel) {
+          this.webrtcFailed = true;
+        }
         this.ws.close();
         reject(new Error("Connection timeout"));
       }
@@ -81,6 +148,7 @@ class WebSocketClient {
       this.connected = true;
       this.connecting = false;
       this.reconnectAttempts = 0;
+      this.scrollbackReceived = false;
 
       console.log("WebSocket connected to session:", this.sessionId);
       this.startHeartbeat();
@@ -130,7 +198,14 @@ class WebSocketClient {
 
   scheduleReconnect() {
     this.reconnectAttempts++;
-    const delay = this.reconnectDelay * Math.pow(2, this.reconnectAttempts - 1);
+    let delay = this.reconnectDelay * Math.pow(2, this.reconnectAttempts - 1);
+
+    // A server shutting down says when it expects to be back. Clients
+    // spread their reconnects over a second so they do not arrive at once.
+    if (this.retryAfter) {
+      delay = Math.max(delay, this.retryAfter) + Math.random() * 1000;
+      this.retryAfter = 0;
+    }
 
     console.log(
       `Scheduling reconnect attempt ${this.reconnectAttempts} in ${delay}ms`
@@ -151,12 +226,32 @@ class WebSocketClient {
 
     switch (message.type) {
       case "output":
-        this.emit("output", message.data);
+        // Panes are for API clients; this page shows the session's own shell
+        if (!message.pane) {
+          // Earlier output is replayed on every connect, so drop what a
+          // reconnecting terminal still shows
+          if (message.scrollback && !this.scrollbackReceived) {
+            this.scrollbackReceived = true;
+            this.emit("reset");
+          }
+          if (message.offset && this.resume) {
+            if (message.channel === "stderr") {
+              this.resume.stderrOffset = message.offset;
+            } else {
+              this.resume.offset = message.offset;
+            }
+          }
+          this.emit("output", formatStreamOutput(message));
+        }
         break;
       case "status":
+        if (message.pane) {
+          break;
+        }
         this.emit("status", {
           sessionId: message.session_id,
           status: message.status,
+          exitCode: message.exit_code,
         });
 
         // Handle session termination
@@ -166,18 +261,71 @@ class WebSocketClient {
           this.emit("session_terminated", {
             sessionId: message.session_id,
             status: message.status,
+            exitCode: message.exit_code,
           });
         }
         break;
       case "error":
         this.emit("error", message.error);
         break;
+      case "closing":
+        // Only a server shutting down (1001 Going Away) is worth
+        // reconnecting to; the other codes mean the session is gone to us
+        if (message.close_code !== 1001) {
+          this.terminated = true;
+        } else if (message.retry_after) {
+          this.retryAfter = message.retry_after * 1000;
+        }
+        this.emit("closing", {
+          sessionId: message.session_id,
+          code: message.close_code,
+          reason: message.reason,
+          retryAfter: message.retry_after,
+          reconnectUrl: message.reconnect_url,
+        });
+        break;
       case "pong":
-        this.handlePong();
+        this.handlePong(message);
         break;
       case "connected":
+        if (message.clock) {
+          // A first estimate, refined by each ping
+          this.serverClock = {
+            monotonicMs: message.clock.monotonic_ns / 1e6,
+            localMs: performance.now(),
+          };
+        }
         this.emit("session_connected", { sessionId: message.session_id });
         break;
+      case "locked":
+        this.emit("locked", { sessionId: message.session_id });
+        break;
+      case "unlocked":
+        this.emit("unlocked", { sessionId: message.session_id });
+        break;
+      case "resume":
+        this.resume = {
+          offset: 0,
+          stderrOffset: 0,
+          ...this.resume,
+          sessionId: message.session_id,
+          token: message.resume_token,
+        };
+        break;
+      case "banner":
+        this.emit("banner", {
+          sessionId: message.session_id,
+          text: message.data,
+          level: message.level,
+        });
+        break;
+      case "presence":
+        this.emit("presence", {
+          sessionId: message.session_id,
+          clients: message.presence.clients,
+          viewers: message.presence.viewers,
+        });
+        break;
       default:
         console.log("Unknown message type:", message.type);
     }
@@ -214,9 +362,14 @@ class WebSocketClient {
   }
 
   sendPing() {
+    this.pingSentAt = performance.now();
     return this.send("ping");
   }
 
+  runSnippet(id) {
+    return this.send("run_snippet", { data: id });
+  }
+
   // Heartbeat management
   startHeartbeat() {
     this.stopHeartbeat();
@@ -246,11 +399,35 @@ class WebSocketClient {
     }
   }
 
-  handlePong() {
+  handlePong(message) {
     if (this.pongTimeout) {
       clearTimeout(this.pongTimeout);
       this.pongTimeout = null;
     }
+
+    // The server read its clock about halfway through the round trip
+    if (this.pingSentAt !== null && message.monotonic_ns) {
+      const roundTrip = performance.now() - this.pingSentAt;
+      this.latency = roundTrip / 2;
+      this.serverClock = {
+        monotonicMs: message.monotonic_ns / 1e6,
+        localMs: this.pingSentAt + this.latency,
+      };
+      this.pingSentAt = null;
+    }
+  }
+
+  // Converts a message's monotonic_ns to local performance.now() time, for
+  // recording output with the timing it was produced with
+  serverToLocalTime(monotonicNs) {
+    if (!this.serverClock) {
+      return null;
+    }
+    return this.serverClock.localMs + (monotonicNs / 1e6 - this.serverClock.monotonicMs);
+  }
+
+  getLatency() {
+    return this.latency;
   }
 
   // Connection status
@@ -278,6 +455,7 @@ class WebSocketClient {
     this.connected = false;
     this.connecting = false;
     this.sessionId = null;
+    this.resume = null;
     this.reconnectAttempts = 0;
     this.terminated = false; // Reset terminated flag on disconnect
   }
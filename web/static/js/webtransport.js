@@ -0,0 +1,106 @@
+// WebTransport connection exposing the same interface as a WebSocket.
+// Messages travel as newline-delimited JSON on one bidirectional stream, except
+// resize and ping messages, which are sent as datagrams.
+class WebTransportSocket {
+  static isSupported() {
+    return !!window.WebTransport && window.location.protocol === "https:";
+  }
+
+  static async connect(sessionId, resumeQuery = "") {
+    const url = WebTermAuth.withToken(
+      `https://${window.location.host}/api/webtransport?session=${encodeURIComponent(sessionId)}${resumeQuery}`
+    );
+    const transport = new WebTransport(url);
+
+    const timeout = new Promise((_, reject) =>
+      setTimeout(() => reject(new Error("WebTransport timeout")), 5000)
+    );
+
+    try {
+      await Promise.race([transport.ready, timeout]);
+      const stream = await transport.createBidirectionalStream();
+      return new WebTransportSocket(transport, stream);
+    } catch (error) {
+      transport.close();
+      throw error;
+    }
+  }
+
+  constructor(transport, stream) {
+    this.transport = transport;
+    this.writer = stream.writable.getWriter();
+    this.reader = stream.readable.getReader();
+    this.datagrams = transport.datagrams.writable.getWriter();
+    this.encoder = new TextEncoder();
+    this.decoder = new TextDecoder();
+
+    this.onopen = null;
+    this.onmessage = null;
+    this.onclose = null;
+    this.onerror = null;
+
+    this.transport.closed
+      .then(() => this.handleClose(1000))
+      .catch(() => this.handleClose(1006));
+
+    // Let the caller attach handlers before the connection reports open
+    setTimeout(() => {
+      if (this.onopen) {
+        this.onopen();
+      }
+      this.readLoop();
+    }, 0);
+  }
+
+  async readLoop() {
+    let buffer = "";
+    try {
+      while (true) {
+        const { value, done } = await this.reader.read();
+        if (done) {
+          break;
+        }
+
+        buffer += this.decoder.decode(value, { stream: true });
+        let newline;
+        while ((newline = buffer.indexOf("\n")) >= 0) {
+          const line = buffer.slice(0, newline);
+          buffer = buffer.slice(newline + 1);
+          if (line && this.onmessage) {
+            this.onmessage({ data: line });
+          }
+        }
+      }
+    } catch (error) {
+      if (this.onerror) {
+        this.onerror(error);
+      }
+    }
+  }
+
+  send(data) {
+    const type = JSON.parse(data).type;
+    if (type === "resize" || type === "ping") {
+      this.datagrams.write(this.encoder.encode(data));
+    } else {
+      this.writer.write(this.encoder.encode(data + "\n"));
+    }
+  }
+
+  handleClose(code) {
+    if (this.closed) {
+      return;
+    }
+    this.closed = true;
+    if (this.onclose) {
+      this.onclose({ code, reason: "" });
+    }
+  }
+
+  close() {
+    this.transport.close();
+  }
+}
+
+// Export for use in other modules
+window.WebTransportSocket = WebTransportSocket;